@@ -1,11 +1,18 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 
 	"snift-api/models"
@@ -14,17 +21,56 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+var calculateOverallScore = services.CalculateOverallScore
+
+var runPreflight = services.Preflight
+
+var isVerifiedURL = utils.IsVerifiedURL
+
+var isDomainVerified = utils.IsDomainVerified
+
+var scanLimiter = utils.NewScanLimiter(utils.DefaultMaxConcurrentScans, utils.ScanQueueTimeout)
+
 // HandleRequests - Handler for all API Requests
 func HandleRequests() {
 	port := os.Getenv("PORT")
 	log.Print("Server starting at PORT ", port)
+	scanLimiter = utils.NewScanLimiter(utils.GetMaxConcurrentScans(), utils.ScanQueueTimeout)
+	models.DialContext = utils.DialContext
+	// Every stage of the connections to the scanned sites is bounded on its own
+	timeouts := utils.GetTimeouts()
+	utils.SetTimeouts(timeouts)
+	models.HandshakeTimeout = timeouts.TLSHandshake
+	// The roots of an internal CA are trusted along with the system ones
+	models.RootCAs = utils.GetTrustedRoots()
+	utils.SetTrustedRoots(models.RootCAs)
+	// A client certificate is only presented to the designated hosts, which require mutual TLS
+	utils.SetClientCertificate(utils.GetClientCertificate(), utils.GetClientCertHosts())
+	models.ClientCertificates = utils.ClientCertificates
+	models.MaxValidityDays = utils.GetMaxCertValidityDays()
+	services.HSTSMinMaxAge = utils.GetHSTSMinMaxAge()
+	services.ResultStore = utils.GetResultStore()
 	myRouter := mux.NewRouter().StrictSlash(true)
 	myRouter.HandleFunc("/", HomePage).Methods("GET")
+	myRouter.HandleFunc("/healthz", HealthCheck).Methods("GET")
+	myRouter.HandleFunc("/version", GetVersion).Methods("GET")
+	myRouter.HandleFunc("/checks", GetChecks).Methods("GET")
 	myRouter.HandleFunc("/scores", GetScore).Methods("POST", "OPTIONS")
+	myRouter.HandleFunc("/scores/compare", CompareScores).Methods("POST", "OPTIONS")
+	myRouter.HandleFunc("/scores/upload", UploadScores).Methods("POST")
+	myRouter.HandleFunc("/scores/preflight", PreflightScore).Methods("GET")
+	myRouter.HandleFunc("/scores/stream", StreamScores).Methods("GET")
+	myRouter.HandleFunc("/scores/{domain}/history", GetScoreHistory).Methods("GET")
+	myRouter.HandleFunc("/scores/{domain}/report.pdf", GetScoreReport).Methods("GET")
+	myRouter.HandleFunc("/rescore/{id}", RescoreResult).Methods("POST")
+	myRouter.HandleFunc("/jobs/{id}", GetJob).Methods("GET")
+	myRouter.HandleFunc("/verify", VerifyDomain).Methods("POST")
 	myRouter.HandleFunc("/token", GetAuthToken).Methods("GET")
-	log.Fatal(http.ListenAndServe(port, myRouter))
+	myRouter.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	log.Fatal(http.ListenAndServe(port, utils.Compress(myRouter)))
 }
 
 // HomePage - the default root endpoint of Snift Backend
@@ -33,24 +79,152 @@ func HomePage(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Welcome to Snift!")
 }
 
+// handlePreflight responds to the CORS Preflight Request and returns true if the request was one
+func handlePreflight(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "OPTIONS" {
+		return false
+	}
+	w.Header().Set("Access-Control-Allow-Methods", "POST")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.Header().Set("Access-Control-Allow-Headers", "x-auth-token,content-type,x-scoring-profile,accept-version,x-min-score,idempotency-key,X-Auth-Token,Content-Type,X-Scoring-Profile,Accept-Version,X-Min-Score,Idempotency-Key")
+	return true
+}
+
+// scoresErrorResponse maps an error from the scoring service to its error type, status code and message
+func scoresErrorResponse(scoresError error) (errorType string, status int, message string) {
+	if errors.Is(scoresError, models.ErrInvalidDomain) {
+		return utils.InvalidDomainError, http.StatusBadRequest, "Invalid Domain"
+	}
+	if errors.Is(scoresError, models.ErrUnreachable) {
+		return utils.UnreachableError, http.StatusBadGateway, "Site is unreachable"
+	}
+	if errors.Is(scoresError, models.ErrTimeout) {
+		return utils.TimeoutError, http.StatusGatewayTimeout, "Site did not answer in time"
+	}
+	if errors.Is(scoresError, utils.ErrRedirectLoop) {
+		return utils.RedirectError, http.StatusBadRequest, "Too many redirects"
+	}
+	if errors.Is(scoresError, utils.ErrPrivateTarget) {
+		return utils.PrivateTargetError, http.StatusBadRequest, "Internal targets cannot be scanned"
+	}
+	if errors.Is(scoresError, services.ErrInvalidTargetOverride) {
+		return utils.InvalidRequestError, http.StatusBadRequest, "Invalid IP or SNI override"
+	}
+	return utils.InternalError, http.StatusInternalServerError, "Unexpected Error Occured"
+}
+
+// invalidURLMessage returns the message of the response rejecting a URL that failed validation
+func invalidURLMessage(err error) string {
+	if errors.Is(err, utils.ErrURLTooLong) {
+		return "URL is too long"
+	}
+	if errors.Is(err, utils.ErrURLControlCharacter) {
+		return "URL contains control characters"
+	}
+	return "Invalid URL"
+}
+
+// scanOptionsErrorMessage returns the message of the response rejecting invalid scan options
+func scanOptionsErrorMessage(err error) string {
+	if errors.Is(err, services.ErrUnknownScoringProfile) {
+		return "Unknown scoring profile"
+	}
+	if errors.Is(err, services.ErrInvalidDKIMSelector) {
+		return "Invalid DKIM selector"
+	}
+	if errors.Is(err, services.ErrInvalidTargetOverride) {
+		return "Invalid IP or SNI override"
+	}
+	if errors.Is(err, services.ErrUnknownCheck) {
+		return "Unknown check to run"
+	}
+	if errors.Is(err, services.ErrForbiddenHeader) {
+		return "Forbidden request header"
+	}
+	if errors.Is(err, services.ErrInvalidCrawlDepth) {
+		return "Invalid crawl depth"
+	}
+	return "Unknown check to skip"
+}
+
+// writeScoresError writes the error response corresponding to an error from the scoring service
+func writeScoresError(w http.ResponseWriter, scoresError error) {
+	errorType, status, message := scoresErrorResponse(scoresError)
+	utils.ScanErrors.WithLabelValues(errorType).Inc()
+	switch status {
+	case http.StatusBadRequest:
+		utils.BadRequest(w, true, message)
+	case http.StatusBadGateway:
+		utils.BadGateway(w, true, message)
+	case http.StatusGatewayTimeout:
+		utils.GatewayTimeout(w, true, message)
+	default:
+		utils.InternalServerError(w, true, message)
+	}
+}
+
+// HealthCheck - GET /healthz handler
+func HealthCheck(w http.ResponseWriter, r *http.Request) {
+	responseBody, jsonError := json.Marshal(&models.Health{
+		Status:             "ok",
+		InFlightScans:      scanLimiter.InFlight(),
+		MaxConcurrentScans: scanLimiter.Limit(),
+	})
+	if jsonError != nil {
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	utils.Writer(w.Write(responseBody))
+}
+
+// GetVersion - GET /version handler
+func GetVersion(w http.ResponseWriter, r *http.Request) {
+	responseBody, jsonError := json.Marshal(&models.BuildInfo{
+		Version:   utils.Version,
+		GitCommit: utils.GitCommit,
+		BuildTime: utils.BuildTime,
+	})
+	if jsonError != nil {
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	utils.Writer(w.Write(responseBody))
+}
+
+// GetChecks - GET /checks handler
+func GetChecks(w http.ResponseWriter, r *http.Request) {
+	responseBody, jsonError := json.Marshal(&models.CheckCatalog{Checks: services.GetCheckCatalog()})
+	if jsonError != nil {
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	utils.Writer(w.Write(responseBody))
+}
+
 // GetScore - POST /scores handler
 func GetScore(w http.ResponseWriter, r *http.Request) {
-	// Handle the Preflight Request
-	if r.Method == "OPTIONS" {
-		w.Header().Set("Access-Control-Allow-Methods", "POST")
-		w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
-		w.Header().Set("Access-Control-Allow-Headers", "x-auth-token,content-type,X-Auth-Token,Content-Type")
+	if handlePreflight(w, r) {
 		return
 	}
+	start := time.Now()
+	utils.ScansTotal.Inc()
+	defer func() { utils.ScanDuration.Observe(time.Since(start).Seconds()) }()
 	if !utils.ValidateToken(r) {
+		utils.ScanErrors.WithLabelValues(utils.UnauthorizedError).Inc()
 		utils.Unauthorized(w, true, "Invalid Token")
 		return
 	}
-	start := time.Now()
 	var scoresRequest models.ScoresRequest
 	err := json.NewDecoder(r.Body).Decode(&scoresRequest)
 	if err != nil {
 		fmt.Println(err)
+		utils.ScanErrors.WithLabelValues(utils.InvalidRequestError).Inc()
 		utils.BadRequest(w, true, "Unexpected Error Occured")
 		return
 	}
@@ -58,23 +232,835 @@ func GetScore(w http.ResponseWriter, r *http.Request) {
 
 	err = utils.IsValidURL(scoresRequest.URL)
 	if err != nil {
-		utils.BadRequest(w, true, "Invalid URL")
+		utils.ScanErrors.WithLabelValues(utils.InvalidURLError).Inc()
+		utils.BadRequest(w, true, invalidURLMessage(err))
+		return
+	}
+	if !utils.IsScannableURL(scoresRequest.URL) {
+		utils.ScanErrors.WithLabelValues(utils.ForbiddenDomainError).Inc()
+		utils.Forbidden(w, true, "Domain cannot be scanned")
+		return
+	}
+	if !isVerifiedURL(scoresRequest.URL) {
+		utils.ScanErrors.WithLabelValues(utils.UnverifiedDomainError).Inc()
+		utils.Forbidden(w, true, "Domain is not verified")
+		return
+	}
+	schemaVersion, ok := requestedSchemaVersion(r)
+	if !ok {
+		utils.ScanErrors.WithLabelValues(utils.InvalidRequestError).Inc()
+		utils.BadRequest(w, true, "Unsupported schema version")
+		return
+	}
+	if scoresRequest.Profile == "" {
+		scoresRequest.Profile = r.Header.Get(utils.ScoringProfileHeader)
+	}
+	scoresRequest.Lang = utils.NegotiateLanguage(scoresRequest.Lang, r.Header.Get("Accept-Language"))
+	err = services.ValidateScanOptions(&scoresRequest.ScanOptions)
+	if err != nil {
+		fmt.Println(err)
+		utils.ScanErrors.WithLabelValues(utils.InvalidRequestError).Inc()
+		utils.BadRequest(w, true, scanOptionsErrorMessage(err))
+		return
+	}
+	minScore, ok := requestedMinScore(r, scoresRequest.MinScore)
+	if !ok {
+		utils.ScanErrors.WithLabelValues(utils.InvalidRequestError).Inc()
+		utils.BadRequest(w, true, "Invalid minimum score")
+		return
+	}
+	if scoresRequest.CallbackURL != "" && services.ValidateCallbackURL(scoresRequest.CallbackURL) != nil {
+		utils.ScanErrors.WithLabelValues(utils.InvalidRequestError).Inc()
+		utils.BadRequest(w, true, "Invalid callback URL")
+		return
+	}
+	scoresRequest.IdempotencyKey, ok = utils.GetIdempotencyKey(r)
+	if !ok {
+		utils.ScanErrors.WithLabelValues(utils.InvalidRequestError).Inc()
+		utils.BadRequest(w, true, "Invalid idempotency key")
+		return
+	}
+	if scoresRequest.IdempotencyKey != "" {
+		scoresRequest.IdempotencyFingerprint = utils.GetRequestFingerprint(scoresRequest.URL, &scoresRequest.ScanOptions, scoresRequest.CallbackURL)
+	}
+	if replayIdempotentRequest(w, r, &scoresRequest, minScore, schemaVersion) {
+		return
+	}
+	if !scanLimiter.Acquire() {
+		utils.ScanErrors.WithLabelValues(utils.TooManyScansError).Inc()
+		utils.ServiceUnavailable(w, true, "Too many scans in progress, please try again later")
+		return
+	}
+	if scoresRequest.CallbackURL != "" {
+		startScanJob(w, &scoresRequest, schemaVersion)
 		return
 	}
-	response, scoresError := services.CalculateOverallScore(scoresRequest.URL)
+	defer scanLimiter.Release()
+	if utils.IsNDJSONRequested(r) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			utils.InternalServerError(w, true, "Streaming is not supported")
+			return
+		}
+		streamScoresNDJSON(w, r, flusher, &scoresRequest, schemaVersion)
+		return
+	}
+	response, scoresError := calculateOverallScore(scoresRequest.URL, &scoresRequest.ScanOptions)
 	if scoresError != nil {
-		if strings.Contains(scoresError.Error(), "no such host") {
-			utils.BadRequest(w, true, "Invalid Domain")
+		writeScoresError(w, scoresError)
+		return
+	}
+	fmt.Printf("Score for %s obtained in %v seconds \n", scoresRequest.URL, time.Since(start).Seconds())
+	writeScoresResponse(w, r, response, minScore, schemaVersion, scoresRequest.Lang)
+}
+
+// writeScoresResponse writes the scores response in the format, schema version and language requested, with 422
+// Unprocessable Entity when the score is below the minimum score
+func writeScoresResponse(w http.ResponseWriter, r *http.Request, response []byte, minScore *float64, schemaVersion int, language string) {
+	status := http.StatusOK
+	if isBelowMinScore(response, minScore) || hasEnforcedComplianceViolations(response) {
+		status = http.StatusUnprocessableEntity
+	}
+	if utils.IsCSVRequested(r) {
+		writeScoresCSV(w, response, status, language)
+		return
+	}
+	response, err := versionScoresResponse(response, schemaVersion, language)
+	if err != nil {
+		fmt.Println(err)
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.Header().Add("Vary", utils.AcceptVersionHeader)
+	w.Header().Add("Vary", "Accept-Language")
+	w.WriteHeader(status)
+	utils.Writer(w.Write(response))
+}
+
+// replayIdempotentRequest answers a request repeating the idempotency key of a request made within IDEMPOTENCY_TTL
+// with the outcome of that request, its job or its scores response, rather than scanning again, and rejects it with
+// 422 Unprocessable Entity when the key was sent with another URL or other options. It returns false when the key is
+// new, or when the checks are streamed
+func replayIdempotentRequest(w http.ResponseWriter, r *http.Request, scoresRequest *models.ScoresRequest, minScore *float64, schemaVersion int) bool {
+	if scoresRequest.IdempotencyKey == "" || utils.IsNDJSONRequested(r) {
+		return false
+	}
+	since := time.Now().Add(-utils.GetIdempotencyTTL())
+	if scoresRequest.CallbackURL != "" {
+		job, ok := services.Jobs.FindByIdempotencyKey(scoresRequest.IdempotencyKey, since)
+		if !ok {
+			return false
+		}
+		if job.IdempotencyFingerprint != scoresRequest.IdempotencyFingerprint {
+			rejectIdempotencyKeyReuse(w)
+			return true
+		}
+		w.Header().Set(utils.IdempotentReplayedHeader, "true")
+		writeJob(w, &job, http.StatusAccepted)
+		return true
+	}
+	result, err := services.ResultStore.FindByIdempotencyKey(r.Context(), scoresRequest.IdempotencyKey, since)
+	if err != nil {
+		if !errors.Is(err, utils.ErrResultNotFound) {
+			fmt.Println(err)
+		}
+		return false
+	}
+	if result.IdempotencyFingerprint != scoresRequest.IdempotencyFingerprint {
+		rejectIdempotencyKeyReuse(w)
+		return true
+	}
+	w.Header().Set(utils.IdempotentReplayedHeader, "true")
+	writeScoresResponse(w, r, []byte(result.Response), minScore, schemaVersion, scoresRequest.Lang)
+	return true
+}
+
+// rejectIdempotencyKeyReuse answers a request repeating an idempotency key with another URL or other options
+func rejectIdempotencyKeyReuse(w http.ResponseWriter) {
+	utils.ScanErrors.WithLabelValues(utils.InvalidRequestError).Inc()
+	utils.UnprocessableEntity(w, true, "Idempotency key was used with a different request")
+}
+
+// startScanJob answers 202 Accepted with a job running the scan in the background, the job releases the scan slot
+// reserved by the request once its result is posted to the callback URL
+func startScanJob(w http.ResponseWriter, scoresRequest *models.ScoresRequest, schemaVersion int) {
+	job, err := services.Jobs.Create(scoresRequest.URL, scoresRequest.CallbackURL, scoresRequest.IdempotencyKey,
+		scoresRequest.IdempotencyFingerprint)
+	if err != nil {
+		scanLimiter.Release()
+		fmt.Println(err)
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	go func() {
+		defer scanLimiter.Release()
+		runScanJob(job.ID, scoresRequest, schemaVersion)
+	}()
+	writeJob(w, &job, http.StatusAccepted)
+}
+
+// writeJob writes the job along with its location
+func writeJob(w http.ResponseWriter, job *models.Job, status int) {
+	responseBody, jsonError := json.Marshal(job)
+	if jsonError != nil {
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.WriteHeader(status)
+	utils.Writer(w.Write(responseBody))
+}
+
+// runScanJob scans the URL of a job and completes the job with the scores response, or with the error of the scan
+func runScanJob(id string, scoresRequest *models.ScoresRequest, schemaVersion int) {
+	response, scoresError := calculateOverallScore(scoresRequest.URL, &scoresRequest.ScanOptions)
+	if scoresError != nil {
+		errorType, _, message := scoresErrorResponse(scoresError)
+		utils.ScanErrors.WithLabelValues(errorType).Inc()
+		services.CompleteJob(id, nil, message)
+		return
+	}
+	response, err := versionScoresResponse(response, schemaVersion, scoresRequest.Lang)
+	if err != nil {
+		fmt.Println(err)
+		services.CompleteJob(id, nil, "Unexpected Error Occured")
+		return
+	}
+	services.CompleteJob(id, response, "")
+}
+
+// requestedMinScore returns the minimum percentage of the request body, or else of X-Min-Score, nil when neither sets
+// one. ok is false when it is not a percentage
+func requestedMinScore(r *http.Request, minScore *float64) (*float64, bool) {
+	if minScore == nil {
+		header := strings.TrimSpace(r.Header.Get(utils.MinScoreHeader))
+		if header == "" {
+			return nil, true
+		}
+		value, err := strconv.ParseFloat(header, 64)
+		if err != nil {
+			return nil, false
+		}
+		minScore = &value
+	}
+	if math.IsNaN(*minScore) || *minScore < 0 || *minScore > 100 {
+		return nil, false
+	}
+	return minScore, true
+}
+
+// isBelowMinScore returns true when the overall score of a scores response is below the minimum percentage
+func isBelowMinScore(response []byte, minScore *float64) bool {
+	if minScore == nil {
+		return false
+	}
+	var scoresResponse models.ScoresResponse
+	if err := json.Unmarshal(response, &scoresResponse); err != nil || scoresResponse.Scores == nil {
+		fmt.Println("Error Occured while reading the score of the response", err)
+		return false
+	}
+	return scoresResponse.Scores.Score < *minScore/100
+}
+
+// hasEnforcedComplianceViolations returns true when REQUIRED_HEADERS_ENFORCED is set and the scores response misses
+// a required header
+func hasEnforcedComplianceViolations(response []byte) bool {
+	if !utils.IsRequiredHeadersEnforced() {
+		return false
+	}
+	var scoresResponse models.ScoresResponse
+	if err := json.Unmarshal(response, &scoresResponse); err != nil || scoresResponse.Scores == nil {
+		fmt.Println("Error Occured while reading the compliance violations of the response", err)
+		return false
+	}
+	return len(scoresResponse.Scores.ComplianceViolations) > 0
+}
+
+// requestedSchemaVersion returns the schema version requested through Accept-Version, the current one when the header
+// is absent, ok is false when the version is not supported
+func requestedSchemaVersion(r *http.Request) (version int, ok bool) {
+	header := strings.TrimSpace(r.Header.Get(utils.AcceptVersionHeader))
+	if header == "" {
+		return models.CurrentSchemaVersion, true
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(header), "v"))
+	if err != nil || !models.IsSupportedSchemaVersion(version) {
+		return 0, false
+	}
+	return version, true
+}
+
+// versionScoresResponse reshapes a scores response to the schema version and translates it into the language, a
+// response cached before it carried its version is served at the requested one as well
+func versionScoresResponse(response []byte, version int, language string) ([]byte, error) {
+	var scoresResponse models.ScoresResponse
+	err := json.Unmarshal(response, &scoresResponse)
+	if err != nil {
+		return nil, err
+	}
+	localizeScoresResponse(&scoresResponse, language)
+	return json.Marshal(models.BuildVersionedScoresResponse(&scoresResponse, version))
+}
+
+// localizeScoresResponse translates the messages of a scores response into the language, a language without a
+// message catalog leaving them in English
+func localizeScoresResponse(scoresResponse *models.ScoresResponse, language string) {
+	if catalog, ok := utils.GetMessageCatalog(language); ok {
+		catalog.LocalizeScoresResponse(scoresResponse)
+	}
+}
+
+func writeScoresCSV(w http.ResponseWriter, response []byte, status int, language string) {
+	var scoresResponse models.ScoresResponse
+	err := json.Unmarshal(response, &scoresResponse)
+	if err != nil {
+		fmt.Println(err)
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	localizeScoresResponse(&scoresResponse, language)
+	w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.WriteHeader(status)
+	csvWriter := csv.NewWriter(w)
+	err = csvWriter.WriteAll(models.BuildScoresCSV(&scoresResponse))
+	if err != nil {
+		log.Println("Error Occured while writing CSV response", err)
+	}
+}
+
+// CompareScores - POST /scores/compare handler
+func CompareScores(w http.ResponseWriter, r *http.Request) {
+	if handlePreflight(w, r) {
+		return
+	}
+	if !utils.ValidateToken(r) {
+		utils.Unauthorized(w, true, "Invalid Token")
+		return
+	}
+	var compareRequest models.CompareRequest
+	err := json.NewDecoder(r.Body).Decode(&compareRequest)
+	if err != nil {
+		fmt.Println(err)
+		utils.BadRequest(w, true, "Unexpected Error Occured")
+		return
+	}
+	log.Print("POST /scores/compare")
+	if !scanLimiter.Acquire() {
+		utils.ServiceUnavailable(w, true, "Too many scans in progress, please try again later")
+		return
+	}
+	defer scanLimiter.Release()
+
+	var scores []*models.Scores
+	for _, scoresURL := range []string{compareRequest.FirstURL, compareRequest.SecondURL} {
+		err = utils.IsValidURL(scoresURL)
+		if err != nil {
+			utils.BadRequest(w, true, invalidURLMessage(err))
 			return
 		}
+		if !utils.IsScannableURL(scoresURL) {
+			utils.Forbidden(w, true, "Domain cannot be scanned")
+			return
+		}
+		if !isVerifiedURL(scoresURL) {
+			utils.Forbidden(w, true, "Domain is not verified")
+			return
+		}
+		response, scoresError := calculateOverallScore(scoresURL, nil)
+		if scoresError != nil {
+			writeScoresError(w, scoresError)
+			return
+		}
+		var scoresResponse models.ScoresResponse
+		err = json.Unmarshal(response, &scoresResponse)
+		if err != nil || scoresResponse.Scores == nil {
+			fmt.Println(err)
+			utils.InternalServerError(w, true, "Unexpected Error Occured")
+			return
+		}
+		scores = append(scores, scoresResponse.Scores)
+	}
+
+	responseBody, jsonError := json.Marshal(models.BuildCompareResponse(scores[0], scores[1]))
+	if jsonError != nil {
 		utils.InternalServerError(w, true, "Unexpected Error Occured")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
 	w.WriteHeader(http.StatusOK)
-	fmt.Printf("Score for %s obtained in %v seconds \n", scoresRequest.URL, time.Since(start).Seconds())
-	utils.Writer(w.Write(response))
+	utils.Writer(w.Write(responseBody))
+}
+
+// UploadScores - POST /scores/upload handler, scans the URLs listed in the text or CSV file of the multipart form
+// and returns the result of every one of them
+func UploadScores(w http.ResponseWriter, r *http.Request) {
+	if !utils.ValidateToken(r) {
+		utils.Unauthorized(w, true, "Invalid Token")
+		return
+	}
+	log.Print("POST /scores/upload")
+	r.Body = http.MaxBytesReader(w, r.Body, utils.MaxUploadSize+utils.UploadFormOverhead)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			utils.RequestEntityTooLarge(w, true, "File is too large")
+			return
+		}
+		fmt.Println(err)
+		utils.BadRequest(w, true, "Missing file")
+		return
+	}
+	defer file.Close()
+	if header.Size > utils.MaxUploadSize {
+		utils.RequestEntityTooLarge(w, true, "File is too large")
+		return
+	}
+	entries, err := utils.ParseURLList(file)
+	if errors.Is(err, utils.ErrLineTooLong) {
+		utils.RequestEntityTooLarge(w, true, "Line is too long")
+		return
+	}
+	if errors.Is(err, utils.ErrTooManyURLs) {
+		utils.RequestEntityTooLarge(w, true, "Too many URLs")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		utils.BadRequest(w, true, "Unreadable file")
+		return
+	}
+	if len(entries) == 0 {
+		utils.BadRequest(w, true, "No URL in the file")
+		return
+	}
+
+	responseBody, jsonError := json.Marshal(&models.BatchResponse{Results: scanBatch(entries)})
+	if jsonError != nil {
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.WriteHeader(http.StatusOK)
+	utils.Writer(w.Write(responseBody))
+}
+
+// scanBatch scans the URLs of a batch, UploadScanConcurrency at a time, and returns their results in the order
+// they were listed. A URL that cannot be scanned gets the error of its result rather than failing the batch
+func scanBatch(entries []utils.URLListEntry) []*models.BatchResult {
+	results := make([]*models.BatchResult, len(entries))
+	pool := utils.NewWorkerPool(context.Background(), utils.UploadScanConcurrency)
+	for i, entry := range entries {
+		pool.Submit(func() {
+			scores, message := scanBatchURL(entry.URL)
+			results[i] = &models.BatchResult{Line: entry.Line, URL: entry.URL, Scores: scores, Error: message}
+		})
+	}
+	pool.Wait()
+	return results
+}
+
+// scanBatchURL returns the scores of a URL of a batch, or the message of the error that prevented its scan
+func scanBatchURL(scoresURL string) (*models.Scores, string) {
+	err := utils.IsValidURL(scoresURL)
+	if err != nil {
+		return nil, invalidURLMessage(err)
+	}
+	if !utils.IsScannableURL(scoresURL) {
+		return nil, "Domain cannot be scanned"
+	}
+	if !isVerifiedURL(scoresURL) {
+		return nil, "Domain is not verified"
+	}
+	if !scanLimiter.Acquire() {
+		return nil, "Too many scans in progress, please try again later"
+	}
+	defer scanLimiter.Release()
+	response, err := calculateOverallScore(scoresURL, nil)
+	if err != nil {
+		_, _, message := scoresErrorResponse(err)
+		return nil, message
+	}
+	var scoresResponse models.ScoresResponse
+	err = json.Unmarshal(response, &scoresResponse)
+	if err != nil || scoresResponse.Scores == nil {
+		fmt.Println(err)
+		return nil, "Unexpected Error Occured"
+	}
+	return scoresResponse.Scores, ""
+}
+
+// StreamScores - GET /scores/stream handler, streams every check as a Server-Sent Event as soon as it completes,
+// followed by a summary event holding the complete scores response
+func StreamScores(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	utils.ScansTotal.Inc()
+	defer func() { utils.ScanDuration.Observe(time.Since(start).Seconds()) }()
+	if !utils.ValidateToken(r) {
+		utils.ScanErrors.WithLabelValues(utils.UnauthorizedError).Inc()
+		utils.Unauthorized(w, true, "Invalid Token")
+		return
+	}
+	log.Print("GET /scores/stream")
+	scoresURL := r.URL.Query().Get("url")
+	err := utils.IsValidURL(scoresURL)
+	if err != nil {
+		utils.ScanErrors.WithLabelValues(utils.InvalidURLError).Inc()
+		utils.BadRequest(w, true, invalidURLMessage(err))
+		return
+	}
+	if !utils.IsScannableURL(scoresURL) {
+		utils.ScanErrors.WithLabelValues(utils.ForbiddenDomainError).Inc()
+		utils.Forbidden(w, true, "Domain cannot be scanned")
+		return
+	}
+	if !isVerifiedURL(scoresURL) {
+		utils.ScanErrors.WithLabelValues(utils.UnverifiedDomainError).Inc()
+		utils.Forbidden(w, true, "Domain is not verified")
+		return
+	}
+	scanOptions := &models.ScanOptions{
+		Skip:          r.URL.Query()["skip"],
+		Only:          r.URL.Query()["only"],
+		Profile:       r.URL.Query().Get("profile"),
+		DKIMSelectors: r.URL.Query()["dkim_selectors"],
+		IP:            r.URL.Query().Get("ip"),
+		SNI:           r.URL.Query().Get("sni"),
+	}
+	if scanOptions.Profile == "" {
+		scanOptions.Profile = r.Header.Get(utils.ScoringProfileHeader)
+	}
+	err = services.ValidateScanOptions(scanOptions)
+	if err != nil {
+		fmt.Println(err)
+		utils.ScanErrors.WithLabelValues(utils.InvalidRequestError).Inc()
+		utils.BadRequest(w, true, scanOptionsErrorMessage(err))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.InternalServerError(w, true, "Streaming is not supported")
+		return
+	}
+	if !scanLimiter.Acquire() {
+		utils.ScanErrors.WithLabelValues(utils.TooManyScansError).Inc()
+		utils.ServiceUnavailable(w, true, "Too many scans in progress, please try again later")
+		return
+	}
+	defer scanLimiter.Release()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	streamScan(r, flusher, scoresURL, scanOptions, func(event string, data []byte) {
+		writeEvent(w, event, data)
+	})
+}
+
+// streamScan runs a scan and writes every check through write as soon as it completes, followed by a summary event
+// holding the complete scores response or an error event. The response headers must already be written
+func streamScan(r *http.Request, flusher http.Flusher, scoresURL string, scanOptions *models.ScanOptions, write func(event string, data []byte)) {
+	checkResults := make(chan *models.CheckResult)
+	// the callback stops sending once the client is gone, so that the scan is not blocked on an unread channel
+	scanOptions.OnCheck = func(check *models.CheckResult) {
+		select {
+		case checkResults <- check:
+		case <-r.Context().Done():
+		}
+	}
+	type scanResult struct {
+		response []byte
+		err      error
+	}
+	done := make(chan scanResult, 1)
+	go func() {
+		response, scoresError := calculateOverallScore(scoresURL, scanOptions)
+		done <- scanResult{response, scoresError}
+	}()
+
+	for {
+		select {
+		case check := <-checkResults:
+			checkJSON, jsonError := json.Marshal(check)
+			if jsonError != nil {
+				fmt.Println("Error Occured while parsing Check Result JSON", jsonError)
+				continue
+			}
+			write("check", checkJSON)
+		case result := <-done:
+			if result.err != nil {
+				errorType, _, message := scoresErrorResponse(result.err)
+				utils.ScanErrors.WithLabelValues(errorType).Inc()
+				write("error", []byte(fmt.Sprintf(`{"error":%q}`, message)))
+			} else {
+				write("summary", result.response)
+			}
+			flusher.Flush()
+			return
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// streamScoresNDJSON streams the checks of a scan as newline delimited JSON, one event object per line
+func streamScoresNDJSON(w http.ResponseWriter, r *http.Request, flusher http.Flusher, scoresRequest *models.ScoresRequest, schemaVersion int) {
+	w.Header().Set("Content-Type", utils.NDJSONContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	streamScan(r, flusher, scoresRequest.URL, &scoresRequest.ScanOptions, func(event string, data []byte) {
+		if event == "summary" {
+			versioned, err := versionScoresResponse(data, schemaVersion, scoresRequest.Lang)
+			if err != nil {
+				fmt.Println(err)
+				event, data = "error", []byte(`{"error":"Unexpected Error Occured"}`)
+			} else {
+				data = versioned
+			}
+		}
+		line, err := json.Marshal(&models.StreamEvent{Event: event, Data: data})
+		if err != nil {
+			log.Println("Error Occured while parsing the NDJSON line", err)
+			return
+		}
+		_, err = w.Write(append(line, '\n'))
+		if err != nil {
+			log.Println("Error Occured while writing the NDJSON line", err)
+		}
+	})
+}
+
+// writeEvent writes a single Server-Sent Event, data must not contain line breaks
+func writeEvent(w http.ResponseWriter, event string, data []byte) {
+	_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	if err != nil {
+		log.Println("Error Occured while writing Server-Sent Event", err)
+	}
+}
+
+// VerifyDomain - POST /verify handler, returns the TXT record proving the control of a domain and whether it is published
+func VerifyDomain(w http.ResponseWriter, r *http.Request) {
+	if !utils.ValidateToken(r) {
+		utils.Unauthorized(w, true, "Invalid Token")
+		return
+	}
+	log.Print("POST /verify")
+	var verificationRequest models.VerificationRequest
+	err := json.NewDecoder(r.Body).Decode(&verificationRequest)
+	if err != nil {
+		fmt.Println(err)
+		utils.BadRequest(w, true, "Unexpected Error Occured")
+		return
+	}
+	domain, err := utils.NormalizeHost(strings.TrimSuffix(strings.TrimSpace(verificationRequest.Domain), "."))
+	if err != nil || domain == "" || net.ParseIP(domain) != nil {
+		utils.BadRequest(w, true, "Invalid Domain")
+		return
+	}
+	domain = strings.ToLower(domain)
+	responseBody, jsonError := json.Marshal(&models.Verification{
+		Domain:   domain,
+		Token:    utils.GetVerificationToken(domain),
+		Record:   utils.GetVerificationRecord(domain),
+		Verified: isDomainVerified(domain),
+	})
+	if jsonError != nil {
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.WriteHeader(http.StatusOK)
+	utils.Writer(w.Write(responseBody))
+}
+
+// PreflightScore - GET /scores/preflight handler
+func PreflightScore(w http.ResponseWriter, r *http.Request) {
+	if !utils.ValidateToken(r) {
+		utils.Unauthorized(w, true, "Invalid Token")
+		return
+	}
+	log.Print("GET /scores/preflight")
+	preflightURL := r.URL.Query().Get("url")
+	err := utils.IsValidURL(preflightURL)
+	if err != nil {
+		utils.BadRequest(w, true, invalidURLMessage(err))
+		return
+	}
+	if !utils.IsScannableURL(preflightURL) {
+		utils.Forbidden(w, true, "Domain cannot be scanned")
+		return
+	}
+	preflight, err := runPreflight(preflightURL)
+	if errors.Is(err, utils.ErrPrivateTarget) {
+		utils.BadRequest(w, true, "Internal targets cannot be scanned")
+		return
+	}
+	if err != nil {
+		utils.BadRequest(w, true, "Invalid URL")
+		return
+	}
+	responseBody, jsonError := json.Marshal(preflight)
+	if jsonError != nil {
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.WriteHeader(http.StatusOK)
+	utils.Writer(w.Write(responseBody))
+}
+
+// GetScoreHistory - GET /scores/{domain}/history handler
+func GetScoreHistory(w http.ResponseWriter, r *http.Request) {
+	if !utils.ValidateToken(r) {
+		utils.Unauthorized(w, true, "Invalid Token")
+		return
+	}
+	log.Print("GET /scores/{domain}/history")
+	domain, err := utils.NormalizeHost(mux.Vars(r)["domain"])
+	if err != nil {
+		utils.BadRequest(w, true, "Invalid Domain")
+		return
+	}
+	history, err := services.ResultStore.GetHistory(r.Context(), domain)
+	if err != nil {
+		fmt.Println(err)
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	responseBody, jsonError := json.Marshal(&models.ScoreHistory{Domain: domain, History: history})
+	if jsonError != nil {
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.WriteHeader(http.StatusOK)
+	utils.Writer(w.Write(responseBody))
+}
+
+// GetScoreReport - GET /scores/{domain}/report.pdf handler, renders the latest scan result of the domain as a PDF report
+func GetScoreReport(w http.ResponseWriter, r *http.Request) {
+	if !utils.ValidateToken(r) {
+		utils.Unauthorized(w, true, "Invalid Token")
+		return
+	}
+	log.Print("GET /scores/{domain}/report.pdf")
+	domain, err := utils.NormalizeHost(mux.Vars(r)["domain"])
+	if err != nil {
+		utils.BadRequest(w, true, "Invalid Domain")
+		return
+	}
+	history, err := services.ResultStore.GetHistory(r.Context(), domain)
+	if err != nil {
+		fmt.Println(err)
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	// the history is ordered oldest first, results saved before their response was kept cannot be rendered
+	var latest *models.ScanResult
+	for _, result := range history {
+		if result.Response != "" {
+			latest = result
+		}
+	}
+	if latest == nil {
+		utils.NotFound(w, true, "No scan result for the domain")
+		return
+	}
+	var scoresResponse models.ScoresResponse
+	err = json.Unmarshal([]byte(latest.Response), &scoresResponse)
+	if err != nil {
+		fmt.Println(err)
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	var report bytes.Buffer
+	err = utils.WriteScoresPDF(&report, &scoresResponse, latest.ScannedAt)
+	if err != nil {
+		fmt.Println(err)
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s-report.pdf"`, domain))
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.WriteHeader(http.StatusOK)
+	utils.Writer(w.Write(report.Bytes()))
+}
+
+// RescoreResult - POST /rescore/{id} handler, recomputes the score of a stored scan result from its observations
+// under the current scoring configuration, without scanning the URL again
+func RescoreResult(w http.ResponseWriter, r *http.Request) {
+	if !utils.ValidateToken(r) {
+		utils.Unauthorized(w, true, "Invalid Token")
+		return
+	}
+	log.Print("POST /rescore/{id}")
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		utils.BadRequest(w, true, "Invalid ID")
+		return
+	}
+	response, err := services.Rescore(r.Context(), uint(id))
+	if errors.Is(err, utils.ErrResultNotFound) {
+		utils.NotFound(w, true, "No scan result for the ID")
+		return
+	}
+	if errors.Is(err, services.ErrNoObservations) {
+		utils.NotFound(w, true, "No observations for the scan result")
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	responseBody, jsonError := json.Marshal(response)
+	if jsonError != nil {
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.WriteHeader(http.StatusOK)
+	utils.Writer(w.Write(responseBody))
+}
+
+// GetJob - GET /jobs/{id} handler, returns the status of a scan run asynchronously, along with its result once done
+func GetJob(w http.ResponseWriter, r *http.Request) {
+	if !utils.ValidateToken(r) {
+		utils.Unauthorized(w, true, "Invalid Token")
+		return
+	}
+	log.Print("GET /jobs/{id}")
+	job, ok := services.Jobs.Get(mux.Vars(r)["id"])
+	if !ok {
+		utils.NotFound(w, true, "No job for the ID")
+		return
+	}
+	responseBody, jsonError := json.Marshal(&job)
+	if jsonError != nil {
+		utils.InternalServerError(w, true, "Unexpected Error Occured")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.Header().Set("Access-Control-Allow-Origin", utils.GetAccessControlAllowOrigin())
+	w.WriteHeader(http.StatusOK)
+	utils.Writer(w.Write(responseBody))
 }
 
 // GetAuthToken - GET /scores handler
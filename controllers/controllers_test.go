@@ -1,17 +1,30 @@
 package controllers
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path"
 	"runtime"
 	"snift-api/models"
+	"snift-api/services"
 	"snift-api/utils"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -63,6 +76,48 @@ func TestInvalidURL(t *testing.T) {
 	assert.Equal(t, rr.Body.String(), "{\"error\":\"Invalid URL\"}")
 
 }
+
+func TestScoresURLLimits(t *testing.T) {
+	for urlJSON, message := range map[string]string{
+		`{"url":"https://www.exa\r\nmple.com"}`:                               "URL contains control characters",
+		`{"url":"https://www.example.com/` + strings.Repeat("a", 2048) + `"}`: "URL is too long",
+	} {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(urlJSON))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, http.StatusBadRequest)
+		assert.Equal(t, rr.Body.String(), `{"error":"`+message+`"}`)
+	}
+}
+
+func TestScoresErrorStatus(t *testing.T) {
+	original := calculateOverallScore
+	defer func() { calculateOverallScore = original }()
+	for _, expected := range []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{models.ClassifyScanError(&net.DNSError{Err: "no such host", Name: "www.example.com", IsNotFound: true}), http.StatusBadRequest, "Invalid Domain"},
+		{models.ClassifyScanError(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), http.StatusBadGateway, "Site is unreachable"},
+		{models.ClassifyScanError(fmt.Errorf("Get \"https://www.example.com\": %w", context.DeadlineExceeded)), http.StatusGatewayTimeout, "Site did not answer in time"},
+		{utils.ErrRedirectLoop, http.StatusBadRequest, "Too many redirects"},
+		{utils.ErrPrivateTarget, http.StatusBadRequest, "Internal targets cannot be scanned"},
+		{errors.New("x509: certificate signed by unknown authority"), http.StatusInternalServerError, "Unexpected Error Occured"},
+	} {
+		calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+			return nil, expected.err
+		}
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com"}`))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, expected.status)
+		assert.Equal(t, rr.Body.String(), `{"error":"`+expected.message+`"}`)
+	}
+}
+
 func TestValidURL(t *testing.T) {
 
 	tokenreq, _ := http.NewRequest("GET", "/token", nil)
@@ -111,5 +166,1037 @@ func TestPreflightRequest(t *testing.T) {
 	assert.Equal(t, rr.Code, http.StatusOK)
 	assert.Equal(t, rr.Header().Get("Access-Control-Allow-Methods"), "POST")
 	assert.Equal(t, rr.Header().Get("Access-Control-Allow-Origin"), utils.GetAccessControlAllowOrigin())
-	assert.Equal(t, rr.Header().Get("Access-Control-Allow-Headers"), "x-auth-token,content-type,X-Auth-Token,Content-Type")
+	assert.Equal(t, rr.Header().Get("Access-Control-Allow-Headers"), "x-auth-token,content-type,x-scoring-profile,accept-version,x-min-score,idempotency-key,X-Auth-Token,Content-Type,X-Scoring-Profile,Accept-Version,X-Min-Score,Idempotency-Key")
+}
+
+func getTestToken(t *testing.T) string {
+	tokenreq, _ := http.NewRequest("GET", "/token", nil)
+	tokenrr := httptest.NewRecorder()
+	http.HandlerFunc(GetAuthToken).ServeHTTP(tokenrr, tokenreq)
+
+	var token models.Token
+	parseerr := json.NewDecoder(tokenrr.Body).Decode(&token)
+	if parseerr != nil {
+		assert.Fail(t, "Error Occured while Decoding")
+	}
+	return token.Token
+}
+
+func mockCalculateOverallScore(response string) func() {
+	original := calculateOverallScore
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		return []byte(response), nil
+	}
+	return func() { calculateOverallScore = original }
+}
+
+const mockScoresResponse = `{"scores":{"url":"https://www.example.com","score":0.75,"grade":"C","badges":[{"name":"HTTP_SECURE"}],"checks":[{"name":"Protocol","score":5,"max_score":5},{"name":"Content-Security-Policy","score":3,"max_score":5}]}}`
+
+func TestScoresCSVResponse(t *testing.T) {
+	defer mockCalculateOverallScore(mockScoresResponse)()
+
+	req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com"}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	req.Header.Set("Accept", "text/csv")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "text/csv; charset=UTF-8")
+	records, err := csv.NewReader(rr.Body).ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, records, [][]string{
+		{"url", "score", "grade", "badges", "Protocol", "Protocol (max)", "Content-Security-Policy", "Content-Security-Policy (max)"},
+		{"https://www.example.com", "0.75", "C", "HTTP_SECURE", "5", "5", "3", "5"},
+	})
+}
+
+func TestScoresDefaultsToJSON(t *testing.T) {
+	defer mockCalculateOverallScore(mockScoresResponse)()
+
+	req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com"}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	req.Header.Set("Accept", "text/html")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "application/json; charset=UTF-8")
+	var response, expected models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.NoError(t, json.Unmarshal([]byte(mockScoresResponse), &expected))
+	assert.Equal(t, response.Scores, expected.Scores)
+}
+
+func TestScoresMinScore(t *testing.T) {
+	defer mockCalculateOverallScore(mockScoresResponse)()
+
+	for _, test := range []struct {
+		body   string
+		header string
+		status int
+	}{
+		{`{"url":"https://www.example.com"}`, "", http.StatusOK},
+		{`{"url":"https://www.example.com","min_score":75}`, "", http.StatusOK},
+		{`{"url":"https://www.example.com","min_score":80}`, "", http.StatusUnprocessableEntity},
+		{`{"url":"https://www.example.com"}`, "60", http.StatusOK},
+		{`{"url":"https://www.example.com"}`, "90.5", http.StatusUnprocessableEntity},
+		// the minimum score of the request body takes precedence over the header
+		{`{"url":"https://www.example.com","min_score":50}`, "90", http.StatusOK},
+		{`{"url":"https://www.example.com","min_score":101}`, "", http.StatusBadRequest},
+		{`{"url":"https://www.example.com"}`, "high", http.StatusBadRequest},
+	} {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(test.body))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		if test.header != "" {
+			req.Header.Set("X-Min-Score", test.header)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, test.status, test.body+" "+test.header)
+		if test.status == http.StatusBadRequest {
+			assert.Equal(t, rr.Body.String(), `{"error":"Invalid minimum score"}`)
+			continue
+		}
+		// the full result is returned whether the score passes or not
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, response.Scores.Score, 0.75)
+	}
+}
+
+func TestScoresRequiredHeaders(t *testing.T) {
+	defer mockCalculateOverallScore(`{"scores":{"url":"https://www.example.com","score":0.75,"grade":"C","checks":[],"compliance_violations":["Content-Security-Policy"]}}`)()
+	defer os.Unsetenv("REQUIRED_HEADERS_ENFORCED")
+
+	for _, enforced := range []bool{false, true} {
+		os.Setenv("REQUIRED_HEADERS_ENFORCED", fmt.Sprint(enforced))
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com"}`))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+		// an enforced violation fails the scan like a score below min_score, with the full result
+		if enforced {
+			assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+		} else {
+			assert.Equal(t, rr.Code, http.StatusOK)
+		}
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, response.Scores.ComplianceViolations, []string{"Content-Security-Policy"})
+	}
+}
+
+func TestScoresSchemaVersion(t *testing.T) {
+	defer mockCalculateOverallScore(mockScoresResponse)()
+
+	for _, test := range []struct {
+		acceptVersion string
+		version       float64
+		fields        bool
+	}{
+		{"", models.CurrentSchemaVersion, true},
+		{"2", models.SchemaVersion2, true},
+		{"v1", models.SchemaVersion1, false},
+		{"1", models.SchemaVersion1, false},
+	} {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com"}`))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		req.Header.Set("Accept-Version", test.acceptVersion)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+		var response map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, response["schema_version"], test.version)
+		scores := response["scores"].(map[string]interface{})
+		assert.Equal(t, scores["url"], "https://www.example.com")
+		assert.Equal(t, scores["score"], 0.75)
+		// the fields added after the first version are left out of it
+		for _, field := range []string{"grade", "checks", "hsts_preload_eligible"} {
+			_, ok := scores[field]
+			assert.Equal(t, ok, test.fields, field)
+		}
+	}
+
+	for _, acceptVersion := range []string{"0", "99", "latest"} {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com"}`))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		req.Header.Set("Accept-Version", acceptVersion)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, http.StatusBadRequest, acceptVersion)
+	}
+}
+
+func TestScoresLanguage(t *testing.T) {
+	defer mockCalculateOverallScore(`{"scores":{"url":"https://www.example.com","score":0.75,"badges":[{"name":"HTTP_SECURE","message":"` + utils.HTTPSBadgeMessage + `"}],"checks":[{"name":"X-Content-Type-Options","score":0,"max_score":5,"remediation":"` + utils.XContentTypeRemediation + `"}]}}`)()
+
+	for _, test := range []struct {
+		body           string
+		acceptLanguage string
+		badge          string
+		remediation    string
+	}{
+		{`{"url":"https://www.example.com"}`, "", utils.HTTPSBadgeMessage, utils.XContentTypeRemediation},
+		{`{"url":"https://www.example.com"}`, "fr-FR,fr;q=0.9,en;q=0.8", "Connexion HTTPS chiffrée", "Ajoutez l'en-tête X-Content-Type-Options: nosniff"},
+		{`{"url":"https://www.example.com","lang":"fr"}`, "en", "Connexion HTTPS chiffrée", "Ajoutez l'en-tête X-Content-Type-Options: nosniff"},
+		// languages without a catalog fall back to English
+		{`{"url":"https://www.example.com","lang":"de"}`, "es", utils.HTTPSBadgeMessage, utils.XContentTypeRemediation},
+	} {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(test.body))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		req.Header.Set("Accept-Language", test.acceptLanguage)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.Contains(t, rr.Header().Values("Vary"), "Accept-Language")
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(t, response.Scores.Badges[0].Message, test.badge, test.body)
+		assert.Equal(t, response.Scores.Checks[0].Remediation, test.remediation, test.body)
+	}
+}
+
+func TestScoresSkipOptions(t *testing.T) {
+	original := calculateOverallScore
+	defer func() { calculateOverallScore = original }()
+	var scanOptions *models.ScanOptions
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		scanOptions = options
+		return []byte(mockScoresResponse), nil
+	}
+
+	req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","skip":["vulnerabilities","dns"]}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, scanOptions.Skip, []string{"vulnerabilities", "dns"})
+
+	scanOptions = nil
+	req, _ = http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","skip":["headers"]}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Nil(t, scanOptions)
+
+	req, _ = http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","dkim_selectors":["s1","mta"]}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, scanOptions.DKIMSelectors, []string{"s1", "mta"})
+
+	scanOptions = nil
+	req, _ = http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","dkim_selectors":["s1;evil"]}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Contains(t, rr.Body.String(), "Invalid DKIM selector")
+	assert.Nil(t, scanOptions)
+}
+
+func TestScoresOnlyOptions(t *testing.T) {
+	original := calculateOverallScore
+	defer func() { calculateOverallScore = original }()
+	var scanOptions *models.ScanOptions
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		scanOptions = options
+		return []byte(mockScoresResponse), nil
+	}
+
+	req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","only":["csp","Strict-Transport-Security"]}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, scanOptions.Only, []string{"csp", "Strict-Transport-Security"})
+
+	scanOptions = nil
+	req, _ = http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","only":["csp","firewall"]}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Equal(t, rr.Body.String(), `{"error":"Unknown check to run"}`)
+	assert.Nil(t, scanOptions)
+}
+
+func TestScoresHeadersOptions(t *testing.T) {
+	original := calculateOverallScore
+	defer func() { calculateOverallScore = original }()
+	var scanOptions *models.ScanOptions
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		scanOptions = options
+		return []byte(mockScoresResponse), nil
+	}
+
+	req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","headers":{"Cookie":"session=secret"}}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, scanOptions.Headers, map[string]string{"Cookie": "session=secret"})
+
+	scanOptions = nil
+	req, _ = http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","headers":{"Host":"localhost"}}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Equal(t, rr.Body.String(), `{"error":"Forbidden request header"}`)
+	assert.Nil(t, scanOptions)
+}
+
+func TestScoresProfileOptions(t *testing.T) {
+	original := calculateOverallScore
+	defer func() { calculateOverallScore = original }()
+	var scanOptions *models.ScanOptions
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		scanOptions = options
+		return []byte(mockScoresResponse), nil
+	}
+
+	// the profile of the request body takes precedence over the header
+	for body, profile := range map[string]string{
+		`{"url":"https://www.example.com","profile":"strict"}`: "strict",
+		`{"url":"https://www.example.com"}`:                    "lenient",
+	} {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(body))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		req.Header.Set("X-Scoring-Profile", "lenient")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, http.StatusOK)
+		assert.Equal(t, scanOptions.Profile, profile)
+	}
+
+	scanOptions = nil
+	req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","profile":"paranoid"}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Equal(t, rr.Body.String(), `{"error":"Unknown scoring profile"}`)
+	assert.Nil(t, scanOptions)
+}
+
+// readEvent reads the next Server-Sent Event of a stream
+func readEvent(t *testing.T, reader *bufio.Reader) (event string, data string) {
+	for {
+		line, err := reader.ReadString('\n')
+		if !assert.NoError(t, err) {
+			return
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStreamScores(t *testing.T) {
+	original := calculateOverallScore
+	defer func() { calculateOverallScore = original }()
+	proceed := make(chan struct{})
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		options.OnCheck(models.GetCheckResult("Protocol", 5, 5))
+		// the second check only completes once the first one was received by the client
+		<-proceed
+		options.OnCheck(models.GetCheckResult("Content-Security-Policy", 3, 5))
+		return []byte(mockScoresResponse), nil
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the test token is issued to a request without a remote address
+		r.RemoteAddr = ""
+		StreamScores(w, r)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/scores/stream?url=https://www.example.com", nil)
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	res, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.Header.Get("Content-Type"), "text/event-stream")
+	reader := bufio.NewReader(res.Body)
+
+	event, data := readEvent(t, reader)
+	assert.Equal(t, event, "check")
+	assert.JSONEq(t, data, `{"name":"Protocol","score":5,"max_score":5}`)
+	close(proceed)
+	event, data = readEvent(t, reader)
+	assert.Equal(t, event, "check")
+	assert.JSONEq(t, data, `{"name":"Content-Security-Policy","score":3,"max_score":5}`)
+	event, data = readEvent(t, reader)
+	assert.Equal(t, event, "summary")
+	assert.Equal(t, data, mockScoresResponse)
+}
+
+func TestScoresNDJSON(t *testing.T) {
+	original := calculateOverallScore
+	defer func() { calculateOverallScore = original }()
+	proceed := make(chan struct{})
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		options.OnCheck(models.GetCheckResult("Protocol", 5, 5))
+		// the second check only completes once the first line was received by the client
+		<-proceed
+		options.OnCheck(models.GetCheckResult("Content-Security-Policy", 3, 5))
+		return []byte(mockScoresResponse), nil
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.RemoteAddr = ""
+		GetScore(w, r)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL+"/scores", strings.NewReader(`{"url":"https://www.example.com"}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	req.Header.Set("Accept", "application/x-ndjson")
+	res, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer res.Body.Close()
+	assert.Equal(t, res.StatusCode, http.StatusOK)
+	assert.Equal(t, res.Header.Get("Content-Type"), "application/x-ndjson")
+	lines := bufio.NewScanner(res.Body)
+
+	var event models.StreamEvent
+	assert.True(t, lines.Scan())
+	assert.NoError(t, json.Unmarshal(lines.Bytes(), &event))
+	assert.Equal(t, event.Event, "check")
+	assert.JSONEq(t, string(event.Data), `{"name":"Protocol","score":5,"max_score":5}`)
+	close(proceed)
+	assert.True(t, lines.Scan())
+	assert.NoError(t, json.Unmarshal(lines.Bytes(), &event))
+	assert.Equal(t, event.Event, "check")
+	assert.JSONEq(t, string(event.Data), `{"name":"Content-Security-Policy","score":3,"max_score":5}`)
+	assert.True(t, lines.Scan())
+	assert.NoError(t, json.Unmarshal(lines.Bytes(), &event))
+	assert.Equal(t, event.Event, "summary")
+	var summary models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(event.Data, &summary))
+	assert.Equal(t, summary.SchemaVersion, models.CurrentSchemaVersion)
+	assert.Equal(t, summary.Scores.Grade, "C")
+	assert.False(t, lines.Scan())
+}
+
+func TestStreamScoresError(t *testing.T) {
+	original := calculateOverallScore
+	defer func() { calculateOverallScore = original }()
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		return nil, utils.ErrRedirectLoop
+	}
+
+	req, _ := http.NewRequest("GET", "/scores/stream?url=https://www.example.com", nil)
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(StreamScores).ServeHTTP(rr, req)
+	event, data := readEvent(t, bufio.NewReader(rr.Body))
+	assert.Equal(t, event, "error")
+	assert.Equal(t, data, `{"error":"Too many redirects"}`)
+
+	req, _ = http.NewRequest("GET", "/scores/stream?url=https://www.example.com&skip=headers", nil)
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(StreamScores).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestCompareScores(t *testing.T) {
+	original := calculateOverallScore
+	defer func() { calculateOverallScore = original }()
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		if scoresURL == "https://www.example.com" {
+			return []byte(mockScoresResponse), nil
+		}
+		return []byte(`{"scores":{"url":"http://www.example.org","score":0.5,"checks":[{"name":"Protocol","score":0,"max_score":5},{"name":"Content-Security-Policy","score":5,"max_score":5}]}}`), nil
+	}
+
+	var compareJSON = `{"first_url":"https://www.example.com","second_url":"http://www.example.org"}`
+	req, _ := http.NewRequest("POST", "/scores/compare", strings.NewReader(compareJSON))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(CompareScores).ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var compareResponse models.CompareResponse
+	err := json.NewDecoder(rr.Body).Decode(&compareResponse)
+	assert.NoError(t, err)
+	assert.Equal(t, compareResponse.ScoreDelta, 0.25)
+	assert.Equal(t, len(compareResponse.Checks), 2)
+	assert.Equal(t, *compareResponse.Checks[0], models.CheckComparison{Name: "Protocol", FirstScore: 5, SecondScore: 0, MaxScore: 5, Delta: 5, Outperforms: "first"})
+	assert.Equal(t, *compareResponse.Checks[1], models.CheckComparison{Name: "Content-Security-Policy", FirstScore: 3, SecondScore: 5, MaxScore: 5, Delta: -2, Outperforms: "second"})
+}
+
+func TestCompareScoresInvalidURL(t *testing.T) {
+	var compareJSON = `{"first_url":"https://www.example.com","second_url":"example"}`
+	req, _ := http.NewRequest("POST", "/scores/compare", strings.NewReader(compareJSON))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	defer mockCalculateOverallScore(mockScoresResponse)()
+	http.HandlerFunc(CompareScores).ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Equal(t, rr.Body.String(), "{\"error\":\"Invalid URL\"}")
+}
+
+func TestScanConcurrencyLimit(t *testing.T) {
+	originalLimiter := scanLimiter
+	scanLimiter = utils.NewScanLimiter(1, 10*time.Millisecond)
+	defer func() { scanLimiter = originalLimiter }()
+	started := make(chan bool)
+	finish := make(chan bool)
+	originalCalculate := calculateOverallScore
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		started <- true
+		<-finish
+		return []byte(mockScoresResponse), nil
+	}
+	defer func() { calculateOverallScore = originalCalculate }()
+	token := getTestToken(t)
+
+	newScoresRequest := func() *http.Request {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com"}`))
+		req.Header.Set("X-Auth-Token", token)
+		return req
+	}
+	firstrr := httptest.NewRecorder()
+	done := make(chan bool)
+	go func() {
+		http.HandlerFunc(GetScore).ServeHTTP(firstrr, newScoresRequest())
+		done <- true
+	}()
+	<-started
+
+	healthrr := httptest.NewRecorder()
+	healthreq, _ := http.NewRequest("GET", "/healthz", nil)
+	http.HandlerFunc(HealthCheck).ServeHTTP(healthrr, healthreq)
+	assert.Equal(t, healthrr.Body.String(), `{"status":"ok","in_flight_scans":1,"max_concurrent_scans":1}`)
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, newScoresRequest())
+	assert.Equal(t, rr.Code, http.StatusServiceUnavailable)
+	assert.Equal(t, rr.Body.String(), "{\"error\":\"Too many scans in progress, please try again later\"}")
+
+	finish <- true
+	<-done
+	assert.Equal(t, firstrr.Code, http.StatusOK)
+	assert.Equal(t, scanLimiter.InFlight(), 0)
+}
+
+func TestGetVersion(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/version", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetVersion).ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Equal(t, rr.Header().Get("Content-Type"), "application/json; charset=UTF-8")
+	assert.Equal(t, rr.Body.String(), `{"version":"dev","git_commit":"dev","build_time":"dev"}`)
+}
+
+func TestGetChecks(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/checks", nil)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetChecks).ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var catalog models.CheckCatalog
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &catalog))
+	names := make(map[string]bool)
+	for _, check := range catalog.Checks {
+		names[check.Name] = true
+	}
+	// every check with a remediation is active, apart from the opt-in Sensitive Paths check
+	for name := range services.Remediations {
+		assert.Equal(t, names[name], name != services.SensitivePathsCheck, name)
+	}
+}
+
+func TestScoreHistory(t *testing.T) {
+	original := services.ResultStore
+	services.ResultStore = utils.NewMemoryResultStore()
+	defer func() { services.ResultStore = original }()
+	services.ResultStore.Save(context.Background(), models.GetScanResult("xn--mller-kva.de", "https://müller.de", 0.7))
+
+	req, _ := http.NewRequest("GET", "/scores/müller.de/history", nil)
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	req = mux.SetURLVars(req, map[string]string{"domain": "müller.de"})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScoreHistory).ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var history models.ScoreHistory
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &history))
+	assert.Equal(t, history.Domain, "xn--mller-kva.de")
+	assert.Len(t, history.History, 1)
+	assert.Equal(t, history.History[0].Score, 0.7)
+}
+
+func TestScoreReport(t *testing.T) {
+	original := services.ResultStore
+	services.ResultStore = utils.NewMemoryResultStore()
+	defer func() { services.ResultStore = original }()
+	result := models.GetScanResult("www.example.com", "https://www.example.com", 0.75)
+	result.Response = mockScoresResponse
+	services.ResultStore.Save(context.Background(), result)
+
+	for _, test := range []struct {
+		domain string
+		status int
+	}{
+		{"www.example.com", http.StatusOK},
+		{"www.example.org", http.StatusNotFound},
+	} {
+		req, _ := http.NewRequest("GET", "/scores/"+test.domain+"/report.pdf", nil)
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		req = mux.SetURLVars(req, map[string]string{"domain": test.domain})
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScoreReport).ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, test.status, test.domain)
+		if test.status == http.StatusOK {
+			assert.Equal(t, rr.Header().Get("Content-Type"), "application/pdf")
+			assert.True(t, strings.HasPrefix(rr.Body.String(), "%PDF-"))
+		}
+	}
+}
+
+func TestRescoreResult(t *testing.T) {
+	original := services.ResultStore
+	services.ResultStore = utils.NewMemoryResultStore()
+	defer func() { services.ResultStore = original }()
+	result := models.GetScanResult("www.example.com", "https://www.example.com", 0.5)
+	result.Observations = `{"url":"https://www.example.com","protocol":"https","response_protocol":"https",` +
+		`"headers":{"X-Frame-Options":"DENY"},"proto":"HTTP/1.1","tls_version":772}`
+	services.ResultStore.Save(context.Background(), result)
+	legacy := models.GetScanResult("www.example.com", "https://www.example.com", 0.5)
+	services.ResultStore.Save(context.Background(), legacy)
+
+	for _, test := range []struct {
+		id     string
+		status int
+	}{
+		{fmt.Sprint(result.ID), http.StatusOK},
+		{fmt.Sprint(legacy.ID), http.StatusNotFound},
+		{"1000", http.StatusNotFound},
+		{"latest", http.StatusBadRequest},
+	} {
+		req, _ := http.NewRequest("POST", "/rescore/"+test.id, nil)
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		req = mux.SetURLVars(req, map[string]string{"id": test.id})
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(RescoreResult).ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, test.status, test.id)
+		if test.status == http.StatusOK {
+			var response models.ScoresResponse
+			assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(t, response.Scores.URL, "https://www.example.com")
+			assert.Equal(t, response.Scores.NegotiatedTLSVersion, "TLS 1.3")
+			for _, check := range response.Scores.Checks {
+				if check.Name == services.XFrameHeader {
+					assert.Equal(t, check.Score, check.MaxScore)
+				}
+			}
+		}
+	}
+}
+
+func newUploadRequest(t *testing.T, content string) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "urls.csv")
+	assert.NoError(t, err)
+	part.Write([]byte(content))
+	assert.NoError(t, form.Close())
+	req, _ := http.NewRequest("POST", "/scores/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	return req
+}
+
+func TestUploadScores(t *testing.T) {
+	original := calculateOverallScore
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		if strings.Contains(scoresURL, "unknown") {
+			return nil, models.ClassifyScanError(&net.DNSError{Err: "no such host", Name: scoresURL, IsNotFound: true})
+		}
+		return []byte(fmt.Sprintf(`{"scores":{"url":%q,"score":0.75,"grade":"C"}}`, scoresURL)), nil
+	}
+	defer func() { calculateOverallScore = original }()
+
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(UploadScores).ServeHTTP(rr, newUploadRequest(t, "url,owner\n"+
+		"https://www.example.com,security\n"+
+		"\n"+
+		"# staging\n"+
+		"not a url\n"+
+		"\"https://unknown.example.org\",web\n"+
+		"www.example.net\n"))
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var response models.BatchResponse
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.Len(t, response.Results, 4)
+	for i, expected := range []struct {
+		line  int
+		url   string
+		error string
+	}{
+		{2, "https://www.example.com", ""},
+		{5, "not a url", "Invalid URL"},
+		{6, "https://unknown.example.org", "Invalid Domain"},
+		{7, "www.example.net", ""},
+	} {
+		result := response.Results[i]
+		assert.Equal(t, result.Line, expected.line)
+		assert.Equal(t, result.URL, expected.url)
+		assert.Equal(t, result.Error, expected.error)
+		if expected.error == "" {
+			assert.Equal(t, result.Scores.URL, expected.url)
+		} else {
+			assert.Nil(t, result.Scores)
+		}
+	}
+
+	for _, test := range []struct {
+		content string
+		status  int
+	}{
+		{"", http.StatusBadRequest},
+		{strings.Repeat("a", utils.MaxUploadLineLength+1), http.StatusRequestEntityTooLarge},
+		{strings.Repeat("www.example.com\n", utils.MaxUploadURLs+1), http.StatusRequestEntityTooLarge},
+		{strings.Repeat(strings.Repeat("a", 1000)+"\n", utils.MaxUploadSize/1000+1), http.StatusRequestEntityTooLarge},
+	} {
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(UploadScores).ServeHTTP(rr, newUploadRequest(t, test.content))
+		assert.Equal(t, rr.Code, test.status, rr.Body.String())
+	}
+}
+
+func TestScoresCallback(t *testing.T) {
+	defer mockCalculateOverallScore(mockScoresResponse)()
+	allowlist := os.Getenv("TARGET_ALLOWLIST")
+	os.Setenv("TARGET_ALLOWLIST", "127.0.0.0/8")
+	defer os.Setenv("TARGET_ALLOWLIST", allowlist)
+	backoff := utils.RetryBackoff
+	utils.RetryBackoff = time.Millisecond
+	defer func() { utils.RetryBackoff = backoff }()
+	// the receiver fails the first delivery, which is retried
+	delivered := make(chan models.Job, 1)
+	requests := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var job models.Job
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&job))
+		delivered <- job
+	}))
+	defer receiver.Close()
+
+	body := `{"url":"https://www.example.com","callback_url":"` + receiver.URL + `/hooks/snift"}`
+	req, _ := http.NewRequest("POST", "/scores", strings.NewReader(body))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusAccepted)
+	var accepted models.Job
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &accepted))
+	assert.NotEmpty(t, accepted.ID)
+	assert.Equal(t, accepted.Status, models.JobRunning)
+	assert.Equal(t, rr.Header().Get("Location"), "/jobs/"+accepted.ID)
+
+	select {
+	case job := <-delivered:
+		assert.Equal(t, job.ID, accepted.ID)
+		assert.Equal(t, job.Status, models.JobCompleted)
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(job.Result, &response))
+		assert.Equal(t, response.Scores.URL, "https://www.example.com")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the callback URL did not receive the job")
+	}
+
+	getJob := func(id string) (int, models.Job) {
+		req, _ := http.NewRequest("GET", "/jobs/"+id, nil)
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetJob).ServeHTTP(rr, req)
+		var job models.Job
+		json.Unmarshal(rr.Body.Bytes(), &job)
+		return rr.Code, job
+	}
+	assert.Eventually(t, func() bool {
+		_, job := getJob(accepted.ID)
+		return job.CallbackDelivered
+	}, 5*time.Second, 10*time.Millisecond)
+	status, job := getJob(accepted.ID)
+	assert.Equal(t, status, http.StatusOK)
+	assert.Equal(t, job.Status, models.JobCompleted)
+	assert.Equal(t, job.CallbackAttempts, 2)
+	assert.NotNil(t, job.CompletedAt)
+
+	status, _ = getJob("unknown")
+	assert.Equal(t, status, http.StatusNotFound)
+
+	req, _ = http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","callback_url":"ftp://hooks.example.com"}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Contains(t, rr.Body.String(), "Invalid callback URL")
+}
+
+func TestScoresIdempotencyKey(t *testing.T) {
+	originalStore := services.ResultStore
+	services.ResultStore = utils.NewMemoryResultStore()
+	defer func() { services.ResultStore = originalStore }()
+	original := calculateOverallScore
+	scans := 0
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		scans++
+		result := models.GetScanResult("www.example.com", scoresURL, 0.75)
+		result.Response = mockScoresResponse
+		result.IdempotencyKey = options.GetIdempotencyKey()
+		result.IdempotencyFingerprint = options.GetIdempotencyFingerprint()
+		services.ResultStore.Save(context.Background(), result)
+		return []byte(mockScoresResponse), nil
+	}
+	defer func() { calculateOverallScore = original }()
+
+	token := getTestToken(t)
+	scoreRequest := func(key string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(body))
+		req.Header.Set("X-Auth-Token", token)
+		req.Header.Set("Idempotency-Key", key)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+		return rr
+	}
+	score := func(key string) *httptest.ResponseRecorder {
+		return scoreRequest(key, `{"url":"https://www.example.com"}`)
+	}
+	first := score("scan-1")
+	assert.Equal(t, first.Code, http.StatusOK)
+	assert.Equal(t, first.Header().Get("Idempotent-Replayed"), "")
+	// the repeated key is answered with the stored response rather than a new scan
+	replayed := score("scan-1")
+	assert.Equal(t, replayed.Code, http.StatusOK)
+	assert.Equal(t, replayed.Header().Get("Idempotent-Replayed"), "true")
+	assert.JSONEq(t, replayed.Body.String(), first.Body.String())
+	assert.Equal(t, scans, 1)
+	// the same URL written otherwise is the same request
+	assert.Equal(t, scoreRequest("scan-1", `{"url":"https://WWW.example.com"}`).Header().Get("Idempotent-Replayed"), "true")
+
+	// the key is not replayed for another URL or other options
+	for _, body := range []string{`{"url":"https://www.example.org"}`, `{"url":"https://www.example.com","only":["csp"]}`} {
+		rr := scoreRequest("scan-1", body)
+		assert.Equal(t, rr.Code, http.StatusUnprocessableEntity, body)
+		assert.Equal(t, rr.Body.String(), `{"error":"Idempotency key was used with a different request"}`)
+		assert.Equal(t, rr.Header().Get("Idempotent-Replayed"), "")
+	}
+	assert.Equal(t, scans, 1)
+
+	assert.Equal(t, score("scan-2").Code, http.StatusOK)
+	assert.Equal(t, scans, 2)
+
+	// the key expires after the TTL
+	os.Setenv("IDEMPOTENCY_TTL", "1ns")
+	defer os.Unsetenv("IDEMPOTENCY_TTL")
+	assert.Equal(t, score("scan-1").Header().Get("Idempotent-Replayed"), "")
+	assert.Equal(t, scans, 3)
+	os.Unsetenv("IDEMPOTENCY_TTL")
+
+	assert.Equal(t, score(strings.Repeat("k", 256)).Code, http.StatusBadRequest)
+	assert.Equal(t, score("scan\t1").Code, http.StatusBadRequest)
+	assert.Equal(t, scans, 3)
+
+	// a job started with a key is returned again rather than started twice
+	allowlist := os.Getenv("TARGET_ALLOWLIST")
+	os.Setenv("TARGET_ALLOWLIST", "127.0.0.0/8")
+	defer os.Setenv("TARGET_ALLOWLIST", allowlist)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+	var jobs []models.Job
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.com","callback_url":"`+receiver.URL+`"}`))
+		req.Header.Set("X-Auth-Token", token)
+		req.Header.Set("Idempotency-Key", "job-1")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, http.StatusAccepted)
+		var job models.Job
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+		jobs = append(jobs, job)
+	}
+	assert.Equal(t, jobs[1].ID, jobs[0].ID)
+	req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"https://www.example.org","callback_url":"`+receiver.URL+`"}`))
+	req.Header.Set("X-Auth-Token", token)
+	req.Header.Set("Idempotency-Key", "job-1")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusUnprocessableEntity)
+	assert.Eventually(t, func() bool {
+		job, _ := services.Jobs.Get(jobs[0].ID)
+		return job.CallbackDelivered
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, scans, 4)
+}
+
+func TestMetrics(t *testing.T) {
+	defer mockCalculateOverallScore(mockScoresResponse)()
+	for _, body := range []string{`{"url":"https://www.example.com"}`, `{"url":"example"}`} {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(body))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		http.HandlerFunc(GetScore).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	rr := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	metrics := rr.Body.String()
+	assert.Contains(t, metrics, "# TYPE snift_scans_total counter")
+	assert.Contains(t, metrics, `snift_scan_errors_total{type="invalid_url"}`)
+	assert.Contains(t, metrics, "# TYPE snift_scan_duration_seconds histogram")
+	assert.Contains(t, metrics, "snift_scan_duration_seconds_count")
+}
+
+func TestPreflightScore(t *testing.T) {
+	original := runPreflight
+	runPreflight = func(preflightURL string) (*models.Preflight, error) {
+		return &models.Preflight{URL: preflightURL, Host: "www.example.com", Port: "443", Resolved: true, Reachable: true, IPs: []string{"93.184.216.34"}}, nil
+	}
+	defer func() { runPreflight = original }()
+
+	req, _ := http.NewRequest("GET", "/scores/preflight?url=https://www.example.com", nil)
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(PreflightScore).ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusOK)
+	var preflight models.Preflight
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &preflight))
+	assert.True(t, preflight.Reachable)
+	assert.Equal(t, preflight.URL, "https://www.example.com")
+
+	req, _ = http.NewRequest("GET", "/scores/preflight?url=example", nil)
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(PreflightScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestScoresInternalTarget(t *testing.T) {
+	original := calculateOverallScore
+	defer func() { calculateOverallScore = original }()
+	calculateOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		return nil, fmt.Errorf("169.254.169.254: %w", utils.ErrPrivateTarget)
+	}
+
+	req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"http://169.254.169.254/latest/meta-data/"}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Equal(t, rr.Body.String(), `{"error":"Internal targets cannot be scanned"}`)
+
+	originalPreflight := runPreflight
+	defer func() { runPreflight = originalPreflight }()
+	runPreflight = func(preflightURL string) (*models.Preflight, error) {
+		return nil, utils.ErrPrivateTarget
+	}
+	req, _ = http.NewRequest("GET", "/scores/preflight?url=http://localhost", nil)
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(PreflightScore).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+	assert.Equal(t, rr.Body.String(), `{"error":"Internal targets cannot be scanned"}`)
+}
+
+func TestScoresDomainLists(t *testing.T) {
+	defer mockCalculateOverallScore(mockScoresResponse)()
+	defer os.Unsetenv("DOMAIN_ALLOWLIST")
+	defer os.Unsetenv("DOMAIN_DENYLIST")
+	os.Setenv("DOMAIN_ALLOWLIST", "*.example.com")
+	os.Setenv("DOMAIN_DENYLIST", "admin.example.com")
+
+	for _, test := range []struct {
+		url  string
+		code int
+	}{
+		{"https://www.example.com", http.StatusOK},
+		{"https://admin.example.com", http.StatusForbidden},
+		{"https://www.example.org", http.StatusForbidden},
+	} {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"`+test.url+`"}`))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, test.code, test.url)
+	}
+}
+
+func TestVerifyDomain(t *testing.T) {
+	original := isDomainVerified
+	defer func() { isDomainVerified = original }()
+	isDomainVerified = func(host string) bool { return host == "example.com" }
+
+	for _, domain := range []string{"Example.com", "example.org"} {
+		req, _ := http.NewRequest("POST", "/verify", strings.NewReader(`{"domain":"`+domain+`"}`))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(VerifyDomain).ServeHTTP(rr, req)
+
+		assert.Equal(t, rr.Code, http.StatusOK)
+		var verification models.Verification
+		assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &verification))
+		assert.Equal(t, verification.Domain, strings.ToLower(domain))
+		assert.Equal(t, verification.Record, "snift-verify="+verification.Token)
+		assert.Equal(t, verification.Verified, verification.Domain == "example.com")
+	}
+
+	req, _ := http.NewRequest("POST", "/verify", strings.NewReader(`{"domain":"93.184.216.34"}`))
+	req.Header.Set("X-Auth-Token", getTestToken(t))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(VerifyDomain).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusBadRequest)
+}
+
+func TestScoresUnverifiedDomain(t *testing.T) {
+	defer mockCalculateOverallScore(mockScoresResponse)()
+	original := isVerifiedURL
+	defer func() { isVerifiedURL = original }()
+	isVerifiedURL = func(rawURL string) bool { return rawURL == "https://www.example.com" }
+
+	for _, test := range []struct {
+		url  string
+		code int
+	}{
+		{"https://www.example.com", http.StatusOK},
+		{"https://www.example.org", http.StatusForbidden},
+	} {
+		req, _ := http.NewRequest("POST", "/scores", strings.NewReader(`{"url":"`+test.url+`"}`))
+		req.Header.Set("X-Auth-Token", getTestToken(t))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(GetScore).ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, test.code, test.url)
+	}
 }
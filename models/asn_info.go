@@ -0,0 +1,8 @@
+package models
+
+// ASNInfo holds the Autonomous System an IP address is announced from, identifying the hosting provider or CDN
+type ASNInfo struct {
+	Number       int    `json:"number"`
+	Organization string `json:"organization"`
+	Country      string `json:"country,omitempty"`
+}
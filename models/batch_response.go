@@ -0,0 +1,15 @@
+package models
+
+// BatchResult holds the outcome of the scan of a URL of a batch, either its scores or the error that prevented them
+type BatchResult struct {
+	// Line is the line of the URL in the uploaded file
+	Line   int     `json:"line"`
+	URL    string  `json:"url"`
+	Scores *Scores `json:"scores,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// BatchResponse holds the results of a batch scan, in the order the URLs were listed
+type BatchResponse struct {
+	Results []*BatchResult `json:"results"`
+}
@@ -1,16 +1,48 @@
 package models
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"io"
+	"math"
 	"net"
+	"strings"
+	"syscall"
 	"time"
 )
 
 // TimeoutSeconds references the total Time Out duration for the Handshake
 var TimeoutSeconds = 3
 
-//Cert holds the certificate details
+// HandshakeTimeout bounds a single TLS Handshake once the connection is open, within the total of TimeoutSeconds
+var HandshakeTimeout = 5 * time.Second
+
+// HandshakeRetries is the number of times a Handshake failing on a transient network error is retried
+var HandshakeRetries = 2
+
+// HandshakeRetryBackoff is the delay before the first retry of a Handshake, doubled on every subsequent attempt
+var HandshakeRetryBackoff = 250 * time.Millisecond
+
+// RootCAs are the roots the certificate chains are verified against, the system pool when it is nil
+var RootCAs *x509.CertPool
+
+// MaxValidityDays is the longest total validity of a certificate, those valid for longer are flagged with
+// OverlongValidity. It defaults to the 398 days allowed by the CA/Browser Forum Baseline Requirements
+var MaxValidityDays = 398
+
+// DialContext opens the connection used for the TLS Handshake, it is replaced to route the connection through a proxy
+var DialContext = (&net.Dialer{}).DialContext
+
+// ClientCertificates returns the client certificates presented in the TLS Handshakes with host, it is replaced to
+// present a client certificate to the hosts requiring mutual TLS
+var ClientCertificates = func(host string) []tls.Certificate {
+	return nil
+}
+
+// Cert holds the certificate details
 type Cert struct {
 	DomainName         string   `json:"domain_name"`
 	IP                 string   `json:"ip_address"`
@@ -21,43 +53,370 @@ type Cert struct {
 	SANs               []string `json:"sans"`
 	NotBefore          string   `json:"not_before"`
 	NotAfter           string   `json:"not_after"`
+	// ValidityDays is the total validity of the certificate, from NotBefore to NotAfter, and OverlongValidity is true
+	// when it exceeds MaxValidityDays
+	ValidityDays     int  `json:"validity_days"`
+	OverlongValidity bool `json:"overlong_validity"`
+	// HostMatchesSAN is false when the certificate is not valid for the requested host
+	HostMatchesSAN bool `json:"host_matches_san"`
+	IsWildcard     bool `json:"is_wildcard"`
+	IsSelfSigned   bool `json:"is_self_signed"`
+	// Chain lists the certificates from the leaf up to the root
+	Chain []CertSummary `json:"chain"`
+	// OCSPStapled is true when the server staples an OCSP response to the Handshake
+	OCSPStapled bool `json:"ocsp_stapled"`
+	// KeyUsage and ExtKeyUsage list the usages the leaf certificate is restricted to, by their RFC 5280 names
+	KeyUsage    []string `json:"key_usage"`
+	ExtKeyUsage []string `json:"ext_key_usage"`
+	// MissingServerAuth is true when the extended key usages do not allow the certificate to authenticate a server
+	MissingServerAuth bool `json:"missing_server_auth"`
+	// Verified is true only when the chain verifies up to a trusted root and the certificate is valid for the host, the
+	// details of a certificate failing validation are still reported, along with the validation failure in Error and
+	// ErrorType
+	Verified bool `json:"verified"`
+	// Error is the reason the certificate could not be retrieved or verified, and ErrorType its class, one of the CertError types
+	Error     string `json:"error,omitempty"`
+	ErrorType string `json:"error_type,omitempty"`
+}
+
+// Classes of the failures to retrieve a certificate, reported through Cert.ErrorType
+const (
+	CertErrorExpired          = "expired"
+	CertErrorInvalid          = "invalid_certificate"
+	CertErrorUntrustedRoot    = "untrusted_root"
+	CertErrorHostnameMismatch = "hostname_mismatch"
+	CertErrorTimeout          = "timeout"
+	CertErrorUnreachable      = "unreachable"
+	CertErrorHandshake        = "handshake_failure"
+	CertErrorUnknown          = "unknown"
+)
+
+// GetCertErrorType classifies the failure to retrieve a certificate, telling the certificates that fail validation
+// apart from the hosts that cannot be reached
+func GetCertErrorType(err error) string {
+	var invalidError x509.CertificateInvalidError
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var hostnameError x509.HostnameError
+	var netError net.Error
+	var opError *net.OpError
+	var alertError tls.AlertError
+	var recordHeaderError tls.RecordHeaderError
+	switch {
+	case errors.As(err, &invalidError):
+		if invalidError.Reason == x509.Expired {
+			return CertErrorExpired
+		}
+		return CertErrorInvalid
+	case errors.As(err, &unknownAuthorityError):
+		return CertErrorUntrustedRoot
+	case errors.As(err, &hostnameError):
+		return CertErrorHostnameMismatch
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netError) && netError.Timeout()):
+		return CertErrorTimeout
+	case errors.As(err, &alertError) || errors.As(err, &recordHeaderError) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return CertErrorHandshake
+	case errors.As(err, &opError):
+		return CertErrorUnreachable
+	}
+	return CertErrorUnknown
+}
+
+// KeyUsageNames maps the key usage bits to their RFC 5280 names, in the order of the bits
+var KeyUsageNames = []struct {
+	Usage x509.KeyUsage
+	Name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+// ExtKeyUsageNames maps the extended key usages to their RFC 5280 names, or the names of their vendor extensions
+var ExtKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "anyExtendedKeyUsage",
+	x509.ExtKeyUsageServerAuth:                     "serverAuth",
+	x509.ExtKeyUsageClientAuth:                     "clientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "codeSigning",
+	x509.ExtKeyUsageEmailProtection:                "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:                   "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "OCSPSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "msSGC",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "nsSGC",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "msCodeCom",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "msKernelCode",
+}
+
+// getKeyUsage returns the names of the key usages of the certificate
+func getKeyUsage(cert *x509.Certificate) []string {
+	usages := []string{}
+	for _, usage := range KeyUsageNames {
+		if cert.KeyUsage&usage.Usage != 0 {
+			usages = append(usages, usage.Name)
+		}
+	}
+	return usages
+}
+
+// getExtKeyUsage returns the names of the extended key usages of the certificate, the unknown ones by their OID
+func getExtKeyUsage(cert *x509.Certificate) []string {
+	usages := []string{}
+	for _, usage := range cert.ExtKeyUsage {
+		usages = append(usages, ExtKeyUsageNames[usage])
+	}
+	for _, oid := range cert.UnknownExtKeyUsage {
+		usages = append(usages, oid.String())
+	}
+	return usages
+}
+
+// allowsServerAuth returns true when the certificate may authenticate a server, which a certificate without
+// extended key usages may do as it is not restricted to any usage
+func allowsServerAuth(cert *x509.Certificate) bool {
+	if len(cert.ExtKeyUsage) == 0 && len(cert.UnknownExtKeyUsage) == 0 {
+		return true
+	}
+	for _, usage := range cert.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageServerAuth || usage == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
+
+// CertSummary holds the details of a single certificate of the chain
+type CertSummary struct {
+	Subject   string `json:"subject"`
+	Issuer    string `json:"issuer"`
+	NotBefore string `json:"not_before"`
+	NotAfter  string `json:"not_after"`
+	IsLeaf    bool   `json:"is_leaf"`
+	IsRoot    bool   `json:"is_root"`
+}
+
+// getChain summarizes the chain up to the trusted root when the presented certificates verify,
+// or the presented certificates as they are otherwise, in which case a root is only marked when the server sent it
+func getChain(certChain []*x509.Certificate) []CertSummary {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certChain[1:] {
+		intermediates.AddCert(cert)
+	}
+	if verifiedChains, err := certChain[0].Verify(x509.VerifyOptions{Roots: RootCAs, Intermediates: intermediates}); err == nil {
+		certChain = verifiedChains[0]
+	}
+	var loc = time.UTC
+	chain := make([]CertSummary, 0, len(certChain))
+	for i, cert := range certChain {
+		chain = append(chain, CertSummary{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			NotBefore: cert.NotBefore.In(loc).String(),
+			NotAfter:  cert.NotAfter.In(loc).String(),
+			IsLeaf:    i == 0,
+			IsRoot:    i == len(certChain)-1 && bytes.Equal(cert.RawIssuer, cert.RawSubject),
+		})
+	}
+	return chain
+}
+
+// getValidityDays returns the total validity of the certificate in days, a started day counting as a whole one
+func getValidityDays(cert *x509.Certificate) int {
+	return int(math.Ceil(cert.NotAfter.Sub(cert.NotBefore).Hours() / 24))
+}
+
+// isWildcard returns true when the Common Name or any of the SANs is a wildcard name
+func isWildcard(cert *x509.Certificate) bool {
+	for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+		if strings.HasPrefix(name, "*.") {
+			return true
+		}
+	}
+	return false
+}
+
+// isSelfSigned returns true when the certificate is its own issuer and is not one of the trusted roots
+func isSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) || cert.CheckSignatureFrom(cert) != nil {
+		return false
+	}
+	_, err := cert.Verify(x509.VerifyOptions{Roots: RootCAs})
+	return err != nil
 }
 
-var serverCert = func(host string, port string) ([]*x509.Certificate, string, error) {
-	d := &net.Dialer{
-		Timeout: time.Duration(TimeoutSeconds) * time.Second,
+// verifyCertificateChain verifies the chain presented by the server without checking the hostname, which
+// GetCertificateContext compares once the details are collected. A certificate restricted to other usages is reported
+// through MissingServerAuth, rather than failing the Handshake
+func verifyCertificateChain(state tls.ConnectionState) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("no certificates presented by the server")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
 	}
-	conn, err := tls.DialWithDialer(d, "tcp", host+":"+port, &tls.Config{
-		InsecureSkipVerify: false,
+	_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         RootCAs,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
 	})
+	return err
+}
+
+// handshake opens a connection to host and performs a TLS Handshake with the config, retrying with an exponential
+// backoff when it fails on a transient network error. Every attempt shares the deadline of ctx, which bounds the total
+func handshake(ctx context.Context, host string, port string, config *tls.Config) (*tls.Conn, error) {
+	backoff := HandshakeRetryBackoff
+	for attempt := 0; ; attempt++ {
+		conn, err := dialHandshake(ctx, host, port, config)
+		if err == nil || attempt == HandshakeRetries || !isTransientNetworkError(err) {
+			return conn, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// dialHandshake performs a single Handshake, the connection is closed when it fails
+func dialHandshake(ctx context.Context, host string, port string, config *tls.Config) (*tls.Conn, error) {
+	rawConn, err := DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	if certificates := ClientCertificates(host); certificates != nil {
+		config = config.Clone()
+		config.Certificates = certificates
+	}
+	conn := tls.Client(rawConn, config)
+	handshakeCtx, cancel := context.WithTimeout(ctx, HandshakeTimeout)
+	defer cancel()
+	err = conn.HandshakeContext(handshakeCtx)
 	if err != nil {
-		return []*x509.Certificate{&x509.Certificate{}}, "", err
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// isTransientNetworkError returns true when the connection was reset or closed by the peer, which a new attempt may
+// not run into. A refused connection, a timeout or a certificate that fails validation is not retried
+func isTransientNetworkError(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+var serverCert = func(ctx context.Context, host string, port string) (tls.ConnectionState, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(TimeoutSeconds)*time.Second)
+	defer cancel()
+	var verifyErr error
+	conn, err := handshake(ctx, host, port, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true, // the chain is still verified, by verifyCertificateChain
+		// a certificate failing validation does not abort the Handshake, so that its details can still be reported
+		VerifyConnection: func(state tls.ConnectionState) error {
+			verifyErr = verifyCertificateChain(state)
+			return nil
+		},
+	})
+	if err != nil {
+		return tls.ConnectionState{}, "", err
 	}
 	defer conn.Close()
 
 	addr := conn.RemoteAddr()
 	ip, _, _ := net.SplitHostPort(addr.String())
-	cert := conn.ConnectionState().PeerCertificates
 
-	return cert, ip, nil
+	return conn.ConnectionState(), ip, verifyErr
+}
+
+// GetMaxTLSVersion performs a dedicated Handshake offering every TLS version from 1.0 to 1.3, the server picks the
+// highest version it supports. The certificate is not verified as only the version is of interest
+func GetMaxTLSVersion(host string, port string) (uint16, error) {
+	return GetMaxTLSVersionContext(context.Background(), host, port)
+}
+
+// GetMaxTLSVersionContext is GetMaxTLSVersion with the Handshake bound to ctx
+func GetMaxTLSVersionContext(ctx context.Context, host string, port string) (uint16, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(TimeoutSeconds)*time.Second)
+	defer cancel()
+	conn, err := handshake(ctx, host, port, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS10,
+		MaxVersion:         tls.VersionTLS13,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().Version, nil
+}
+
+// GetALPNProtocol performs a dedicated Handshake offering HTTP/2 and HTTP/1.1 through ALPN, and returns the protocol
+// selected by the server, empty when it does not support ALPN. The certificate is not verified as only the protocol is
+// of interest
+func GetALPNProtocol(host string, port string) (string, error) {
+	return GetALPNProtocolContext(context.Background(), host, port)
+}
+
+// GetALPNProtocolContext is GetALPNProtocol with the Handshake bound to ctx
+func GetALPNProtocolContext(ctx context.Context, host string, port string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(TimeoutSeconds)*time.Second)
+	defer cancel()
+	conn, err := handshake(ctx, host, port, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"h2", "http/1.1"},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().NegotiatedProtocol, nil
 }
 
 // GetCertificate returns the Certificate associated with a host-port
 func GetCertificate(host string, port string, protocol string) (*Cert, error) {
+	return GetCertificateContext(context.Background(), host, port, protocol)
+}
+
+// GetCertificateContext is GetCertificate with the Handshake bound to ctx
+func GetCertificateContext(ctx context.Context, host string, port string, protocol string) (*Cert, error) {
 	// dont get certificates for non-https protocols, and when port number is 80
 	// trying to fetch certs with port:80 causes tls overload
 	if protocol != "https" || (protocol == "https" && port == "80") {
 		return nil, nil
 	}
-	certChain, ip, err := serverCert(host, port)
-	if err != nil {
-		return &Cert{DomainName: host}, err
+	// serverCert returns the presented certificates along with the error of a chain that fails validation
+	state, ip, err := serverCert(ctx, host, port)
+	if len(state.PeerCertificates) == 0 {
+		if err == nil {
+			err = errors.New("no certificates presented by the server")
+		}
+		return &Cert{DomainName: host, Error: err.Error(), ErrorType: GetCertErrorType(err)}, err
 	}
+	certChain := state.PeerCertificates
 	cert := certChain[0]
+	// a certificate issued for another name is never trusted, even when its chain verifies
+	hostnameErr := cert.VerifyHostname(host)
+	if err == nil {
+		err = hostnameErr
+	}
 
 	var loc = time.UTC // Setting UTC as Standard Time
 
-	return &Cert{
+	details := &Cert{
 		DomainName:         host,
 		IP:                 ip,
 		Issuer:             cert.Issuer.CommonName,
@@ -67,5 +426,21 @@ func GetCertificate(host string, port string, protocol string) (*Cert, error) {
 		SANs:               cert.DNSNames, // Subject Alternative Name
 		NotBefore:          cert.NotBefore.In(loc).String(),
 		NotAfter:           cert.NotAfter.In(loc).String(),
-	}, nil
+		ValidityDays:       getValidityDays(cert),
+		HostMatchesSAN:     hostnameErr == nil,
+		IsWildcard:         isWildcard(cert),
+		IsSelfSigned:       isSelfSigned(cert),
+		Chain:              getChain(certChain),
+		OCSPStapled:        len(state.OCSPResponse) > 0,
+		KeyUsage:           getKeyUsage(cert),
+		ExtKeyUsage:        getExtKeyUsage(cert),
+		MissingServerAuth:  !allowsServerAuth(cert),
+		Verified:           err == nil,
+	}
+	details.OverlongValidity = details.ValidityDays > MaxValidityDays
+	if err != nil {
+		details.Error = err.Error()
+		details.ErrorType = GetCertErrorType(err)
+	}
+	return details, err
 }
@@ -1,7 +1,21 @@
 package models
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -19,3 +33,559 @@ func TestGetCertificates(t *testing.T) {
 	assert.Nil(t, results)
 	assert.Nil(t, error)
 }
+
+func TestGetCertificatesIPv6(t *testing.T) {
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not available", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Listener = listener
+	server.StartTLS()
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// the handshake reaches the server, and only fails as the test certificate is untrusted
+	results, err := GetCertificate("::1", port, "https")
+	assert.Error(t, err)
+	var unknownAuthorityError x509.UnknownAuthorityError
+	assert.True(t, errors.As(err, &unknownAuthorityError), err)
+	assert.Equal(t, results.DomainName, "::1")
+}
+
+func TestGetCertificatesDialContext(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	var dialedAddress string
+	original := DialContext
+	DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		dialedAddress = address
+		return original(ctx, network, address)
+	}
+	defer func() { DialContext = original }()
+
+	_, err := GetCertificate(host, port, "https")
+	assert.Error(t, err)
+	assert.Equal(t, dialedAddress, server.Listener.Addr().String())
+}
+
+// createTestCertificate signs a certificate for the SANs with parent, or self-signs it when parent is nil
+func createTestCertificate(commonName string, sans []string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	return createTestCertificateWithUsage(commonName, sans, 0, nil, parent, parentKey)
+}
+
+// createTestCertificateWithUsage is createTestCertificate restricting the certificate to the key usages
+func createTestCertificateWithUsage(commonName string, sans []string, keyUsage x509.KeyUsage, extKeyUsage []x509.ExtKeyUsage, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		KeyUsage:              keyUsage,
+		ExtKeyUsage:           extKeyUsage,
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              sans,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+// createTestCertificateWithValidity is createTestCertificate valid for the duration, starting an hour ago
+func createTestCertificateWithValidity(commonName string, validity time.Duration, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(-time.Hour).Add(validity),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+// mockDialServer makes the Handshakes reach server whatever the host, which is still presented through SNI
+func mockDialServer(server *httptest.Server) func() {
+	original := DialContext
+	DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		return original(ctx, network, server.Listener.Addr().String())
+	}
+	return func() { DialContext = original }
+}
+
+func mockServerCert(chain ...*x509.Certificate) func() {
+	return mockServerState(tls.ConnectionState{PeerCertificates: chain})
+}
+
+func mockServerState(state tls.ConnectionState) func() {
+	original := serverCert
+	serverCert = func(ctx context.Context, host string, port string) (tls.ConnectionState, string, error) {
+		return state, "127.0.0.1", nil
+	}
+	return func() { serverCert = original }
+}
+
+func TestGetCertificatesHostMatchesSAN(t *testing.T) {
+	ca, caKey := createTestCertificate("Test CA", nil, nil, nil)
+
+	cert, _ := createTestCertificate("www.example.com", []string{"www.example.com", "example.com"}, ca, caKey)
+	defer mockServerCert(cert, ca)()
+	results, err := GetCertificate("example.com", "443", "https")
+	assert.NoError(t, err)
+	assert.True(t, results.HostMatchesSAN)
+
+	wildcard, _ := createTestCertificate("*.example.com", []string{"*.example.com"}, ca, caKey)
+	defer mockServerCert(wildcard, ca)()
+	results, _ = GetCertificate("shop.example.com", "443", "https")
+	assert.True(t, results.HostMatchesSAN)
+	// a wildcard only covers a single label
+	results, _ = GetCertificate("a.shop.example.com", "443", "https")
+	assert.False(t, results.HostMatchesSAN)
+
+	other, _ := createTestCertificate("shared.hosting.test", []string{"shared.hosting.test"}, ca, caKey)
+	defer mockServerCert(other, ca)()
+	results, _ = GetCertificate("example.com", "443", "https")
+	assert.False(t, results.HostMatchesSAN)
+}
+
+func TestGetCertificatesVerifiesChain(t *testing.T) {
+	// the test server certificate is only valid for example.com and the loopback addresses
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	assert.True(t, server.Certificate().VerifyHostname("www.example.org") != nil)
+
+	defer mockDialServer(server)()
+
+	// the chain is still verified, so the untrusted test certificate fails before the hostname is compared
+	_, err := GetCertificate("www.example.org", port, "https")
+	var unknownAuthorityError x509.UnknownAuthorityError
+	assert.True(t, errors.As(err, &unknownAuthorityError), err)
+}
+
+func TestGetCertificatesWildcardAndSelfSigned(t *testing.T) {
+	ca, caKey := createTestCertificate("Test CA", nil, nil, nil)
+
+	wildcard, _ := createTestCertificate("example.com", []string{"example.com", "*.example.com"}, ca, caKey)
+	defer mockServerCert(wildcard, ca)()
+	results, _ := GetCertificate("www.example.com", "443", "https")
+	assert.True(t, results.IsWildcard)
+	assert.False(t, results.IsSelfSigned)
+
+	selfSigned, _ := createTestCertificate("www.example.com", []string{"www.example.com"}, nil, nil)
+	defer mockServerCert(selfSigned)()
+	results, _ = GetCertificate("www.example.com", "443", "https")
+	assert.False(t, results.IsWildcard)
+	assert.True(t, results.IsSelfSigned)
+
+	issued, _ := createTestCertificate("www.example.com", []string{"www.example.com"}, ca, caKey)
+	defer mockServerCert(issued, ca)()
+	results, _ = GetCertificate("www.example.com", "443", "https")
+	assert.False(t, results.IsWildcard)
+	assert.False(t, results.IsSelfSigned)
+}
+
+func TestGetCertificatesOCSPStapled(t *testing.T) {
+	ca, caKey := createTestCertificate("Test CA", nil, nil, nil)
+	cert, _ := createTestCertificate("www.example.com", []string{"www.example.com"}, ca, caKey)
+
+	defer mockServerState(tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert, ca}, OCSPResponse: []byte{0x30}})()
+	results, _ := GetCertificate("www.example.com", "443", "https")
+	assert.True(t, results.OCSPStapled)
+
+	defer mockServerCert(cert, ca)()
+	results, _ = GetCertificate("www.example.com", "443", "https")
+	assert.False(t, results.OCSPStapled)
+}
+
+func TestGetMaxTLSVersion(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	version, err := GetMaxTLSVersion(host, port)
+	assert.NoError(t, err)
+	assert.Equal(t, version, uint16(tls.VersionTLS13))
+
+	legacyServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	legacyServer.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	legacyServer.StartTLS()
+	defer legacyServer.Close()
+	host, port, _ = net.SplitHostPort(legacyServer.Listener.Addr().String())
+	version, err = GetMaxTLSVersion(host, port)
+	assert.NoError(t, err)
+	assert.Equal(t, version, uint16(tls.VersionTLS12))
+}
+
+func TestGetALPNProtocol(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	protocol, err := GetALPNProtocol(host, port)
+	assert.NoError(t, err)
+	assert.Equal(t, protocol, "h2")
+
+	legacyServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer legacyServer.Close()
+	host, port, _ = net.SplitHostPort(legacyServer.Listener.Addr().String())
+	protocol, err = GetALPNProtocol(host, port)
+	assert.NoError(t, err)
+	assert.Equal(t, protocol, "http/1.1")
+}
+
+func TestGetCertificatesChain(t *testing.T) {
+	root, rootKey := createTestCertificate("Test Root CA", nil, nil, nil)
+	intermediate, intermediateKey := createTestCertificate("Test Intermediate CA", nil, root, rootKey)
+	intermediate.IsCA = true
+	leaf, _ := createTestCertificate("www.example.com", []string{"www.example.com"}, intermediate, intermediateKey)
+
+	defer mockServerCert(leaf, intermediate, root)()
+	results, err := GetCertificate("www.example.com", "443", "https")
+	assert.NoError(t, err)
+	assert.Equal(t, len(results.Chain), 3)
+	for i, subject := range []string{"CN=www.example.com", "CN=Test Intermediate CA", "CN=Test Root CA"} {
+		assert.Equal(t, results.Chain[i].Subject, subject)
+		assert.Equal(t, results.Chain[i].IsLeaf, i == 0)
+		assert.Equal(t, results.Chain[i].IsRoot, i == 2)
+	}
+	assert.Equal(t, results.Chain[0].Issuer, "CN=Test Intermediate CA")
+	assert.Equal(t, results.Chain[1].Issuer, "CN=Test Root CA")
+	assert.Equal(t, results.Chain[0].NotAfter, leaf.NotAfter.UTC().String())
+
+	// without the root, the top of the presented chain is not marked as the root
+	defer mockServerCert(leaf, intermediate)()
+	results, _ = GetCertificate("www.example.com", "443", "https")
+	assert.Equal(t, len(results.Chain), 2)
+	assert.True(t, results.Chain[0].IsLeaf)
+	assert.False(t, results.Chain[1].IsRoot)
+}
+
+func TestGetCertificatesKeyUsage(t *testing.T) {
+	ca, caKey := createTestCertificate("Test CA", nil, nil, nil)
+
+	server, _ := createTestCertificateWithUsage("www.example.com", []string{"www.example.com"},
+		x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}, ca, caKey)
+	defer mockServerCert(server, ca)()
+	results, err := GetCertificate("www.example.com", "443", "https")
+	assert.NoError(t, err)
+	assert.Equal(t, results.KeyUsage, []string{"digitalSignature", "keyEncipherment"})
+	assert.Equal(t, results.ExtKeyUsage, []string{"serverAuth", "clientAuth"})
+	assert.False(t, results.MissingServerAuth)
+
+	client, _ := createTestCertificateWithUsage("www.example.com", []string{"www.example.com"},
+		x509.KeyUsageDigitalSignature, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, ca, caKey)
+	defer mockServerCert(client, ca)()
+	results, _ = GetCertificate("www.example.com", "443", "https")
+	assert.Equal(t, results.ExtKeyUsage, []string{"clientAuth"})
+	assert.True(t, results.MissingServerAuth)
+
+	// a certificate without extended key usages is not restricted to any usage
+	unrestricted, _ := createTestCertificate("www.example.com", []string{"www.example.com"}, ca, caKey)
+	defer mockServerCert(unrestricted, ca)()
+	results, _ = GetCertificate("www.example.com", "443", "https")
+	assert.Equal(t, results.ExtKeyUsage, []string{})
+	assert.False(t, results.MissingServerAuth)
+}
+
+func mockTransientDialFailures(failures int, dials *int) func() {
+	original, originalBackoff := DialContext, HandshakeRetryBackoff
+	HandshakeRetryBackoff = time.Millisecond
+	DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		*dials++
+		if *dials <= failures {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNRESET}
+		}
+		return original(ctx, network, address)
+	}
+	return func() { DialContext, HandshakeRetryBackoff = original, originalBackoff }
+}
+
+func TestHandshakeRetry(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// the Handshake succeeds once the transient failure is retried
+	dials := 0
+	restore := mockTransientDialFailures(1, &dials)
+	version, err := GetMaxTLSVersion(host, port)
+	restore()
+	assert.NoError(t, err)
+	assert.Equal(t, version, uint16(tls.VersionTLS13))
+	assert.Equal(t, dials, 2)
+
+	// the retries are bounded
+	dials = 0
+	restore = mockTransientDialFailures(HandshakeRetries+1, &dials)
+	_, err = GetMaxTLSVersion(host, port)
+	restore()
+	assert.True(t, errors.Is(err, syscall.ECONNRESET), err)
+	assert.Equal(t, dials, HandshakeRetries+1)
+
+	// a certificate that fails validation is not retried
+	dials = 0
+	restore = mockTransientDialFailures(1, &dials)
+	_, err = GetCertificate(host, port, "https")
+	restore()
+	var unknownAuthorityError x509.UnknownAuthorityError
+	assert.True(t, errors.As(err, &unknownAuthorityError), err)
+	assert.Equal(t, dials, 2)
+}
+
+func TestGetCertificatesErrorType(t *testing.T) {
+	// a server presenting an untrusted certificate
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	results, err := GetCertificate(host, port, "https")
+	assert.Error(t, err)
+	assert.Equal(t, results.ErrorType, CertErrorUntrustedRoot)
+	assert.Equal(t, results.Error, err.Error())
+
+	// a host that does not accept connections
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	_, closedPort, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+	results, _ = GetCertificate("127.0.0.1", closedPort, "https")
+	assert.Equal(t, results.ErrorType, CertErrorUnreachable)
+
+	// a server that never completes the Handshake
+	silent, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer silent.Close()
+	_, silentPort, _ := net.SplitHostPort(silent.Addr().String())
+	originalTimeout := TimeoutSeconds
+	TimeoutSeconds = 1
+	results, _ = GetCertificate("127.0.0.1", silentPort, "https")
+	TimeoutSeconds = originalTimeout
+	assert.Equal(t, results.ErrorType, CertErrorTimeout)
+
+	// a server that does not speak TLS
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plain.Close()
+	_, plainPort, _ := net.SplitHostPort(plain.Listener.Addr().String())
+	results, _ = GetCertificate("127.0.0.1", plainPort, "https")
+	assert.Equal(t, results.ErrorType, CertErrorHandshake)
+
+	// a certificate for a name outside the constraints of its root, an expired one and one issued for another name,
+	// under trusted roots
+	root, rootKey := createTestCertificate("Internal Root CA", nil, nil, nil)
+	constrainedKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "Constrained Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		PermittedDNSDomains:   []string{"example.org"},
+	}
+	constrainedDER, _ := x509.CreateCertificate(rand.Reader, template, template, &constrainedKey.PublicKey, constrainedKey)
+	constrained, _ := x509.ParseCertificate(constrainedDER)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	roots.AddCert(constrained)
+	RootCAs = roots
+	defer func() { RootCAs = nil }()
+	leaf, leafKey := createTestCertificate("www.example.com", []string{"www.example.com"}, constrained, constrainedKey)
+	expired, expiredKey := createTestCertificate("www.example.com", []string{"www.example.com"}, root, rootKey)
+	expired.NotAfter = time.Now().Add(-time.Minute)
+	expiredDER, _ := x509.CreateCertificate(rand.Reader, expired, root, expired.PublicKey, rootKey)
+	other, otherKey := createTestCertificate("shared.hosting.test", []string{"shared.hosting.test"}, root, rootKey)
+	for _, test := range []struct {
+		certificate tls.Certificate
+		errorType   string
+	}{
+		{tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: leafKey}, CertErrorInvalid},
+		{tls.Certificate{Certificate: [][]byte{expiredDER}, PrivateKey: expiredKey}, CertErrorExpired},
+		{tls.Certificate{Certificate: [][]byte{other.Raw}, PrivateKey: otherKey}, CertErrorHostnameMismatch},
+	} {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.TLS = &tls.Config{Certificates: []tls.Certificate{test.certificate}}
+		server.StartTLS()
+		_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+		restore := mockDialServer(server)
+		results, err := GetCertificate("www.example.com", port, "https")
+		restore()
+		server.Close()
+		assert.Error(t, err)
+		assert.False(t, results.Verified)
+		assert.Equal(t, results.ErrorType, test.errorType, err)
+	}
+}
+
+func TestHandshakeTimeout(t *testing.T) {
+	// a server that accepts the connection but never completes the Handshake
+	silent, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer silent.Close()
+	_, silentPort, _ := net.SplitHostPort(silent.Addr().String())
+	originalTimeout := HandshakeTimeout
+	HandshakeTimeout = 50 * time.Millisecond
+	defer func() { HandshakeTimeout = originalTimeout }()
+
+	// the Handshake gives up well before the total timeout
+	start := time.Now()
+	_, err := GetMaxTLSVersion("127.0.0.1", silentPort)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.True(t, time.Since(start) < time.Duration(TimeoutSeconds)*time.Second)
+}
+
+func TestGetCertificatesUnverified(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "expired.example.com"},
+		DNSNames:     []string{"expired.example.com"},
+		NotBefore:    time.Now().Add(-48 * time.Hour),
+		NotAfter:     time.Now().Add(-24 * time.Hour),
+	}
+	der, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	server.StartTLS()
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// the details of the expired certificate are reported, marked as unverified
+	results, err := GetCertificate(host, port, "https")
+	assert.Error(t, err)
+	assert.False(t, results.Verified)
+	assert.Equal(t, results.CommonName, "expired.example.com")
+	assert.Equal(t, results.SANs, []string{"expired.example.com"})
+	assert.Equal(t, results.ErrorType, CertErrorExpired)
+	assert.NotEmpty(t, results.Error)
+
+	// a certificate is only marked as verified when its chain verifies
+	ca, caKey := createTestCertificate("Test CA", nil, nil, nil)
+	issued, _ := createTestCertificate("www.example.com", []string{"www.example.com"}, ca, caKey)
+	defer mockServerCert(issued, ca)()
+	results, err = GetCertificate("www.example.com", "443", "https")
+	assert.NoError(t, err)
+	assert.True(t, results.Verified)
+	assert.Empty(t, results.ErrorType)
+}
+
+func TestGetCertificatesRootCAs(t *testing.T) {
+	root, rootKey := createTestCertificate("Internal Root CA", nil, nil, nil)
+	leaf, leafKey := createTestCertificate("intranet.example.com", []string{"intranet.example.com"}, root, rootKey)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.Raw}, PrivateKey: leafKey}}}
+	server.StartTLS()
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	defer mockDialServer(server)()
+
+	// the internal root is not part of the system pool
+	results, _ := GetCertificate("intranet.example.com", port, "https")
+	assert.Equal(t, results.ErrorType, CertErrorUntrustedRoot)
+	assert.False(t, results.Verified)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	RootCAs = roots
+	defer func() { RootCAs = nil }()
+	results, err := GetCertificate("intranet.example.com", port, "https")
+	assert.NoError(t, err)
+	assert.True(t, results.Verified)
+	assert.Equal(t, results.Chain[len(results.Chain)-1].Subject, "CN=Internal Root CA")
+}
+
+func TestGetCertificatesHostnameMismatch(t *testing.T) {
+	root, rootKey := createTestCertificate("Internal Root CA", nil, nil, nil)
+	leaf, leafKey := createTestCertificate("shared.hosting.test", []string{"shared.hosting.test"}, root, rootKey)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.Raw}, PrivateKey: leafKey}}}
+	server.StartTLS()
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	defer mockDialServer(server)()
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	RootCAs = roots
+	defer func() { RootCAs = nil }()
+
+	// the chain verifies, but the certificate was issued for another name
+	results, err := GetCertificate("www.example.com", port, "https")
+	var hostnameError x509.HostnameError
+	assert.True(t, errors.As(err, &hostnameError), err)
+	assert.False(t, results.Verified)
+	assert.False(t, results.HostMatchesSAN)
+	assert.Equal(t, results.ErrorType, CertErrorHostnameMismatch)
+	assert.Equal(t, results.Error, err.Error())
+	assert.Equal(t, results.CommonName, "shared.hosting.test")
+
+	results, err = GetCertificate("shared.hosting.test", port, "https")
+	assert.NoError(t, err)
+	assert.True(t, results.Verified)
+}
+
+func TestGetCertificatesClientCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// over TLS 1.3 a missing client certificate is only reported after the Handshake completes on the client
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert, MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// a server requiring mutual TLS rejects the Handshake without a client certificate
+	_, err := GetMaxTLSVersion(host, port)
+	assert.Error(t, err)
+
+	client, clientKey := createTestCertificateWithUsage("snift-scanner", nil, 0, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, nil, nil)
+	var requestedHost string
+	original := ClientCertificates
+	ClientCertificates = func(host string) []tls.Certificate {
+		requestedHost = host
+		return []tls.Certificate{{Certificate: [][]byte{client.Raw}, PrivateKey: clientKey}}
+	}
+	defer func() { ClientCertificates = original }()
+	version, err := GetMaxTLSVersion(host, port)
+	assert.NoError(t, err)
+	assert.Equal(t, version, uint16(tls.VersionTLS12))
+	assert.Equal(t, requestedHost, host)
+	// the certificate of the test server is reported, rather than the failure of the Handshake
+	results, _ := GetCertificate(host, port, "https")
+	assert.Equal(t, results.ErrorType, CertErrorUntrustedRoot)
+}
+
+func TestGetCertificatesValidity(t *testing.T) {
+	ca, caKey := createTestCertificate("Test CA", nil, nil, nil)
+	day := 24 * time.Hour
+	for _, test := range []struct {
+		validity time.Duration
+		days     int
+		overlong bool
+	}{
+		{90 * day, 90, false},
+		{398 * day, 398, false},
+		// a started day counts as a whole one
+		{398*day + time.Second, 399, true},
+		{5 * 365 * day, 1825, true},
+	} {
+		cert := createTestCertificateWithValidity("www.example.com", test.validity, ca, caKey)
+		restore := mockServerCert(cert, ca)
+		results, err := GetCertificate("www.example.com", "443", "https")
+		restore()
+		assert.NoError(t, err)
+		assert.Equal(t, results.ValidityDays, test.days)
+		assert.Equal(t, results.OverlongValidity, test.overlong, test.days)
+	}
+
+	// the maximum validity is configurable
+	original := MaxValidityDays
+	defer func() { MaxValidityDays = original }()
+	MaxValidityDays = 90
+	defer mockServerCert(createTestCertificateWithValidity("www.example.com", 200*day, ca, caKey), ca)()
+	results, _ := GetCertificate("www.example.com", "443", "https")
+	assert.Equal(t, results.ValidityDays, 200)
+	assert.True(t, results.OverlongValidity)
+}
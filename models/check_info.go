@@ -0,0 +1,17 @@
+package models
+
+// CheckInfo describes a check of the scan, as listed in the catalog of checks
+type CheckInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MaxScore    int    `json:"max_score"`
+	// VariableMaxScore is true when the maximum score depends on the scanned site, e.g. its number of incidents
+	VariableMaxScore bool   `json:"variable_max_score,omitempty"`
+	Badge            string `json:"badge,omitempty"`
+	Critical         bool   `json:"critical,omitempty"`
+}
+
+// CheckCatalog holds the structure for the Checks API Response
+type CheckCatalog struct {
+	Checks []*CheckInfo `json:"checks"`
+}
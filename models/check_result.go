@@ -0,0 +1,45 @@
+package models
+
+import "sort"
+
+// CheckResult holds the outcome of an individual security check
+type CheckResult struct {
+	Name     string   `json:"name"`
+	Score    int      `json:"score"`
+	MaxScore int      `json:"max_score"`
+	Message  string   `json:"message,omitempty"`
+	Findings []string `json:"findings,omitempty"`
+	// Warnings report anomalies of the check's response headers, such as conflicting duplicates
+	Warnings    []string `json:"warnings,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+	// Critical checks cap the grade when they do not get the full score
+	Critical bool `json:"critical,omitempty"`
+	// Inconclusive checks could not tell whether the site passes them, they are left out of the maximum score
+	Inconclusive bool `json:"inconclusive,omitempty"`
+}
+
+// GetCheckResult returns a valid CheckResult instance
+func GetCheckResult(name string, score int, maxScore int) *CheckResult {
+	return &CheckResult{
+		Name:     name,
+		Score:    score,
+		MaxScore: maxScore,
+	}
+}
+
+// GetCheckRank returns the position of a check in an ordering, the checks it does not list come after the listed ones
+func GetCheckRank(order []string, name string) int {
+	for i, ordered := range order {
+		if ordered == name {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// SortChecks orders the checks listed in order first, in that order, the other ones keep their relative order
+func SortChecks(checks []*CheckResult, order []string) {
+	sort.SliceStable(checks, func(i, j int) bool {
+		return GetCheckRank(order, checks[i].Name) < GetCheckRank(order, checks[j].Name)
+	})
+}
@@ -0,0 +1,69 @@
+package models
+
+// CompareRequest holds the structure for Compare Scores API Request Body
+type CompareRequest struct {
+	FirstURL  string `json:"first_url"`
+	SecondURL string `json:"second_url"`
+}
+
+// CheckComparison holds the scores obtained by both URLs for a single check
+type CheckComparison struct {
+	Name        string `json:"name"`
+	FirstScore  int    `json:"first_score"`
+	SecondScore int    `json:"second_score"`
+	MaxScore    int    `json:"max_score"`
+	Delta       int    `json:"delta"`
+	Outperforms string `json:"outperforms"`
+}
+
+// CompareResponse holds the scores of both URLs along with the per-check differences
+type CompareResponse struct {
+	First      *Scores            `json:"first"`
+	Second     *Scores            `json:"second"`
+	ScoreDelta float64            `json:"score_delta"`
+	Checks     []*CheckComparison `json:"checks"`
+}
+
+func outperforms(delta int) string {
+	if delta > 0 {
+		return "first"
+	} else if delta < 0 {
+		return "second"
+	}
+	return "none"
+}
+
+// BuildCompareResponse builds the final api response for /scores/compare
+// Deltas are positive when the first URL scores higher than the second
+func BuildCompareResponse(first *Scores, second *Scores) *CompareResponse {
+	comparisons := make(map[string]*CheckComparison)
+	var checks []*CheckComparison
+	getComparison := func(check *CheckResult) *CheckComparison {
+		comparison, ok := comparisons[check.Name]
+		if !ok {
+			comparison = &CheckComparison{Name: check.Name}
+			comparisons[check.Name] = comparison
+			checks = append(checks, comparison)
+		}
+		if check.MaxScore > comparison.MaxScore {
+			comparison.MaxScore = check.MaxScore
+		}
+		return comparison
+	}
+	for _, check := range first.Checks {
+		getComparison(check).FirstScore = check.Score
+	}
+	for _, check := range second.Checks {
+		getComparison(check).SecondScore = check.Score
+	}
+	for _, comparison := range checks {
+		comparison.Delta = comparison.FirstScore - comparison.SecondScore
+		comparison.Outperforms = outperforms(comparison.Delta)
+	}
+	return &CompareResponse{
+		First:      first,
+		Second:     second,
+		ScoreDelta: first.Score - second.Score,
+		Checks:     checks,
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+// PageScore is the score of the response headers of a page of the scanned site
+type PageScore struct {
+	URL      string         `json:"url"`
+	Score    int            `json:"score"`
+	MaxScore int            `json:"max_score"`
+	Checks   []*CheckResult `json:"checks,omitempty"`
+	// Error is the reason the page could not be scored, its score is then left out of the aggregate
+	Error string `json:"error,omitempty"`
+}
+
+// Crawl holds the pages scored by crawling the internal links of the scanned site, the landing page first
+type Crawl struct {
+	Depth int          `json:"depth"`
+	Pages []*PageScore `json:"pages"`
+	// Score aggregates the response headers of every page scored, as the share of their maximum score
+	Score float64 `json:"score"`
+	// Truncated is true when the crawl stopped at the page limit or timed out before visiting every link found
+	Truncated bool `json:"truncated,omitempty"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// Kinds of the failures to reach a scanned site, a ScanError matches its kind through errors.Is
+var (
+	// ErrInvalidDomain is returned when the domain of the scanned site does not resolve
+	ErrInvalidDomain = errors.New("domain does not resolve")
+	// ErrUnreachable is returned when the connection to the scanned site is refused or cannot be established
+	ErrUnreachable = errors.New("site is unreachable")
+	// ErrTimeout is returned when the scanned site does not answer in time
+	ErrTimeout = errors.New("site did not answer in time")
+)
+
+// ScanError is the failure to reach a scanned site, of one of the kinds ErrInvalidDomain, ErrUnreachable or ErrTimeout.
+// It keeps the message of the underlying error, which errors.Is and errors.As reach as well
+type ScanError struct {
+	Kind error
+	Err  error
+}
+
+func (scanError *ScanError) Error() string {
+	return scanError.Err.Error()
+}
+
+// Unwrap returns the kind of the failure along with the underlying error
+func (scanError *ScanError) Unwrap() []error {
+	return []error{scanError.Kind, scanError.Err}
+}
+
+// ClassifyScanError returns a ScanError of the kind of a network failure, a domain that does not resolve, a connection
+// that cannot be established or a timeout. Other errors, such as a certificate failing validation, are returned as
+// they are
+func ClassifyScanError(err error) error {
+	var scanError *ScanError
+	if err == nil || errors.As(err, &scanError) {
+		return err
+	}
+	var dnsError *net.DNSError
+	var netError net.Error
+	var opError *net.OpError
+	switch {
+	case errors.As(err, &dnsError) && (dnsError.IsNotFound || dnsError.Err == "no such host"):
+		return &ScanError{Kind: ErrInvalidDomain, Err: err}
+	case errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netError) && netError.Timeout()):
+		return &ScanError{Kind: ErrTimeout, Err: err}
+	case errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EHOSTUNREACH) ||
+		errors.Is(err, syscall.ENETUNREACH) || errors.As(err, &opError):
+		return &ScanError{Kind: ErrUnreachable, Err: err}
+	}
+	return err
+}
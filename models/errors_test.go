@@ -0,0 +1,39 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyScanError(t *testing.T) {
+	for _, expected := range []struct {
+		err  error
+		kind error
+	}{
+		{&net.DNSError{Err: "no such host", Name: "www.example.invalid", IsNotFound: true}, ErrInvalidDomain},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}, ErrInvalidDomain},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, ErrUnreachable},
+		{fmt.Errorf("Get \"https://www.example.com\": %w", context.DeadlineExceeded), ErrTimeout},
+		{&net.DNSError{Err: "i/o timeout", IsTimeout: true}, ErrTimeout},
+	} {
+		err := ClassifyScanError(expected.err)
+		var scanError *ScanError
+		assert.True(t, errors.As(err, &scanError))
+		assert.True(t, errors.Is(err, expected.kind))
+		assert.True(t, errors.Is(err, expected.err))
+		assert.Equal(t, err.Error(), expected.err.Error())
+	}
+
+	certificateError := errors.New("x509: certificate signed by unknown authority")
+	assert.Equal(t, ClassifyScanError(certificateError), certificateError)
+	assert.Nil(t, ClassifyScanError(nil))
+	scanError := ClassifyScanError(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})
+	wrapped := fmt.Errorf("retrying: %w", scanError)
+	assert.Equal(t, ClassifyScanError(wrapped), wrapped)
+}
@@ -0,0 +1,69 @@
+package models
+
+// GradeThreshold holds the minimum score fraction needed for a grade
+type GradeThreshold struct {
+	Grade    string
+	MinScore float64
+}
+
+// GradeThresholds is used to map the overall score to a letter grade, ordered from the best grade
+var GradeThresholds = []GradeThreshold{
+	{Grade: "A", MinScore: 0.9},
+	{Grade: "B", MinScore: 0.8},
+	{Grade: "C", MinScore: 0.7},
+	{Grade: "D", MinScore: 0.6},
+}
+
+// LowestGrade is given to scores below every threshold
+const LowestGrade = "F"
+
+// CriticalFailureMaxGrade is the best grade possible when a critical check fails
+const CriticalFailureMaxGrade = "B"
+
+// hasCriticalFailure returns true when any critical check did not get its full score
+func hasCriticalFailure(checks []*CheckResult) bool {
+	for _, check := range checks {
+		if check.Critical && check.Score < check.MaxScore {
+			return true
+		}
+	}
+	return false
+}
+
+// gradeRank returns the position of a grade in the thresholds, lower is better
+func gradeRank(grade string, thresholds []GradeThreshold) int {
+	for rank, threshold := range thresholds {
+		if threshold.Grade == grade {
+			return rank
+		}
+	}
+	return len(thresholds)
+}
+
+// GetGrade returns the letter grade of a score, capped at CriticalFailureMaxGrade when a critical check failed
+func GetGrade(score float64, criticalFailure bool) string {
+	return GetGradeWithThresholds(score, criticalFailure, GradeThresholds)
+}
+
+// GetGradeWithThresholds returns the letter grade of a score with the thresholds of a ScoringProfile
+func GetGradeWithThresholds(score float64, criticalFailure bool, thresholds []GradeThreshold) string {
+	grade := LowestGrade
+	for _, threshold := range thresholds {
+		if score >= threshold.MinScore {
+			grade = threshold.Grade
+			break
+		}
+	}
+	if criticalFailure {
+		return CapGrade(grade, CriticalFailureMaxGrade, thresholds)
+	}
+	return grade
+}
+
+// CapGrade returns maxGrade when grade is better than it, grade otherwise
+func CapGrade(grade string, maxGrade string, thresholds []GradeThreshold) string {
+	if gradeRank(grade, thresholds) < gradeRank(maxGrade, thresholds) {
+		return maxGrade
+	}
+	return grade
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetGrade(t *testing.T) {
+	grades := map[float64]string{
+		1:    "A",
+		0.9:  "A",
+		0.89: "B",
+		0.8:  "B",
+		0.75: "C",
+		0.6:  "D",
+		0.59: "F",
+		0:    "F",
+	}
+	for score, grade := range grades {
+		assert.Equal(t, GetGrade(score, false), grade, score)
+	}
+}
+
+func TestGetGradeCriticalFailure(t *testing.T) {
+	assert.Equal(t, GetGrade(0.95, true), "B")
+	assert.Equal(t, GetGrade(0.85, true), "B")
+	assert.Equal(t, GetGrade(0.65, true), "D")
+
+	protocolCheck := GetCheckResult("Protocol", 0, 5)
+	protocolCheck.Critical = true
+	scores := GetScores("http://www.example.com", 0.92, nil, []*CheckResult{protocolCheck, GetCheckResult("Content-Security-Policy", 5, 5)})
+	assert.Equal(t, scores.Grade, "B")
+
+	// failing non-critical checks do not cap the grade
+	scores = GetScores("https://www.example.com", 0.92, nil, []*CheckResult{GetCheckResult("Content-Security-Policy", 3, 5)})
+	assert.Equal(t, scores.Grade, "A")
+}
+
+func TestCapGrade(t *testing.T) {
+	assert.Equal(t, CapGrade("A", "D", GradeThresholds), "D")
+	assert.Equal(t, CapGrade("D", "D", GradeThresholds), "D")
+	assert.Equal(t, CapGrade("F", "D", GradeThresholds), "F")
+
+	builder := NewScoreBuilder()
+	builder.AddCheck(GetCheckResult("Content-Security-Policy", 5, 5))
+	builder.SetGradeCap("C")
+	scores := builder.Finalize("http://www.example.com")
+	assert.Equal(t, scores.Score, 1.0)
+	assert.Equal(t, scores.Grade, "C")
+	assert.True(t, scores.GradeCapped)
+
+	builder.SetGradeCap("")
+	scores = builder.Finalize("http://www.example.com")
+	assert.Equal(t, scores.Grade, "A")
+	assert.False(t, scores.GradeCapped)
+}
@@ -0,0 +1,8 @@
+package models
+
+// Health holds the status of the service reported by /healthz
+type Health struct {
+	Status             string `json:"status"`
+	InFlightScans      int    `json:"in_flight_scans"`
+	MaxConcurrentScans int    `json:"max_concurrent_scans"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// HostInfo holds every address a host resolves to, used to spot shared hosting or CDN usage
+type HostInfo struct {
+	Host      string       `json:"host"`
+	Addresses []*IPAddress `json:"addresses"`
+}
+
+// IPAddress holds a resolved IP address along with its reverse DNS (PTR) names
+type IPAddress struct {
+	IP         string   `json:"ip"`
+	ReverseDNS []string `json:"reverse_dns"`
+	ASN        *ASNInfo `json:"asn,omitempty"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Statuses of a scan Job
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Job tracks a scan run asynchronously, whose result is posted to its callback URL once done
+type Job struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	URL         string     `json:"url"`
+	CallbackURL string     `json:"callback_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// Result is the scores response of a completed job, Error the message of a failed one
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+	// CallbackAttempts counts the requests posting the job to its callback URL, CallbackDelivered is true once
+	// one of them succeeded
+	CallbackAttempts  int  `json:"callback_attempts"`
+	CallbackDelivered bool `json:"callback_delivered"`
+	// IdempotencyKey is the key of the request that started the job, if any
+	IdempotencyKey string `json:"-"`
+	// IdempotencyFingerprint identifies the URL and options of the request that started the job along with its key
+	IdempotencyFingerprint string `json:"-"`
+}
@@ -0,0 +1,49 @@
+package models
+
+// Observations holds the raw signals observed by a scan, from which its score can be recomputed under the current
+// scoring configuration without any network I/O
+type Observations struct {
+	// URL is the scanned URL as requested, PunycodeURL its ASCII-compatible form when its host is internationalized
+	URL         string `json:"url"`
+	PunycodeURL string `json:"punycode_url,omitempty"`
+	// Protocol is the scheme of the scanned URL, ResponseProtocol the scheme of the response the headers came from
+	Protocol         string `json:"protocol"`
+	ResponseProtocol string `json:"response_protocol"`
+	// Headers are the response headers, the values of a repeated header joined, and HeaderWarnings the anomalies
+	// detected from their raw values by check
+	Headers        map[string]string   `json:"headers"`
+	HeaderWarnings map[string][]string `json:"header_warnings,omitempty"`
+	// Proto is the HTTP version of the request, MaxProto the highest one the server supports through ALPN
+	Proto    string `json:"proto"`
+	MaxProto string `json:"max_proto,omitempty"`
+	// TLSVersion is the TLS version of the request, MaxTLSVersion the highest one the server supports, both 0 over http
+	TLSVersion    uint16 `json:"tls_version,omitempty"`
+	MaxTLSVersion uint16 `json:"max_tls_version,omitempty"`
+	OCSPStapled   bool   `json:"ocsp_stapled"`
+	// CertificateTransparency is true when the server delivered Signed Certificate Timestamps
+	CertificateTransparency bool `json:"certificate_transparency"`
+	// TLSHello holds the TLS compression and renegotiation support of the server, nil when unknown
+	TLSHello *TLSHello `json:"tls_hello,omitempty"`
+	// SessionResumption is true when the server resumed a TLS session through a session ticket
+	SessionResumption bool `json:"session_resumption"`
+	// CORSOriginReflected is true when the response allowed the random Origin sent in the request
+	CORSOriginReflected bool `json:"cors_origin_reflected"`
+	// HTTPSRedirect is true when the plain HTTP site redirects to HTTPS, nil when it is unknown
+	HTTPSRedirect *bool `json:"https_redirect,omitempty"`
+	// RedirectChain lists the URLs visited by the request, starting with the requested one
+	RedirectChain         []string `json:"redirect_chain"`
+	CrossHostRedirect     bool     `json:"cross_host_redirect"`
+	RedirectLimitExceeded bool     `json:"redirect_limit_exceeded"`
+	RequestMethod         string   `json:"request_method"`
+	// Checks are the results of the checks not derived from the response, such as those of the DNS records, kept
+	// as they were scored along with the badges they earned
+	Checks []*CheckResult `json:"checks,omitempty"`
+	Badges []*Badge       `json:"badges,omitempty"`
+	Cert   *Cert          `json:"certificate_details,omitempty"`
+	// Incidents are the previous vulnerabilities of the host
+	Incidents []Incident `json:"security_incidents,omitempty"`
+	HostInfo  *HostInfo  `json:"host_info,omitempty"`
+	// Profile is the name of the ScoringProfile the scan asked for, Only the checks it was restricted to
+	Profile string   `json:"profile,omitempty"`
+	Only    []string `json:"only,omitempty"`
+}
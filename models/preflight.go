@@ -0,0 +1,12 @@
+package models
+
+// Preflight holds the reachability of a URL, checked without scoring it
+type Preflight struct {
+	URL       string   `json:"url"`
+	Host      string   `json:"host"`
+	Port      string   `json:"port"`
+	Resolved  bool     `json:"resolved"`
+	Reachable bool     `json:"reachable"`
+	IPs       []string `json:"ip_addresses"`
+	Error     string   `json:"error,omitempty"`
+}
@@ -0,0 +1,107 @@
+package models
+
+// ScanOptions holds the options of a scan, the zero value runs every check
+type ScanOptions struct {
+	// Skip lists the groups of checks left out of the scan and of its maximum score
+	Skip []string `json:"skip,omitempty"`
+	// Only lists the checks run by the scan, by name or alias, every other check is left out of it and of its maximum score
+	Only []string `json:"only,omitempty"`
+	// Profile is the name of the scoring profile, the default profile is used when it is empty
+	Profile string `json:"profile,omitempty"`
+	// DKIMSelectors replaces the configured or common selectors probed by the DKIM check
+	DKIMSelectors []string `json:"dkim_selectors,omitempty"`
+	// IP pins the connections of the scan to a pre-resolved address instead of the addresses of the domain
+	IP string `json:"ip,omitempty"`
+	// SNI is the domain presented through SNI and the Host header when the URL targets an IP
+	SNI string `json:"sni,omitempty"`
+	// Headers are sent along with the request whose response headers are scored, e.g. to score a page behind a login
+	Headers map[string]string `json:"headers,omitempty"`
+	// IncludeRaw attaches the response headers observed by the scan to its response, the sensitive ones redacted
+	IncludeRaw bool `json:"include_raw,omitempty"`
+	// CrawlDepth is the number of levels of same-origin links followed from the landing page, whose pages are
+	// scored as well, 0 scores the landing page only
+	CrawlDepth int `json:"crawl_depth,omitempty"`
+	// IdempotencyKey is stored with the result of the scan, so that a request repeating the key is answered with it
+	IdempotencyKey string `json:"-"`
+	// IdempotencyFingerprint identifies the request the idempotency key was sent with, a request repeating the key
+	// with another URL or other options is rejected rather than answered with the stored result
+	IdempotencyFingerprint string `json:"-"`
+	// OnCheck is called with every check as soon as it completes, before the overall score is calculated
+	OnCheck func(check *CheckResult) `json:"-"`
+}
+
+// GetDKIMSelectors returns the DKIM selectors of the options, a nil ScanOptions has none
+func (options *ScanOptions) GetDKIMSelectors() []string {
+	if options == nil {
+		return nil
+	}
+	return options.DKIMSelectors
+}
+
+// GetProfile returns the name of the scoring profile of the options, a nil ScanOptions uses the default profile
+func (options *ScanOptions) GetProfile() string {
+	if options == nil {
+		return ""
+	}
+	return options.Profile
+}
+
+// GetHeaders returns the custom request headers of the options, a nil ScanOptions has none
+func (options *ScanOptions) GetHeaders() map[string]string {
+	if options == nil {
+		return nil
+	}
+	return options.Headers
+}
+
+// IsRawIncluded returns true when the observed response headers are attached to the response, a nil ScanOptions
+// leaves them out
+func (options *ScanOptions) IsRawIncluded() bool {
+	return options != nil && options.IncludeRaw
+}
+
+// GetCrawlDepth returns the crawl depth of the options, a nil ScanOptions does not crawl
+func (options *ScanOptions) GetCrawlDepth() int {
+	if options == nil {
+		return 0
+	}
+	return options.CrawlDepth
+}
+
+// GetIdempotencyKey returns the idempotency key of the options, a nil ScanOptions has none
+func (options *ScanOptions) GetIdempotencyKey() string {
+	if options == nil {
+		return ""
+	}
+	return options.IdempotencyKey
+}
+
+// GetIdempotencyFingerprint returns the fingerprint of the request the idempotency key was sent with, a nil
+// ScanOptions has none
+func (options *ScanOptions) GetIdempotencyFingerprint() string {
+	if options == nil {
+		return ""
+	}
+	return options.IdempotencyFingerprint
+}
+
+// GetTargetOverride returns the IP and SNI overrides of the options, a nil ScanOptions has none
+func (options *ScanOptions) GetTargetOverride() (ip string, sni string) {
+	if options == nil {
+		return "", ""
+	}
+	return options.IP, options.SNI
+}
+
+// IsSkipped returns true when the group of checks is skipped, a nil ScanOptions skips nothing
+func (options *ScanOptions) IsSkipped(group string) bool {
+	if options == nil {
+		return false
+	}
+	for _, skipped := range options.Skip {
+		if skipped == group {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// ScanResult holds the outcome of a single scan, kept to build the score history of a domain
+type ScanResult struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	Domain    string    `gorm:"size:255;index" json:"domain"`
+	URL       string    `gorm:"size:2047" json:"url"`
+	Score     float64   `json:"score"`
+	ScannedAt time.Time `json:"scanned_at"`
+	// Response is the JSON scores response of the scan, rendered by the PDF report
+	Response string `gorm:"type:text" json:"-"`
+	// Observations is the JSON of the Observations the response was scored from, empty for the results stored
+	// before they were kept
+	Observations string `gorm:"type:text" json:"-"`
+	// IdempotencyKey is the key of the request that ran the scan, if any
+	IdempotencyKey string `gorm:"size:64;index" json:"-"`
+	// IdempotencyFingerprint identifies the URL and options of the request that ran the scan along with its key
+	IdempotencyFingerprint string `gorm:"size:64" json:"-"`
+}
+
+// ScoreHistory holds the past scan results of a domain for the Score History API
+type ScoreHistory struct {
+	Domain  string        `json:"domain"`
+	History []*ScanResult `json:"history"`
+}
+
+// GetScanResult returns a ScanResult for the domain scanned now
+func GetScanResult(domain string, url string, score float64) *ScanResult {
+	return &ScanResult{
+		Domain:    domain,
+		URL:       url,
+		Score:     score,
+		ScannedAt: time.Now().UTC(),
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+// Versions of the ScoresResponse schema. Adding an optional field keeps the version, while the version is bumped
+// whenever a field is added to the required ones, removed, renamed or changes meaning. Every version stays servable
+// through BuildVersionedScoresResponse, so that a client pinned to an older version keeps receiving the shape it expects
+const (
+	// SchemaVersion1 is the original response, with the url, score and badges of the scores and the certificate names and dates
+	SchemaVersion1 = 1
+	// SchemaVersion2 adds the grade and the per-check breakdown of the scores, along with the TLS, redirect and certificate chain details
+	SchemaVersion2 = 2
+	// CurrentSchemaVersion is the version of the responses built by BuildScoresResponse
+	CurrentSchemaVersion = SchemaVersion2
+)
+
+// ScoresResponseV1 is the ScoresResponse at SchemaVersion1
+type ScoresResponseV1 struct {
+	SchemaVersion int           `json:"schema_version"`
+	Scores        *ScoresV1     `json:"scores"`
+	Cert          *CertV1       `json:"certificate_details,omitempty"`
+	IncidentList  []Incident    `json:"security_incidents,omitempty"`
+	ServerDetail  *ServerDetail `json:"web_server,omitempty"`
+}
+
+// ScoresV1 is the Scores at SchemaVersion1
+type ScoresV1 struct {
+	URL    string   `json:"url"`
+	Score  float64  `json:"score"`
+	Badges []*Badge `json:"badges"`
+}
+
+// CertV1 is the Cert at SchemaVersion1
+type CertV1 struct {
+	DomainName         string   `json:"domain_name"`
+	IP                 string   `json:"ip_address"`
+	Issuer             string   `json:"issuer"`
+	IssuerOrganization []string `json:"issuer_organization"`
+	CertificateURL     []string `json:"certificate_url"`
+	CommonName         string   `json:"common_name"`
+	SANs               []string `json:"sans"`
+	NotBefore          string   `json:"not_before"`
+	NotAfter           string   `json:"not_after"`
+}
+
+// IsSupportedSchemaVersion returns true when the responses can be served at the version
+func IsSupportedSchemaVersion(version int) bool {
+	return version >= SchemaVersion1 && version <= CurrentSchemaVersion
+}
+
+// BuildVersionedScoresResponse returns the response in the shape of the schema version, which must be supported
+func BuildVersionedScoresResponse(response *ScoresResponse, version int) interface{} {
+	if version != SchemaVersion1 {
+		response.SchemaVersion = version
+		return response
+	}
+	versioned := &ScoresResponseV1{
+		SchemaVersion: SchemaVersion1,
+		IncidentList:  response.IncidentList,
+		ServerDetail:  response.ServerDetail,
+	}
+	if response.Scores != nil {
+		versioned.Scores = &ScoresV1{URL: response.Scores.URL, Score: response.Scores.Score, Badges: response.Scores.Badges}
+	}
+	if response.Cert != nil {
+		versioned.Cert = &CertV1{
+			DomainName:         response.Cert.DomainName,
+			IP:                 response.Cert.IP,
+			Issuer:             response.Cert.Issuer,
+			IssuerOrganization: response.Cert.IssuerOrganization,
+			CertificateURL:     response.Cert.CertificateURL,
+			CommonName:         response.Cert.CommonName,
+			SANs:               response.Cert.SANs,
+			NotBefore:          response.Cert.NotBefore,
+			NotAfter:           response.Cert.NotAfter,
+		}
+	}
+	return versioned
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildVersionedScoresResponse(t *testing.T) {
+	scores := GetScores("https://www.example.com", 0.9, nil, []*CheckResult{GetCheckResult("Protocol", 5, 5)})
+	cert := &Cert{DomainName: "www.example.com", CommonName: "www.example.com", HostMatchesSAN: true, Chain: []CertSummary{{Subject: "CN=www.example.com"}}}
+	response := BuildScoresResponse(scores, cert, nil, nil)
+	assert.Equal(t, response.SchemaVersion, CurrentSchemaVersion)
+
+	body, _ := json.Marshal(BuildVersionedScoresResponse(response, SchemaVersion1))
+	var v1 struct {
+		SchemaVersion int                    `json:"schema_version"`
+		Scores        map[string]interface{} `json:"scores"`
+		Cert          map[string]interface{} `json:"certificate_details"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &v1))
+	assert.Equal(t, v1.SchemaVersion, SchemaVersion1)
+	assert.Equal(t, v1.Scores["url"], "https://www.example.com")
+	assert.NotContains(t, v1.Scores, "grade")
+	assert.Equal(t, v1.Cert["common_name"], "www.example.com")
+	assert.NotContains(t, v1.Cert, "chain")
+
+	assert.Equal(t, BuildVersionedScoresResponse(response, SchemaVersion2), response)
+	assert.True(t, IsSupportedSchemaVersion(SchemaVersion1))
+	assert.False(t, IsSupportedSchemaVersion(CurrentSchemaVersion+1))
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"math"
+	"sync"
+)
+
+// ScoreBuilder accumulates the checks and badges of a scan, it is safe for concurrent use so that checks
+// running in parallel can report their results to the same scan
+type ScoreBuilder struct {
+	mu      sync.Mutex
+	checks  []*CheckResult
+	badges  []*Badge
+	profile *ScoringProfile
+	order   []string
+	only    map[string]bool
+	// gradeCap is the best grade Finalize can give, empty when the grade is not capped
+	gradeCap string
+}
+
+// NewScoreBuilder returns an empty ScoreBuilder
+func NewScoreBuilder() *ScoreBuilder {
+	return &ScoreBuilder{}
+}
+
+// SetProfile weights the checks and grades the overall score with a ScoringProfile
+func (builder *ScoreBuilder) SetProfile(profile *ScoringProfile) {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	builder.profile = profile
+}
+
+// SetOrder lists the checks reported first by Finalize, in that order
+func (builder *ScoreBuilder) SetOrder(order []string) {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	builder.order = order
+}
+
+// SetOnly restricts the checks recorded by AddCheck to the given names, a nil map records every check
+func (builder *ScoreBuilder) SetOnly(only map[string]bool) {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	builder.only = only
+}
+
+// SetGradeCap caps the grade given by Finalize at maxGrade whatever the score, an empty maxGrade removes the cap
+func (builder *ScoreBuilder) SetGradeCap(maxGrade string) {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	builder.gradeCap = maxGrade
+}
+
+// AddCheck records the result of a check, unless it is left out through SetOnly
+func (builder *ScoreBuilder) AddCheck(result *CheckResult) {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	if builder.only != nil && !builder.only[result.Name] {
+		return
+	}
+	builder.checks = append(builder.checks, result)
+}
+
+// AddBadge records a badge earned by the scanned site
+func (builder *ScoreBuilder) AddBadge(badge *Badge) {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	builder.badges = append(builder.badges, badge)
+}
+
+// Checks returns the checks recorded so far, in the order they were added
+func (builder *ScoreBuilder) Checks() []*CheckResult {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	return append([]*CheckResult(nil), builder.checks...)
+}
+
+// Badges returns the badges recorded so far, in the order they were added
+func (builder *ScoreBuilder) Badges() []*Badge {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	return append([]*Badge(nil), builder.badges...)
+}
+
+// Totals returns the sum of the scores and of the maximum scores of the checks recorded so far
+func (builder *ScoreBuilder) Totals() (score int, maxScore int) {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	for _, check := range builder.checks {
+		score += check.Score
+		maxScore += check.MaxScore
+	}
+	return
+}
+
+// Finalize returns the Scores of the url, the overall score is the fraction of the maximum score rounded up
+// to two decimals, and the grade is capped when a critical check failed. With a ScoringProfile, every check
+// counts according to its weight and the grade follows the thresholds of the profile. The checks follow the order
+// set through SetOrder, and otherwise the order they were added in
+func (builder *ScoreBuilder) Finalize(url string) *Scores {
+	builder.mu.Lock()
+	defer builder.mu.Unlock()
+	var score, maxScore float64
+	for _, check := range builder.checks {
+		weight := 1.0
+		if builder.profile != nil {
+			weight = builder.profile.GetWeight(check.Name)
+		}
+		score += float64(check.Score) * weight
+		maxScore += float64(check.MaxScore) * weight
+	}
+	overallScore := 0.0
+	if maxScore > 0 {
+		overallScore = math.Ceil((score/maxScore)*100) / 100
+	}
+	checks := append([]*CheckResult(nil), builder.checks...)
+	SortChecks(checks, builder.order)
+	scores := GetScores(url, overallScore, append([]*Badge(nil), builder.badges...), checks)
+	thresholds := GradeThresholds
+	if builder.profile != nil {
+		scores.Profile = builder.profile.Name
+		thresholds = builder.profile.GradeThresholds
+		scores.Grade = GetGradeWithThresholds(overallScore, hasCriticalFailure(checks), thresholds)
+	}
+	if builder.gradeCap != "" {
+		grade := CapGrade(scores.Grade, builder.gradeCap, thresholds)
+		scores.GradeCapped = grade != scores.Grade
+		scores.Grade = grade
+	}
+	return scores
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreBuilderConcurrentAddCheck(t *testing.T) {
+	builder := NewScoreBuilder()
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			builder.AddCheck(GetCheckResult("check", i%6, 5))
+			if i%10 == 0 {
+				builder.AddBadge(&Badge{Name: "badge"})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	score, maxScore := builder.Totals()
+	expectedScore := 0
+	for i := 0; i < 100; i++ {
+		expectedScore += i % 6
+	}
+	assert.Equal(t, score, expectedScore)
+	assert.Equal(t, maxScore, 500)
+	scores := builder.Finalize("https://www.example.com")
+	assert.Equal(t, len(scores.Checks), 100)
+	assert.Equal(t, len(scores.Badges), 10)
+}
+
+func TestScoreBuilderFinalize(t *testing.T) {
+	builder := NewScoreBuilder()
+	critical := GetCheckResult("Protocol", 5, 5)
+	critical.Critical = true
+	builder.AddCheck(critical)
+	builder.AddCheck(GetCheckResult("Content-Security-Policy", 4, 5))
+	scores := builder.Finalize("https://www.example.com")
+	assert.Equal(t, scores.URL, "https://www.example.com")
+	assert.Equal(t, scores.Score, 0.9)
+	assert.Equal(t, scores.Grade, "A")
+
+	// a failing critical check caps the grade
+	critical.Score = 0
+	builder.AddCheck(GetCheckResult("Referrer-Policy", 5, 5))
+	scores = builder.Finalize("https://www.example.com")
+	assert.Equal(t, scores.Score, 0.6)
+	assert.Equal(t, scores.Grade, "D")
+
+	// an empty builder scores nothing
+	assert.Equal(t, NewScoreBuilder().Finalize("https://www.example.com").Score, 0.0)
+}
+
+func TestScoreBuilderOrder(t *testing.T) {
+	builder := NewScoreBuilder()
+	builder.AddCheck(GetCheckResult("Referrer-Policy", 5, 5))
+	builder.AddCheck(GetCheckResult("X-Frame-Options", 5, 5))
+	builder.AddCheck(GetCheckResult("Protocol", 0, 5))
+	builder.AddCheck(GetCheckResult("Content-Security-Policy", 0, 5))
+	builder.SetOrder([]string{"Protocol", "Content-Security-Policy", "HSTS"})
+
+	// the ordered checks come first, the other ones keep the order they were added in
+	var names []string
+	for _, check := range builder.Finalize("https://www.example.com").Checks {
+		names = append(names, check.Name)
+	}
+	assert.Equal(t, names, []string{"Protocol", "Content-Security-Policy", "Referrer-Policy", "X-Frame-Options"})
+	assert.Equal(t, builder.Checks()[0].Name, "Referrer-Policy")
+}
@@ -2,22 +2,69 @@ package models
 
 // Scores holds a valid score, the incoming url and the outgoing message
 type Scores struct {
-	URL    string   `json:"url"`
-	Score  float64  `json:"score"`
-	Badges []*Badge `json:"badges"`
+	URL    string         `json:"url"`
+	Score  float64        `json:"score"`
+	Grade  string         `json:"grade"`
+	Badges []*Badge       `json:"badges"`
+	Checks []*CheckResult `json:"checks"`
+	// GradeCapped is true when the grade was lowered to a cap, such as the one of a site not served over HTTPS,
+	// the score being left as it is
+	GradeCapped bool `json:"grade_capped,omitempty"`
+	// HSTSPreloadEligible is true when the HSTS Header meets the preload list requirements
+	HSTSPreloadEligible bool `json:"hsts_preload_eligible"`
+	// ClearSiteDataPresent is true when the site can clear cookies, storage or cache through Clear-Site-Data
+	ClearSiteDataPresent bool `json:"clear_site_data_present"`
+	// PunycodeURL is the ASCII-compatible URL that was scanned when the requested URL has an internationalized host
+	PunycodeURL string `json:"punycode_url,omitempty"`
+	// NegotiatedTLSVersion is the TLS version of the scan request, MaxTLSVersion the highest version the server supports
+	NegotiatedTLSVersion string `json:"negotiated_tls_version,omitempty"`
+	MaxTLSVersion        string `json:"max_tls_version,omitempty"`
+	// SessionResumption is true when the server resumes TLS sessions, sparing returning clients a full Handshake
+	SessionResumption bool `json:"session_resumption"`
+	// NegotiatedHTTPVersion is the HTTP version of the scan request, MaxHTTPVersion the highest version the server
+	// supports as negotiated through ALPN
+	NegotiatedHTTPVersion string `json:"negotiated_http_version,omitempty"`
+	MaxHTTPVersion        string `json:"max_http_version,omitempty"`
+	// RedirectChain lists every URL visited when the requested URL redirects, the last one being the FinalURL that is scored
+	RedirectChain []string `json:"redirect_chain,omitempty"`
+	FinalURL      string   `json:"final_url,omitempty"`
+	// CrossHostRedirect is true when a redirect leads to a different host than the one requested
+	CrossHostRedirect bool `json:"cross_host_redirect"`
+	// RedirectLimitExceeded is true when the URL kept redirecting past the redirect limit, the RedirectChain then
+	// stops at the last URL visited, whose response was scored
+	RedirectLimitExceeded bool `json:"redirect_limit_exceeded"`
+	// RequestMethod is the HTTP method the response headers were scored from, GET when the server rejected HEAD
+	RequestMethod string `json:"request_method,omitempty"`
+	// Profile is the name of the ScoringProfile the score and grade were calculated with
+	Profile string `json:"profile,omitempty"`
+	// Only lists the checks the score and its maximum are scoped to, when the scan was restricted to them
+	Only []string `json:"only,omitempty"`
+	// ComplianceViolations lists the required headers the site does not send, they do not affect the score
+	ComplianceViolations []string `json:"compliance_violations,omitempty"`
 }
 
 // ScoresRequest holds the structure for Scores API Request Body
 type ScoresRequest struct {
 	URL string `json:"url"`
+	// MinScore is the percentage the overall score must reach for the response to succeed, below it the
+	// result is returned with 422 Unprocessable Entity
+	MinScore *float64 `json:"min_score,omitempty"`
+	// CallbackURL runs the scan asynchronously, the request returns its job right away and the completed job is
+	// posted to the callback URL
+	CallbackURL string `json:"callback_url,omitempty"`
+	// Lang is the language of the messages of the response, negotiated from Accept-Language when it is not set
+	Lang string `json:"lang,omitempty"`
+	ScanOptions
 }
 
 // GetScores returns a valid Score instance
-func GetScores(url string, score float64, badges []*Badge) *Scores {
+func GetScores(url string, score float64, badges []*Badge, checks []*CheckResult) *Scores {
 	response := &Scores{
 		URL:    url,
 		Score:  score,
+		Grade:  GetGrade(score, hasCriticalFailure(checks)),
 		Badges: badges,
+		Checks: checks,
 	}
 	return response
 }
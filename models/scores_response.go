@@ -1,20 +1,57 @@
 package models
 
+import (
+	"strconv"
+	"strings"
+)
+
 // ScoresResponse holds a Score JSON, the Certificate Details JSON for the main Scores API
 type ScoresResponse struct {
-	Scores       *Scores       `json:"scores"`
-	Cert         *Cert         `json:"certificate_details,omitempty"`
-	IncidentList []Incident    `json:"security_incidents,omitempty"`
-	ServerDetail *ServerDetail `json:"web_server,omitempty"`
+	// SchemaVersion is the version of the shape of the response, see CurrentSchemaVersion
+	SchemaVersion int           `json:"schema_version"`
+	Scores        *Scores       `json:"scores"`
+	Cert          *Cert         `json:"certificate_details,omitempty"`
+	IncidentList  []Incident    `json:"security_incidents,omitempty"`
+	ServerDetail  *ServerDetail `json:"web_server,omitempty"`
+	HostInfo      *HostInfo     `json:"host_info,omitempty"`
+	// RawHeaders are the response headers observed by the scan, only attached when requested through IncludeRaw
+	RawHeaders map[string]string `json:"raw_headers,omitempty"`
+	// Crawl holds the scores of the internal pages, only attached when requested through CrawlDepth
+	Crawl *Crawl `json:"crawl,omitempty"`
 }
 
 // BuildScoresResponse builds the final api response for /score
 func BuildScoresResponse(scores *Scores, cert *Cert, IncidentList []Incident, ServerDetail *ServerDetail) *ScoresResponse {
 	response := &ScoresResponse{
-		Scores:       scores,
-		Cert:         cert,
-		IncidentList: IncidentList,
-		ServerDetail: ServerDetail,
+		SchemaVersion: CurrentSchemaVersion,
+		Scores:        scores,
+		Cert:          cert,
+		IncidentList:  IncidentList,
+		ServerDetail:  ServerDetail,
 	}
 	return response
 }
+
+// BuildScoresCSV flattens the /scores response into a header and a single record with a column per check
+func BuildScoresCSV(response *ScoresResponse) [][]string {
+	header := []string{"url", "score", "grade", "badges"}
+	record := []string{"", "", "", ""}
+	if response.Scores == nil {
+		return [][]string{header, record}
+	}
+	badges := make([]string, 0, len(response.Scores.Badges))
+	for _, badge := range response.Scores.Badges {
+		badges = append(badges, badge.Name)
+	}
+	record = []string{
+		response.Scores.URL,
+		strconv.FormatFloat(response.Scores.Score, 'f', -1, 64),
+		response.Scores.Grade,
+		strings.Join(badges, ";"),
+	}
+	for _, check := range response.Scores.Checks {
+		header = append(header, check.Name, check.Name+" (max)")
+		record = append(record, strconv.Itoa(check.Score), strconv.Itoa(check.MaxScore))
+	}
+	return [][]string{header, record}
+}
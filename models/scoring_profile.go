@@ -0,0 +1,18 @@
+package models
+
+// ScoringProfile holds the weights of the checks and the grade thresholds of a level of strictness
+type ScoringProfile struct {
+	Name string
+	// Weights multiplies the score and maximum score of a check in the overall score, a check missing from it weighs 1
+	Weights map[string]float64
+	// GradeThresholds maps the overall score to a letter grade, ordered from the best grade
+	GradeThresholds []GradeThreshold
+}
+
+// GetWeight returns the weight of a check in the profile
+func (profile *ScoringProfile) GetWeight(check string) float64 {
+	if weight, ok := profile.Weights[check]; ok {
+		return weight
+	}
+	return 1
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"time"
+)
+
+// SessionTicketTimeout bounds the wait for the session tickets a TLS 1.3 server sends after the Handshake
+var SessionTicketTimeout = time.Second
+
+// GetSessionResumption performs two Handshakes sharing a session cache, and returns true when the second one resumed
+// the session of the first. crypto/tls resumes through session tickets only, so a server resuming through session IDs
+// alone is not detected. The certificate is not verified as only the resumption is of interest
+func GetSessionResumption(host string, port string) (bool, error) {
+	return GetSessionResumptionContext(context.Background(), host, port)
+}
+
+// GetSessionResumptionContext is GetSessionResumption with the Handshakes bound to ctx
+func GetSessionResumptionContext(ctx context.Context, host string, port string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(TimeoutSeconds)*time.Second)
+	defer cancel()
+	config := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+		ClientSessionCache: tls.NewLRUClientSessionCache(1),
+	}
+	conn, err := handshake(ctx, host, port, config)
+	if err != nil {
+		return false, err
+	}
+	receiveSessionTickets(conn, host)
+	conn.Close()
+
+	conn, err = handshake(ctx, host, port, config)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().DidResume, nil
+}
+
+// receiveSessionTickets sends a request over a TLS 1.3 connection and reads the start of its response, as the session
+// tickets sent after the Handshake are only processed while reading. TLS 1.2 tickets are part of the Handshake
+func receiveSessionTickets(conn *tls.Conn, host string) {
+	if conn.ConnectionState().Version != tls.VersionTLS13 {
+		return
+	}
+	conn.SetDeadline(time.Now().Add(SessionTicketTimeout))
+	_, err := io.WriteString(conn, "HEAD / HTTP/1.1\r\nHost: "+host+"\r\nConnection: close\r\n\r\n")
+	if err != nil {
+		return
+	}
+	conn.Read(make([]byte, 1))
+}
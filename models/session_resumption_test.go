@@ -0,0 +1,39 @@
+package models
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSessionResumption(t *testing.T) {
+	for _, test := range []struct {
+		maxVersion     uint16
+		ticketsEnabled bool
+	}{
+		{tls.VersionTLS13, true},
+		{tls.VersionTLS13, false},
+		{tls.VersionTLS12, true},
+		{tls.VersionTLS12, false},
+	} {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.TLS = &tls.Config{MaxVersion: test.maxVersion, SessionTicketsDisabled: !test.ticketsEnabled}
+		server.StartTLS()
+		host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+		resumed, err := GetSessionResumption(host, port)
+		assert.NoError(t, err)
+		assert.Equal(t, resumed, test.ticketsEnabled, test)
+		server.Close()
+	}
+
+	// a server that cannot be reached is an error rather than a lack of resumption
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	server.Close()
+	_, err := GetSessionResumption(host, port)
+	assert.Error(t, err)
+}
@@ -0,0 +1,10 @@
+package models
+
+import "encoding/json"
+
+// StreamEvent is a single line of a scan streamed as newline delimited JSON, the event is check, summary or error
+// and the data holds the CheckResult, the ScoresResponse or the error respectively
+type StreamEvent struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
@@ -0,0 +1,199 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+)
+
+// ErrTLSHelloInconclusive is returned when the server does not answer a TLS 1.2 ClientHello with a ServerHello, as a
+// server supporting TLS 1.3 only answers it with an alert, so that its compression and renegotiation support is unknown
+var ErrTLSHelloInconclusive = errors.New("the server did not answer the ClientHello with a ServerHello")
+
+// TLSHello holds the TLS features selected by a server in its ServerHello, which crypto/tls does not expose
+type TLSHello struct {
+	// Compression is true when the server selected TLS compression, which exposes the connections to CRIME
+	Compression bool `json:"compression"`
+	// SecureRenegotiation is true when the server supports the renegotiation_info extension (RFC 5746), without it
+	// the server may allow the insecure renegotiation that lets an attacker prefix the requests of a client
+	SecureRenegotiation bool `json:"secure_renegotiation"`
+}
+
+// TLS record and handshake values of the ClientHello, see RFC 5246
+const (
+	recordTypeHandshake          = 22
+	handshakeTypeClientHello     = 1
+	handshakeTypeServerHello     = 2
+	compressionNull              = 0
+	compressionDeflate           = 1
+	extensionServerName          = 0x0000
+	extensionSupportedGroups     = 0x000a
+	extensionECPointFormats      = 0x000b
+	extensionSignatureAlgorithms = 0x000d
+	extensionRenegotiationInfo   = 0xff01
+	// the signaling cipher suite announcing the support of secure renegotiation (RFC 5746)
+	emptyRenegotiationInfoSCSV = 0x00ff
+	maxServerHelloLength       = 1 << 14
+)
+
+// tlsHelloCipherSuites are the TLS 1.2 and earlier cipher suites offered by the ClientHello
+var tlsHelloCipherSuites = []uint16{
+	0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, 0xc009, 0xc013, 0xc00a, 0xc014,
+	0x009c, 0x009d, 0x002f, 0x0035, 0x000a, emptyRenegotiationInfoSCSV,
+}
+
+// GetTLSHello sends a TLS 1.2 ClientHello offering DEFLATE compression and announcing secure renegotiation, and
+// returns the features selected by the ServerHello. The Handshake is not completed as only the ServerHello is of interest
+func GetTLSHello(host string, port string) (*TLSHello, error) {
+	return GetTLSHelloContext(context.Background(), host, port)
+}
+
+// GetTLSHelloContext is GetTLSHello with the exchange bound to ctx
+func GetTLSHelloContext(ctx context.Context, host string, port string) (*TLSHello, error) {
+	ctx, cancel := context.WithTimeout(ctx, HandshakeTimeout)
+	defer cancel()
+	conn, err := DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	clientHello, err := buildClientHello(host)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Write(clientHello)
+	if err != nil {
+		return nil, err
+	}
+	serverHello, err := readServerHello(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseServerHello(serverHello)
+}
+
+// buildClientHello returns the TLS record holding the ClientHello, with the host sent through SNI unless it is an IP
+func buildClientHello(host string) ([]byte, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	body := []byte{0x03, 0x03}
+	body = append(body, random...)
+	// no session ID
+	body = append(body, 0)
+	body = binary.BigEndian.AppendUint16(body, uint16(2*len(tlsHelloCipherSuites)))
+	for _, suite := range tlsHelloCipherSuites {
+		body = binary.BigEndian.AppendUint16(body, suite)
+	}
+	body = append(body, 2, compressionDeflate, compressionNull)
+
+	var extensions []byte
+	if net.ParseIP(host) == nil {
+		serverName := []byte{0}
+		serverName = binary.BigEndian.AppendUint16(serverName, uint16(len(host)))
+		serverName = append(serverName, host...)
+		extensions = appendExtension(extensions, extensionServerName, appendVector16(nil, serverName))
+	}
+	// x25519, secp256r1 and secp384r1
+	extensions = appendExtension(extensions, extensionSupportedGroups, appendVector16(nil, []byte{0x00, 0x1d, 0x00, 0x17, 0x00, 0x18}))
+	extensions = appendExtension(extensions, extensionECPointFormats, []byte{1, 0})
+	// ECDSA, RSA-PSS and RSA PKCS#1 with SHA-256, SHA-384 and SHA-512, along with the legacy SHA-1 ones
+	extensions = appendExtension(extensions, extensionSignatureAlgorithms, appendVector16(nil, []byte{
+		0x04, 0x03, 0x05, 0x03, 0x06, 0x03, 0x08, 0x04, 0x08, 0x05, 0x08, 0x06,
+		0x04, 0x01, 0x05, 0x01, 0x06, 0x01, 0x02, 0x03, 0x02, 0x01,
+	}))
+	body = appendVector16(body, extensions)
+
+	handshake := []byte{handshakeTypeClientHello, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}
+	handshake = append(handshake, body...)
+	record := []byte{recordTypeHandshake, 0x03, 0x01}
+	return appendVector16(record, handshake), nil
+}
+
+func appendVector16(data []byte, vector []byte) []byte {
+	data = binary.BigEndian.AppendUint16(data, uint16(len(vector)))
+	return append(data, vector...)
+}
+
+func appendExtension(extensions []byte, extensionType uint16, data []byte) []byte {
+	extensions = binary.BigEndian.AppendUint16(extensions, extensionType)
+	return appendVector16(extensions, data)
+}
+
+// readServerHello reads the handshake records answering the ClientHello until they hold the ServerHello message,
+// and returns its body
+func readServerHello(conn io.Reader) ([]byte, error) {
+	var handshake []byte
+	header := make([]byte, 5)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(header[3:]))
+		if header[0] != recordTypeHandshake || length > maxServerHelloLength {
+			// an alert, or anything else than a handshake record, does not tell whether the features are supported
+			return nil, ErrTLSHelloInconclusive
+		}
+		fragment := make([]byte, length)
+		if _, err := io.ReadFull(conn, fragment); err != nil {
+			return nil, err
+		}
+		handshake = append(handshake, fragment...)
+		if len(handshake) < 4 {
+			continue
+		}
+		if handshake[0] != handshakeTypeServerHello {
+			return nil, ErrTLSHelloInconclusive
+		}
+		messageLength := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+		if messageLength > maxServerHelloLength {
+			return nil, ErrTLSHelloInconclusive
+		}
+		if len(handshake) >= 4+messageLength {
+			return handshake[4 : 4+messageLength], nil
+		}
+	}
+}
+
+// parseServerHello returns the compression method and the renegotiation_info extension selected by a ServerHello
+func parseServerHello(serverHello []byte) (*TLSHello, error) {
+	// version and random, followed by the session ID
+	offset := 2 + 32
+	if len(serverHello) < offset+1 {
+		return nil, ErrTLSHelloInconclusive
+	}
+	offset += 1 + int(serverHello[offset])
+	// cipher suite and compression method
+	if len(serverHello) < offset+3 {
+		return nil, ErrTLSHelloInconclusive
+	}
+	hello := &TLSHello{Compression: serverHello[offset+2] != compressionNull}
+	offset += 3
+	if len(serverHello) < offset+2 {
+		// a ServerHello without extensions does not support secure renegotiation
+		return hello, nil
+	}
+	extensions := serverHello[offset+2:]
+	if len(extensions) != int(binary.BigEndian.Uint16(serverHello[offset:])) {
+		return nil, ErrTLSHelloInconclusive
+	}
+	for len(extensions) >= 4 {
+		extensionType := binary.BigEndian.Uint16(extensions)
+		length := int(binary.BigEndian.Uint16(extensions[2:]))
+		if len(extensions) < 4+length {
+			return nil, ErrTLSHelloInconclusive
+		}
+		if extensionType == extensionRenegotiationInfo {
+			hello.SecureRenegotiation = true
+		}
+		extensions = extensions[4+length:]
+	}
+	return hello, nil
+}
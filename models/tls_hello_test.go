@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveTLSRecord accepts a connection, reads the ClientHello record and answers it with record
+func serveTLSRecord(t *testing.T, record []byte) (host string, port string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		io.ReadFull(conn, make([]byte, binary.BigEndian.Uint16(header[3:])))
+		conn.Write(record)
+	}()
+	host, port, _ = net.SplitHostPort(listener.Addr().String())
+	return
+}
+
+// getServerHelloRecord returns a TLS 1.2 ServerHello record selecting the compression method, with the extensions
+func getServerHelloRecord(compression byte, extensions []byte) []byte {
+	body := append([]byte{0x03, 0x03}, make([]byte, 32)...)
+	body = append(body, 0, 0xc0, 0x2f, compression)
+	if extensions != nil {
+		body = appendVector16(body, extensions)
+	}
+	handshake := append([]byte{handshakeTypeServerHello, 0, byte(len(body) >> 8), byte(len(body))}, body...)
+	return appendVector16([]byte{recordTypeHandshake, 0x03, 0x03}, handshake)
+}
+
+func TestGetTLSHello(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	host, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	hello, err := GetTLSHello(host, port)
+	assert.NoError(t, err)
+	assert.Equal(t, hello, &TLSHello{Compression: false, SecureRenegotiation: true})
+
+	// a legacy server selecting DEFLATE, without the renegotiation_info extension
+	host, port = serveTLSRecord(t, getServerHelloRecord(compressionDeflate, nil))
+	hello, err = GetTLSHello(host, port)
+	assert.NoError(t, err)
+	assert.Equal(t, hello, &TLSHello{Compression: true, SecureRenegotiation: false})
+
+	host, port = serveTLSRecord(t, getServerHelloRecord(compressionNull, appendExtension(nil, extensionRenegotiationInfo, []byte{0})))
+	hello, err = GetTLSHello(host, port)
+	assert.NoError(t, err)
+	assert.Equal(t, hello, &TLSHello{Compression: false, SecureRenegotiation: true})
+
+	// a protocol_version alert, as sent by a server supporting TLS 1.3 only
+	host, port = serveTLSRecord(t, []byte{21, 0x03, 0x03, 0, 2, 2, 70})
+	_, err = GetTLSHello(host, port)
+	assert.ErrorIs(t, err, ErrTLSHelloInconclusive)
+
+	host, port = serveTLSRecord(t, getServerHelloRecord(compressionNull, nil)[:20])
+	_, err = GetTLSHello(host, port)
+	assert.Error(t, err)
+}
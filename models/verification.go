@@ -0,0 +1,14 @@
+package models
+
+// VerificationRequest holds the structure for Verify API Request Body
+type VerificationRequest struct {
+	Domain string `json:"domain"`
+}
+
+// Verification holds the TXT record to publish to prove the control of a domain, and whether it is already published
+type Verification struct {
+	Domain   string `json:"domain"`
+	Token    string `json:"token"`
+	Record   string `json:"record"`
+	Verified bool   `json:"verified"`
+}
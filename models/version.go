@@ -0,0 +1,8 @@
+package models
+
+// BuildInfo holds the metadata of the running build
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitCommit string `json:"git_commit"`
+	BuildTime string `json:"build_time"`
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"strings"
+)
+
+// parseTags returns the tag-value pairs of a DMARC or BIMI record, with the tag names lowercased
+func parseTags(record string) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(record, ";") {
+		name, value, found := strings.Cut(tag, "=")
+		if found {
+			tags[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
+		}
+	}
+	return tags
+}
+
+// isDMARCEnforced returns true when the DMARC record quarantines or rejects all of the failing mail, as BIMI requires
+func isDMARCEnforced(dmarcRecord string) bool {
+	tags := parseTags(dmarcRecord)
+	policy := strings.ToLower(tags["p"])
+	if policy != DMARCQuarantinePolicy && policy != DMARCRejectPolicy {
+		return false
+	}
+	pct, ok := tags["pct"]
+	return !ok || pct == "100"
+}
+
+// GetBIMIScore returns the informational Brand Indicators for Message Identification Score of the Domain.
+// A BIMI record is only honored by mail clients once DMARC is enforced, so it is not awarded otherwise
+func GetBIMIScore(domain string) int {
+	return GetBIMIScoreContext(context.Background(), domain)
+}
+
+// GetBIMIScoreContext is GetBIMIScore with the lookups made through the DNS cache of ctx
+func GetBIMIScoreContext(ctx context.Context, domain string) int {
+	_, dmarcRecord := GetDMARCScoreContext(ctx, domain)
+	if !isDMARCEnforced(dmarcRecord) {
+		return 0
+	}
+	records, err := cachedLookupTXT(ctx, BIMIPrefix+domain)
+	if err != nil {
+		return 0
+	}
+	for _, record := range records {
+		tags := parseTags(record)
+		if tags["v"] == "BIMI1" && strings.HasPrefix(strings.ToLower(tags["l"]), "https://") {
+			return BIMIScore
+		}
+	}
+	return 0
+}
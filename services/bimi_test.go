@@ -0,0 +1,28 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetBIMIScore(t *testing.T) {
+	defer mockLookupTXT(map[string][]string{
+		"_dmarc.example.com":                {"v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+		"default._bimi.example.com":         {"v=BIMI1; l=https://example.com/logo.svg; a=https://example.com/vmc.pem"},
+		"_dmarc.example.org":                {"v=DMARC1; p=quarantine; pct=100"},
+		"default._bimi.example.org":         {"v=BIMI1; l="},
+		"_dmarc.example.net":                {"v=DMARC1; p=none"},
+		"default._bimi.example.net":         {"v=BIMI1; l=https://example.net/logo.svg"},
+		"_dmarc.partial.example.com":        {"v=DMARC1; p=quarantine; pct=50"},
+		"default._bimi.partial.example.com": {"v=BIMI1; l=https://example.com/logo.svg"},
+	})()
+
+	assert.Equal(t, GetBIMIScore("example.com"), BIMIScore)
+	// a record without a logo is not a usable BIMI record
+	assert.Equal(t, GetBIMIScore("example.org"), 0)
+	// mail clients ignore BIMI until DMARC quarantines or rejects all of the failing mail
+	assert.Equal(t, GetBIMIScore("example.net"), 0)
+	assert.Equal(t, GetBIMIScore("partial.example.com"), 0)
+	assert.Equal(t, GetBIMIScore("missing.example.com"), 0)
+}
@@ -1,33 +1,50 @@
 package services
 
 import (
-	"bufio"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"math"
-	"net"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
+	"regexp"
 	"snift-api/models"
 	"snift-api/utils"
 	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
-var badges []*models.Badge
+var lookupTXT = utils.LookupTXTContext
+
+// cachedLookupTXT returns the TXT records of a domain, queried at most once per scan through the DNS cache carried by
+// ctx. The cache is created at the start of every scan, so that no records are carried over from one scan to another
+func cachedLookupTXT(ctx context.Context, domain string) ([]string, error) {
+	return utils.GetDNSCache(ctx).LookupTXT(ctx, domain, lookupTXT)
+}
+
+var maxTLSVersion = models.GetMaxTLSVersionContext
+
+var alpnProtocol = models.GetALPNProtocolContext
+
+var tlsHello = models.GetTLSHelloContext
+
+var sessionResumption = models.GetSessionResumptionContext
+
+// ResultStore keeps the result of every completed scan for the score history
+var ResultStore utils.ResultStore = utils.NewMemoryResultStore()
 
 // CalculateProtocolScore returns a score based on whether the protocol is http/https
 func CalculateProtocolScore(protocol string) (score int) {
 	if protocol == "https" {
-		score = 5
-		badges = append(badges, utils.GetHTTPSBadge())
+		score = HTTPSScore
 	}
 	return
 }
@@ -42,87 +59,347 @@ var getDefaultPort = func(protocol string) string {
 	return port
 }
 
+// getHostAndPort returns the host without brackets for IPv6 literals, and the explicit or default port
+func getHostAndPort(domain *url.URL) (host string, port string) {
+	host = domain.Hostname()
+	port = domain.Port()
+	if port == "" {
+		port = getDefaultPort(domain.Scheme)
+	}
+	return
+}
+
 // CalculateOverallScore returns the overall score for the specified URL
-/** The following sub-scores are calculated to determine the overall score
+/** The overall score is calculated from the results of the registered checks, run in the order of RegisteredChecks
  * Protocol Score
  * Response Headers Score
  * Mail Server Configuration Score
+ * Previous Vulnerabilities Score
+ * The checks skipped through the options, or not among the only checks they run, are left out of both the
+ * calculated and the maximum score. Concurrent scans of the same URL with the default options share a single scan,
+ * unless they report their checks as they complete or store their result under an idempotency key
  **/
-func CalculateOverallScore(scoresURL string) ([]byte, error) {
-	var host string
-	var port string
-	badges = nil
-	dbresponse := utils.FindEntry(scoresURL)
-	if dbresponse != "" {
-		return []byte(dbresponse), nil
+func CalculateOverallScore(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+	if !isCacheableScan(options) || (options != nil && (options.OnCheck != nil || options.IdempotencyKey != "")) {
+		return scanOverallScore(scoresURL, options)
 	}
-	domain, err := url.Parse(scoresURL)
+	response, err, _ := scanGroup.Do(getScanKey(scoresURL), func() (interface{}, error) {
+		return scanOverallScore(scoresURL, options)
+	})
 	if err != nil {
-		fmt.Println(err)
 		return nil, err
 	}
+	// every caller gets its own copy of the response shared between them
+	return append([]byte(nil), response.([]byte)...), nil
+}
 
-	protocol := domain.Scheme
-	if strings.Contains(domain.Host, ":") {
-		host, port, _ = net.SplitHostPort(domain.Host)
-	} else {
-		host = domain.Host
+// scanGroup runs a single scan at a time for each URL scanned with the default options
+var scanGroup singleflight.Group
+
+// scanOverallScore scans a URL, the scans served by CalculateOverallScore for concurrent identical requests
+var scanOverallScore = calculateOverallScore
+
+// getScanKey returns the normalized form of a URL, identifying the scans shared by concurrent requests. A URL without
+// scheme keeps no scheme, as its scan may fall back to http unlike the scan of the same URL over https
+func getScanKey(scoresURL string) string {
+	schemeURL, explicitScheme := utils.WithDefaultScheme(scoresURL)
+	key, _, err := utils.NormalizeURL(schemeURL)
+	if err != nil {
+		key = schemeURL
 	}
-	if port == "" {
-		port = getDefaultPort(protocol)
+	if !explicitScheme {
+		return strings.TrimPrefix(key, utils.DefaultScheme+":")
 	}
-	var maximumPossibleScore = new(int)
-	var calculatedScore = new(int)
-	*maximumPossibleScore = 5 // why is this initialized to 5?
+	return key
+}
 
-	protocolScore := CalculateProtocolScore(protocol)
-	*calculatedScore += protocolScore
+// isCacheableScan returns true when a scan runs with the default options. The cache only holds those scans, a scan
+// skipping or selecting checks, probing its own DKIM selectors, sending its own headers, using another scoring
+// profile, pinned to an IP, including the raw headers or crawling the site is neither served from nor stored in it
+func isCacheableScan(options *models.ScanOptions) bool {
+	if options == nil {
+		return true
+	}
+	profile, err := GetScoringProfile(options.GetProfile())
+	if err != nil {
+		return false
+	}
+	overrideIP, overrideSNI := options.GetTargetOverride()
+	return len(options.Skip) == 0 && len(options.Only) == 0 && len(options.DKIMSelectors) == 0 &&
+		len(options.Headers) == 0 && profile.Name == DefaultScoringProfile && overrideIP == "" && overrideSNI == "" &&
+		!options.IncludeRaw && options.CrawlDepth == 0
+}
 
-	responseHeaderScore, ServerDetail, ServerData, err := GetResponseHeaderScore(scoresURL)
+// calculateOverallScore scans a URL and scores it, unless its score is found in the cache
+func calculateOverallScore(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+	var host string
+	var port string
+	start := time.Now()
+	result := "error"
+	defer func() {
+		utils.ScoreCalculations.WithLabelValues(result).Inc()
+		utils.ScoreCalculationDuration.Observe(time.Since(start).Seconds())
+	}()
+	// A URL without scheme is scanned over https first, and over http only when https fails
+	scoresURL, explicitScheme := utils.WithDefaultScheme(scoresURL)
+	profile, err := GetScoringProfile(options.GetProfile())
+	if err != nil {
+		return nil, err
+	}
+	cacheable := isCacheableScan(options)
+	onlyNames, only := getOnlyChecks(options)
+	if cacheable {
+		dbresponse := utils.FindEntry(scoresURL)
+		if dbresponse != "" {
+			result = "cached"
+			return []byte(dbresponse), nil
+		}
+	}
+	// Unicode hosts are scanned through their punycode form, while the response keeps the URL as requested
+	asciiURL, displayHost, err := utils.NormalizeURL(scoresURL)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+	domain, err := url.Parse(asciiURL)
 	if err != nil {
+		fmt.Println(err)
 		return nil, err
 	}
 
-	*maximumPossibleScore += responseHeaderScore.maximumValue
-	*calculatedScore += responseHeaderScore.value
+	punycode := domain.Hostname() != displayHost
+	// A scan pinned to an IP opens its connections to that IP, while the domain is presented through SNI and the Host header
+	pinnedIP, err := applyTargetOverride(domain, options)
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
+	asciiURL = domain.String()
 
-	mailServerScore, txtRecords, dmarcRecords := GetMailServerConfigurationScore(MailServerConfigParams{host, maximumPossibleScore})
-	*calculatedScore += mailServerScore
+	host, _ = getHostAndPort(domain)
+	// the DNS lookups are deduplicated within the scan only
+	scanCtx := utils.WithDNSCache(context.Background(), utils.NewDNSCache())
+	if pinnedIP != nil {
+		scanCtx = utils.WithDialOverride(scanCtx, host, pinnedIP)
+	}
+	// Internal targets are rejected before any request is sent, so that the scanner cannot be used to reach them
+	ctx, cancel := context.WithTimeout(scanCtx, TargetValidationTimeout)
+	err = models.ClassifyScanError(utils.ValidateTarget(ctx, host))
+	cancel()
+	if err != nil {
+		fmt.Println(err)
+		return nil, err
+	}
 
-	overallScore := math.Ceil((float64(float64(*calculatedScore)/float64(*maximumPossibleScore)))*100) / 100
-	fmt.Println("Final Score for: " + scoresURL + " is " + strconv.Itoa(*calculatedScore) + " out of " + strconv.Itoa(*maximumPossibleScore))
+	responseHeaderScore, _, ServerData, err := getResponseHeaderScore(scanCtx, asciiURL, options.GetHeaders())
+	if err != nil && !explicitScheme && isHTTPSFailure(err) {
+		fmt.Println("Falling back to http for "+scoresURL, err)
+		domain.Scheme = "http"
+		asciiURL = domain.String()
+		scoresURL = "http" + strings.TrimPrefix(scoresURL, utils.DefaultScheme)
+		responseHeaderScore, _, ServerData, err = getResponseHeaderScore(scanCtx, asciiURL, options.GetHeaders())
+	}
+	if err != nil {
+		return nil, models.ClassifyScanError(err)
+	}
+	protocol := domain.Scheme
+	host, port = getHostAndPort(domain)
+	builder := models.NewScoreBuilder()
+	builder.SetProfile(profile)
+	builder.SetOrder(getCheckOrder())
+	builder.SetOnly(only)
 
-	certificates, certError := models.GetCertificate(host, port, protocol)
-	if certError != nil {
-		return nil, certError
+	target := &ScanTarget{
+		URL:      asciiURL,
+		Host:     host,
+		Port:     port,
+		Protocol: protocol,
+		Options:  options,
+		only:     only,
+		response: &responseHeaderScore,
+	}
+	// the checks scored from the response are scored again from its observations, the others are kept as observed
+	var observedChecks []*models.CheckResult
+	var observedBadges []*models.Badge
+	reported := 0
+	for _, check := range RegisteredChecks() {
+		checkResult := check.Run(scanCtx, target)
+		checks, badges := len(builder.Checks()), len(builder.Badges())
+		for _, checkScore := range checkResult.Checks {
+			builder.AddCheck(checkScore)
+		}
+		for _, badge := range checkResult.Badges {
+			builder.AddBadge(badge)
+		}
+		if !checkResult.FromResponse {
+			observedChecks = append(observedChecks, builder.Checks()[checks:]...)
+			observedBadges = append(observedBadges, builder.Badges()[badges:]...)
+		}
+		reported = reportChecks(options, builder, reported)
 	}
 
-	scores := models.GetScores(scoresURL, overallScore, badges)
-	response := models.BuildScoresResponse(scores, certificates, nil, ServerDetail)
+	calculatedScore, maximumPossibleScore := builder.Totals()
+	fmt.Println("Final Score for: " + scoresURL + " is " + strconv.Itoa(calculatedScore) + " out of " + strconv.Itoa(maximumPossibleScore))
+
+	// The response is scored from the observations of the scan, which are stored with its result so that it can
+	// be rescored later on
+	observations := responseHeaderScore.observations
+	observations.URL = scoresURL
+	if punycode {
+		observations.PunycodeURL = asciiURL
+	}
+	observations.Protocol = protocol
+	observations.Checks = observedChecks
+	observations.Badges = observedBadges
+	observations.Cert = target.cert
+	observations.Incidents = target.incidents
+	if !options.IsSkipped(SkipDNS) && only == nil {
+		observations.HostInfo = GetHostInfoContext(scanCtx, host)
+	}
+	observations.Profile = profile.Name
+	observations.Only = onlyNames
+	response, err := ScoreObservations(observations)
+	if err != nil {
+		return nil, err
+	}
+	if options.IsRawIncluded() {
+		response.RawHeaders = redactHeaders(observations.Headers)
+	}
+	if crawlDepth := options.GetCrawlDepth(); crawlDepth > 0 {
+		response.Crawl = crawlSite(scanCtx, &responseHeaderScore, crawlDepth, options.GetHeaders(), only)
+	}
+	overallScore := response.Scores.Score
 	responseBody, err := json.Marshal(response)
 	serverdataJSON, serverdataJSONerr := json.Marshal(ServerData)
 	if serverdataJSONerr != nil {
 		fmt.Println("Error Occured while parsing Server Data JSON", serverdataJSONerr)
 	}
+	incidentListJSON, incidentListJSONerr := json.Marshal(target.incidents)
+	if incidentListJSONerr != nil {
+		fmt.Println("Error Occured while parsing Incident List JSON", incidentListJSONerr)
+	}
 
 	entry := &models.Domain{
 		Name:         scoresURL,
 		ServerData:   string(serverdataJSON),
-		TxtRecords:   txtRecords,
-		DmarcRecords: dmarcRecords,
+		TxtRecords:   target.txtRecords,
+		DmarcRecords: target.dmarcRecords,
 		Response:     string(responseBody),
-		IncidentList: "",
+		IncidentList: string(incidentListJSON),
 		Score:        overallScore,
 	}
-	utils.CreateEntry(entry)
+	if cacheable {
+		utils.CreateEntry(entry)
+	}
+	result = "scanned"
+	scanResult := models.GetScanResult(host, scoresURL, overallScore)
+	scanResult.Response = string(responseBody)
+	observationsJSON, observationsJSONerr := json.Marshal(observations)
+	if observationsJSONerr != nil {
+		fmt.Println("Error Occured while parsing Observations JSON", observationsJSONerr)
+	}
+	scanResult.Observations = string(observationsJSON)
+	scanResult.IdempotencyKey = options.GetIdempotencyKey()
+	scanResult.IdempotencyFingerprint = options.GetIdempotencyFingerprint()
+	saveErr := ResultStore.Save(context.Background(), scanResult)
+	if saveErr != nil {
+		fmt.Println("Error Occured while saving the Scan Result", saveErr)
+	}
 	return responseBody, err
 }
 
+// isHTTPSFailure returns true when the request failed over https for a reason that http may not share,
+// a host that does not resolve, is internal or redirects in a loop fails over both
+func isHTTPSFailure(err error) bool {
+	return !errors.Is(models.ClassifyScanError(err), models.ErrInvalidDomain) && !errors.Is(err, utils.ErrPrivateTarget) &&
+		!errors.Is(err, utils.ErrRedirectLoop)
+}
+
+// getCheckOrder returns the names of the checks reported first, in that order, from CHECK_ORDER or DefaultCheckOrder
+func getCheckOrder() []string {
+	if order := utils.GetCheckOrder(); len(order) > 0 {
+		return order
+	}
+	return DefaultCheckOrder
+}
+
+// redactHeaders returns a copy of the response headers with the values of the headers of REDACTED_HEADERS replaced
+func redactHeaders(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for name, value := range headers {
+		redacted[name] = value
+	}
+	for _, name := range utils.GetRedactedHeaders() {
+		if _, ok := redacted[name]; ok {
+			redacted[name] = utils.RedactedValue
+		}
+	}
+	return redacted
+}
+
+// getComplianceViolations returns the headers of REQUIRED_HEADERS missing from the response headers, in that order
+func getComplianceViolations(headers map[string]string) (missing []string) {
+	for _, name := range utils.GetRequiredHeaders() {
+		if strings.TrimSpace(headers[name]) == "" {
+			missing = append(missing, name)
+		}
+	}
+	return
+}
+
+// reportChecks passes the checks added to the builder since the last report to the OnCheck callback of the options,
+// and returns the number of checks reported so far
+func reportChecks(options *models.ScanOptions, builder *models.ScoreBuilder, reported int) int {
+	checks := builder.Checks()
+	if options == nil || options.OnCheck == nil {
+		return len(checks)
+	}
+	addRemediations(checks[reported:])
+	models.SortChecks(checks[reported:], getCheckOrder())
+	for _, check := range checks[reported:] {
+		options.OnCheck(check)
+	}
+	return len(checks)
+}
+
+// addRemediations attaches the remediation to every check that did not get its full score
+func addRemediations(checks []*models.CheckResult) {
+	for _, check := range checks {
+		if check.Score < check.MaxScore {
+			check.Remediation = Remediations[check.Name]
+		}
+	}
+}
+
 // HeaderScore represents a header score with value, meta and maxValue
 type HeaderScore struct {
 	value        int
 	meta         string
 	maximumValue int
+	// name of the check being scored, reported in the per-check breakdown
+	name                string
+	message             string
+	findings            []string
+	inconclusive        bool
+	checkMaximumValue   int
+	checks              []*models.CheckResult
+	badges              []*models.Badge
+	hstsPreloadEligible bool
+	clearSiteData       []string
+	// TLS versions negotiated by the request and the highest supported by the server
+	negotiatedTLSVersion uint16
+	maxTLSVersion        uint16
+	// HTTP versions of the request and the highest supported by the server, as negotiated through ALPN
+	negotiatedHTTPVersion string
+	maxHTTPVersion        string
+	// URLs visited while following redirects, starting with the requested URL and ending with the scored one
+	redirectChain         []string
+	crossHostRedirect     bool
+	redirectLimitExceeded bool
+	// HTTP method of the request the headers were scored from
+	requestMethod string
+	// observations of the response the headers were scored from
+	observations *models.Observations
 }
 
 // ResponseHeader returns a pointer to a the HeaderScore struct
@@ -132,65 +409,295 @@ type ResponseHeader func(hScore *HeaderScore) error
 func BuildResponseHeaderScore(opts ...ResponseHeader) (*HeaderScore, error) {
 	var hScore HeaderScore
 	for _, opt := range opts {
+		previousValue := hScore.value
+		hScore.message = ""
+		hScore.findings = nil
+		hScore.inconclusive = false
+		// every header is worth 5 points unless the check lowers its own weight
+		hScore.checkMaximumValue = 5
 		err := opt(&hScore)
 		if err != nil {
 			return nil, err
 		}
-		hScore.maximumValue += 5
+		hScore.maximumValue += hScore.checkMaximumValue
+		check := models.GetCheckResult(hScore.name, hScore.value-previousValue, hScore.checkMaximumValue)
+		check.Message = hScore.message
+		check.Findings = hScore.findings
+		check.Inconclusive = hScore.inconclusive
+		hScore.checks = append(hScore.checks, check)
 	}
 	return &hScore, nil
 }
 
 // GetResponseHeaderScore returns a cumulative score based on the response headers for the specified URL
 func GetResponseHeaderScore(url string) (reponseHeaderScore HeaderScore, serverInfo *models.ServerDetail, serverData map[string]string, err error) {
+	return getResponseHeaderScore(context.Background(), url, nil)
+}
+
+// getResponseHeaderScore is GetResponseHeaderScore with the requests bound to ctx and sending the custom headers
+func getResponseHeaderScore(ctx context.Context, url string, headers map[string]string) (reponseHeaderScore HeaderScore, serverInfo *models.ServerDetail, serverData map[string]string, err error) {
 	err = utils.IsValidURL(url)
 	if err != nil {
 		return reponseHeaderScore, nil, nil, err
 	}
 	var responseHeaderMap map[string]string
-	// Initializing client to avoid Redirects
+	// Redirects are followed so the final destination is scored, every hop is recorded in the chain. Past the
+	// configured number of redirects, the last response is scored and the chain is reported as capped
+	redirectChain := []string{url}
+	crossHostRedirect := false
+	redirectLimitExceeded := false
+	maxRedirects := utils.GetMaxRedirects()
 	client := &http.Client{
+		Transport: utils.HTTPTransport,
+		Timeout:   utils.RequestTimeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
+			for _, previous := range via {
+				if previous.URL.String() == req.URL.String() {
+					return utils.ErrRedirectLoop
+				}
+			}
+			if len(via) > maxRedirects {
+				redirectLimitExceeded = true
+				return http.ErrUseLastResponse
+			}
+			// a public URL must not be able to redirect the scanner to an internal one
+			if err := utils.ValidateTarget(req.Context(), req.URL.Hostname()); err != nil {
+				return err
+			}
+			redirectChain = append(redirectChain, req.URL.String())
+			if !strings.EqualFold(req.URL.Hostname(), via[0].URL.Hostname()) {
+				crossHostRedirect = true
+			}
+			return nil
 		}}
-	response, err := client.Head(url)
+	method := http.MethodHead
+	probe := newReflectionProbe()
+	response, err := sendHeaderRequest(ctx, client, method, url, headers, probe)
 	if err != nil {
 		fmt.Println(err)
 		return reponseHeaderScore, nil, nil, err
 	}
+	// Servers rejecting HEAD, or answering it without any header, are scored from a GET request instead
+	if isHeadRejected(response) {
+		response.Body.Close()
+		method = http.MethodGet
+		redirectChain = []string{url}
+		crossHostRedirect = false
+		redirectLimitExceeded = false
+		response, err = sendHeaderRequest(ctx, client, method, url, headers, probe)
+		if err != nil {
+			fmt.Println(err)
+			return reponseHeaderScore, nil, nil, err
+		}
+		// only the headers are scored, the body is read up to a bound and discarded
+		if _, _, readErr := utils.ReadBody(response.Body, HeaderFallbackMaxBodySize); readErr != nil {
+			fmt.Println("Error Occured while reading the body of "+url, readErr)
+		}
+	}
+	defer response.Body.Close()
 	responseHeaderMap = make(map[string]string)
-	// Constructing Response Header Map
+	// Constructing Response Header Map, the values of a repeated header are joined while its anomalies are
+	// detected from the raw values
 	for k, v := range response.Header {
 		value := strings.Join(v, ",")
 		responseHeaderMap[k] = value
 	}
+	observations := &models.Observations{
+		ResponseProtocol:      response.Request.URL.Scheme,
+		Headers:               responseHeaderMap,
+		HeaderWarnings:        getHeaderAnomalies(response.Header, probe),
+		CORSOriginReflected:   isCORSOriginReflected(response.Header, probe),
+		HTTPSRedirect:         getHTTPSRedirect(response, redirectChain),
+		Proto:                 response.Proto,
+		MaxProto:              getMaxHTTPVersion(response),
+		MaxTLSVersion:         getMaxTLSVersion(response),
+		TLSHello:              getTLSHello(response),
+		SessionResumption:     getSessionResumption(response),
+		RedirectChain:         redirectChain,
+		CrossHostRedirect:     crossHostRedirect,
+		RedirectLimitExceeded: redirectLimitExceeded,
+		RequestMethod:         method,
+	}
+	if response.TLS != nil {
+		observations.TLSVersion = response.TLS.Version
+		observations.OCSPStapled = len(response.TLS.OCSPResponse) > 0
+		observations.CertificateTransparency = hasSCTs(response.TLS)
+	}
 	// Calculating Scores for Individual Headers
-	responseHeaderScore, err := BuildResponseHeaderScore(
-		GetXSSScore(responseHeaderMap[XSSHeader]),
-		GetXFrameScore(responseHeaderMap[XFrameHeader]),
-		GetHSTSScore(responseHeaderMap[HSTSHeader]),
-		GetCSPScore(responseHeaderMap[CSPHeader]),
-		GetPKPScore(responseHeaderMap[PKPHeader]),
-		GetReferrerPolicyScore(responseHeaderMap[RPHeader]),
-		GetXContentTypeScore(responseHeaderMap[XContentTypeHeader]),
-		GetHTTPVersionScore(response.Proto),
-		GetTLSVersionScore(response.TLS),
-	)
+	responseHeaderScore, err := scoreResponseObservations(observations)
+	if err != nil {
+		return reponseHeaderScore, nil, nil, err
+	}
 
 	serverInfo = getServerInformation(responseHeaderMap[Server])
 	serverData = responseHeaderMap
 	return *responseHeaderScore, serverInfo, serverData, err
 }
 
+// scoreResponseObservations scores the response headers of the observations, along with the versions and the
+// redirects of the request they were observed from
+func scoreResponseObservations(observations *models.Observations) (*HeaderScore, error) {
+	var TLS *tls.ConnectionState
+	if observations.TLSVersion != 0 {
+		TLS = &tls.ConnectionState{Version: observations.TLSVersion}
+		// only the presence of a stapled OCSP response and of SCTs is scored, their content is not observed
+		if observations.OCSPStapled {
+			TLS.OCSPResponse = []byte{0}
+		}
+		if observations.CertificateTransparency {
+			TLS.SignedCertificateTimestamps = [][]byte{{0}}
+		}
+	}
+	responseHeaderScore, err := BuildResponseHeaderScore(
+		getResponseHeaders(observations.Headers, observations.ResponseProtocol, observations.Proto, observations.MaxProto, TLS, observations.MaxTLSVersion, observations.TLSHello, observations.SessionResumption, observations.CORSOriginReflected, observations.HTTPSRedirect)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, check := range responseHeaderScore.checks {
+		check.Warnings = observations.HeaderWarnings[check.Name]
+	}
+	responseHeaderScore.redirectChain = observations.RedirectChain
+	responseHeaderScore.crossHostRedirect = observations.CrossHostRedirect
+	responseHeaderScore.redirectLimitExceeded = observations.RedirectLimitExceeded
+	responseHeaderScore.requestMethod = observations.RequestMethod
+	responseHeaderScore.observations = observations
+	return responseHeaderScore, nil
+}
+
+// sendHeaderRequest sends the request whose response headers are scored, along with the custom headers and the
+// reflection probe
+func sendHeaderRequest(ctx context.Context, client *http.Client, method string, url string, headers map[string]string, probe string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the probe is sent as the Origin as well, unless overridden, to detect a CORS policy allowing any origin
+	if probe != "" {
+		request.Header.Set("Origin", getCORSProbeOrigin(probe))
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	if probe != "" {
+		request.Header.Set(ReflectionProbeHeader, probe)
+	}
+	return client.Do(request)
+}
+
+// isHeadRejected returns true when the server answered a HEAD request as not allowed or not implemented, or without
+// any header, so that its headers cannot be trusted to be those of a GET request
+func isHeadRejected(response *http.Response) bool {
+	return response.StatusCode == http.StatusMethodNotAllowed || response.StatusCode == http.StatusNotImplemented ||
+		len(response.Header) == 0
+}
+
+// getResponseHeaders returns the scorers of the individual response headers, in the order they are reported
+func getResponseHeaders(headers map[string]string, protocol string, proto string, maxProto string, TLS *tls.ConnectionState, maxTLSVersion uint16, hello *models.TLSHello, resumption bool, corsReflected bool, redirect *bool) []ResponseHeader {
+	return []ResponseHeader{
+		GetXSSScore(headers[XSSHeader]),
+		GetXFrameScore(headers[XFrameHeader], headers[CSPHeader]),
+		GetHSTSScore(headers[HSTSHeader], protocol),
+		GetTransportSecurityScore(protocol, headers[HSTSHeader], redirect),
+		GetCSPScore(headers[CSPHeader]),
+		GetPKPScore(headers[PKPHeader]),
+		GetReferrerPolicyScore(headers[RPHeader]),
+		GetXContentTypeScore(headers[XContentTypeHeader]),
+		GetCrossDomainPolicyScore(headers[CrossDomainPolicyHeader]),
+		GetCrossOriginIsolationScore(headers),
+		GetClearSiteDataScore(headers[ClearSiteDataHeader]),
+		GetCacheControlScore(headers[CacheControlHeader]),
+		GetReportingScore(headers),
+		GetCORSScore(headers, corsReflected),
+		GetServerScore(headers[Server]),
+		GetHTTPVersionScore(proto, maxProto),
+		GetTLSVersionScore(TLS, maxTLSVersion),
+		GetOCSPStaplingScore(TLS),
+		GetCertificateTransparencyScore(TLS),
+		GetTLSRenegotiationScore(TLS, hello),
+		GetSessionResumptionScore(TLS, resumption),
+	}
+}
+
+// getMaxHTTPVersion confirms through ALPN whether the server of an HTTPS response supports HTTP/2, which the request
+// may not have negotiated, and returns the highest HTTP version it supports, empty when unknown
+func getMaxHTTPVersion(response *http.Response) string {
+	if response.TLS == nil {
+		return ""
+	}
+	host, port := getHostAndPort(response.Request.URL)
+	protocol, err := alpnProtocol(response.Request.Context(), host, port)
+	if err != nil {
+		fmt.Println("Error Occured while negotiating the HTTP version of "+host, err)
+		return ""
+	}
+	if protocol == "h2" {
+		return HTTPVersion[0]
+	}
+	return HTTPVersion[1]
+}
+
+// getMaxTLSVersion discovers the highest TLS version supported by the server of an HTTPS response, 0 when unknown
+func getMaxTLSVersion(response *http.Response) uint16 {
+	if response.TLS == nil {
+		return 0
+	}
+	host, port := getHostAndPort(response.Request.URL)
+	version, err := maxTLSVersion(response.Request.Context(), host, port)
+	if err != nil {
+		fmt.Println("Error Occured while discovering the maximum TLS version of "+host, err)
+		return 0
+	}
+	return version
+}
+
+// getTLSHello returns the TLS compression and renegotiation support of the server of an HTTPS response, nil when unknown
+func getTLSHello(response *http.Response) *models.TLSHello {
+	if response.TLS == nil {
+		return nil
+	}
+	host, port := getHostAndPort(response.Request.URL)
+	hello, err := tlsHello(response.Request.Context(), host, port)
+	if err != nil {
+		fmt.Println("Error Occured while checking the TLS compression and renegotiation of "+host, err)
+		return nil
+	}
+	return hello
+}
+
+// getSessionResumption returns true when the server of an HTTPS response resumes TLS sessions, false when it does not
+// or when it is unknown
+func getSessionResumption(response *http.Response) bool {
+	if response.TLS == nil {
+		return false
+	}
+	host, port := getHostAndPort(response.Request.URL)
+	resumed, err := sessionResumption(response.Request.Context(), host, port)
+	if err != nil {
+		fmt.Println("Error Occured while checking the TLS session resumption of "+host, err)
+		return false
+	}
+	return resumed
+}
+
 // GetXSSScore returns the XSS Score of the URL
 func GetXSSScore(XSSValue string) ResponseHeader {
 	return func(xssHScore *HeaderScore) error {
+		xssHScore.name = XSSHeader
 		if XSSValue != "" {
 			XSSValue = strings.TrimSpace(XSSValue)
+			xssHScore.message = utils.XSSModernGuidanceMessage
 			if XSSValue == XSSValues[0] {
 				xssHScore.value += 0
 			} else if strings.HasPrefix(XSSValue, XSSValues[1]) {
-				xssHScore.value += 5
+				// mode=block stops the page from rendering instead of sanitising it
+				xssHScore.value += 4
+				for _, directive := range strings.Split(XSSValue, ";")[1:] {
+					if strings.ToLower(strings.Replace(directive, " ", "", -1)) == XSSModeBlock {
+						xssHScore.value++
+						break
+					}
+				}
 			}
 			XSSValueReport := strings.Split(XSSValue, "report=")
 			if len(XSSValueReport) == 2 {
@@ -202,36 +709,121 @@ func GetXSSScore(XSSValue string) ResponseHeader {
 }
 
 // GetXFrameScore returns the HTTP X-Frame-Options Response Header Score of the URL
-func GetXFrameScore(XFrameValue string) ResponseHeader {
+// The frame-ancestors directive of the Content-Security-Policy supersedes the header and scores the same
+func GetXFrameScore(XFrameValue string, CSP string) ResponseHeader {
 	return func(xFrameScore *HeaderScore) error {
-		if XFrameValue != "" {
-			XFrameValue = strings.TrimSpace(strings.ToLower(XFrameValue))
-			if XFrameValue == XFrameValues[0] || XFrameValue == XFrameValues[1] {
-				badges = append(badges, utils.GetXFrameBadge())
-				xFrameScore.value += 5
-			} else if strings.HasPrefix(XFrameValue, XFrameValues[2]) {
-				xFrameScore.value += 4
-			}
-		} else {
-			xFrameScore.value++
+		xFrameScore.name = XFrameHeader
+		XFrameValue = strings.TrimSpace(strings.ToLower(XFrameValue))
+		if XFrameValue == XFrameValues[0] || XFrameValue == XFrameValues[1] || hasFrameAncestors(CSP) {
+			xFrameScore.badges = append(xFrameScore.badges, utils.GetXFrameBadge())
+			xFrameScore.value += 5
+		} else if strings.HasPrefix(XFrameValue, XFrameValues[2]) {
+			// ALLOW-FROM is ignored by modern browsers, which then allow the page to be framed by any site
+			xFrameScore.value += XFrameAllowFromScore
+			xFrameScore.message = utils.XFrameAllowFromMessage
 		}
 		return nil
 	}
 }
 
+// getCSPDirective returns the sources of a Content-Security-Policy directive, ok is false when the policy does not set it
+func getCSPDirective(CSP string, name string) (sources []string, ok bool) {
+	for _, directive := range strings.Split(CSP, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) > 0 && strings.EqualFold(fields[0], name) {
+			return fields[1:], true
+		}
+	}
+	return nil, false
+}
+
+// hasFrameAncestors returns true when the Content-Security-Policy restricts the sites allowed to frame the page
+func hasFrameAncestors(CSP string) bool {
+	sources, ok := getCSPDirective(CSP, CSPFrameAncestorsDirective)
+	if !ok {
+		return false
+	}
+	for _, source := range sources {
+		if source == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// HSTSPolicy holds the directives parsed from a Strict-Transport-Security Header
+type HSTSPolicy struct {
+	MaxAge            int64
+	ValidMaxAge       bool
+	IncludeSubDomains bool
+	Preload           bool
+}
+
+// ParseHSTS parses the directives of a Strict-Transport-Security Header
+func ParseHSTS(HSTS string) (policy HSTSPolicy) {
+	for _, directive := range strings.Split(HSTS, ";") {
+		directive = strings.TrimSpace(directive)
+		name := strings.ToLower(directive)
+		if strings.HasPrefix(name, HSTSValues[0]) {
+			// the name matched case-insensitively, so the value is sliced off rather than trimmed by the lowercase name
+			value := strings.TrimSpace(directive[len(HSTSValues[0]):])
+			if !strings.HasPrefix(value, "=") {
+				continue
+			}
+			maxAge, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(value[1:]), `"`), 10, 64)
+			if err == nil && maxAge >= 0 {
+				policy.MaxAge = maxAge
+				policy.ValidMaxAge = true
+			}
+		} else if name == strings.ToLower(HSTSValues[1]) {
+			policy.IncludeSubDomains = true
+		} else if name == HSTSValues[2] {
+			policy.Preload = true
+		}
+	}
+	return
+}
+
+// IsHSTSPreloadEligible returns true when the header meets the requirements of the HSTS preload list
+func IsHSTSPreloadEligible(HSTS string) bool {
+	policy := ParseHSTS(HSTS)
+	return policy.ValidMaxAge && policy.MaxAge >= HSTSPreloadMinMaxAge && policy.IncludeSubDomains && policy.Preload
+}
+
 // GetHSTSScore returns the HTTP Strict-Transport-Security Response Header Score of the URL
-func GetHSTSScore(HSTS string) ResponseHeader {
+// Browsers ignore the header when it is not delivered over HTTPS, so no points are awarded for other protocols.
+// A max-age below HSTSMinMaxAge loses a point, and most of the score when it is below HSTSShortMaxAge, as the
+// policy expires between the visits it is meant to protect
+func GetHSTSScore(HSTS string, protocol string) ResponseHeader {
 	return func(hstsScore *HeaderScore) error {
+		hstsScore.name = HSTSHeader
+		if protocol != "https" {
+			hstsScore.message = utils.HSTSOverHTTPMessage
+			return nil
+		}
 		if HSTS != "" {
-			if strings.HasPrefix(HSTS, HSTSValues[0]) {
-				hstsScore.value += 4
-				if strings.Contains(HSTS, HSTSValues[1]) || strings.Contains(HSTS, HSTSValues[2]) {
-					badges = append(badges, utils.GetHSTSBadge())
+			policy := ParseHSTS(HSTS)
+			if policy.ValidMaxAge {
+				minMaxAge := HSTSMinMaxAge
+				switch {
+				case policy.MaxAge < HSTSShortMaxAge && policy.MaxAge < minMaxAge:
+					hstsScore.value++
+				case policy.MaxAge < minMaxAge:
+					hstsScore.value += 3
+				default:
+					hstsScore.value += 4
+				}
+				if policy.MaxAge < minMaxAge {
+					hstsScore.message = fmt.Sprintf(utils.HSTSShortMaxAgeMessage, policy.MaxAge, minMaxAge)
+				}
+				if policy.IncludeSubDomains || policy.Preload {
+					hstsScore.badges = append(hstsScore.badges, utils.GetHSTSBadge())
+				}
+				if IsHSTSPreloadEligible(HSTS) {
+					hstsScore.hstsPreloadEligible = true
 					hstsScore.value++
 				}
 			}
-		} else {
-			hstsScore.value += 2
 		}
 		return nil
 	}
@@ -240,24 +832,23 @@ func GetHSTSScore(HSTS string) ResponseHeader {
 // GetCSPScore returns the score for Content Security Policy Header
 func GetCSPScore(CSP string) ResponseHeader {
 	return func(cspScore *HeaderScore) error {
+		cspScore.name = CSPHeader
 		if CSP != "" {
-			badges = append(badges, utils.GetCSPBadge())
+			cspScore.badges = append(cspScore.badges, utils.GetCSPBadge())
 			cspScore.value += 5
-		} else {
-			cspScore.value += 3
 		}
 		return nil
 	}
 }
 
-// GetPKPScore returns the score for Public Key Pinning Header
+// GetPKPScore returns the informational score for the Public Key Pinning Header. Browsers no longer enforce HPKP, so
+// the header is neither rewarded nor penalized and its weight went to the Certificate Transparency check
 func GetPKPScore(PKP string) ResponseHeader {
 	return func(pkpScore *HeaderScore) error {
+		pkpScore.name = PKPHeader
+		pkpScore.checkMaximumValue = 0
 		if PKP != "" {
-			badges = append(badges, utils.GetHPKPBadge())
-			pkpScore.value += 5
-		} else {
-			pkpScore.value += 3
+			pkpScore.message = utils.PKPDeprecatedMessage
 		}
 		return nil
 	}
@@ -266,12 +857,17 @@ func GetPKPScore(PKP string) ResponseHeader {
 // GetReferrerPolicyScore returns the HTTP Referrer-Policy Response Header Score of the URL
 func GetReferrerPolicyScore(ReferrerPolicy string) ResponseHeader {
 	return func(xReferrerPolicyScore *HeaderScore) error {
+		xReferrerPolicyScore.name = RPHeader
 		if ReferrerPolicy != "" {
-			ReferrerPolicy = strings.TrimSpace(strings.ToLower(ReferrerPolicy))
-			if score, ok := ReferrerPolicyValues[ReferrerPolicy]; ok {
-				xReferrerPolicyScore.value += score
-				if score >= 4 {
-					badges = append(badges, utils.GetRPBadge())
+			// browsers honor the last policy they recognise in a comma-separated fallback list
+			tokens := strings.Split(strings.ToLower(ReferrerPolicy), ",")
+			for index := len(tokens) - 1; index >= 0; index-- {
+				if score, ok := ReferrerPolicyValues[strings.TrimSpace(tokens[index])]; ok {
+					xReferrerPolicyScore.value += score
+					if score >= 4 {
+						xReferrerPolicyScore.badges = append(xReferrerPolicyScore.badges, utils.GetRPBadge())
+					}
+					break
 				}
 			}
 		}
@@ -282,8 +878,9 @@ func GetReferrerPolicyScore(ReferrerPolicy string) ResponseHeader {
 // GetXContentTypeScore returns the score for X-Content-Type-Options Header
 func GetXContentTypeScore(XContentType string) ResponseHeader {
 	return func(xContentTypeScore *HeaderScore) error {
+		xContentTypeScore.name = XContentTypeHeader
 		if XContentType == XContentTypeHeaderValue {
-			badges = append(badges, utils.GetXContentTypeBadge())
+			xContentTypeScore.badges = append(xContentTypeScore.badges, utils.GetXContentTypeBadge())
 			xContentTypeScore.value += 5
 		}
 		return nil
@@ -291,13 +888,143 @@ func GetXContentTypeScore(XContentType string) ResponseHeader {
 
 }
 
-// GetHTTPVersionScore returns the score for HTTP Version
-func GetHTTPVersionScore(Proto string) ResponseHeader {
+// GetCrossDomainPolicyScore returns the score for X-Permitted-Cross-Domain-Policies Header
+func GetCrossDomainPolicyScore(CrossDomainPolicy string) ResponseHeader {
+	return func(xCrossDomainPolicyScore *HeaderScore) error {
+		xCrossDomainPolicyScore.name = CrossDomainPolicyHeader
+		if CrossDomainPolicy != "" {
+			CrossDomainPolicy = strings.TrimSpace(strings.ToLower(CrossDomainPolicy))
+			if score, ok := CrossDomainPolicyValues[CrossDomainPolicy]; ok {
+				xCrossDomainPolicyScore.value += score
+				if score == 5 {
+					xCrossDomainPolicyScore.badges = append(xCrossDomainPolicyScore.badges, utils.GetCrossDomainPolicyBadge())
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// GetCrossOriginIsolationScore returns the combined score for the COOP, COEP and CORP Headers
+func GetCrossOriginIsolationScore(headers map[string]string) ResponseHeader {
+	return func(crossOriginIsolationScore *HeaderScore) error {
+		crossOriginIsolationScore.name = CrossOriginIsolationCheck
+		coop := strings.TrimSpace(strings.ToLower(headers[COOPHeader]))
+		coep := strings.TrimSpace(strings.ToLower(headers[COEPHeader]))
+		corp := strings.TrimSpace(strings.ToLower(headers[CORPHeader]))
+		crossOriginIsolationScore.value += COOPValues[coop] + COEPValues[coep] + CORPValues[corp]
+		// A document is only cross-origin isolated when both COOP and COEP are enforced
+		if COOPValues[coop] == 2 && COEPValues[coep] == 2 {
+			crossOriginIsolationScore.badges = append(crossOriginIsolationScore.badges, utils.GetCrossOriginIsolationBadge())
+		}
+		return nil
+	}
+}
+
+// ParseClearSiteData returns the known directives of a Clear-Site-Data Header
+func ParseClearSiteData(ClearSiteData string) (directives []string) {
+	for _, directive := range strings.Split(ClearSiteData, ",") {
+		directive = strings.Trim(strings.TrimSpace(directive), `"`)
+		for _, knownDirective := range ClearSiteDataValues {
+			if directive == knownDirective {
+				directives = append(directives, directive)
+			}
+		}
+	}
+	return
+}
+
+// GetCacheControlScore returns an informational score for the Cache-Control Header, rewarding the directives that keep
+// sensitive responses out of shared caches. Its weight is low as caching is fine for public content
+func GetCacheControlScore(CacheControl string) ResponseHeader {
+	return func(cacheControlScore *HeaderScore) error {
+		cacheControlScore.name = CacheControlHeader
+		cacheControlScore.checkMaximumValue = 2
+		score := 0
+		public := false
+		for _, directive := range strings.Split(CacheControl, ",") {
+			directive = strings.ToLower(strings.TrimSpace(strings.SplitN(directive, "=", 2)[0]))
+			if directive == CacheControlPublic {
+				public = true
+			}
+			if CacheControlValues[directive] > score {
+				score = CacheControlValues[directive]
+			}
+		}
+		// no-store keeps the response out of every cache, whatever else is declared
+		if public && score < CacheControlValues["no-store"] {
+			cacheControlScore.message = utils.CacheControlPublicMessage
+			score = 0
+		}
+		cacheControlScore.value += score
+		return nil
+	}
+}
+
+// serverVersionPattern matches a version in the Server Header, e.g. nginx/1.18.0 or Microsoft-IIS/10.0
+var serverVersionPattern = regexp.MustCompile(`/\s*v?\d|\d+\.\d+`)
+
+// GetServerScore returns the informational score for the Server Header, rewarding a server that does not reveal its
+// software and version to fingerprinting. It is given a low weight, as hiding it does not fix a vulnerable server
+func GetServerScore(server string) ResponseHeader {
+	return func(serverScore *HeaderScore) error {
+		serverScore.name = Server
+		serverScore.checkMaximumValue = 2
+		server = strings.TrimSpace(server)
+		switch {
+		case server == "":
+			serverScore.value += 2
+		case serverVersionPattern.MatchString(server):
+			serverScore.message = fmt.Sprintf(utils.ServerVersionMessage, server)
+		default:
+			serverScore.message = fmt.Sprintf(utils.ServerGenericMessage, server)
+			serverScore.value++
+		}
+		return nil
+	}
+}
+
+// GetClearSiteDataScore returns the informational score for the Clear-Site-Data Header
+// It is mostly sent on logout endpoints, so it is given a low weight
+func GetClearSiteDataScore(ClearSiteData string) ResponseHeader {
+	return func(clearSiteDataScore *HeaderScore) error {
+		clearSiteDataScore.name = ClearSiteDataHeader
+		clearSiteDataScore.checkMaximumValue = 2
+		directives := ParseClearSiteData(ClearSiteData)
+		if len(directives) == 0 {
+			return nil
+		}
+		clearSiteDataScore.clearSiteData = directives
+		clearSiteDataScore.message = fmt.Sprintf(utils.ClearSiteDataMessage, strings.Join(directives, ", "))
+		cleared := make(map[string]bool)
+		for _, directive := range directives {
+			cleared[directive] = true
+		}
+		if cleared["*"] || (cleared["cookies"] && cleared["storage"] && cleared["cache"]) {
+			clearSiteDataScore.value += 2
+		} else {
+			clearSiteDataScore.value++
+		}
+		return nil
+	}
+}
+
+// GetHTTPVersionScore returns the score for HTTP Version, the version of the request is upgraded to HTTP/2 when the
+// server confirmed it through ALPN
+func GetHTTPVersionScore(Proto string, maxProto string) ResponseHeader {
 	return func(xHTTPVersionScore *HeaderScore) error {
-		if Proto == HTTPVersion[0] {
-			badges = append(badges, utils.GetHTTPVersionBadge())
+		xHTTPVersionScore.name = HTTPVersionCheck
+		// the score is based on the best version the server supports, the request may have negotiated a lower one
+		version := Proto
+		if maxProto == HTTPVersion[0] {
+			version = maxProto
+		}
+		xHTTPVersionScore.negotiatedHTTPVersion = Proto
+		xHTTPVersionScore.maxHTTPVersion = version
+		if version == HTTPVersion[0] {
+			xHTTPVersionScore.badges = append(xHTTPVersionScore.badges, utils.GetHTTPVersionBadge())
 			xHTTPVersionScore.value += 5
-		} else if Proto == HTTPVersion[1] {
+		} else if version == HTTPVersion[1] {
 			xHTTPVersionScore.value += 2
 		}
 		return nil
@@ -306,15 +1033,23 @@ func GetHTTPVersionScore(Proto string) ResponseHeader {
 }
 
 // GetTLSVersionScore returns the score for TLS Version
-func GetTLSVersionScore(TLS *tls.ConnectionState) ResponseHeader {
+func GetTLSVersionScore(TLS *tls.ConnectionState, maxVersion uint16) ResponseHeader {
 	return func(xTLSVersionScore *HeaderScore) error {
+		xTLSVersionScore.name = TLSVersionCheck
 		if TLS != nil {
-			if TLS.Version == tls.VersionTLS12 {
-				badges = append(badges, utils.GetTLSVersionBadge())
+			// the score is based on the best version the server supports, the HEAD request may have negotiated a lower one
+			version := TLS.Version
+			if maxVersion > version {
+				version = maxVersion
+			}
+			xTLSVersionScore.negotiatedTLSVersion = TLS.Version
+			xTLSVersionScore.maxTLSVersion = version
+			if version == tls.VersionTLS13 || version == tls.VersionTLS12 {
+				xTLSVersionScore.badges = append(xTLSVersionScore.badges, utils.GetTLSVersionBadge())
 				xTLSVersionScore.value += 5
-			} else if TLS.Version == tls.VersionTLS11 {
+			} else if version == tls.VersionTLS11 {
 				xTLSVersionScore.value += 3
-			} else if TLS.Version == tls.VersionTLS10 {
+			} else if version == tls.VersionTLS10 {
 				xTLSVersionScore.value++
 			}
 		}
@@ -322,134 +1057,261 @@ func GetTLSVersionScore(TLS *tls.ConnectionState) ResponseHeader {
 	}
 }
 
-//MailServerConfigParams denotes args passed on to GetMailServerConfiguration
-type MailServerConfigParams struct {
-	host                 string
-	maximumPossibleScore *int
+// GetCertificateTransparencyScore returns the score for the Signed Certificate Timestamps of the certificate, which
+// prove that it was logged to Certificate Transparency so that a misissued certificate can be detected
+func GetCertificateTransparencyScore(TLS *tls.ConnectionState) ResponseHeader {
+	return func(certificateTransparencyScore *HeaderScore) error {
+		certificateTransparencyScore.name = CertificateTransparencyCheck
+		if hasSCTs(TLS) {
+			certificateTransparencyScore.value += 5
+		}
+		return nil
+	}
 }
 
-// GetMailServerConfigurationScore returns the Mail Server Configuration Score of a Domain
-func GetMailServerConfigurationScore(params MailServerConfigParams) (mailServerScore int, txtRecords string, dmarcRecord string) {
-	mailServerScore = 0
-	host := params.host
-	maximumPossibleScore := params.maximumPossibleScore
-
-	if strings.HasPrefix(host, "www.") {
-		host = strings.Replace(host, "www.", "", -1)
+// hasSCTs returns true when a TLS connection delivered Signed Certificate Timestamps, through the TLS extension or
+// embedded in the leaf certificate
+func hasSCTs(TLS *tls.ConnectionState) bool {
+	if TLS == nil {
+		return false
 	}
-
-	spfScore, maxSPFScore, txtRecords := GetSPFScore(host)
-	mailServerScore += spfScore
-
-	dmarcScore, dmarcRecord := GetDMARCScore(host)
-	mailServerScore += dmarcScore
-
-	if maximumPossibleScore != nil {
-		*maximumPossibleScore += maxSPFScore
-		*maximumPossibleScore += 5
+	if len(TLS.SignedCertificateTimestamps) > 0 {
+		return true
 	}
-
-	return
+	if len(TLS.PeerCertificates) == 0 {
+		return false
+	}
+	for _, extension := range TLS.PeerCertificates[0].Extensions {
+		if extension.Id.Equal(SCTListExtension) {
+			return true
+		}
+	}
+	return false
 }
 
-// GetSPFScore returns the Sender Policy Framework Score of the Domain
-func GetSPFScore(domain string) (spfScore int, maxSPFScore int, txtRecords string) {
-	command := strings.Replace(TXTQuery, "domain.com", domain, -1)
-	out, err := exec.Command("bash", "-c", command).Output()
-	txtRecords = string(out[:])
+// GetOCSPStaplingScore returns the score for an OCSP response stapled to the TLS Handshake, which spares the
+// clients a request to the Certificate Authority to check the revocation of the certificate
+func GetOCSPStaplingScore(TLS *tls.ConnectionState) ResponseHeader {
+	return func(ocspStaplingScore *HeaderScore) error {
+		ocspStaplingScore.name = OCSPStaplingCheck
+		ocspStaplingScore.checkMaximumValue = OCSPStaplingScore
+		if TLS != nil && len(TLS.OCSPResponse) > 0 {
+			ocspStaplingScore.value += OCSPStaplingScore
+		}
+		return nil
+	}
+}
 
-	if err != nil {
-		fmt.Println("Unexpected Error Occured while extracting TXT Records", err)
+// GetSessionResumptionScore returns the informational score for the TLS session resumption support of the server,
+// which spares the returning clients a full Handshake
+func GetSessionResumptionScore(TLS *tls.ConnectionState, resumption bool) ResponseHeader {
+	return func(sessionResumptionScore *HeaderScore) error {
+		sessionResumptionScore.name = SessionResumptionCheck
+		sessionResumptionScore.checkMaximumValue = SessionResumptionScore
+		if TLS != nil && resumption {
+			sessionResumptionScore.value += SessionResumptionScore
+		}
+		return nil
 	}
+}
 
-	scanner := bufio.NewScanner(strings.NewReader(txtRecords))
-	scanner.Split(bufio.ScanLines)
+// GetTLSRenegotiationScore returns the score for the TLS compression and renegotiation support of the server, with
+// a finding for every risk. The check is inconclusive when the server did not tell, and left out of the maximum score
+func GetTLSRenegotiationScore(TLS *tls.ConnectionState, hello *models.TLSHello) ResponseHeader {
+	return func(tlsRenegotiationScore *HeaderScore) error {
+		tlsRenegotiationScore.name = TLSRenegotiationCheck
+		if TLS == nil {
+			return nil
+		}
+		if hello == nil {
+			tlsRenegotiationScore.inconclusive = true
+			tlsRenegotiationScore.checkMaximumValue = 0
+			tlsRenegotiationScore.message = utils.TLSHelloInconclusiveMessage
+			return nil
+		}
+		tlsRenegotiationScore.value += tlsRenegotiationScore.checkMaximumValue
+		if hello.Compression {
+			tlsRenegotiationScore.value -= TLSCompressionPenalty
+			tlsRenegotiationScore.findings = append(tlsRenegotiationScore.findings, utils.TLSCompressionMessage)
+		}
+		if !hello.SecureRenegotiation {
+			tlsRenegotiationScore.value -= InsecureRenegotiationPenalty
+			tlsRenegotiationScore.findings = append(tlsRenegotiationScore.findings, utils.InsecureRenegotiationMessage)
+		}
+		return nil
+	}
+}
 
-	spfRecordCount := 0
-	spfScore = 0
+// MailServerConfigParams denotes args passed on to GetMailServerConfiguration
+type MailServerConfigParams struct {
+	host          string
+	dkimSelectors []string
+	// builder receives the SPF and DMARC checks, it is left nil when only the score is of interest
+	builder *models.ScoreBuilder
+	// ctx bounds the checks, those not started when it is done are left out, a nil ctx never is
+	ctx context.Context
+}
 
-	for scanner.Scan() {
-		txtRecord := scanner.Text()
-		// Removing Surrounding Quotes and trimming spaces
-		txtRecord = strings.TrimSpace(txtRecord[1 : len(txtRecord)-1])
-		if strings.HasSuffix(txtRecord, "-all") {
-			spfScore += 5
-			spfRecordCount++
+// mailCheckConcurrency is the number of DNS-based mail checks run at the same time
+var mailCheckConcurrency = MaxConcurrentMailChecks
 
-		} else if strings.HasSuffix(txtRecord, "~all") {
-			spfScore += 3
-			spfRecordCount++
+// GetMailServerConfigurationScore returns the Mail Server Configuration Score of a Domain. The checks are independent
+// lookups, so they run concurrently through the DNS cache of the scan, while they are aggregated in a fixed order
+func GetMailServerConfigurationScore(params MailServerConfigParams) (mailServerScore int, txtRecords string, dmarcRecord string) {
+	mailServerScore = 0
+	// the domain-level checks query the registrable domain, so that every host of a site gets the same results
+	host := utils.RegistrableDomain(params.host)
+	builder := params.builder
+	ctx := params.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
-		} else if strings.HasSuffix(txtRecord, "?all") {
-			spfScore += 2
-			spfRecordCount++
+	var spfCheck, dmarcCheck, dkimCheck, bimiCheck *models.CheckResult
+	var spfBadge bool
+	runConcurrently(ctx, mailCheckConcurrency, []func(){
+		func() {
+			spfScore, maxSPFScore, records, spfFindings := GetSPFScoreContext(ctx, host)
+			txtRecords = records
+			spfCheck = models.GetCheckResult(SPFCheck, spfScore, maxSPFScore)
+			spfCheck.Findings = spfFindings
+			spfBadge = maxSPFScore > 0 && spfScore == maxSPFScore
+		},
+		func() {
+			var dmarcScore int
+			dmarcScore, dmarcRecord = GetDMARCScoreContext(ctx, host)
+			dmarcCheck = models.GetCheckResult(DMARCCheck, dmarcScore, 5)
+		},
+		func() {
+			dkimScore, maxDKIMScore, dkimFindings := GetDKIMScoreContext(ctx, host, params.dkimSelectors)
+			dkimCheck = models.GetCheckResult(DKIMCheck, dkimScore, maxDKIMScore)
+			dkimCheck.Findings = dkimFindings
+		},
+		func() {
+			bimiCheck = models.GetCheckResult(BIMICheck, GetBIMIScoreContext(ctx, host), BIMIScore)
+		},
+	})
 
-		} else if strings.HasSuffix(txtRecord, "+all") {
-			spfRecordCount++
+	for _, check := range []*models.CheckResult{spfCheck, dmarcCheck, dkimCheck, bimiCheck} {
+		if check == nil {
+			continue
+		}
+		mailServerScore += check.Score
+		if builder != nil {
+			builder.AddCheck(check)
 		}
 	}
-	maxSPFScore = spfRecordCount * 5
-	if spfScore == maxSPFScore {
-		badges = append(badges, utils.GetSPFBadge())
+	if builder != nil && spfBadge {
+		builder.AddBadge(utils.GetSPFBadge())
 	}
+
 	return
 }
 
+// runConcurrently runs the tasks with at most limit of them at a time and waits for them, the tasks not started
+// when ctx is done are dropped
+func runConcurrently(ctx context.Context, limit int, tasks []func()) {
+	pool := utils.NewWorkerPool(ctx, limit)
+	for _, task := range tasks {
+		if !pool.Submit(task) {
+			break
+		}
+	}
+	pool.Wait()
+}
+
 // GetDMARCScore returns the DMARC Score of the Domain
 func GetDMARCScore(domain string) (score int, dmarcRecord string) {
-	command := strings.Replace(DMARCQuery, "domain.com", domain, -1)
-	out, err := exec.Command("bash", "-c", command).Output()
-	dmarcRecord = string(out[:])
-
-	score = 0
+	return GetDMARCScoreContext(context.Background(), domain)
+}
 
+// GetDMARCScoreContext is GetDMARCScore with the lookup made through the DNS cache of ctx
+func GetDMARCScoreContext(ctx context.Context, domain string) (score int, dmarcRecord string) {
+	records, err := cachedLookupTXT(ctx, DMARCPrefix+domain)
 	if err != nil {
-		log.Fatal("Unexpected Error Occured while extracting DMARC Records ", err)
+		fmt.Println("Unexpected Error Occured while extracting DMARC Records ", err)
 	}
-	if len(dmarcRecord) > 2 {
-		dmarcRecord = strings.TrimSpace(dmarcRecord[1 : len(dmarcRecord)-1])
-		if strings.HasPrefix(dmarcRecord, "v=DMARC") {
+
+	score = 0
+	for _, record := range records {
+		record = strings.TrimSpace(record)
+		if strings.HasPrefix(record, "v=DMARC") {
+			dmarcRecord = record
 			score = 5
+			break
 		}
 	}
 	return
 }
 
+var fetchIncidents = func(ctx context.Context, host string) ([]byte, error) {
+	return NewVulnerabilityClient(utils.HTTPClient).FetchIncidentsContext(ctx, host)
+}
+
 // GetPreviousVulnerabilitiesScore gets the score for Previous Vulnerabilities taken from openbugbounty.org
-func GetPreviousVulnerabilitiesScore(host string) (totalScore int, maxScore int, IncidentList []models.Incident) {
-	if strings.HasPrefix(host, "www.") {
-		host = strings.Replace(host, "www.", "", -1)
-	}
-	resp, err := http.Get(OpenBugBountyURL + host)
-	if err != nil {
-		log.Fatalln("Error Occured while sending Request ", err)
-	}
+func GetPreviousVulnerabilitiesScore(host string) (totalScore int, maxScore int, IncidentList []models.Incident, err error) {
+	return GetPreviousVulnerabilitiesScoreContext(context.Background(), host)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
+// GetPreviousVulnerabilitiesScoreContext is GetPreviousVulnerabilitiesScore with the requests bound to ctx
+func GetPreviousVulnerabilitiesScoreContext(ctx context.Context, host string) (totalScore int, maxScore int, IncidentList []models.Incident, err error) {
+	host = utils.RegistrableDomain(host)
+	body, err := fetchIncidents(ctx, host)
 	if err != nil {
-		log.Fatalln("Error Occured while reading HTTP Response ", err)
+		fmt.Println("Error Occured while fetching Previous Vulnerabilities ", err)
+		return 0, 0, nil, err
 	}
 
 	var incidents models.Incidents
 	err = xml.Unmarshal(body, &incidents)
 	if err != nil {
-		log.Fatalln("Error Occured while Unmarshalling XML Response", err)
+		fmt.Println("Error Occured while Unmarshalling XML Response", err)
+		return 0, 0, nil, err
 	}
-	maxScore = len(incidents.IncidentList) * 10
 	totalScore = 0
 	for _, incident := range incidents.IncidentList {
-		if incident.Fixed {
-			ReportedDate, _ := time.Parse(time.RFC1123Z, incident.ReportedDate)
-			FixedDate, _ := time.Parse(time.RFC1123Z, incident.FixedDate)
-			diff := FixedDate.Sub(ReportedDate)
-			if diff.Hours() > MaxIncidentResponseTime {
-				totalScore += 5
-			} else {
-				totalScore += 10
-			}
+		// every incident is worth 10 points per level of severity, an unfixed incident scores none of them
+		weight := getIncidentWeight(incident.Type)
+		if !incident.Fixed {
+			maxScore += 10 * weight
+			continue
+		}
+		// an incident without a usable response time is left out rather than scored as a quick fix
+		ReportedDate, reportedErr := parseIncidentDate(incident.ReportedDate)
+		FixedDate, fixedErr := parseIncidentDate(incident.FixedDate)
+		if reportedErr != nil || fixedErr != nil || FixedDate.Before(ReportedDate) {
+			fmt.Println("Skipping incident of "+host+" with invalid dates", incident.ReportedDate, incident.FixedDate)
+			continue
+		}
+		maxScore += 10 * weight
+		diff := FixedDate.Sub(ReportedDate)
+		if diff.Hours() > MaxIncidentResponseTime {
+			totalScore += 5 * weight
+		} else {
+			totalScore += 10 * weight
+		}
+	}
+	return totalScore, maxScore, incidents.IncidentList, nil
+}
+
+// getIncidentWeight returns the severity weight of a type of vulnerability, DefaultIncidentWeight when it is unknown
+func getIncidentWeight(vulnerabilityType string) int {
+	if weight, ok := IncidentSeverityWeights[strings.ToLower(strings.TrimSpace(vulnerabilityType))]; ok {
+		return weight
+	}
+	return DefaultIncidentWeight
+}
+
+// parseIncidentDate parses a date reported by openbugbounty with any of the IncidentDateLayouts
+func parseIncidentDate(value string) (date time.Time, err error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range IncidentDateLayouts {
+		date, err = time.Parse(layout, value)
+		if err == nil {
+			return date, nil
 		}
 	}
-	return totalScore, maxScore, incidents.IncidentList
+	return date, fmt.Errorf("unknown date format %q", value)
 }
 
 func getServerInformation(server string) (serverInfo *models.ServerDetail) {
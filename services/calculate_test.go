@@ -1,10 +1,29 @@
 package services
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"path"
 	"runtime"
+	"snift-api/models"
+	"snift-api/utils"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -16,6 +35,8 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	// the test servers listen on the loopback addresses, which are otherwise rejected as internal targets
+	os.Setenv("TARGET_ALLOWLIST", "127.0.0.0/8,::1/128")
 }
 
 func MockBuildResponseHeaderScore(rh ResponseHeader) (*HeaderScore, error) {
@@ -41,11 +62,28 @@ func TestGetDefaultPort(t *testing.T) {
 }
 
 func TestCalculateOverallScore(t *testing.T) {
-	_, err := CalculateOverallScore("http://example.com")
+	_, err := CalculateOverallScore("http://example.com", nil)
 	assert.NoError(t, err)
 
-	_, err = CalculateOverallScore("example.com")
-	assert.Error(t, err)
+	// a URL without scheme is scanned over https, the domain is reached through the server
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	defer mockFetchIncidents("<incidents></incidents>", nil)()
+	defer mockLookupTXT(nil)()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	utils.SetTrustedRoots(roots)
+	defer utils.SetTrustedRoots(nil)
+	originalDialContext, originalRootCAs := models.DialContext, models.RootCAs
+	defer func() { models.DialContext, models.RootCAs = originalDialContext, originalRootCAs }()
+	models.DialContext, models.RootCAs = utils.DialContext, roots
+	ip, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	defer mockHostLookups(map[string][]net.IP{"example.com": {net.ParseIP(ip)}}, nil)()
+	responseBody, err := CalculateOverallScore("example.com:"+port, &models.ScanOptions{IP: ip})
+	assert.NoError(t, err)
+	var response models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+	assert.True(t, strings.HasPrefix(response.Scores.URL, "https://example.com:"+port), response.Scores.URL)
 }
 
 func TestGetXSSScore(t *testing.T) {
@@ -54,12 +92,21 @@ func TestGetXSSScore(t *testing.T) {
 	assert.Nil(t, err)
 
 	xssScore, err = MockBuildResponseHeaderScore(GetXSSScore("1"))
-	assert.Equal(t, xssScore.value, 5)
+	assert.Equal(t, xssScore.value, 4)
 	assert.Equal(t, xssScore.meta, "")
+	assert.Equal(t, xssScore.message, utils.XSSModernGuidanceMessage)
 	assert.Nil(t, err)
 
-	xssScore, err = MockBuildResponseHeaderScore(GetXSSScore("1; report=https://www.example.com"))
+	xssScore, err = MockBuildResponseHeaderScore(GetXSSScore("1; mode=block"))
+	assert.Equal(t, xssScore.value, 5)
+	assert.Nil(t, err)
+
+	xssScore, err = MockBuildResponseHeaderScore(GetXSSScore("1;mode = block"))
 	assert.Equal(t, xssScore.value, 5)
+	assert.Nil(t, err)
+
+	xssScore, err = MockBuildResponseHeaderScore(GetXSSScore("1; report=https://www.example.com"))
+	assert.Equal(t, xssScore.value, 4)
 	assert.Equal(t, xssScore.meta, "https://www.example.com")
 	assert.Nil(t, err)
 
@@ -75,56 +122,187 @@ func TestGetXSSScore(t *testing.T) {
 }
 
 func TestGetXFrameScore(t *testing.T) {
-	xFrameScore, err := MockBuildResponseHeaderScore(GetXFrameScore("DENY"))
+	xFrameScore, err := MockBuildResponseHeaderScore(GetXFrameScore("DENY", ""))
 	assert.Equal(t, xFrameScore.value, 5)
 	assert.Nil(t, err)
 
-	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("deny"))
+	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("deny", ""))
 	assert.Equal(t, xFrameScore.value, 5)
 	assert.Nil(t, err)
 
-	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("SAMEORIGIN"))
+	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("SAMEORIGIN", ""))
 	assert.Equal(t, xFrameScore.value, 5)
 	assert.Nil(t, err)
 
-	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("sameorigin"))
+	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("sameorigin", ""))
 	assert.Equal(t, xFrameScore.value, 5)
 
-	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("ALLOW-FROM https://www.example.com"))
-	assert.Equal(t, xFrameScore.value, 4)
+	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("ALLOW-FROM https://www.example.com", ""))
+	assert.Equal(t, xFrameScore.value, XFrameAllowFromScore)
+	assert.Equal(t, xFrameScore.message, utils.XFrameAllowFromMessage)
 	assert.Nil(t, err)
 
-	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("allow-from https://www.example.com"))
-	assert.Equal(t, xFrameScore.value, 4)
+	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("allow-from https://www.example.com", ""))
+	assert.Equal(t, xFrameScore.value, XFrameAllowFromScore)
 	assert.Nil(t, err)
 
-	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore(""))
-	assert.Equal(t, xFrameScore.value, 1)
+	xFrameScore, err = MockBuildResponseHeaderScore(GetXFrameScore("", ""))
+	assert.Equal(t, xFrameScore.value, 0)
 	assert.Nil(t, err)
+}
 
+func TestGetXFrameScoreFrameAncestors(t *testing.T) {
+	// frame-ancestors protects against clickjacking without X-Frame-Options
+	xFrameScore, err := MockBuildResponseHeaderScore(GetXFrameScore("", "default-src 'self'; frame-ancestors 'none'"))
+	assert.Equal(t, xFrameScore.value, 5)
+	assert.Equal(t, xFrameScore.badges, []*models.Badge{utils.GetXFrameBadge()})
+	assert.Empty(t, xFrameScore.message)
+	assert.Nil(t, err)
+
+	xFrameScore, _ = MockBuildResponseHeaderScore(GetXFrameScore("", "FRAME-ANCESTORS https://partner.example.com"))
+	assert.Equal(t, xFrameScore.value, 5)
+
+	// allowing every site to frame the page is no protection
+	xFrameScore, _ = MockBuildResponseHeaderScore(GetXFrameScore("", "frame-ancestors *"))
+	assert.Equal(t, xFrameScore.value, 0)
+
+	xFrameScore, _ = MockBuildResponseHeaderScore(GetXFrameScore("", "default-src 'self'"))
+	assert.Equal(t, xFrameScore.value, 0)
+
+	// ALLOW-FROM is ignored by the browsers that honor frame-ancestors
+	xFrameScore, _ = MockBuildResponseHeaderScore(GetXFrameScore("ALLOW-FROM https://partner.example.com", "frame-ancestors https://partner.example.com"))
+	assert.Equal(t, xFrameScore.value, 5)
+	assert.Empty(t, xFrameScore.message)
 }
 
 func TestGetHSTSScore(t *testing.T) {
-	hstsScore, err := MockBuildResponseHeaderScore(GetHSTSScore("max-age=65536"))
+	hstsScore, err := MockBuildResponseHeaderScore(GetHSTSScore("max-age=65536", "https"))
+	assert.Equal(t, hstsScore.value, 1)
+	assert.Nil(t, err)
+
+	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("max-age=31536000; includeSubDomains", "https"))
 	assert.Equal(t, hstsScore.value, 4)
+	assert.False(t, hstsScore.hstsPreloadEligible)
 	assert.Nil(t, err)
 
-	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("max-age=31536000; includeSubDomains"))
+	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("max-age=31536000; includeSubDomains; preload", "https"))
 	assert.Equal(t, hstsScore.value, 5)
+	assert.True(t, hstsScore.hstsPreloadEligible)
 	assert.Nil(t, err)
 
-	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("max-age=31536000; includeSubDomains; preload"))
-	assert.Equal(t, hstsScore.value, 5)
+	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("max-age=31536;  includeSubDomains; preload", "https"))
+	assert.Equal(t, hstsScore.value, 1)
+	assert.False(t, hstsScore.hstsPreloadEligible)
+	assert.Nil(t, err)
+
+	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("max-age=abc; includeSubDomains; preload", "https"))
+	assert.Equal(t, hstsScore.value, 0)
+	assert.Nil(t, err)
+
+	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("Max-Age=31536000; includeSubDomains", "https"))
+	assert.Equal(t, hstsScore.value, 4)
+	assert.Nil(t, err)
+
+	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("", "https"))
+	assert.Equal(t, hstsScore.value, 0)
 	assert.Nil(t, err)
 
-	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("max-age=31536;  preload"))
+}
+
+func TestGetHSTSScoreMaxAge(t *testing.T) {
+	for _, test := range []struct {
+		maxAge  int64
+		score   int
+		message string
+	}{
+		// short
+		{300, 1, fmt.Sprintf(utils.HSTSShortMaxAgeMessage, 300, 31536000)},
+		// medium
+		{15768000, 3, fmt.Sprintf(utils.HSTSShortMaxAgeMessage, 15768000, 31536000)},
+		// long
+		{31536000, 4, ""},
+		{63072000, 4, ""},
+	} {
+		hstsScore, err := BuildResponseHeaderScore(GetHSTSScore(fmt.Sprintf("max-age=%d; includeSubDomains", test.maxAge), "https"))
+		assert.Nil(t, err)
+		assert.Equal(t, hstsScore.checks[0].Score, test.score, test.maxAge)
+		assert.Equal(t, hstsScore.checks[0].Message, test.message, test.maxAge)
+	}
+
+	// the threshold is configurable
+	defer func() { HSTSMinMaxAge = utils.DefaultHSTSMinMaxAge }()
+	HSTSMinMaxAge = 15552000
+	hstsScore, err := BuildResponseHeaderScore(GetHSTSScore("max-age=15768000", "https"))
+	assert.Nil(t, err)
+	assert.Equal(t, hstsScore.checks[0].Score, 4)
+	assert.Equal(t, hstsScore.checks[0].Message, "")
+	hstsScore, err = BuildResponseHeaderScore(GetHSTSScore("max-age=7776000", "https"))
+	assert.Nil(t, err)
+	assert.Equal(t, hstsScore.checks[0].Score, 3)
+	assert.Equal(t, hstsScore.checks[0].Message, fmt.Sprintf(utils.HSTSShortMaxAgeMessage, 7776000, 15552000))
+}
+
+func TestGetHSTSScoreOverHTTP(t *testing.T) {
+	hstsScore, err := MockBuildResponseHeaderScore(GetHSTSScore("max-age=63072000; includeSubDomains; preload", "https"))
 	assert.Equal(t, hstsScore.value, 5)
+	assert.Equal(t, hstsScore.message, "")
+	assert.Nil(t, err)
+
+	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("max-age=63072000; includeSubDomains; preload", "http"))
+	assert.Equal(t, hstsScore.value, 0)
+	assert.False(t, hstsScore.hstsPreloadEligible)
+	assert.Equal(t, hstsScore.message, utils.HSTSOverHTTPMessage)
 	assert.Nil(t, err)
 
-	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore(""))
-	assert.Equal(t, hstsScore.value, 2)
+	hstsScore, err = MockBuildResponseHeaderScore(GetHSTSScore("", "http"))
+	assert.Equal(t, hstsScore.value, 0)
 	assert.Nil(t, err)
+}
 
+func getCheck(checks []*models.CheckResult, name string) *models.CheckResult {
+	for _, check := range checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	return nil
+}
+
+func TestGetResponseHeaderScoreHSTSOverHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HSTSHeader, "max-age=63072000; includeSubDomains; preload")
+	}))
+	defer server.Close()
+
+	responseHeaderScore, _, _, err := GetResponseHeaderScore(server.URL)
+	assert.NoError(t, err)
+	hstsCheck := getCheck(responseHeaderScore.checks, HSTSHeader)
+	assert.Equal(t, hstsCheck.Score, 0)
+	assert.Equal(t, hstsCheck.Message, utils.HSTSOverHTTPMessage)
+}
+
+func TestGetResponseHeaderScoreUserAgent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") == utils.GetUserAgent() {
+			w.Header().Set(XContentTypeHeader, XContentTypeHeaderValue)
+		}
+	}))
+	defer server.Close()
+
+	responseHeaderScore, _, _, err := GetResponseHeaderScore(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, getCheck(responseHeaderScore.checks, XContentTypeHeader).Score, 5)
+}
+
+func TestIsHSTSPreloadEligible(t *testing.T) {
+	assert.True(t, IsHSTSPreloadEligible("max-age=63072000; includeSubDomains; preload"))
+	assert.True(t, IsHSTSPreloadEligible(`max-age="31536000"; IncludeSubDomains; Preload`))
+	assert.False(t, IsHSTSPreloadEligible("max-age=86400; includeSubDomains; preload"))
+	assert.False(t, IsHSTSPreloadEligible("max-age=63072000; preload"))
+	assert.False(t, IsHSTSPreloadEligible("max-age=63072000; includeSubDomains"))
+	assert.False(t, IsHSTSPreloadEligible(""))
+	assert.True(t, IsHSTSPreloadEligible("Max-Age=31536000; includeSubDomains; preload"))
+	assert.True(t, IsHSTSPreloadEligible("MAX-AGE = 31536000; INCLUDESUBDOMAINS; PRELOAD"))
 }
 
 func TestGetReferrerPolicyScore(t *testing.T) {
@@ -164,6 +342,22 @@ func TestGetReferrerPolicyScore(t *testing.T) {
 	assert.Equal(t, xRPScore.value, 0)
 	assert.Nil(t, err)
 
+	xRPScore, err = MockBuildResponseHeaderScore(GetReferrerPolicyScore("no-referrer, strict-origin-when-cross-origin"))
+	assert.Equal(t, xRPScore.value, 4)
+	assert.Nil(t, err)
+
+	xRPScore, err = MockBuildResponseHeaderScore(GetReferrerPolicyScore("unsafe-url,no-referrer"))
+	assert.Equal(t, xRPScore.value, 5)
+	assert.Nil(t, err)
+
+	xRPScore, err = MockBuildResponseHeaderScore(GetReferrerPolicyScore("no-referrer, not-a-policy"))
+	assert.Equal(t, xRPScore.value, 5)
+	assert.Nil(t, err)
+
+	xRPScore, err = MockBuildResponseHeaderScore(GetReferrerPolicyScore("not-a-policy"))
+	assert.Equal(t, xRPScore.value, 0)
+	assert.Nil(t, err)
+
 }
 
 func TestGetCSPScore(t *testing.T) {
@@ -172,18 +366,22 @@ func TestGetCSPScore(t *testing.T) {
 	assert.Nil(t, err)
 
 	cspScore, err = MockBuildResponseHeaderScore(GetCSPScore(""))
-	assert.Equal(t, cspScore.value, 3)
+	assert.Equal(t, cspScore.value, 0)
 	assert.Nil(t, err)
 
 }
 
 func TestGetPKPScore(t *testing.T) {
-	pkpScore, err := MockBuildResponseHeaderScore(GetPKPScore("pin - sha256 = \"cUPcTAZWKaASuYWhhneDttWpY3oBAkE3h2+soZS7sWs=\" pin - sha256 = \"M8HztCzM3elUxkcjR2S5P4hhyBNf6lHkmjAHKhpGPWE=\" max - age = 5184000 includeSubDomains report - uri = \"https://www.example.org/hpkp-report\""))
-	assert.Equal(t, pkpScore.value, 5)
+	pkpScore, err := BuildResponseHeaderScore(GetPKPScore("pin - sha256 = \"cUPcTAZWKaASuYWhhneDttWpY3oBAkE3h2+soZS7sWs=\" pin - sha256 = \"M8HztCzM3elUxkcjR2S5P4hhyBNf6lHkmjAHKhpGPWE=\" max - age = 5184000 includeSubDomains report - uri = \"https://www.example.org/hpkp-report\""))
+	assert.Equal(t, pkpScore.value, 0)
+	assert.Equal(t, pkpScore.maximumValue, 0)
+	assert.Equal(t, pkpScore.checks[0].Message, utils.PKPDeprecatedMessage)
+	assert.Empty(t, pkpScore.badges)
 	assert.Nil(t, err)
 
-	pkpScore, err = MockBuildResponseHeaderScore(GetPKPScore(""))
-	assert.Equal(t, pkpScore.value, 3)
+	pkpScore, err = BuildResponseHeaderScore(GetPKPScore(""))
+	assert.Equal(t, pkpScore.value, 0)
+	assert.Equal(t, pkpScore.checks[0].Message, "")
 	assert.Nil(t, err)
 }
 
@@ -197,18 +395,391 @@ func TestGetXContentTypeScore(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestGetServerScore(t *testing.T) {
+	// an absent Server Header gets the full score
+	serverScore, err := BuildResponseHeaderScore(GetServerScore(""))
+	assert.Nil(t, err)
+	assert.Equal(t, serverScore.value, 2)
+	assert.Equal(t, serverScore.maximumValue, 2)
+	assert.Equal(t, serverScore.checks[0].Message, "")
+
+	// a generic one names the software only
+	for _, server := range []string{"nginx", "cloudflare", "Apache"} {
+		serverScore, _ = BuildResponseHeaderScore(GetServerScore(server))
+		assert.Equal(t, serverScore.value, 1, server)
+		assert.Equal(t, serverScore.checks[0].Message, fmt.Sprintf(utils.ServerGenericMessage, server))
+	}
+
+	// a versioned one helps fingerprinting the server
+	for _, server := range []string{"nginx/1.18.0", "Apache/2.4.41 (Ubuntu)", "Microsoft-IIS/10.0", "gws 2.1"} {
+		serverScore, _ = BuildResponseHeaderScore(GetServerScore(server))
+		assert.Equal(t, serverScore.value, 0, server)
+		assert.Equal(t, serverScore.checks[0].Message, fmt.Sprintf(utils.ServerVersionMessage, server))
+	}
+}
+
+func TestGetTLSRenegotiationScore(t *testing.T) {
+	TLS := &tls.ConnectionState{Version: tls.VersionTLS12}
+	for _, test := range []struct {
+		hello    *models.TLSHello
+		value    int
+		findings []string
+	}{
+		{&models.TLSHello{SecureRenegotiation: true}, 5, nil},
+		{&models.TLSHello{Compression: true, SecureRenegotiation: true}, 2, []string{utils.TLSCompressionMessage}},
+		{&models.TLSHello{}, 3, []string{utils.InsecureRenegotiationMessage}},
+		{&models.TLSHello{Compression: true}, 0, []string{utils.TLSCompressionMessage, utils.InsecureRenegotiationMessage}},
+	} {
+		score, err := BuildResponseHeaderScore(GetTLSRenegotiationScore(TLS, test.hello))
+		assert.Nil(t, err)
+		assert.Equal(t, score.value, test.value)
+		assert.Equal(t, score.maximumValue, 5)
+		assert.Equal(t, score.checks[0].Findings, test.findings)
+		assert.False(t, score.checks[0].Inconclusive)
+	}
+
+	// a server that did not answer the ClientHello is left out of the maximum score
+	score, _ := BuildResponseHeaderScore(GetTLSRenegotiationScore(TLS, nil))
+	assert.Equal(t, score.maximumValue, 0)
+	assert.True(t, score.checks[0].Inconclusive)
+	assert.Equal(t, score.checks[0].Message, utils.TLSHelloInconclusiveMessage)
+
+	// a site served over http misses the check
+	score, _ = BuildResponseHeaderScore(GetTLSRenegotiationScore(nil, nil))
+	assert.Equal(t, score.value, 0)
+	assert.Equal(t, score.maximumValue, 5)
+}
+
 func TestGetHTTPVersionScore(t *testing.T) {
-	httpVersionScore, err := MockBuildResponseHeaderScore(GetHTTPVersionScore("HTTP/2.0"))
+	httpVersionScore, err := MockBuildResponseHeaderScore(GetHTTPVersionScore("HTTP/2.0", ""))
 	assert.Equal(t, httpVersionScore.value, 5)
 	assert.Nil(t, err)
 
-	httpVersionScore, err = MockBuildResponseHeaderScore(GetHTTPVersionScore("HTTP/1.1"))
+	httpVersionScore, err = MockBuildResponseHeaderScore(GetHTTPVersionScore("HTTP/1.1", ""))
 	assert.Equal(t, httpVersionScore.value, 2)
 	assert.Nil(t, err)
 
-	httpVersionScore, err = MockBuildResponseHeaderScore(GetHTTPVersionScore(""))
+	httpVersionScore, err = MockBuildResponseHeaderScore(GetHTTPVersionScore("", ""))
 	assert.Equal(t, httpVersionScore.value, 0)
 	assert.Nil(t, err)
+
+	// HTTP/2 confirmed through ALPN is scored although the request negotiated HTTP/1.1
+	httpVersionScore, _ = MockBuildResponseHeaderScore(GetHTTPVersionScore("HTTP/1.1", "HTTP/2.0"))
+	assert.Equal(t, httpVersionScore.value, 5)
+	assert.Equal(t, httpVersionScore.negotiatedHTTPVersion, "HTTP/1.1")
+	assert.Equal(t, httpVersionScore.maxHTTPVersion, "HTTP/2.0")
+}
+
+func TestGetMaxHTTPVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	// the request of the client negotiated HTTP/1.1, the server advertises h2 through ALPN
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.ForceAttemptHTTP2 = false
+	transport.TLSClientConfig.NextProtos = nil
+	transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	response, err := (&http.Client{Transport: transport}).Head(server.URL)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, response.Proto, "HTTP/1.1")
+	assert.Equal(t, getMaxHTTPVersion(response), "HTTP/2.0")
+
+	original := alpnProtocol
+	defer func() { alpnProtocol = original }()
+	alpnProtocol = func(ctx context.Context, host string, port string) (string, error) {
+		return "", errors.New("handshake failure")
+	}
+	assert.Equal(t, getMaxHTTPVersion(response), "")
+}
+
+func TestGetTLSVersionScore(t *testing.T) {
+	tlsVersionScore, err := MockBuildResponseHeaderScore(GetTLSVersionScore(&tls.ConnectionState{Version: tls.VersionTLS12}, tls.VersionTLS13))
+	assert.Nil(t, err)
+	assert.Equal(t, tlsVersionScore.value, 5)
+	assert.Equal(t, tlsVersionScore.negotiatedTLSVersion, uint16(tls.VersionTLS12))
+	assert.Equal(t, tlsVersionScore.maxTLSVersion, uint16(tls.VersionTLS13))
+
+	// without a dedicated handshake the negotiated version is scored
+	tlsVersionScore, _ = MockBuildResponseHeaderScore(GetTLSVersionScore(&tls.ConnectionState{Version: tls.VersionTLS11}, 0))
+	assert.Equal(t, tlsVersionScore.value, 3)
+	assert.Equal(t, tlsVersionScore.maxTLSVersion, uint16(tls.VersionTLS11))
+
+	tlsVersionScore, _ = MockBuildResponseHeaderScore(GetTLSVersionScore(nil, 0))
+	assert.Equal(t, tlsVersionScore.value, 0)
+}
+
+// getStaplingState returns the state of a Handshake with a test server, which staples an OCSP response when one is given
+func getStaplingState(t *testing.T, ocspStaple []byte) *tls.ConnectionState {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.StartTLS()
+	defer server.Close()
+	server.TLS.Certificates[0].OCSPStaple = ocspStaple
+	conn, err := tls.Dial("tcp", server.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	defer conn.Close()
+	state := conn.ConnectionState()
+	return &state
+}
+
+func TestGetOCSPStaplingScore(t *testing.T) {
+	ocspStaplingScore, err := BuildResponseHeaderScore(GetOCSPStaplingScore(getStaplingState(t, []byte{0x30, 0x03, 0x0a, 0x01, 0x00})))
+	assert.Nil(t, err)
+	assert.Equal(t, ocspStaplingScore.value, OCSPStaplingScore)
+	assert.Equal(t, ocspStaplingScore.maximumValue, OCSPStaplingScore)
+
+	ocspStaplingScore, _ = MockBuildResponseHeaderScore(GetOCSPStaplingScore(getStaplingState(t, nil)))
+	assert.Equal(t, ocspStaplingScore.value, 0)
+
+	ocspStaplingScore, _ = BuildResponseHeaderScore(GetOCSPStaplingScore(nil))
+	assert.Equal(t, ocspStaplingScore.value, 0)
+	assert.Equal(t, ocspStaplingScore.checks[0].Name, OCSPStaplingCheck)
+}
+
+func TestGetCertificateTransparencyScore(t *testing.T) {
+	certificateTransparencyScore, err := BuildResponseHeaderScore(GetCertificateTransparencyScore(&tls.ConnectionState{SignedCertificateTimestamps: [][]byte{{0}}}))
+	assert.Nil(t, err)
+	assert.Equal(t, certificateTransparencyScore.value, 5)
+	assert.Equal(t, certificateTransparencyScore.checks[0].Name, CertificateTransparencyCheck)
+
+	// SCTs embedded in the leaf certificate
+	certificate := &x509.Certificate{Extensions: []pkix.Extension{{Id: SCTListExtension, Value: []byte{0}}}}
+	certificateTransparencyScore, _ = BuildResponseHeaderScore(GetCertificateTransparencyScore(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{certificate}}))
+	assert.Equal(t, certificateTransparencyScore.value, 5)
+
+	certificateTransparencyScore, _ = BuildResponseHeaderScore(GetCertificateTransparencyScore(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{{}}}))
+	assert.Equal(t, certificateTransparencyScore.value, 0)
+
+	certificateTransparencyScore, _ = BuildResponseHeaderScore(GetCertificateTransparencyScore(nil))
+	assert.Equal(t, certificateTransparencyScore.value, 0)
+}
+
+func TestGetSessionResumptionScore(t *testing.T) {
+	TLS := &tls.ConnectionState{Version: tls.VersionTLS13}
+	sessionResumptionScore, err := BuildResponseHeaderScore(GetSessionResumptionScore(TLS, true))
+	assert.Nil(t, err)
+	assert.Equal(t, sessionResumptionScore.value, SessionResumptionScore)
+	assert.Equal(t, sessionResumptionScore.maximumValue, SessionResumptionScore)
+	assert.Equal(t, sessionResumptionScore.checks[0].Name, SessionResumptionCheck)
+
+	sessionResumptionScore, _ = BuildResponseHeaderScore(GetSessionResumptionScore(TLS, false))
+	assert.Equal(t, sessionResumptionScore.value, 0)
+
+	// plain HTTP responses have no session to resume
+	sessionResumptionScore, _ = BuildResponseHeaderScore(GetSessionResumptionScore(nil, true))
+	assert.Equal(t, sessionResumptionScore.value, 0)
+}
+
+func TestGetSessionResumption(t *testing.T) {
+	for _, ticketsDisabled := range []bool{false, true} {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.TLS = &tls.Config{SessionTicketsDisabled: ticketsDisabled}
+		server.StartTLS()
+		serverURL, _ := url.Parse(server.URL)
+		response := &http.Response{
+			Request: &http.Request{URL: serverURL},
+			TLS:     &tls.ConnectionState{Version: tls.VersionTLS13},
+		}
+		assert.Equal(t, getSessionResumption(response), !ticketsDisabled)
+		server.Close()
+	}
+
+	// plain HTTP responses have no session to resume
+	assert.False(t, getSessionResumption(&http.Response{Request: &http.Request{URL: &url.URL{Scheme: "http", Host: "127.0.0.1:80"}}}))
+}
+
+func TestGetMaxTLSVersion(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	response := &http.Response{
+		Request: &http.Request{URL: serverURL},
+		TLS:     &tls.ConnectionState{Version: tls.VersionTLS12},
+	}
+	assert.Equal(t, getMaxTLSVersion(response), uint16(tls.VersionTLS13))
+
+	// plain HTTP responses have no TLS version
+	response.TLS = nil
+	assert.Equal(t, getMaxTLSVersion(response), uint16(0))
+}
+
+func TestGetCrossDomainPolicyScore(t *testing.T) {
+	crossDomainPolicyScore, err := MockBuildResponseHeaderScore(GetCrossDomainPolicyScore("none"))
+	assert.Equal(t, crossDomainPolicyScore.value, 5)
+	assert.Nil(t, err)
+
+	crossDomainPolicyScore, err = MockBuildResponseHeaderScore(GetCrossDomainPolicyScore("Master-Only"))
+	assert.Equal(t, crossDomainPolicyScore.value, 3)
+	assert.Nil(t, err)
+
+	crossDomainPolicyScore, err = MockBuildResponseHeaderScore(GetCrossDomainPolicyScore("by-content-type"))
+	assert.Equal(t, crossDomainPolicyScore.value, 2)
+	assert.Nil(t, err)
+
+	crossDomainPolicyScore, err = MockBuildResponseHeaderScore(GetCrossDomainPolicyScore("all"))
+	assert.Equal(t, crossDomainPolicyScore.value, 0)
+	assert.Nil(t, err)
+
+	crossDomainPolicyScore, err = MockBuildResponseHeaderScore(GetCrossDomainPolicyScore(""))
+	assert.Equal(t, crossDomainPolicyScore.value, 0)
+	assert.Nil(t, err)
+}
+
+func TestGetCrossOriginIsolationScore(t *testing.T) {
+	crossOriginIsolationScore, err := MockBuildResponseHeaderScore(GetCrossOriginIsolationScore(map[string]string{
+		COOPHeader: "same-origin",
+		COEPHeader: "require-corp",
+		CORPHeader: "same-origin",
+	}))
+	assert.Equal(t, crossOriginIsolationScore.value, 5)
+	assert.Nil(t, err)
+
+	crossOriginIsolationScore, err = MockBuildResponseHeaderScore(GetCrossOriginIsolationScore(map[string]string{
+		COOPHeader: "same-origin-allow-popups",
+		CORPHeader: "cross-origin",
+	}))
+	assert.Equal(t, crossOriginIsolationScore.value, 1)
+	assert.Nil(t, err)
+
+	crossOriginIsolationScore, err = MockBuildResponseHeaderScore(GetCrossOriginIsolationScore(map[string]string{}))
+	assert.Equal(t, crossOriginIsolationScore.value, 0)
+	assert.Nil(t, err)
+}
+
+func TestGetClearSiteDataScore(t *testing.T) {
+	clearSiteDataScore, err := MockBuildResponseHeaderScore(GetClearSiteDataScore(`"*"`))
+	assert.Equal(t, clearSiteDataScore.value, 2)
+	assert.Equal(t, clearSiteDataScore.clearSiteData, []string{"*"})
+	assert.Nil(t, err)
+
+	clearSiteDataScore, err = MockBuildResponseHeaderScore(GetClearSiteDataScore(`"cache", "cookies", "storage"`))
+	assert.Equal(t, clearSiteDataScore.value, 2)
+	assert.Nil(t, err)
+
+	clearSiteDataScore, err = MockBuildResponseHeaderScore(GetClearSiteDataScore(`"cookies"`))
+	assert.Equal(t, clearSiteDataScore.value, 1)
+	assert.Equal(t, clearSiteDataScore.clearSiteData, []string{"cookies"})
+	assert.Equal(t, clearSiteDataScore.message, fmt.Sprintf(utils.ClearSiteDataMessage, "cookies"))
+	assert.Nil(t, err)
+
+	clearSiteDataScore, err = MockBuildResponseHeaderScore(GetClearSiteDataScore(""))
+	assert.Equal(t, clearSiteDataScore.value, 0)
+	assert.Nil(t, clearSiteDataScore.clearSiteData)
+	assert.Nil(t, err)
+
+	responseHeaderScore, err := BuildResponseHeaderScore(GetClearSiteDataScore(`"cookies"`))
+	assert.Equal(t, responseHeaderScore.maximumValue, 2)
+	assert.Nil(t, err)
+}
+
+func TestGetCacheControlScore(t *testing.T) {
+	cacheControlScore, err := MockBuildResponseHeaderScore(GetCacheControlScore("no-store"))
+	assert.Equal(t, cacheControlScore.value, 2)
+	assert.Empty(t, cacheControlScore.message)
+	assert.Nil(t, err)
+
+	cacheControlScore, err = MockBuildResponseHeaderScore(GetCacheControlScore("private, max-age=600"))
+	assert.Equal(t, cacheControlScore.value, 1)
+	assert.Nil(t, err)
+
+	cacheControlScore, err = MockBuildResponseHeaderScore(GetCacheControlScore("public, max-age=3600"))
+	assert.Equal(t, cacheControlScore.value, 0)
+	assert.Equal(t, cacheControlScore.message, utils.CacheControlPublicMessage)
+	assert.Nil(t, err)
+
+	cacheControlScore, err = MockBuildResponseHeaderScore(GetCacheControlScore(""))
+	assert.Equal(t, cacheControlScore.value, 0)
+	assert.Empty(t, cacheControlScore.message)
+	assert.Nil(t, err)
+
+	responseHeaderScore, err := BuildResponseHeaderScore(GetCacheControlScore("No-Store"))
+	assert.Equal(t, responseHeaderScore.value, 2)
+	assert.Equal(t, responseHeaderScore.maximumValue, 2)
+	assert.Equal(t, responseHeaderScore.checks[0].Name, CacheControlHeader)
+	assert.Nil(t, err)
+}
+
+func TestResponseHeaderScoreWithinMaximum(t *testing.T) {
+	values := map[string][]string{
+		XSSHeader:               {"", "0", "1", "1; mode=block", "1;mode=block; report=/xss", "invalid"},
+		XFrameHeader:            {"", "DENY", "sameorigin", "allow-from https://example.com", "invalid"},
+		HSTSHeader:              {"", "max-age=0", "max-age=31536000; includeSubDomains; preload", "max-age=300", "preload"},
+		CSPHeader:               {"", "default-src 'self'", "frame-ancestors 'self'", "frame-ancestors *"},
+		PKPHeader:               {"", "pin-sha256=\"abc\"; max-age=5184000"},
+		RPHeader:                {"", "no-referrer", "unsafe-url, no-referrer", "invalid, unsafe-url", "invalid"},
+		XContentTypeHeader:      {"", "nosniff", "invalid"},
+		CrossDomainPolicyHeader: {"", "none", "all", "invalid"},
+		COOPHeader:              {"", "same-origin", "unsafe-none"},
+		COEPHeader:              {"", "require-corp", "unsafe-none"},
+		CORPHeader:              {"", "same-origin", "cross-origin"},
+		ClearSiteDataHeader:     {"", "*", "\"cache\", \"cookies\"", "invalid"},
+		CacheControlHeader:      {"", "no-store", "private, max-age=0", "public, max-age=3600", "public, no-store", "invalid"},
+		"Proto":                 {"", "HTTP/1.1", "HTTP/2.0"},
+		"Protocol":              {"http", "https"},
+	}
+	tlsStates := []*tls.ConnectionState{nil, {Version: tls.VersionTLS10}, {Version: tls.VersionTLS12}, {Version: tls.VersionTLS13}}
+	random := rand.New(rand.NewSource(1))
+	pick := func(name string) string {
+		return values[name][random.Intn(len(values[name]))]
+	}
+	for i := 0; i < 1000; i++ {
+		headers := map[string]string{COOPHeader: pick(COOPHeader), COEPHeader: pick(COEPHeader), CORPHeader: pick(CORPHeader)}
+		responseHeaderScore, err := BuildResponseHeaderScore(
+			GetXSSScore(pick(XSSHeader)),
+			GetXFrameScore(pick(XFrameHeader), pick(CSPHeader)),
+			GetHSTSScore(pick(HSTSHeader), pick("Protocol")),
+			GetCSPScore(pick(CSPHeader)),
+			GetPKPScore(pick(PKPHeader)),
+			GetReferrerPolicyScore(pick(RPHeader)),
+			GetXContentTypeScore(pick(XContentTypeHeader)),
+			GetCrossDomainPolicyScore(pick(CrossDomainPolicyHeader)),
+			GetCrossOriginIsolationScore(headers),
+			GetClearSiteDataScore(pick(ClearSiteDataHeader)),
+			GetCacheControlScore(pick(CacheControlHeader)),
+			GetHTTPVersionScore(pick("Proto"), ""),
+			GetTLSVersionScore(tlsStates[random.Intn(len(tlsStates))], uint16(tls.VersionTLS10+random.Intn(4))),
+		)
+		assert.Nil(t, err)
+		assert.True(t, responseHeaderScore.value >= 0 && responseHeaderScore.value <= responseHeaderScore.maximumValue, responseHeaderScore.value)
+		for _, check := range responseHeaderScore.checks {
+			assert.True(t, check.Score >= 0 && check.Score <= check.MaxScore, check.Name, check.Score)
+		}
+	}
+
+	// a response without any security header scores nothing
+	responseHeaderScore, _ := BuildResponseHeaderScore(
+		GetXSSScore(""), GetXFrameScore("", ""), GetHSTSScore("", "https"), GetCSPScore(""), GetPKPScore(""),
+		GetReferrerPolicyScore(""), GetXContentTypeScore(""), GetCrossDomainPolicyScore(""),
+		GetCrossOriginIsolationScore(map[string]string{}), GetClearSiteDataScore(""), GetCacheControlScore(""),
+	)
+	assert.Equal(t, responseHeaderScore.value, 0)
+}
+
+func TestAddRemediations(t *testing.T) {
+	failingCheck := models.GetCheckResult(CSPHeader, 3, 5)
+	passingCheck := models.GetCheckResult(HSTSHeader, 5, 5)
+	addRemediations([]*models.CheckResult{failingCheck, passingCheck})
+	assert.Equal(t, failingCheck.Remediation, utils.CSPRemediation)
+	assert.Equal(t, passingCheck.Remediation, "")
+
+	// every check that can be reported has a remediation
+	for _, name := range []string{ProtocolCheck, SPFCheck, DMARCCheck, PreviousVulnerabilitiesCheck} {
+		assert.NotEmpty(t, Remediations[name], name)
+	}
+	responseHeaderScore, _ := BuildResponseHeaderScore(
+		GetXSSScore(""), GetXFrameScore("", ""), GetHSTSScore("", "https"), GetCSPScore(""),
+		GetReferrerPolicyScore(""), GetXContentTypeScore(""), GetCrossDomainPolicyScore(""),
+		GetCrossOriginIsolationScore(map[string]string{}), GetClearSiteDataScore(""),
+		GetHTTPVersionScore("", ""), GetTLSVersionScore(nil, 0), GetCertificateTransparencyScore(nil),
+	)
+	addRemediations(responseHeaderScore.checks)
+	for _, check := range responseHeaderScore.checks {
+		assert.NotEmpty(t, check.Remediation, check.Name)
+	}
 }
 
 func TestGetDMARCScore(t *testing.T) {
@@ -219,6 +790,24 @@ func TestGetDMARCScore(t *testing.T) {
 	assert.Equal(t, dmarcScore, 0)
 }
 
+func TestGetDMARCScoreRecords(t *testing.T) {
+	defer mockLookupTXT(map[string][]string{
+		"_dmarc.example.com": {"v=DMARC1; p=reject; rua=mailto:dmarc@example.com"},
+		"_dmarc.example.org": {"some-other-record"},
+	})()
+
+	dmarcScore, dmarcRecord := GetDMARCScore("example.com")
+	assert.Equal(t, dmarcScore, 5)
+	assert.Equal(t, dmarcRecord, "v=DMARC1; p=reject; rua=mailto:dmarc@example.com")
+
+	dmarcScore, dmarcRecord = GetDMARCScore("example.org")
+	assert.Equal(t, dmarcScore, 0)
+	assert.Equal(t, dmarcRecord, "")
+
+	dmarcScore, _ = GetDMARCScore("missing.example.com")
+	assert.Equal(t, dmarcScore, 0)
+}
+
 func TestGetMailServerConfigurationScore(t *testing.T) {
 	mailServerScore, _, _ := GetMailServerConfigurationScore(MailServerConfigParams{host: "google.com"})
 	assert.Equal(t, mailServerScore, 8)
@@ -226,3 +815,977 @@ func TestGetMailServerConfigurationScore(t *testing.T) {
 	mailServerScore, _, _ = GetMailServerConfigurationScore(MailServerConfigParams{host: "www.google.com"})
 	assert.Equal(t, mailServerScore, 8)
 }
+
+// mailRecords are the TXT records of a domain passing every mail check
+var mailRecords = map[string][]string{
+	"example.com":                               {"v=spf1 include:_spf.example.com -all"},
+	"_spf.example.com":                          {"v=spf1 ip4:192.0.2.0/24 -all"},
+	DMARCPrefix + "example.com":                 {"v=DMARC1; p=reject"},
+	"mta" + DKIMDomainKeyPrefix + "example.com": {"v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA"},
+	BIMIPrefix + "example.com":                  {"v=BIMI1; l=https://example.com/logo.svg"},
+}
+
+func TestGetMailServerConfigurationScoreConcurrent(t *testing.T) {
+	defer mockLookupTXT(mailRecords)()
+	defer func() { mailCheckConcurrency = MaxConcurrentMailChecks }()
+	scan := func(concurrency int) (*models.Scores, int, string, string) {
+		mailCheckConcurrency = concurrency
+		builder := models.NewScoreBuilder()
+		mailServerScore, txtRecords, dmarcRecord := GetMailServerConfigurationScore(MailServerConfigParams{
+			host: "www.example.com", dkimSelectors: []string{"mta"}, builder: builder,
+			ctx: utils.WithDNSCache(context.Background(), utils.NewDNSCache()),
+		})
+		return builder.Finalize("https://www.example.com"), mailServerScore, txtRecords, dmarcRecord
+	}
+
+	// the checks run concurrently aggregate to the result of running them one after another
+	sequentialScores, sequentialScore, sequentialTXT, sequentialDMARC := scan(1)
+	scores, mailServerScore, txtRecords, dmarcRecord := scan(MaxConcurrentMailChecks)
+	assert.Equal(t, scores, sequentialScores)
+	assert.Equal(t, mailServerScore, sequentialScore)
+	assert.Equal(t, txtRecords, sequentialTXT)
+	assert.Equal(t, dmarcRecord, sequentialDMARC)
+	assert.Equal(t, len(scores.Checks), 4)
+	assert.Equal(t, scores.Score, 1.0)
+	assert.Equal(t, dmarcRecord, "v=DMARC1; p=reject")
+
+	// the checks not started when the scan is cancelled are left out
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	builder := models.NewScoreBuilder()
+	mailServerScore, _, _ = GetMailServerConfigurationScore(MailServerConfigParams{host: "example.com", builder: builder, ctx: ctx})
+	assert.Equal(t, mailServerScore, 0)
+	assert.Empty(t, builder.Checks())
+}
+
+func TestGetMailServerConfigurationScoreDomain(t *testing.T) {
+	defer mockLookupTXT(mailRecords)()
+	scan := func(host string) *models.Scores {
+		builder := models.NewScoreBuilder()
+		GetMailServerConfigurationScore(MailServerConfigParams{host: host, dkimSelectors: []string{"mta"}, builder: builder})
+		return builder.Finalize("https://example.com")
+	}
+
+	// every host of the site queries the records of its registrable domain
+	apex := scan("example.com")
+	assert.Equal(t, apex.Score, 1.0)
+	for _, host := range []string{"www.example.com", "WWW.Example.com.", "blog.example.com", "mail.corp.example.com"} {
+		assert.Equal(t, scan(host), apex, host)
+	}
+
+	// multi-label public suffixes keep the organizational domain
+	var queried []string
+	lookupTXT = func(ctx context.Context, domain string) ([]string, error) {
+		queried = append(queried, domain)
+		return nil, errors.New("no such host")
+	}
+	// the checks run one after another so that the queries are recorded safely
+	mailCheckConcurrency = 1
+	defer func() { mailCheckConcurrency = MaxConcurrentMailChecks }()
+	GetMailServerConfigurationScore(MailServerConfigParams{host: "mail.corp.example.co.uk", dkimSelectors: []string{"mta"}})
+	assert.Contains(t, queried, "example.co.uk")
+	assert.Contains(t, queried, DMARCPrefix+"example.co.uk")
+	assert.NotContains(t, queried, DMARCPrefix+"co.uk")
+
+	var incidentHosts []string
+	originalFetchIncidents := fetchIncidents
+	defer func() { fetchIncidents = originalFetchIncidents }()
+	fetchIncidents = func(ctx context.Context, host string) ([]byte, error) {
+		incidentHosts = append(incidentHosts, host)
+		return []byte("<incidents></incidents>"), nil
+	}
+	for _, host := range []string{"www.example.com", "example.com", "www.example.co.uk", "127.0.0.1"} {
+		GetPreviousVulnerabilitiesScore(host)
+	}
+	assert.Equal(t, incidentHosts, []string{"example.com", "example.com", "example.co.uk", "127.0.0.1"})
+}
+
+func BenchmarkGetMailServerConfigurationScore(b *testing.B) {
+	original := lookupTXT
+	defer func() { lookupTXT = original }()
+	lookupTXT = func(ctx context.Context, domain string) ([]string, error) {
+		time.Sleep(time.Millisecond)
+		return mailRecords[domain], nil
+	}
+	defer func() { mailCheckConcurrency = MaxConcurrentMailChecks }()
+	for _, concurrency := range []int{1, MaxConcurrentMailChecks} {
+		b.Run(fmt.Sprint("concurrency-", concurrency), func(b *testing.B) {
+			mailCheckConcurrency = concurrency
+			for i := 0; i < b.N; i++ {
+				GetMailServerConfigurationScore(MailServerConfigParams{host: "example.com", dkimSelectors: []string{"mta"},
+					ctx: utils.WithDNSCache(context.Background(), utils.NewDNSCache())})
+			}
+		})
+	}
+}
+
+func mockFetchIncidents(body string, err error) func() {
+	original := fetchIncidents
+	fetchIncidents = func(ctx context.Context, host string) ([]byte, error) {
+		return []byte(body), err
+	}
+	return func() { fetchIncidents = original }
+}
+
+func TestCalculateOverallScoreSkip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	var incidentLookups int
+	var txtLookups int32
+	originalFetchIncidents, originalLookupTXT := fetchIncidents, lookupTXT
+	defer func() { fetchIncidents, lookupTXT = originalFetchIncidents, originalLookupTXT }()
+	fetchIncidents = func(ctx context.Context, host string) ([]byte, error) {
+		incidentLookups++
+		return []byte("<incidents></incidents>"), nil
+	}
+	// the mail checks query their records concurrently
+	lookupTXT = func(ctx context.Context, domain string) ([]string, error) {
+		atomic.AddInt32(&txtLookups, 1)
+		return nil, errors.New("no such host")
+	}
+
+	for _, test := range []struct {
+		options         *models.ScanOptions
+		incidentLookups int
+		mailChecks      bool
+	}{
+		{nil, 1, true},
+		{&models.ScanOptions{Skip: []string{SkipVulnerabilities}}, 0, true},
+		{&models.ScanOptions{Skip: []string{SkipVulnerabilities, SkipDNS}}, 0, false},
+	} {
+		incidentLookups = 0
+		atomic.StoreInt32(&txtLookups, 0)
+		responseBody, err := CalculateOverallScore(server.URL, test.options)
+		assert.NoError(t, err)
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(responseBody, &response))
+
+		assert.Equal(t, incidentLookups, test.incidentLookups)
+		assert.Equal(t, atomic.LoadInt32(&txtLookups) > 0, test.mailChecks)
+		assert.Equal(t, getCheck(response.Scores.Checks, PreviousVulnerabilitiesCheck) != nil, test.incidentLookups > 0)
+		assert.Equal(t, getCheck(response.Scores.Checks, SPFCheck) != nil, test.mailChecks)
+		assert.Equal(t, getCheck(response.Scores.Checks, DMARCCheck) != nil, test.mailChecks)
+		assert.Equal(t, response.HostInfo != nil, test.mailChecks)
+
+		// the overall score is made of the reported checks only
+		var calculatedScore, maximumPossibleScore int
+		for _, check := range response.Scores.Checks {
+			assert.True(t, check.Score <= check.MaxScore, check.Name)
+			calculatedScore += check.Score
+			maximumPossibleScore += check.MaxScore
+		}
+		assert.Equal(t, response.Scores.Score, math.Ceil(float64(calculatedScore)/float64(maximumPossibleScore)*100)/100)
+	}
+}
+
+func TestCalculateOverallScoreConcurrentDNSCache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	defer mockFetchIncidents("<incidents></incidents>", nil)()
+	var mutex sync.Mutex
+	queries := make(map[string]int)
+	original := lookupTXT
+	defer func() { lookupTXT = original }()
+	lookupTXT = func(ctx context.Context, domain string) ([]string, error) {
+		mutex.Lock()
+		queries[domain]++
+		mutex.Unlock()
+		// the lookups are slowed down so that the scans overlap
+		time.Sleep(10 * time.Millisecond)
+		return nil, errors.New("no such host")
+	}
+
+	// every scan queries the records through its own cache, which is never shared with the scans running along
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := calculateOverallScore(server.URL, &models.ScanOptions{Skip: []string{SkipVulnerabilities}})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.NotEmpty(t, queries)
+	for domain, count := range queries {
+		assert.Equal(t, count, 2, domain)
+	}
+}
+
+func TestCalculateOverallScoreOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(CSPHeader, "default-src 'self'")
+		w.Header().Set(XFrameHeader, "DENY")
+	}))
+	defer server.Close()
+	var incidentLookups, txtLookups int
+	originalFetchIncidents, originalLookupTXT := fetchIncidents, lookupTXT
+	defer func() { fetchIncidents, lookupTXT = originalFetchIncidents, originalLookupTXT }()
+	fetchIncidents = func(ctx context.Context, host string) ([]byte, error) {
+		incidentLookups++
+		return []byte("<incidents></incidents>"), nil
+	}
+	lookupTXT = func(ctx context.Context, domain string) ([]string, error) {
+		txtLookups++
+		return nil, errors.New("no such host")
+	}
+
+	responseBody, err := CalculateOverallScore(server.URL, &models.ScanOptions{Only: []string{"csp"}})
+	assert.NoError(t, err)
+	var response models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+
+	// nothing but the requested check runs, and the score is scoped to its maximum
+	assert.Equal(t, incidentLookups, 0)
+	assert.Equal(t, txtLookups, 0)
+	assert.Nil(t, response.HostInfo)
+	assert.Equal(t, len(response.Scores.Checks), 1)
+	check := response.Scores.Checks[0]
+	assert.Equal(t, check.Name, CSPHeader)
+	assert.Equal(t, response.Scores.Score, math.Ceil(float64(check.Score)/float64(check.MaxScore)*100)/100)
+	assert.Equal(t, response.Scores.Only, []string{CSPHeader})
+	for _, badge := range response.Scores.Badges {
+		assert.Equal(t, badge.Name, utils.CSPBadge)
+	}
+
+	assert.True(t, errors.Is(ValidateScanOptions(&models.ScanOptions{Only: []string{"csp", "firewall"}}), ErrUnknownCheck))
+	assert.NoError(t, ValidateScanOptions(&models.ScanOptions{Only: []string{"TLS", "x-frame-options", "SPF"}}))
+}
+
+func TestCalculateOverallScoreSharedScan(t *testing.T) {
+	original := scanOverallScore
+	defer func() { scanOverallScore = original }()
+	var scans int32
+	release := make(chan bool)
+	scanOverallScore = func(scoresURL string, options *models.ScanOptions) ([]byte, error) {
+		atomic.AddInt32(&scans, 1)
+		<-release
+		return []byte(`{"scores":{"url":"https://www.example.com"}}`), nil
+	}
+
+	// concurrent identical requests share a single scan and all get its result
+	responses := make(chan []byte)
+	for i := 0; i < 10; i++ {
+		go func() {
+			response, err := CalculateOverallScore("https://WWW.example.com", nil)
+			assert.NoError(t, err)
+			responses <- response
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, string(<-responses), `{"scores":{"url":"https://www.example.com"}}`)
+	}
+	assert.Equal(t, atomic.LoadInt32(&scans), int32(1))
+
+	// scans with their own options, or completed ones, are not shared
+	atomic.StoreInt32(&scans, 0)
+	for _, options := range []*models.ScanOptions{nil, nil, {Only: []string{"csp"}}, {IdempotencyKey: "key"}} {
+		_, err := CalculateOverallScore("https://www.example.com", options)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, atomic.LoadInt32(&scans), int32(4))
+}
+
+func TestGetScanKey(t *testing.T) {
+	assert.Equal(t, getScanKey("https://www.example.com/"), "https://www.example.com/")
+	assert.Equal(t, getScanKey("https://bücher.example"), "https://xn--bcher-kva.example")
+	assert.Equal(t, getScanKey("www.example.com"), "//www.example.com")
+	assert.Equal(t, getScanKey("http://www.example.com"), "http://www.example.com")
+}
+
+func TestCalculateOverallScoreHTTPSGradeCap(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(XFrameHeader, "DENY")
+		w.Header().Set(XContentTypeHeader, "nosniff")
+		w.Header().Set(RPHeader, "no-referrer")
+	}))
+	defer server.Close()
+	options := &models.ScanOptions{Only: []string{"xfo", "nosniff", "referrer"}}
+
+	responseBody, err := CalculateOverallScore(server.URL, options)
+	assert.NoError(t, err)
+	var response models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+	// the headers score in full over http, but the grade is capped while the score is left as it is
+	assert.Equal(t, response.Scores.Score, 1.0)
+	assert.Equal(t, response.Scores.Grade, utils.DefaultHTTPSGradeCap)
+	assert.True(t, response.Scores.GradeCapped)
+
+	defer os.Unsetenv("HTTPS_GRADE_CAP")
+	os.Setenv("HTTPS_GRADE_CAP", "off")
+	responseBody, err = CalculateOverallScore(server.URL, options)
+	assert.NoError(t, err)
+	response = models.ScoresResponse{}
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+	assert.Equal(t, response.Scores.Score, 1.0)
+	assert.Equal(t, response.Scores.Grade, "A")
+	assert.False(t, response.Scores.GradeCapped)
+}
+
+func TestCalculateOverallScoreRequiredHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(XFrameHeader, "DENY")
+	}))
+	defer server.Close()
+	defer os.Unsetenv("REQUIRED_HEADERS")
+	options := &models.ScanOptions{Skip: []string{SkipVulnerabilities, SkipDNS}}
+
+	for _, test := range []struct {
+		requiredHeaders string
+		violations      []string
+	}{
+		{"", nil},
+		{"x-frame-options", nil},
+		{"X-Frame-Options, content-security-policy,Permissions-Policy", []string{CSPHeader, "Permissions-Policy"}},
+	} {
+		os.Setenv("REQUIRED_HEADERS", test.requiredHeaders)
+		responseBody, err := CalculateOverallScore(server.URL, options)
+		assert.NoError(t, err)
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(responseBody, &response))
+		assert.Equal(t, response.Scores.ComplianceViolations, test.violations, test.requiredHeaders)
+		// the violations are flagged apart from the score
+		assert.Equal(t, getCheck(response.Scores.Checks, XFrameHeader).Score, 5)
+	}
+}
+
+func TestCalculateOverallScoreIncludeRaw(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(XFrameHeader, "DENY")
+		w.Header().Add("Set-Cookie", "session=secret")
+		w.Header().Add("Set-Cookie", "theme=dark")
+		w.Header().Set("X-Internal-Token", "secret")
+	}))
+	defer server.Close()
+	scan := func(includeRaw bool) models.ScoresResponse {
+		responseBody, err := CalculateOverallScore(server.URL, &models.ScanOptions{
+			Skip: []string{SkipVulnerabilities, SkipDNS}, IncludeRaw: includeRaw,
+		})
+		assert.NoError(t, err)
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(responseBody, &response))
+		return response
+	}
+
+	// the raw headers are left out unless requested
+	assert.Nil(t, scan(false).RawHeaders)
+
+	rawHeaders := scan(true).RawHeaders
+	assert.Equal(t, rawHeaders[XFrameHeader], "DENY")
+	assert.Equal(t, rawHeaders["X-Internal-Token"], "secret")
+	assert.Equal(t, rawHeaders["Set-Cookie"], utils.RedactedValue)
+
+	defer os.Unsetenv("REDACTED_HEADERS")
+	os.Setenv("REDACTED_HEADERS", "x-internal-token")
+	rawHeaders = scan(true).RawHeaders
+	assert.Equal(t, rawHeaders["X-Internal-Token"], utils.RedactedValue)
+	assert.Equal(t, rawHeaders["Set-Cookie"], "session=secret,theme=dark")
+	os.Setenv("REDACTED_HEADERS", "")
+	assert.Equal(t, scan(true).RawHeaders["X-Internal-Token"], "secret")
+}
+
+func TestCalculateOverallScoreCustomHeaders(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			received = r.Header.Clone()
+		}
+		if r.Header.Get("Cookie") == "session=secret" {
+			w.Header().Set(CSPHeader, "default-src 'self'")
+		}
+	}))
+	defer server.Close()
+	options := &models.ScanOptions{
+		Skip: []string{SkipVulnerabilities, SkipDNS},
+		Headers: map[string]string{
+			"Cookie":          "session=secret",
+			"accept-language": "fr-FR",
+			"User-Agent":      "Mozilla/5.0",
+		},
+	}
+	assert.NoError(t, ValidateScanOptions(options))
+
+	// the custom headers are sent with the request whose headers are scored, so the page behind the login is scored
+	responseBody, err := CalculateOverallScore(server.URL, options)
+	assert.NoError(t, err)
+	var response models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+	assert.Equal(t, received.Get("Cookie"), "session=secret")
+	assert.Equal(t, received.Get("Accept-Language"), "fr-FR")
+	assert.Equal(t, received.Get("User-Agent"), "Mozilla/5.0")
+	assert.True(t, getCheck(response.Scores.Checks, CSPHeader).Score > 0)
+
+	for _, headers := range []map[string]string{
+		{"Host": "internal.example.com"},
+		{"content-length": "0"},
+		{"Transfer-Encoding": "chunked"},
+		{ReflectionProbeHeader: "snift-0"},
+		{"Bad Header": "value"},
+		{"X-Injected": "value\r\nHost: internal.example.com"},
+	} {
+		err := ValidateScanOptions(&models.ScanOptions{Headers: headers})
+		assert.True(t, errors.Is(err, ErrForbiddenHeader), headers)
+	}
+}
+
+func TestCalculateOverallScoreHeadRejected(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			return
+		}
+		methods = append(methods, r.Method)
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set(CSPHeader, "default-src 'self'")
+		w.Write([]byte(strings.Repeat("a", HeaderFallbackMaxBodySize*2)))
+	}))
+	defer server.Close()
+	options := &models.ScanOptions{Skip: []string{SkipVulnerabilities, SkipDNS}}
+
+	// the headers are scored from the GET request the server answers
+	responseBody, err := CalculateOverallScore(server.URL, options)
+	assert.NoError(t, err)
+	var response models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+	assert.Equal(t, methods, []string{http.MethodHead, http.MethodGet})
+	assert.Equal(t, response.Scores.RequestMethod, http.MethodGet)
+	csp := getCheck(response.Scores.Checks, CSPHeader)
+	assert.True(t, csp.Score > 0)
+
+	// a server answering HEAD is not sent a GET
+	methods = nil
+	headServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			methods = append(methods, r.Method)
+		}
+		w.Header().Set(CSPHeader, "default-src 'self'")
+	}))
+	defer headServer.Close()
+	responseBody, err = CalculateOverallScore(headServer.URL, options)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+	assert.Equal(t, methods, []string{http.MethodHead})
+	assert.Equal(t, response.Scores.RequestMethod, http.MethodHead)
+}
+
+func TestCalculateOverallScoreOnCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	var reported []*models.CheckResult
+	options := &models.ScanOptions{
+		Skip:    []string{SkipVulnerabilities, SkipDNS},
+		OnCheck: func(check *models.CheckResult) { reported = append(reported, check) },
+	}
+	responseBody, err := CalculateOverallScore(server.URL, options)
+	assert.NoError(t, err)
+	var response models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+	// every check is reported once, in the order of the response and with its remediation
+	assert.Equal(t, len(reported), len(response.Scores.Checks))
+	for i, check := range response.Scores.Checks {
+		assert.Equal(t, reported[i].Name, check.Name)
+		assert.Equal(t, reported[i].Remediation, check.Remediation)
+	}
+}
+
+func TestCalculateOverallScoreWithoutScheme(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	defer mockFetchIncidents("<incidents></incidents>", nil)()
+	defer mockLookupTXT(nil)()
+	address := server.Listener.Addr().String()
+
+	// the server only speaks http, which is tried once https fails
+	for _, rawURL := range []string{address, "//" + address + "/path"} {
+		responseBody, err := CalculateOverallScore(rawURL, nil)
+		assert.NoError(t, err, rawURL)
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(responseBody, &response))
+		assert.True(t, strings.HasPrefix(response.Scores.URL, "http://"+address), response.Scores.URL)
+		assert.Equal(t, getCheck(response.Scores.Checks, ProtocolCheck).Score, HTTPScore)
+	}
+
+	// an explicit scheme is never replaced
+	_, err := CalculateOverallScore("https://"+address, nil)
+	assert.Error(t, err)
+}
+
+func TestCalculateOverallScoreInternalTarget(t *testing.T) {
+	allowlist := os.Getenv("TARGET_ALLOWLIST")
+	defer os.Setenv("TARGET_ALLOWLIST", allowlist)
+	os.Unsetenv("TARGET_ALLOWLIST")
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { requests++ }))
+	defer server.Close()
+
+	for _, target := range []string{server.URL, "http://169.254.169.254/latest/meta-data/", "http://[::1]:8080"} {
+		_, err := CalculateOverallScore(target, nil)
+		assert.True(t, errors.Is(err, utils.ErrPrivateTarget), target)
+	}
+	assert.Equal(t, requests, 0)
+}
+
+func TestGetResponseHeaderScoreInternalRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	_, _, _, err := GetResponseHeaderScore(server.URL)
+	assert.True(t, errors.Is(err, utils.ErrPrivateTarget), err)
+}
+
+func TestValidateScanOptions(t *testing.T) {
+	assert.NoError(t, ValidateScanOptions(nil))
+	assert.NoError(t, ValidateScanOptions(&models.ScanOptions{}))
+	assert.NoError(t, ValidateScanOptions(&models.ScanOptions{Skip: []string{SkipVulnerabilities, SkipDNS}}))
+	assert.Error(t, ValidateScanOptions(&models.ScanOptions{Skip: []string{"headers"}}))
+	assert.NoError(t, ValidateScanOptions(&models.ScanOptions{DKIMSelectors: []string{"s1", "mta.2024", "google_1"}}))
+	assert.ErrorIs(t, ValidateScanOptions(&models.ScanOptions{DKIMSelectors: []string{"s 1"}}), ErrInvalidDKIMSelector)
+	assert.ErrorIs(t, ValidateScanOptions(&models.ScanOptions{DKIMSelectors: []string{""}}), ErrInvalidDKIMSelector)
+	assert.ErrorIs(t, ValidateScanOptions(&models.ScanOptions{DKIMSelectors: make([]string, MaxDKIMSelectors+1)}), ErrInvalidDKIMSelector)
+	assert.NoError(t, ValidateScanOptions(&models.ScanOptions{IP: "2001:db8::1", SNI: "www.example.com"}))
+	assert.ErrorIs(t, ValidateScanOptions(&models.ScanOptions{IP: "www.example.com"}), ErrInvalidTargetOverride)
+	assert.ErrorIs(t, ValidateScanOptions(&models.ScanOptions{SNI: "93.184.216.34"}), ErrInvalidTargetOverride)
+	assert.ErrorIs(t, ValidateScanOptions(&models.ScanOptions{SNI: "www.example.com/path"}), ErrInvalidTargetOverride)
+}
+
+func TestCalculateOverallScoreTargetOverride(t *testing.T) {
+	var hosts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+	}))
+	defer server.Close()
+	ip, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	skip := []string{SkipVulnerabilities, SkipDNS}
+
+	// the domain does not resolve to the server, which is reached through the IP with the domain as the Host
+	for _, test := range []struct {
+		url     string
+		options *models.ScanOptions
+	}{
+		{"http://www.example.com:" + port, &models.ScanOptions{Skip: skip, IP: ip}},
+		{"http://www.example.com:" + port, &models.ScanOptions{Skip: skip, IP: ip, SNI: "WWW.example.com"}},
+		{server.URL, &models.ScanOptions{Skip: skip, SNI: "www.example.com"}},
+	} {
+		hosts = nil
+		responseBody, err := CalculateOverallScore(test.url, test.options)
+		assert.NoError(t, err, test.url)
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(responseBody, &response))
+		assert.NotEmpty(t, hosts)
+		for _, host := range hosts {
+			assert.True(t, strings.EqualFold(host, "www.example.com:"+port), host)
+		}
+	}
+
+	for _, test := range []struct {
+		url     string
+		options *models.ScanOptions
+	}{
+		{"http://www.example.com:" + port, &models.ScanOptions{IP: ip, SNI: "shop.example.com"}},
+		{"http://www.example.com:" + port, &models.ScanOptions{SNI: "www.example.com"}},
+		{server.URL, &models.ScanOptions{IP: "127.0.0.2", SNI: "www.example.com"}},
+	} {
+		_, err := CalculateOverallScore(test.url, test.options)
+		assert.ErrorIs(t, err, ErrInvalidTargetOverride)
+	}
+}
+
+func TestGetScoringProfile(t *testing.T) {
+	profile, err := GetScoringProfile("")
+	assert.NoError(t, err)
+	assert.Equal(t, profile.Name, BalancedProfile)
+	profile, _ = GetScoringProfile("Strict")
+	assert.Equal(t, profile.Name, StrictProfile)
+	_, err = GetScoringProfile("paranoid")
+	assert.ErrorIs(t, err, ErrUnknownScoringProfile)
+	assert.ErrorIs(t, ValidateScanOptions(&models.ScanOptions{Profile: "paranoid"}), ErrUnknownScoringProfile)
+}
+
+func TestScoringProfileGrades(t *testing.T) {
+	// the same site, missing HSTS and Public-Key-Pins, is graded differently by every profile
+	grades := map[string]string{StrictProfile: "D", BalancedProfile: "C", LenientProfile: "B"}
+	for name, grade := range grades {
+		profile, _ := GetScoringProfile(name)
+		builder := models.NewScoreBuilder()
+		builder.SetProfile(profile)
+		protocolCheck := models.GetCheckResult(ProtocolCheck, 5, 5)
+		protocolCheck.Critical = true
+		builder.AddCheck(protocolCheck)
+		builder.AddCheck(models.GetCheckResult(CSPHeader, 5, 5))
+		builder.AddCheck(models.GetCheckResult(HSTSHeader, 0, 5))
+		builder.AddCheck(models.GetCheckResult(XSSHeader, 5, 5))
+		builder.AddCheck(models.GetCheckResult(PKPHeader, 0, 5))
+		builder.AddCheck(models.GetCheckResult(XContentTypeHeader, 5, 5))
+		builder.AddCheck(models.GetCheckResult(RPHeader, 5, 5))
+		scores := builder.Finalize("https://www.example.com")
+		assert.Equal(t, scores.Grade, grade, name)
+		assert.Equal(t, scores.Profile, name)
+	}
+}
+
+func TestCalculateOverallScoreProfile(t *testing.T) {
+	// the site is served over http, so it misses the checks weighted up by the strict profile
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(XFrameHeader, "DENY")
+		w.Header().Set(XContentTypeHeader, "nosniff")
+		w.Header().Set(RPHeader, "no-referrer")
+	}))
+	defer server.Close()
+
+	skip := []string{SkipVulnerabilities, SkipDNS}
+	scores := make(map[string]float64)
+	for _, profile := range []string{StrictProfile, LenientProfile} {
+		responseBody, err := CalculateOverallScore(server.URL, &models.ScanOptions{Skip: skip, Profile: profile})
+		assert.NoError(t, err)
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(responseBody, &response))
+		assert.Equal(t, response.Scores.Profile, profile)
+		scores[profile] = response.Scores.Score
+	}
+	// the missing headers weigh more in the strict profile
+	assert.True(t, scores[StrictProfile] < scores[LenientProfile], scores)
+
+	_, err := CalculateOverallScore(server.URL, &models.ScanOptions{Profile: "paranoid"})
+	assert.ErrorIs(t, err, ErrUnknownScoringProfile)
+}
+
+func TestGetPreviousVulnerabilitiesScore(t *testing.T) {
+	restore := mockFetchIncidents(`<incidents>
+	<item>
+		<host>example.com</host>
+		<reporteddate>Mon, 02 Jan 2017 15:04:05 +0000</reporteddate>
+		<fixed>1</fixed>
+		<fixeddate>Tue, 03 Jan 2017 15:04:05 +0000</fixeddate>
+	</item>
+	<item>
+		<host>example.com</host>
+		<reporteddate>Mon, 02 Jan 2017 15:04:05 +0000</reporteddate>
+		<fixed>1</fixed>
+		<fixeddate>Mon, 06 Mar 2017 15:04:05 +0000</fixeddate>
+	</item>
+	<item>
+		<host>example.com</host>
+		<reporteddate>Mon, 02 Jan 2017 15:04:05 +0000</reporteddate>
+		<fixed>0</fixed>
+	</item>
+</incidents>`, nil)
+	defer restore()
+
+	totalScore, maxScore, incidentList, err := GetPreviousVulnerabilitiesScore("www.example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, totalScore, 15)
+	assert.Equal(t, maxScore, 30)
+	assert.Equal(t, len(incidentList), 3)
+}
+
+func TestGetPreviousVulnerabilitiesScoreDateLayouts(t *testing.T) {
+	restore := mockFetchIncidents(`<incidents>
+	<item>
+		<reporteddate>Mon, 02 Jan 2017 15:04:05 +0000</reporteddate>
+		<fixed>1</fixed>
+		<fixeddate>2017-01-03T15:04:05Z</fixeddate>
+	</item>
+	<item>
+		<reporteddate>2017-01-02T15:04:05+01:00</reporteddate>
+		<fixed>1</fixed>
+		<fixeddate>2017-03-06 15:04:05</fixeddate>
+	</item>
+	<item>
+		<reporteddate>02/01/2017</reporteddate>
+		<fixed>1</fixed>
+		<fixeddate>Tue, 03 Jan 2017 15:04:05 +0000</fixeddate>
+	</item>
+	<item>
+		<reporteddate>Mon, 02 Jan 2017 15:04:05 +0000</reporteddate>
+		<fixed>1</fixed>
+	</item>
+	<item>
+		<reporteddate>Tue, 03 Jan 2017 15:04:05 +0000</reporteddate>
+		<fixed>1</fixed>
+		<fixeddate>Mon, 02 Jan 2017 15:04:05 +0000</fixeddate>
+	</item>
+</incidents>`, nil)
+	defer restore()
+
+	// the incidents with malformed, missing or inconsistent dates are neither scored nor counted in the maximum
+	totalScore, maxScore, incidentList, err := GetPreviousVulnerabilitiesScore("example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, totalScore, 15)
+	assert.Equal(t, maxScore, 20)
+	assert.Equal(t, len(incidentList), 5)
+}
+
+func TestGetPreviousVulnerabilitiesScoreSeverity(t *testing.T) {
+	restore := mockFetchIncidents(`<incidents>
+	<item>
+		<type>Cross Site Scripting</type>
+		<reporteddate>Mon, 02 Jan 2017 15:04:05 +0000</reporteddate>
+		<fixed>1</fixed>
+		<fixeddate>Tue, 03 Jan 2017 15:04:05 +0000</fixeddate>
+	</item>
+	<item>
+		<type>Open Redirect</type>
+		<reporteddate>Mon, 02 Jan 2017 15:04:05 +0000</reporteddate>
+		<fixed>1</fixed>
+		<fixeddate>Mon, 06 Mar 2017 15:04:05 +0000</fixeddate>
+	</item>
+	<item>
+		<type>SQL Injection</type>
+		<reporteddate>Mon, 02 Jan 2017 15:04:05 +0000</reporteddate>
+		<fixed>0</fixed>
+	</item>
+	<item>
+		<type>Unknown Type</type>
+		<reporteddate>Mon, 02 Jan 2017 15:04:05 +0000</reporteddate>
+		<fixed>1</fixed>
+		<fixeddate>Mon, 06 Mar 2017 15:04:05 +0000</fixeddate>
+	</item>
+</incidents>`, nil)
+	defer restore()
+
+	// XSS fixed in a day 20/20, Open Redirect fixed late 5/10, unfixed SQL Injection 0/30, unknown type fixed late 5/10
+	totalScore, maxScore, _, err := GetPreviousVulnerabilitiesScore("example.com")
+	assert.Nil(t, err)
+	assert.Equal(t, totalScore, 30)
+	assert.Equal(t, maxScore, 70)
+	assert.True(t, totalScore <= maxScore)
+}
+
+func TestGetPreviousVulnerabilitiesScoreError(t *testing.T) {
+	restore := mockFetchIncidents("", errors.New("timeout"))
+	defer restore()
+
+	totalScore, maxScore, incidentList, err := GetPreviousVulnerabilitiesScore("example.com")
+	assert.Error(t, err)
+	assert.Equal(t, totalScore, 0)
+	assert.Equal(t, maxScore, 0)
+	assert.Nil(t, incidentList)
+
+	mockFetchIncidents("<incidents>", nil)
+	_, _, _, err = GetPreviousVulnerabilitiesScore("example.com")
+	assert.Error(t, err)
+}
+
+func TestGetPreviousVulnerabilitiesScoreRetry(t *testing.T) {
+	utils.RetryBackoff = time.Millisecond
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, r.URL.Query().Get("domain"), "example.com")
+		fmt.Fprint(w, `<incidents><item><host>example.com</host><fixed>0</fixed></item></incidents>`)
+	}))
+	defer server.Close()
+	os.Setenv("OPENBUGBOUNTY_URL", server.URL+"/?domain=")
+	defer os.Unsetenv("OPENBUGBOUNTY_URL")
+
+	totalScore, maxScore, incidentList, err := GetPreviousVulnerabilitiesScore("www.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, requests, 2)
+	assert.Equal(t, totalScore, 0)
+	assert.Equal(t, maxScore, 10)
+	assert.Equal(t, len(incidentList), 1)
+}
+
+func TestGetHostAndPort(t *testing.T) {
+	domain, _ := url.Parse("https://[2606:4700::1]")
+	host, port := getHostAndPort(domain)
+	assert.Equal(t, host, "2606:4700::1")
+	assert.Equal(t, port, "443")
+
+	domain, _ = url.Parse("http://[2606:4700::1]:8080/path")
+	host, port = getHostAndPort(domain)
+	assert.Equal(t, host, "2606:4700::1")
+	assert.Equal(t, port, "8080")
+
+	domain, _ = url.Parse("https://www.example.com:8443")
+	host, port = getHostAndPort(domain)
+	assert.Equal(t, host, "www.example.com")
+	assert.Equal(t, port, "8443")
+}
+
+func TestGetResponseHeaderScoreIPv6(t *testing.T) {
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("IPv6 is not available", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(XContentTypeHeader, "nosniff")
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	// server.URL is of the form http://[::1]:port
+	responseHeaderScore, _, _, err := GetResponseHeaderScore(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, getCheck(responseHeaderScore.checks, XContentTypeHeader).Score, 5)
+}
+
+func TestGetResponseHeaderScoreDualStackHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(XContentTypeHeader, "nosniff")
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// localhost may resolve to both 127.0.0.1 and ::1
+	responseHeaderScore, _, _, err := GetResponseHeaderScore("http://localhost:" + port)
+	assert.NoError(t, err)
+	assert.Equal(t, getCheck(responseHeaderScore.checks, XContentTypeHeader).Score, 5)
+}
+
+func TestGetResponseHeaderScoreSameHostRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			http.Redirect(w, r, "/home", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set(XContentTypeHeader, XContentTypeHeaderValue)
+	}))
+	defer server.Close()
+
+	responseHeaderScore, _, _, err := GetResponseHeaderScore(server.URL + "/")
+	assert.NoError(t, err)
+	assert.Equal(t, responseHeaderScore.redirectChain, []string{server.URL + "/", server.URL + "/home"})
+	assert.False(t, responseHeaderScore.crossHostRedirect)
+	// the headers of the final response are scored
+	assert.Equal(t, getCheck(responseHeaderScore.checks, XContentTypeHeader).Score, 5)
+}
+
+func TestGetResponseHeaderScoreCrossHostRedirect(t *testing.T) {
+	destination := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer destination.Close()
+	_, port, _ := net.SplitHostPort(destination.Listener.Addr().String())
+	destinationURL := "http://localhost:" + port + "/"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, destinationURL, http.StatusFound)
+	}))
+	defer server.Close()
+
+	responseHeaderScore, _, _, err := GetResponseHeaderScore(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, responseHeaderScore.redirectChain, []string{server.URL, destinationURL})
+	assert.True(t, responseHeaderScore.crossHostRedirect)
+}
+
+func TestGetResponseHeaderScoreRedirectLoop(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/a" {
+			http.Redirect(w, r, "/b", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, "/a", http.StatusFound)
+	}))
+	defer server.Close()
+
+	_, _, _, err := GetResponseHeaderScore(server.URL + "/a")
+	assert.True(t, errors.Is(err, utils.ErrRedirectLoop), err)
+	assert.Equal(t, requests, 2)
+
+	// a chain of distinct URLs is capped, and the last response is scored
+	requests = 0
+	endless := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Redirect(w, r, fmt.Sprintf("/%d", requests), http.StatusFound)
+	}))
+	defer endless.Close()
+
+	responseHeaderScore, _, _, err := GetResponseHeaderScore(endless.URL)
+	assert.NoError(t, err)
+	assert.True(t, responseHeaderScore.redirectLimitExceeded)
+	assert.Equal(t, requests, utils.DefaultMaxRedirects+1)
+	assert.Equal(t, len(responseHeaderScore.redirectChain), utils.DefaultMaxRedirects+1)
+}
+
+func TestGetResponseHeaderScoreRequestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+	originalTimeout := utils.RequestTimeout
+	utils.RequestTimeout = 50 * time.Millisecond
+	defer func() { utils.RequestTimeout = originalTimeout }()
+
+	start := time.Now()
+	_, _, _, err := GetResponseHeaderScore(server.URL)
+	var netError net.Error
+	assert.True(t, errors.As(err, &netError) && netError.Timeout(), err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestCalculateOverallScoreRedirectLimit(t *testing.T) {
+	os.Setenv("MAX_REDIRECTS", "3")
+	defer os.Unsetenv("MAX_REDIRECTS")
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			return
+		}
+		requests++
+		if requests <= 5 {
+			http.Redirect(w, r, fmt.Sprintf("/%d", requests), http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	responseBody, err := CalculateOverallScore(server.URL, &models.ScanOptions{Skip: []string{SkipVulnerabilities, SkipDNS}})
+	assert.NoError(t, err)
+	var response models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+	// the requested URL and the 3 redirects followed are reported, the fourth one is not followed
+	assert.True(t, response.Scores.RedirectLimitExceeded)
+	assert.Equal(t, requests, 4)
+	assert.Equal(t, response.Scores.RedirectChain, []string{server.URL, server.URL + "/1", server.URL + "/2", server.URL + "/3"})
+	assert.Equal(t, response.Scores.FinalURL, server.URL+"/3")
+
+	// a chain within the limit is not capped
+	os.Unsetenv("MAX_REDIRECTS")
+	requests = 0
+	responseBody, err = CalculateOverallScore(server.URL, &models.ScanOptions{Skip: []string{SkipVulnerabilities, SkipDNS}})
+	assert.NoError(t, err)
+	response = models.ScoresResponse{}
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+	assert.False(t, response.Scores.RedirectLimitExceeded)
+	assert.Equal(t, len(response.Scores.RedirectChain), 6)
+}
+
+func TestGetResponseHeaderScoreAnomalies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add(XFrameHeader, "DENY")
+		w.Header().Add(XFrameHeader, "SAMEORIGIN")
+		w.Header().Add(XContentTypeHeader, "nosniff")
+		w.Header().Add(XContentTypeHeader, "NoSniff ")
+		w.Header().Add(CSPHeader, "default-src 'self'")
+		w.Header().Add(CSPHeader, "frame-ancestors 'none'")
+		w.Header().Set(CrossDomainPolicyHeader, r.Header.Get(ReflectionProbeHeader))
+	}))
+	defer server.Close()
+
+	responseHeaderScore, _, _, err := GetResponseHeaderScore(server.URL)
+	assert.NoError(t, err)
+	// conflicting duplicates are reported, while identical duplicates and repeated policies are not
+	assert.Equal(t, getCheck(responseHeaderScore.checks, XFrameHeader).Warnings,
+		[]string{fmt.Sprintf(utils.ConflictingHeaderMessage, XFrameHeader, 2, "DENY | SAMEORIGIN")})
+	assert.Empty(t, getCheck(responseHeaderScore.checks, XContentTypeHeader).Warnings)
+	assert.Empty(t, getCheck(responseHeaderScore.checks, CSPHeader).Warnings)
+	// a header echoing the request is reported as reflected
+	assert.Equal(t, getCheck(responseHeaderScore.checks, CrossDomainPolicyHeader).Warnings,
+		[]string{fmt.Sprintf(utils.ReflectedHeaderMessage, CrossDomainPolicyHeader)})
+}
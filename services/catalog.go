@@ -0,0 +1,52 @@
+package services
+
+import (
+	"snift-api/models"
+	"snift-api/utils"
+	"sort"
+)
+
+// getCheckInfo returns the catalog entry of a check
+func getCheckInfo(name string, maxScore int) *models.CheckInfo {
+	return &models.CheckInfo{
+		Name:        name,
+		Description: CheckDescriptions[name],
+		MaxScore:    maxScore,
+		Badge:       CheckBadges[name],
+	}
+}
+
+// GetCheckCatalog returns the checks of a scan run with the default options, in the order they are reported.
+// The maximum scores of the response headers are taken from their scorers, so the catalog follows their weights
+func GetCheckCatalog() []*models.CheckInfo {
+	protocol := getCheckInfo(ProtocolCheck, HTTPSScore)
+	protocol.Critical = true
+	catalog := []*models.CheckInfo{protocol}
+
+	responseHeaderScore, _ := BuildResponseHeaderScore(getResponseHeaders(map[string]string{}, "https", "", "", nil, 0, nil, false, false, new(bool))...)
+	for _, check := range responseHeaderScore.checks {
+		catalog = append(catalog, getCheckInfo(check.Name, check.MaxScore))
+	}
+
+	catalog = append(catalog, getCheckInfo(SecurityTxtCheck, SecurityTxtScore))
+	if utils.IsSensitivePathsCheckEnabled() {
+		catalog = append(catalog, getCheckInfo(SensitivePathsCheck, SensitivePathsScore))
+	}
+
+	spf := getCheckInfo(SPFCheck, 5)
+	spf.VariableMaxScore = true
+	dkim := getCheckInfo(DKIMCheck, DKIMScore)
+	dkim.VariableMaxScore = true
+	catalog = append(catalog, spf, getCheckInfo(DMARCCheck, 5), dkim, getCheckInfo(BIMICheck, BIMIScore))
+
+	// every incident adds 10 points per level of severity to the maximum score
+	vulnerabilities := getCheckInfo(PreviousVulnerabilitiesCheck, 0)
+	vulnerabilities.VariableMaxScore = true
+	catalog = append(catalog, vulnerabilities, getCheckInfo(CertificateValidityCheck, CertificateValidityScore))
+
+	order := getCheckOrder()
+	sort.SliceStable(catalog, func(i, j int) bool {
+		return models.GetCheckRank(order, catalog[i].Name) < models.GetCheckRank(order, catalog[j].Name)
+	})
+	return catalog
+}
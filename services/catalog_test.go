@@ -0,0 +1,80 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"snift-api/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCheckCatalog(t *testing.T) {
+	os.Setenv("SENSITIVE_PATHS_CHECK", "true")
+	defer os.Unsetenv("SENSITIVE_PATHS_CHECK")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	defer mockFetchIncidents("<incidents></incidents>", nil)()
+	defer mockLookupTXT(nil)()
+
+	responseBody, err := CalculateOverallScore(server.URL, nil)
+	assert.NoError(t, err)
+	var response models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+
+	// the catalog lists every check of a full scan, in the same order and with the same fixed maximum scores, but for
+	// the check of the certificate the test site served over http does not have
+	var catalog []*models.CheckInfo
+	for _, check := range GetCheckCatalog() {
+		if check.Name != CertificateValidityCheck {
+			catalog = append(catalog, check)
+		}
+	}
+	assert.Equal(t, len(catalog), len(GetCheckCatalog())-1)
+	assert.Equal(t, len(catalog), len(response.Scores.Checks))
+	for i, check := range response.Scores.Checks {
+		assert.Equal(t, catalog[i].Name, check.Name)
+		assert.NotEmpty(t, catalog[i].Description, check.Name)
+		if !catalog[i].VariableMaxScore {
+			assert.Equal(t, catalog[i].MaxScore, check.MaxScore, check.Name)
+		}
+	}
+	assert.True(t, catalog[0].Critical)
+	assert.Equal(t, getCheckInfo(CacheControlHeader, 0).Badge, "")
+}
+
+func TestGetCheckCatalogSensitivePaths(t *testing.T) {
+	for _, check := range GetCheckCatalog() {
+		assert.NotEqual(t, check.Name, SensitivePathsCheck)
+	}
+}
+
+func TestGetCheckCatalogOrder(t *testing.T) {
+	os.Setenv("CHECK_ORDER", SPFCheck+", "+XFrameHeader)
+	defer os.Unsetenv("CHECK_ORDER")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	defer mockFetchIncidents("<incidents></incidents>", nil)()
+	defer mockLookupTXT(nil)()
+
+	var streamed []string
+	options := &models.ScanOptions{OnCheck: func(check *models.CheckResult) {
+		streamed = append(streamed, check.Name)
+	}}
+	responseBody, err := CalculateOverallScore(server.URL, options)
+	assert.NoError(t, err)
+	var response models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+
+	// the configured checks are reported first, in the catalog as well as in the scan, and the streamed checks are
+	// ordered within the batches reported together
+	catalog := GetCheckCatalog()
+	assert.Equal(t, catalog[0].Name, SPFCheck)
+	assert.Equal(t, catalog[1].Name, XFrameHeader)
+	assert.Equal(t, response.Scores.Checks[0].Name, SPFCheck)
+	assert.Equal(t, response.Scores.Checks[1].Name, XFrameHeader)
+	assert.Equal(t, len(streamed), len(response.Scores.Checks))
+	assert.Equal(t, streamed[:2], []string{ProtocolCheck, XFrameHeader})
+}
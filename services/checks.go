@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"snift-api/models"
+	"snift-api/utils"
+	"sync"
+)
+
+// Check is a check run against every scanned site, CalculateOverallScore runs the registered checks in the order
+// they were registered and scores the site from their results
+type Check interface {
+	// Name returns the name of the check, or of the group of checks it runs
+	Name() string
+	// Run runs the check against the target, a check that the options of the scan leave out returns no result
+	Run(ctx context.Context, target *ScanTarget) CheckResult
+}
+
+// CheckResult holds the checks and badges recorded by a Check
+type CheckResult struct {
+	Checks []*models.CheckResult
+	Badges []*models.Badge
+	// FromResponse is true when the checks are scored from the response, they are then scored again from its
+	// observations rather than stored along with them
+	FromResponse bool
+}
+
+// ScanTarget is the site a scan runs the checks against, along with what the checks found besides their results
+type ScanTarget struct {
+	// URL is the URL requested, with its host in its ASCII form
+	URL      string
+	Host     string
+	Port     string
+	Protocol string
+	Options  *models.ScanOptions
+	only     map[string]bool
+	// response is the response of the site, along with the checks scored from it
+	response     *HeaderScore
+	txtRecords   string
+	dmarcRecords string
+	incidents    []models.Incident
+	cert         *models.Cert
+}
+
+// Runs returns true when the scan runs one of the checks
+func (target *ScanTarget) Runs(checks ...string) bool {
+	return runsAnyCheck(target.only, checks...)
+}
+
+var (
+	checkRegistry = []Check{
+		protocolCheck{},
+		responseHeadersCheck{},
+		securityTxtCheck{},
+		sensitivePathsCheck{},
+		mailServerCheck{},
+		previousVulnerabilitiesCheck{},
+		certificateCheck{},
+	}
+	checkRegistryMutex sync.RWMutex
+)
+
+// RegisterCheck adds a check to those run by every scan, after the checks registered before it
+func RegisterCheck(check Check) {
+	checkRegistryMutex.Lock()
+	defer checkRegistryMutex.Unlock()
+	checkRegistry = append(checkRegistry, check)
+}
+
+// RegisteredChecks returns the checks run by every scan, in the order they run
+func RegisteredChecks() []Check {
+	checkRegistryMutex.RLock()
+	defer checkRegistryMutex.RUnlock()
+	return append([]Check(nil), checkRegistry...)
+}
+
+// protocolCheck scores the protocol of the site, HTTPS being critical
+type protocolCheck struct{}
+
+func (protocolCheck) Name() string {
+	return ProtocolCheck
+}
+
+func (protocolCheck) Run(ctx context.Context, target *ScanTarget) CheckResult {
+	result := CheckResult{FromResponse: true}
+	protocolScore := CalculateProtocolScore(target.Protocol)
+	if protocolScore == HTTPSScore {
+		result.Badges = append(result.Badges, utils.GetHTTPSBadge())
+	}
+	check := models.GetCheckResult(ProtocolCheck, protocolScore, HTTPSScore)
+	check.Critical = true
+	result.Checks = append(result.Checks, check)
+	return result
+}
+
+// responseHeadersCheck reports the checks scored from the response of the site, its headers, HTTP and TLS versions
+type responseHeadersCheck struct{}
+
+func (responseHeadersCheck) Name() string {
+	return "Response-Headers"
+}
+
+func (responseHeadersCheck) Run(ctx context.Context, target *ScanTarget) CheckResult {
+	return CheckResult{Checks: target.response.checks, Badges: target.response.badges, FromResponse: true}
+}
+
+// securityTxtCheck scores the security.txt file of the site
+type securityTxtCheck struct{}
+
+func (securityTxtCheck) Name() string {
+	return SecurityTxtCheck
+}
+
+func (securityTxtCheck) Run(ctx context.Context, target *ScanTarget) CheckResult {
+	if !target.Runs(SecurityTxtCheck) {
+		return CheckResult{}
+	}
+	securityTxtScore := getSecurityTxtScore(ctx, target.URL)
+	return CheckResult{Checks: []*models.CheckResult{models.GetCheckResult(SecurityTxtCheck, securityTxtScore, SecurityTxtScore)}}
+}
+
+// sensitivePathsCheck scores the sensitive paths exposed by the site, when SENSITIVE_PATHS_CHECK enables it
+type sensitivePathsCheck struct{}
+
+func (sensitivePathsCheck) Name() string {
+	return SensitivePathsCheck
+}
+
+func (sensitivePathsCheck) Run(ctx context.Context, target *ScanTarget) CheckResult {
+	if !utils.IsSensitivePathsCheckEnabled() || !target.Runs(SensitivePathsCheck) {
+		return CheckResult{}
+	}
+	sensitivePathsScore, sensitivePathsFindings := getSensitivePathsScore(ctx, target.URL)
+	check := models.GetCheckResult(SensitivePathsCheck, sensitivePathsScore, SensitivePathsScore)
+	check.Findings = sensitivePathsFindings
+	return CheckResult{Checks: []*models.CheckResult{check}}
+}
+
+// mailServerCheck scores the mail server configuration of the domain, its SPF, DMARC, DKIM and BIMI records
+type mailServerCheck struct{}
+
+func (mailServerCheck) Name() string {
+	return "Mail-Server-Configuration"
+}
+
+func (mailServerCheck) Run(ctx context.Context, target *ScanTarget) CheckResult {
+	if target.Options.IsSkipped(SkipDNS) || !target.Runs(SPFCheck, DMARCCheck, DKIMCheck, BIMICheck) {
+		return CheckResult{}
+	}
+	builder := models.NewScoreBuilder()
+	_, target.txtRecords, target.dmarcRecords = GetMailServerConfigurationScore(MailServerConfigParams{target.Host, target.Options.GetDKIMSelectors(), builder, ctx})
+	return CheckResult{Checks: builder.Checks(), Badges: builder.Badges()}
+}
+
+// previousVulnerabilitiesCheck scores the response of the host to the incidents reported on openbugbounty.org
+type previousVulnerabilitiesCheck struct{}
+
+func (previousVulnerabilitiesCheck) Name() string {
+	return PreviousVulnerabilitiesCheck
+}
+
+func (previousVulnerabilitiesCheck) Run(ctx context.Context, target *ScanTarget) CheckResult {
+	if target.Options.IsSkipped(SkipVulnerabilities) || !target.Runs(PreviousVulnerabilitiesCheck) {
+		return CheckResult{}
+	}
+	// A failing openbugbounty lookup must not fail the whole scan, the check is skipped instead
+	vulnerabilityScore, maxVulnerabilityScore, incidents, err := GetPreviousVulnerabilitiesScoreContext(ctx, target.Host)
+	target.incidents = incidents
+	if err != nil {
+		fmt.Println("Skipping Previous Vulnerabilities Score for "+target.Host, err)
+		return CheckResult{}
+	}
+	return CheckResult{Checks: []*models.CheckResult{models.GetCheckResult(PreviousVulnerabilitiesCheck, vulnerabilityScore, maxVulnerabilityScore)}}
+}
+
+// certificateCheck retrieves the certificate of the site, which the checks of the response are scored along with, and
+// scores its total validity
+type certificateCheck struct{}
+
+func (certificateCheck) Name() string {
+	return "Certificate"
+}
+
+func (certificateCheck) Run(ctx context.Context, target *ScanTarget) CheckResult {
+	if !target.Runs(ProtocolCheck, TLSVersionCheck, OCSPStaplingCheck, CertificateValidityCheck) {
+		return CheckResult{}
+	}
+	// A certificate that cannot be retrieved is reported with the class of the failure rather than failing the scan
+	certificates, err := models.GetCertificateContext(ctx, target.Host, target.Port, target.Protocol)
+	if err != nil {
+		fmt.Println("Error Occured while fetching the certificate of "+target.Host, err)
+	}
+	target.cert = certificates
+	if certificates == nil || certificates.ValidityDays == 0 {
+		return CheckResult{}
+	}
+	return CheckResult{Checks: []*models.CheckResult{GetCertificateValidityCheck(certificates)}}
+}
+
+// GetCertificateValidityCheck returns the check of the total validity of a certificate, which loses its score when the
+// certificate is valid for longer than the maximum validity, as it either breaks the CA/Browser Forum rules or was
+// issued before them and left unrenewed for years
+func GetCertificateValidityCheck(cert *models.Cert) *models.CheckResult {
+	if !cert.OverlongValidity {
+		return models.GetCheckResult(CertificateValidityCheck, CertificateValidityScore, CertificateValidityScore)
+	}
+	check := models.GetCheckResult(CertificateValidityCheck, 0, CertificateValidityScore)
+	check.Findings = []string{fmt.Sprintf(utils.CertValidityMessage, cert.ValidityDays, models.MaxValidityDays)}
+	return check
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"snift-api/models"
+	"snift-api/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// dummyCheck records the target it runs against and scores it 3 out of 4
+type dummyCheck struct {
+	target *ScanTarget
+}
+
+func (check *dummyCheck) Name() string {
+	return "Dummy"
+}
+
+func (check *dummyCheck) Run(ctx context.Context, target *ScanTarget) CheckResult {
+	check.target = target
+	return CheckResult{
+		Checks: []*models.CheckResult{models.GetCheckResult("Dummy", 3, 4)},
+		Badges: []*models.Badge{{Name: "DUMMY", Message: "Dummy Badge"}},
+	}
+}
+
+func TestRegisterCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	originalFetchIncidents, originalLookupTXT := fetchIncidents, lookupTXT
+	defer func() { fetchIncidents, lookupTXT = originalFetchIncidents, originalLookupTXT }()
+	fetchIncidents = func(ctx context.Context, host string) ([]byte, error) {
+		return []byte("<incidents></incidents>"), nil
+	}
+	lookupTXT = func(ctx context.Context, domain string) ([]string, error) {
+		return nil, errors.New("no such host")
+	}
+
+	responseBody, err := CalculateOverallScore(server.URL, &models.ScanOptions{Skip: []string{SkipDNS}})
+	assert.NoError(t, err)
+	var unregistered models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &unregistered))
+
+	defer func(original []Check) { checkRegistry = original }(RegisteredChecks())
+	check := &dummyCheck{}
+	RegisterCheck(check)
+	registered := RegisteredChecks()
+	assert.Equal(t, registered[len(registered)-1], Check(check))
+
+	responseBody, err = CalculateOverallScore(server.URL, &models.ScanOptions{Skip: []string{SkipDNS}})
+	assert.NoError(t, err)
+	var response models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &response))
+
+	// the registered check runs against the scanned site
+	assert.NotNil(t, check.target)
+	assert.Equal(t, check.target.Host, "127.0.0.1")
+	assert.Equal(t, check.target.Protocol, "http")
+
+	// and its result contributes to the score, along with its badge
+	var dummy *models.CheckResult
+	for _, checkResult := range response.Scores.Checks {
+		if checkResult.Name == "Dummy" {
+			dummy = checkResult
+		}
+	}
+	assert.NotNil(t, dummy)
+	assert.Equal(t, dummy.Score, 3)
+	assert.Equal(t, dummy.MaxScore, 4)
+	assert.Equal(t, len(response.Scores.Checks), len(unregistered.Scores.Checks)+1)
+	assert.Contains(t, response.Scores.Badges, &models.Badge{Name: "DUMMY", Message: "Dummy Badge"})
+	assert.NotEqual(t, response.Scores.Score, unregistered.Scores.Score)
+}
+
+func TestRegisteredChecks(t *testing.T) {
+	var names []string
+	for _, check := range RegisteredChecks() {
+		names = append(names, check.Name())
+	}
+	assert.Equal(t, names, []string{
+		ProtocolCheck, "Response-Headers", SecurityTxtCheck, SensitivePathsCheck, "Mail-Server-Configuration",
+		PreviousVulnerabilitiesCheck, "Certificate",
+	})
+}
+
+func TestGetCertificateValidityCheck(t *testing.T) {
+	check := GetCertificateValidityCheck(&models.Cert{ValidityDays: 90})
+	assert.Equal(t, check.Name, CertificateValidityCheck)
+	assert.Equal(t, check.Score, CertificateValidityScore)
+	assert.Equal(t, check.MaxScore, CertificateValidityScore)
+	assert.Empty(t, check.Findings)
+
+	check = GetCertificateValidityCheck(&models.Cert{ValidityDays: 1825, OverlongValidity: true})
+	assert.Equal(t, check.Score, 0)
+	assert.Equal(t, check.MaxScore, CertificateValidityScore)
+	assert.Equal(t, check.Findings, []string{fmt.Sprintf(utils.CertValidityMessage, 1825, models.MaxValidityDays)})
+}
@@ -1,5 +1,13 @@
 package services
 
+import (
+	"crypto/tls"
+	"encoding/asn1"
+	"snift-api/models"
+	"snift-api/utils"
+	"time"
+)
+
 // XSSHeader has the XSS Header Name
 const XSSHeader = "X-Xss-Protection"
 
@@ -21,30 +29,436 @@ const RPHeader = "Referrer-Policy"
 // XContentTypeHeader has the X-Content-Type Header Name
 const XContentTypeHeader = "X-Content-Type-Options"
 
+// CrossDomainPolicyHeader has the X-Permitted-Cross-Domain-Policies Header Name
+const CrossDomainPolicyHeader = "X-Permitted-Cross-Domain-Policies"
+
+// COOPHeader has the Cross-Origin-Opener-Policy Header Name
+const COOPHeader = "Cross-Origin-Opener-Policy"
+
+// COEPHeader has the Cross-Origin-Embedder-Policy Header Name
+const COEPHeader = "Cross-Origin-Embedder-Policy"
+
+// CORPHeader has the Cross-Origin-Resource-Policy Header Name
+const CORPHeader = "Cross-Origin-Resource-Policy"
+
+// ClearSiteDataHeader has the Clear-Site-Data Header Name
+const ClearSiteDataHeader = "Clear-Site-Data"
+
+// CacheControlHeader has the Cache-Control Header Name
+const CacheControlHeader = "Cache-Control"
+
+// ReportingHeader has the Reporting-Endpoints Header Name
+const ReportingHeader = "Reporting-Endpoints"
+
+// ACAOHeader has the Access-Control-Allow-Origin Header Name
+const ACAOHeader = "Access-Control-Allow-Origin"
+
+// ACACHeader has the Access-Control-Allow-Credentials Header Name
+const ACACHeader = "Access-Control-Allow-Credentials"
+
+// CORSProbeOriginFormat formats the Origin sent along with the reflection probe, under a reserved TLD
+const CORSProbeOriginFormat = "https://%s.invalid"
+
+// ReportToHeader has the legacy Report-To Header Name, superseded by Reporting-Endpoints
+const ReportToHeader = "Report-To"
+
 // Server has the Server Header
 const Server = "Server"
 
-// TXTQuery is used to extract all the TXT Records of a Domain
-const TXTQuery = "dig @8.8.8.8 +ignore +short +bufsize=1024 domain.com txt"
+// Names of the checks that are not scored from a single Response Header
+const (
+	ProtocolCheck                = "Protocol"
+	CrossOriginIsolationCheck    = "Cross-Origin-Isolation"
+	HTTPVersionCheck             = "HTTP-Version"
+	TLSVersionCheck              = "TLS-Version"
+	OCSPStaplingCheck            = "OCSP-Stapling"
+	CertificateTransparencyCheck = "Certificate-Transparency"
+	CertificateValidityCheck     = "Certificate-Validity"
+	TLSRenegotiationCheck        = "TLS-Renegotiation-Compression"
+	ReportingCheck               = "Reporting"
+	CORSCheck                    = "CORS"
+	TransportSecurityCheck       = "Transport-Security"
+	SessionResumptionCheck       = "TLS-Session-Resumption"
+	SPFCheck                     = "SPF"
+	DMARCCheck                   = "DMARC"
+	DKIMCheck                    = "DKIM"
+	BIMICheck                    = "BIMI"
+	PreviousVulnerabilitiesCheck = "Previous-Vulnerabilities"
+	SecurityTxtCheck             = "Security-Txt"
+	SensitivePathsCheck          = "Sensitive-Paths"
+)
+
+// DefaultCheckOrder lists the most security-critical checks, reported first when CHECK_ORDER is not set: the protocol
+// and the certificate come before the headers enforcing HTTPS and restricting the content of the page
+var DefaultCheckOrder = []string{ProtocolCheck, TLSVersionCheck, OCSPStaplingCheck, HSTSHeader, CSPHeader}
+
+// Remediations is used to store the remediation for each check, reported when the check does not get the full score
+var Remediations = map[string]string{
+	ProtocolCheck:                utils.ProtocolRemediation,
+	XSSHeader:                    utils.XSSRemediation,
+	XFrameHeader:                 utils.XFrameRemediation,
+	HSTSHeader:                   utils.HSTSRemediation,
+	CSPHeader:                    utils.CSPRemediation,
+	PKPHeader:                    utils.PKPRemediation,
+	RPHeader:                     utils.RPRemediation,
+	XContentTypeHeader:           utils.XContentTypeRemediation,
+	CrossDomainPolicyHeader:      utils.CrossDomainPolicyRemediation,
+	CrossOriginIsolationCheck:    utils.CrossOriginIsolationRemediation,
+	ClearSiteDataHeader:          utils.ClearSiteDataRemediation,
+	CacheControlHeader:           utils.CacheControlRemediation,
+	Server:                       utils.ServerRemediation,
+	HTTPVersionCheck:             utils.HTTPVersionRemediation,
+	TLSVersionCheck:              utils.TLSVersionRemediation,
+	OCSPStaplingCheck:            utils.OCSPStaplingRemediation,
+	CertificateTransparencyCheck: utils.CertificateTransparencyRemediation,
+	CertificateValidityCheck:     utils.CertificateValidityRemediation,
+	TLSRenegotiationCheck:        utils.TLSRenegotiationRemediation,
+	ReportingCheck:               utils.ReportingRemediation,
+	CORSCheck:                    utils.CORSRemediation,
+	TransportSecurityCheck:       utils.TransportSecurityRemediation,
+	SessionResumptionCheck:       utils.SessionResumptionRemediation,
+	SPFCheck:                     utils.SPFRemediation,
+	DMARCCheck:                   utils.DMARCRemediation,
+	DKIMCheck:                    utils.DKIMRemediation,
+	BIMICheck:                    utils.BIMIRemediation,
+	PreviousVulnerabilitiesCheck: utils.PreviousVulnerabilitiesRemediation,
+	SecurityTxtCheck:             utils.SecurityTxtRemediation,
+	SensitivePathsCheck:          utils.SensitivePathsRemediation,
+}
+
+// CheckDescriptions is used to describe every check in the catalog of checks
+var CheckDescriptions = map[string]string{
+	ProtocolCheck:                utils.ProtocolDescription,
+	XSSHeader:                    utils.XSSDescription,
+	XFrameHeader:                 utils.XFrameDescription,
+	HSTSHeader:                   utils.HSTSDescription,
+	CSPHeader:                    utils.CSPDescription,
+	PKPHeader:                    utils.PKPDescription,
+	RPHeader:                     utils.RPDescription,
+	XContentTypeHeader:           utils.XContentTypeDescription,
+	CrossDomainPolicyHeader:      utils.CrossDomainPolicyDescription,
+	CrossOriginIsolationCheck:    utils.CrossOriginIsolationDescription,
+	ClearSiteDataHeader:          utils.ClearSiteDataDescription,
+	CacheControlHeader:           utils.CacheControlDescription,
+	Server:                       utils.ServerDescription,
+	HTTPVersionCheck:             utils.HTTPVersionDescription,
+	TLSVersionCheck:              utils.TLSVersionDescription,
+	OCSPStaplingCheck:            utils.OCSPStaplingDescription,
+	CertificateTransparencyCheck: utils.CertificateTransparencyDescription,
+	CertificateValidityCheck:     utils.CertificateValidityDescription,
+	TLSRenegotiationCheck:        utils.TLSRenegotiationDescription,
+	ReportingCheck:               utils.ReportingDescription,
+	CORSCheck:                    utils.CORSDescription,
+	TransportSecurityCheck:       utils.TransportSecurityDescription,
+	SessionResumptionCheck:       utils.SessionResumptionDescription,
+	SecurityTxtCheck:             utils.SecurityTxtDescription,
+	SensitivePathsCheck:          utils.SensitivePathsDescription,
+	SPFCheck:                     utils.SPFDescription,
+	DMARCCheck:                   utils.DMARCDescription,
+	DKIMCheck:                    utils.DKIMDescription,
+	BIMICheck:                    utils.BIMIDescription,
+	PreviousVulnerabilitiesCheck: utils.PreviousVulnerabilitiesDescription,
+}
+
+// CheckBadges is used to store the badge a check can earn
+var CheckBadges = map[string]string{
+	ProtocolCheck:             utils.HTTPSBadge,
+	XFrameHeader:              utils.XFrameBadge,
+	HSTSHeader:                utils.HSTSBadge,
+	CSPHeader:                 utils.CSPBadge,
+	RPHeader:                  utils.RPBadge,
+	XContentTypeHeader:        utils.XContentTypeBadge,
+	CrossDomainPolicyHeader:   utils.CrossDomainPolicyBadge,
+	CrossOriginIsolationCheck: utils.CrossOriginIsolationBadge,
+	HTTPVersionCheck:          utils.HTTPVersionBadge,
+	TLSVersionCheck:           utils.TLSVersionBadge,
+	SPFCheck:                  utils.SPFBadge,
+}
+
+// CheckAliases is used to resolve the short names of the checks requested through the ScanOptions, the full names
+// of the checks are accepted as well, in any case
+var CheckAliases = map[string]string{
+	"https":           ProtocolCheck,
+	"xss":             XSSHeader,
+	"xfo":             XFrameHeader,
+	"hsts":            HSTSHeader,
+	"csp":             CSPHeader,
+	"hpkp":            PKPHeader,
+	"referrer":        RPHeader,
+	"nosniff":         XContentTypeHeader,
+	"coi":             CrossOriginIsolationCheck,
+	"tls":             TLSVersionCheck,
+	"ocsp":            OCSPStaplingCheck,
+	"ct":              CertificateTransparencyCheck,
+	"validity":        CertificateValidityCheck,
+	"crime":           TLSRenegotiationCheck,
+	"reporting":       ReportingCheck,
+	"resumption":      SessionResumptionCheck,
+	"cors":            CORSCheck,
+	"transport":       TransportSecurityCheck,
+	"vulnerabilities": PreviousVulnerabilitiesCheck,
+}
+
+// Groups of checks that can be skipped through the ScanOptions, vulnerabilities is the openbugbounty lookup
+// and dns covers the mail server (SPF, DMARC) and host address lookups
+const (
+	SkipVulnerabilities = "vulnerabilities"
+	SkipDNS             = "dns"
+)
+
+// SkippableChecks is used to validate the groups of checks requested to be skipped
+var SkippableChecks = [...]string{SkipVulnerabilities, SkipDNS}
+
+// Names of the scoring profiles, the balanced profile being the default
+const (
+	StrictProfile         = "strict"
+	BalancedProfile       = "balanced"
+	LenientProfile        = "lenient"
+	DefaultScoringProfile = BalancedProfile
+)
+
+// ScoringProfiles is used to store the weights and grade thresholds of every scoring profile
+var ScoringProfiles = map[string]*models.ScoringProfile{
+	StrictProfile: {
+		Name: StrictProfile,
+		Weights: map[string]float64{
+			ProtocolCheck:   2,
+			HSTSHeader:      2,
+			CSPHeader:       2,
+			TLSVersionCheck: 2,
+			XFrameHeader:    1.5,
+		},
+		GradeThresholds: []models.GradeThreshold{
+			{Grade: "A", MinScore: 0.95},
+			{Grade: "B", MinScore: 0.85},
+			{Grade: "C", MinScore: 0.75},
+			{Grade: "D", MinScore: 0.65},
+		},
+	},
+	BalancedProfile: {
+		Name:            BalancedProfile,
+		GradeThresholds: models.GradeThresholds,
+	},
+	LenientProfile: {
+		Name: LenientProfile,
+		Weights: map[string]float64{
+			PKPHeader:                 0,
+			XSSHeader:                 0.5,
+			CrossOriginIsolationCheck: 0.5,
+			HTTPVersionCheck:          0.5,
+			ClearSiteDataHeader:       0,
+			CacheControlHeader:        0,
+			Server:                    0,
+		},
+		GradeThresholds: []models.GradeThreshold{
+			{Grade: "A", MinScore: 0.85},
+			{Grade: "B", MinScore: 0.75},
+			{Grade: "C", MinScore: 0.65},
+			{Grade: "D", MinScore: 0.55},
+		},
+	},
+}
+
+// SPFMaxDNSLookups is the maximum number of DNS querying terms allowed while evaluating an SPF record (RFC 7208)
+const SPFMaxDNSLookups = 10
+
+// SPFAllValues is used to store the scores for the qualifiers of the SPF all mechanism
+var SPFAllValues = map[string]int{
+	"-": 5,
+	"~": 3,
+	"?": 2,
+	"+": 0,
+}
+
+// DMARCPrefix is prepended to a domain to query its DMARC Records
+const DMARCPrefix = "_dmarc."
+
+// DMARC policies that quarantine or reject the mail failing authentication
+const (
+	DMARCQuarantinePolicy = "quarantine"
+	DMARCRejectPolicy     = "reject"
+)
+
+// BIMIPrefix is prepended to a domain to query its default BIMI Record
+const BIMIPrefix = "default._bimi."
+
+// BIMIScore is the low weight informational score of a BIMI Record published along with an enforced DMARC policy
+const BIMIScore = 1
+
+// DKIMDomainKeyPrefix is placed between a selector and the domain to query a DKIM public key
+const DKIMDomainKeyPrefix = "._domainkey."
 
-// DMARCQuery is used to extract all the DMARC Records of a Domain
-const DMARCQuery = "dig +short TXT _dmarc.domain.com"
+// DKIMSelectors are the common selectors probed when neither the request nor DKIM_SELECTORS supplies any
+var DKIMSelectors = [...]string{"default", "dkim", "google", "k1", "mail", "s1", "s2", "selector1", "selector2"}
+
+// MaxDKIMSelectors bounds the number of selectors queried by the DKIM check
+const MaxDKIMSelectors = 10
+
+// DKIMScore is awarded when a DKIM public key is published for one of the selectors
+const DKIMScore = 5
 
 // OpenBugBountyURL is used to query for previous security incidents
 const OpenBugBountyURL = "https://www.openbugbounty.org/api/1/search/?domain="
 
+// SecurityTxtPath is the well-known location of the security.txt file (RFC 9116)
+const SecurityTxtPath = "/.well-known/security.txt"
+
+// SecurityTxtMaxSize is the maximum number of bytes read from a security.txt file
+const SecurityTxtMaxSize = 32 * 1024
+
+// SecurityTxtScore is awarded when the security.txt file lists a Contact
+const SecurityTxtScore = 2
+
+// TargetValidationTimeout bounds the resolution of a host checked against the internal networks
+const TargetValidationTimeout = 3 * time.Second
+
+// PreflightTimeout bounds the DNS resolution and TCP connection of a Preflight
+const PreflightTimeout = 3 * time.Second
+
+// HostInfoTimeout bounds the resolution of the addresses and reverse DNS of a host
+const HostInfoTimeout = 3 * time.Second
+
+// ReflectionProbeHeader carries a random value in the request whose headers are scored, a response header
+// echoing it reflects the request
+const ReflectionProbeHeader = "X-Snift-Probe"
+
+// SecurityHeaderChecks is used to map the security headers checked for anomalies to the check they are scored by
+var SecurityHeaderChecks = map[string]string{
+	XSSHeader:               XSSHeader,
+	XFrameHeader:            XFrameHeader,
+	HSTSHeader:              HSTSHeader,
+	CSPHeader:               CSPHeader,
+	PKPHeader:               PKPHeader,
+	RPHeader:                RPHeader,
+	XContentTypeHeader:      XContentTypeHeader,
+	CrossDomainPolicyHeader: CrossDomainPolicyHeader,
+	COOPHeader:              CrossOriginIsolationCheck,
+	COEPHeader:              CrossOriginIsolationCheck,
+	CORPHeader:              CrossOriginIsolationCheck,
+	ClearSiteDataHeader:     ClearSiteDataHeader,
+	CacheControlHeader:      CacheControlHeader,
+	ReportingHeader:         ReportingCheck,
+	ReportToHeader:          ReportingCheck,
+}
+
+// ListValuedHeaders is used to store the security headers that may be sent more than once, every Content-Security-Policy
+// is enforced and the other ones combine their values or list fallbacks
+var ListValuedHeaders = map[string]bool{
+	CSPHeader:           true,
+	RPHeader:            true,
+	ClearSiteDataHeader: true,
+	CacheControlHeader:  true,
+	ReportingHeader:     true,
+	ReportToHeader:      true,
+}
+
+// MaxCustomHeaders is the number of custom request headers a scan can send
+const MaxCustomHeaders = 20
+
+// ForbiddenCustomHeaders is used to store the request headers that cannot be overridden through the ScanOptions, as
+// they frame the request or identify the scan
+var ForbiddenCustomHeaders = map[string]bool{
+	"Host":                true,
+	"Content-Length":      true,
+	"Transfer-Encoding":   true,
+	"Connection":          true,
+	"Upgrade":             true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Expect":              true,
+	"Keep-Alive":          true,
+	"Proxy-Connection":    true,
+	"Proxy-Authorization": true,
+	ReflectionProbeHeader: true,
+}
+
+// HeaderFallbackMaxBodySize is the number of bytes read and discarded from the body of the GET request sent to the
+// servers rejecting HEAD requests
+const HeaderFallbackMaxBodySize = 64 << 10
+
+// RobotsTxtPath is the location of the robots.txt file, its disallowed paths are checked for exposure
+const RobotsTxtPath = "/robots.txt"
+
+// RobotsTxtMaxSize is the maximum number of bytes read from a robots.txt file
+const RobotsTxtMaxSize = 64 * 1024
+
+// MaxCrawlDepth is the deepest crawl of the internal links of the scanned site
+const MaxCrawlDepth = 2
+
+// CrawlMaxPages bounds the number of internal pages scored by a crawl, besides the landing page
+const CrawlMaxPages = 5
+
+// CrawlMaxBodySize is the maximum number of bytes of a page read to discover its links
+const CrawlMaxBodySize = 256 << 10
+
+// SensitivePaths is the curated list of paths that should never be served publicly
+var SensitivePaths = [...]string{"/.git/config", "/.env", "/admin", "/server-status", "/backup.sql"}
+
+// SensitivePathsMaxRequests bounds the number of requests sent by the Sensitive Paths check
+const SensitivePathsMaxRequests = 10
+
+// SensitivePathsScore is the low weight informational score of the Sensitive Paths check
+const SensitivePathsScore = 1
+
+// IncidentDateLayouts are the layouts tried in order to parse the dates of an incident
+var IncidentDateLayouts = [...]string{time.RFC1123Z, time.RFC1123, time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"}
+
+// IncidentSeverityWeights is used to weight the incidents by the severity of their type of vulnerability
+var IncidentSeverityWeights = map[string]int{
+	"sql injection":           3,
+	"remote code execution":   3,
+	"cross site scripting":    2,
+	"xss":                     2,
+	"improper access control": 2,
+	"csrf":                    1,
+	"open redirect":           1,
+	"information leakage":     1,
+}
+
+// DefaultIncidentWeight is the weight of an incident without type, or of a type missing from IncidentSeverityWeights
+const DefaultIncidentWeight = 1
+
 // MaxIncidentResponseTime is the Maximum Incident Response Time taken as 30 days -> 30 * 24 = 720 hours
 const MaxIncidentResponseTime = 720
 
 // XSSValues is used to store the X-Xss-Protection Header values
 var XSSValues = [...]string{"0", "1"}
 
+// XSSModeBlock is the X-Xss-Protection directive that blocks rendering of the page when an attack is detected
+const XSSModeBlock = "mode=block"
+
 // XFrameValues is used to store the X-Frame-Options Header values
 var XFrameValues = [...]string{"deny", "sameorigin", "allow-from"}
 
+// XFrameAllowFromScore is the score of the deprecated ALLOW-FROM directive, which offers no protection in modern browsers
+const XFrameAllowFromScore = 1
+
+// CSPFrameAncestorsDirective is the Content-Security-Policy directive superseding X-Frame-Options
+const CSPFrameAncestorsDirective = "frame-ancestors"
+
+// CSPReportToDirective is the Content-Security-Policy directive naming the reporting endpoint of the violations
+const CSPReportToDirective = "report-to"
+
+// ReportToDefaultGroup is the name of a Report-To group declared without one
+const ReportToDefaultGroup = "default"
+
+// ReportingScore is the low weight score of the reporting endpoints, a point for declaring them and a point for
+// the Content-Security-Policy reporting to them
+const ReportingScore = 2
+
 // HSTSValues used to store the X-Frame-Options Header values
 var HSTSValues = [...]string{"max-age", "includeSubDomains", "preload"}
 
+// HSTSPreloadMinMaxAge is the minimum max-age (1 year) accepted by the HSTS preload list
+const HSTSPreloadMinMaxAge = 31536000
+
+// HSTSShortMaxAge is the max-age (1 day) below which the policy expires before most visitors return, a max-age below
+// it keeps a single point of the HSTS check
+const HSTSShortMaxAge = 86400
+
+// HSTSMinMaxAge is the max-age below which the HSTS check loses points, it is loaded from HSTS_MIN_MAX_AGE at startup
+var HSTSMinMaxAge int64 = utils.DefaultHSTSMinMaxAge
+
 // ReferrerPolicyValues used to store the Referrer-Policy Header values
 var ReferrerPolicyValues = map[string]int{
 	"no-referrer":                     5,
@@ -57,12 +471,84 @@ var ReferrerPolicyValues = map[string]int{
 	"unsafe-url":                      2,
 }
 
+// CrossDomainPolicyValues used to store the X-Permitted-Cross-Domain-Policies Header values
+var CrossDomainPolicyValues = map[string]int{
+	"none":               5,
+	"none-this-response": 4,
+	"master-only":        3,
+	"by-content-type":    2,
+	"by-ftp-filename":    2,
+	"all":                0,
+}
+
+// COOPValues used to store the Cross-Origin-Opener-Policy Header values
+var COOPValues = map[string]int{
+	"same-origin":              2,
+	"same-origin-allow-popups": 1,
+	"unsafe-none":              0,
+}
+
+// COEPValues used to store the Cross-Origin-Embedder-Policy Header values
+var COEPValues = map[string]int{
+	"require-corp":   2,
+	"credentialless": 2,
+	"unsafe-none":    0,
+}
+
+// CORPValues used to store the Cross-Origin-Resource-Policy Header values
+var CORPValues = map[string]int{
+	"same-origin":  1,
+	"same-site":    1,
+	"cross-origin": 0,
+}
+
+// ClearSiteDataValues is used to store the Clear-Site-Data Header directives
+var ClearSiteDataValues = [...]string{"*", "cookies", "storage", "cache", "executionContexts", "clientHints"}
+
+// CacheControlValues used to store the Cache-Control directives that keep a response out of shared caches
+var CacheControlValues = map[string]int{
+	"no-store": 2,
+	"private":  1,
+}
+
+// CacheControlPublic is the Cache-Control directive allowing shared caches to store the response
+const CacheControlPublic = "public"
+
 // XContentTypeHeaderValue is used to store the value for X-Content-Type Options Header
 const XContentTypeHeaderValue = "nosniff"
 
 // HTTPVersion is used to store the HTTP Versions
 var HTTPVersion = [...]string{"HTTP/2.0", "HTTP/1.1"}
 
+// TLSVersionNames is used to report the TLS versions in the response
+var TLSVersionNames = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// SCTListExtension is the OID of the X.509 extension embedding the Signed Certificate Timestamps of a certificate
+var SCTListExtension = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// OCSPStaplingScore is the low weight score of an OCSP response stapled to the TLS Handshake
+const OCSPStaplingScore = 1
+
+// CertificateValidityScore is the score of a certificate valid for no longer than the maximum validity
+const CertificateValidityScore = 2
+
+// SessionResumptionScore is the low weight score of the TLS session resumption, a performance signal
+const SessionResumptionScore = 1
+
+// Points lost by the TLS-Renegotiation-Compression check for TLS compression and for the lack of secure renegotiation
+const (
+	TLSCompressionPenalty        = 3
+	InsecureRenegotiationPenalty = 2
+)
+
+// MaxConcurrentMailChecks is the number of the DNS-based mail checks (SPF, DMARC, DKIM and BIMI) run at the same time
+const MaxConcurrentMailChecks = 4
+
 // Stores the Scores for various Parameters
 const (
 	HTTPScore  = 0
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"snift-api/utils"
+	"strings"
+)
+
+// getCORSProbeOrigin returns the Origin sent along with the reflection probe, a response allowing it allows any origin
+func getCORSProbeOrigin(probe string) string {
+	return fmt.Sprintf(CORSProbeOriginFormat, probe)
+}
+
+// isCORSOriginReflected returns true when the response allows the Origin of the reflection probe
+func isCORSOriginReflected(header http.Header, probe string) bool {
+	return probe != "" && strings.EqualFold(strings.TrimSpace(header.Get(ACAOHeader)), getCORSProbeOrigin(probe))
+}
+
+// GetCORSScore returns the score for the CORS policy of the Access-Control-Allow-Origin and
+// Access-Control-Allow-Credentials Headers. A site allowing any origin, by reflecting the Origin of the request or
+// allowing the null origin, loses the check when it allows credentials as well, as any site can then read its
+// responses on behalf of the logged-in visitors. A wildcard along with credentials is rejected by browsers, but
+// reported as the misconfiguration it reveals
+func GetCORSScore(headers map[string]string, reflected bool) ResponseHeader {
+	return func(corsScore *HeaderScore) error {
+		corsScore.name = CORSCheck
+		origin := strings.TrimSpace(headers[ACAOHeader])
+		credentials := strings.EqualFold(strings.TrimSpace(headers[ACACHeader]), "true")
+		switch {
+		case reflected || strings.EqualFold(origin, "null"):
+			message := utils.CORSReflectedMessage
+			if !reflected {
+				message = utils.CORSNullOriginMessage
+			}
+			corsScore.findings = append(corsScore.findings, message)
+			if credentials {
+				corsScore.findings = append(corsScore.findings, utils.CORSCredentialsMessage)
+				return nil
+			}
+			corsScore.value += 3
+		case origin == "*" && credentials:
+			corsScore.findings = append(corsScore.findings, utils.CORSWildcardMessage)
+			corsScore.value += 3
+		default:
+			corsScore.value += 5
+		}
+		return nil
+	}
+}
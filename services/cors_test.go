@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"snift-api/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCORSScore(t *testing.T) {
+	for _, test := range []struct {
+		headers   map[string]string
+		reflected bool
+		score     int
+		findings  []string
+	}{
+		// safe policies
+		{map[string]string{}, false, 5, nil},
+		{map[string]string{ACAOHeader: "https://app.example.com", ACACHeader: "true"}, false, 5, nil},
+		{map[string]string{ACAOHeader: "*"}, false, 5, nil},
+		// wildcard along with credentials
+		{map[string]string{ACAOHeader: "*", ACACHeader: "true"}, false, 3, []string{utils.CORSWildcardMessage}},
+		// any origin allowed
+		{map[string]string{ACAOHeader: "https://snift-probe.invalid"}, true, 3, []string{utils.CORSReflectedMessage}},
+		{map[string]string{ACAOHeader: "https://snift-probe.invalid", ACACHeader: "true"}, true, 0, []string{utils.CORSReflectedMessage, utils.CORSCredentialsMessage}},
+		{map[string]string{ACAOHeader: "null"}, false, 3, []string{utils.CORSNullOriginMessage}},
+		{map[string]string{ACAOHeader: "null", ACACHeader: "TRUE"}, false, 0, []string{utils.CORSNullOriginMessage, utils.CORSCredentialsMessage}},
+	} {
+		headerScore, err := BuildResponseHeaderScore(GetCORSScore(test.headers, test.reflected))
+		assert.NoError(t, err)
+		check := headerScore.checks[0]
+		assert.Equal(t, check.Name, CORSCheck)
+		assert.Equal(t, check.Score, test.score, test.headers)
+		assert.Equal(t, check.MaxScore, 5)
+		assert.Equal(t, check.Findings, test.findings, test.headers)
+	}
+}
+
+func TestGetResponseHeaderScoreCORSReflection(t *testing.T) {
+	for _, test := range []struct {
+		handler   http.HandlerFunc
+		reflected bool
+		score     int
+	}{
+		{func(w http.ResponseWriter, r *http.Request) {}, false, 5},
+		{func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(ACAOHeader, "https://app.example.com")
+		}, false, 5},
+		{func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(ACAOHeader, r.Header.Get("Origin"))
+			w.Header().Set(ACACHeader, "true")
+		}, true, 0},
+	} {
+		server := httptest.NewServer(test.handler)
+		responseHeaderScore, _, _, err := getResponseHeaderScore(context.Background(), server.URL, nil)
+		server.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, responseHeaderScore.observations.CORSOriginReflected, test.reflected)
+		for _, check := range responseHeaderScore.checks {
+			if check.Name == CORSCheck {
+				assert.Equal(t, check.Score, test.score)
+			}
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"snift-api/models"
+	"snift-api/utils"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// errCrossOriginRedirect is returned when a crawled page redirects out of the origin of the scanned site
+var errCrossOriginRedirect = errors.New("redirect out of the origin of the scanned site")
+
+// crawlTarget is a page to crawl, level being the number of links followed from the landing page to reach it
+type crawlTarget struct {
+	url   *url.URL
+	level int
+}
+
+// crawlSite follows the same-origin links of the landing page up to depth levels, the paths disallowed by robots.txt
+// left out, and scores the response headers of every page found. The TLS and HTTP versions of the pages are those
+// observed on the landing page, as they share its origin. The whole crawl is bounded by the RequestTimeout
+func crawlSite(ctx context.Context, landing *HeaderScore, depth int, headers map[string]string, only map[string]bool) *models.Crawl {
+	crawl := &models.Crawl{Depth: depth, Pages: []*models.PageScore{}}
+	landingObservations := landing.observations
+	base, err := url.Parse(landingObservations.RedirectChain[len(landingObservations.RedirectChain)-1])
+	if err != nil {
+		fmt.Println("Error Occured while parsing the URL of the landing page for the crawl", err)
+		return crawl
+	}
+	base.Fragment = ""
+	crawl.Pages = append(crawl.Pages, getPageScore(base.String(), landing.checks, only))
+
+	ctx, cancel := context.WithTimeout(ctx, utils.RequestTimeout)
+	defer cancel()
+	disallowed := getRobotsRules(ctx, base)
+	visited := map[string]bool{base.String(): true}
+	queue := []crawlTarget{{base, 0}}
+	for len(queue) > 0 {
+		target := queue[0]
+		queue = queue[1:]
+		if ctx.Err() != nil {
+			crawl.Truncated = true
+			break
+		}
+		response, body, err := getCrawlPage(ctx, target.url, headers)
+		if target.level > 0 {
+			if err != nil {
+				crawl.Pages = append(crawl.Pages, &models.PageScore{URL: target.url.String(), Error: err.Error()})
+				continue
+			}
+			crawl.Pages = append(crawl.Pages, scoreCrawledPage(target.url.String(), response, landingObservations, only))
+		}
+		if err != nil || target.level >= depth {
+			continue
+		}
+		for _, link := range extractLinks(body, response.Request.URL) {
+			if visited[link.String()] || !isSameOrigin(link, base) || isDisallowed(link.Path, disallowed) {
+				continue
+			}
+			if len(visited) > CrawlMaxPages {
+				crawl.Truncated = true
+				break
+			}
+			visited[link.String()] = true
+			queue = append(queue, crawlTarget{link, target.level + 1})
+		}
+	}
+	crawl.Score = getCrawlScore(crawl.Pages)
+	return crawl
+}
+
+// getRobotsRules returns the paths disallowed by the robots.txt file of the site, none when it cannot be read
+func getRobotsRules(ctx context.Context, base *url.URL) []string {
+	status, body, err := getPathStatus(ctx, base, RobotsTxtPath)
+	if err != nil {
+		return nil
+	}
+	defer body.Close()
+	if status != http.StatusOK {
+		return nil
+	}
+	robotsTxt, _, err := utils.ReadBody(body, RobotsTxtMaxSize)
+	if err != nil {
+		return nil
+	}
+	return parseRobotsRules(bytes.NewReader(robotsTxt))
+}
+
+// isDisallowed returns true when the path matches one of the disallowed paths of robots.txt
+func isDisallowed(path string, disallowed []string) bool {
+	if path == "" {
+		path = "/"
+	}
+	for _, rule := range disallowed {
+		if matchRobotsRule(path, rule) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRobotsRule returns true when the path starts with the rule, where * matches any sequence of characters and a
+// trailing $ anchors the rule to the end of the path
+func matchRobotsRule(path string, rule string) bool {
+	anchored := strings.HasSuffix(rule, "$")
+	parts := strings.Split(strings.TrimSuffix(rule, "$"), "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		index := strings.Index(rest, part)
+		if index < 0 {
+			return false
+		}
+		rest = rest[index+len(part):]
+	}
+	if !anchored || rest == "" {
+		return true
+	}
+	// the first occurrence of the last part of an anchored rule may not end the path while a later one does
+	last := parts[len(parts)-1]
+	return len(parts) > 1 && strings.HasSuffix(path, last)
+}
+
+// getCrawlPage sends a GET request for a page along with the custom headers, and returns its response and the start
+// of its body. Redirects are followed within the origin of the page only
+func getCrawlPage(ctx context.Context, page *url.URL, headers map[string]string) (*http.Response, []byte, error) {
+	client := &http.Client{
+		Transport: utils.HTTPTransport,
+		Timeout:   utils.RequestTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > utils.GetMaxRedirects() {
+				return http.ErrUseLastResponse
+			}
+			if !isSameOrigin(req.URL, via[0].URL) {
+				return errCrossOriginRedirect
+			}
+			return nil
+		},
+	}
+	response, err := sendHeaderRequest(ctx, client, http.MethodGet, page.String(), headers, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer response.Body.Close()
+	body, _, err := utils.ReadBody(response.Body, CrawlMaxBodySize)
+	if err != nil {
+		fmt.Println("Error Occured while reading the body of "+page.String(), err)
+	}
+	return response, body, nil
+}
+
+// scoreCrawledPage scores the response headers of a crawled page
+func scoreCrawledPage(pageURL string, response *http.Response, landing *models.Observations, only map[string]bool) *models.PageScore {
+	pageHeaders := make(map[string]string)
+	for name, values := range response.Header {
+		pageHeaders[name] = strings.Join(values, ",")
+	}
+	observations := &models.Observations{
+		ResponseProtocol:        response.Request.URL.Scheme,
+		Headers:                 pageHeaders,
+		HeaderWarnings:          getHeaderAnomalies(response.Header, ""),
+		Proto:                   response.Proto,
+		MaxProto:                landing.MaxProto,
+		MaxTLSVersion:           landing.MaxTLSVersion,
+		TLSVersion:              landing.TLSVersion,
+		OCSPStapled:             landing.OCSPStapled,
+		CertificateTransparency: landing.CertificateTransparency,
+		TLSHello:                landing.TLSHello,
+		SessionResumption:       landing.SessionResumption,
+		HTTPSRedirect:           landing.HTTPSRedirect,
+		RedirectChain:           []string{pageURL},
+		RequestMethod:           http.MethodGet,
+	}
+	pageScore, err := scoreResponseObservations(observations)
+	if err != nil {
+		return &models.PageScore{URL: pageURL, Error: err.Error()}
+	}
+	return getPageScore(pageURL, pageScore.checks, only)
+}
+
+// getPageScore totals the checks of a page run by the scan
+func getPageScore(pageURL string, checks []*models.CheckResult, only map[string]bool) *models.PageScore {
+	page := &models.PageScore{URL: pageURL}
+	for _, check := range checks {
+		if !runsAnyCheck(only, check.Name) {
+			continue
+		}
+		page.Checks = append(page.Checks, check)
+		page.Score += check.Score
+		page.MaxScore += check.MaxScore
+	}
+	return page
+}
+
+// getCrawlScore returns the total score of the pages scored as the share of their total maximum score, rounded up
+// to two decimals as the overall score is
+func getCrawlScore(pages []*models.PageScore) float64 {
+	score, maxScore := 0, 0
+	for _, page := range pages {
+		if page.Error == "" {
+			score += page.Score
+			maxScore += page.MaxScore
+		}
+	}
+	if maxScore == 0 {
+		return 0
+	}
+	return math.Ceil(float64(score)/float64(maxScore)*100) / 100
+}
+
+// extractLinks returns the http and https links of the anchors of an HTML body, resolved against the page URL and
+// without their fragment
+func extractLinks(body []byte, page *url.URL) (links []*url.URL) {
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			return links
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+		token := tokenizer.Token()
+		if token.Data != "a" && token.Data != "area" {
+			continue
+		}
+		for _, attribute := range token.Attr {
+			if attribute.Key != "href" {
+				continue
+			}
+			link, err := page.Parse(strings.TrimSpace(attribute.Val))
+			if err != nil || (link.Scheme != "http" && link.Scheme != "https") {
+				continue
+			}
+			link.Fragment = ""
+			links = append(links, link)
+		}
+	}
+}
+
+// isSameOrigin returns true when both URLs share their scheme, host and port
+func isSameOrigin(a *url.URL, b *url.URL) bool {
+	aHost, aPort := getHostAndPort(a)
+	bHost, bPort := getHostAndPort(b)
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(aHost, bHost) && aPort == bPort
+}
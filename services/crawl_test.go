@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"snift-api/models"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newMockSite serves linked pages, the path of every request being recorded
+func newMockSite(pages map[string]string, headers map[string]map[string]string) (*httptest.Server, func() map[string]int) {
+	var mutex sync.Mutex
+	requested := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		requested[r.URL.Path]++
+		mutex.Unlock()
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		for name, value := range headers[r.URL.Path] {
+			w.Header().Set(name, value)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, body)
+	}))
+	return server, func() map[string]int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return requested
+	}
+}
+
+func TestCalculateOverallScoreCrawl(t *testing.T) {
+	server, requested := newMockSite(map[string]string{
+		"/": `<a href="/a">A</a> <a href="b#section">B</a> <a href="/a#top">A again</a> <a href="https://other.example/x">other</a>
+			<a href="/private/p">private</a> <a href="mailto:security@example.com">mail</a>`,
+		"/a":          `<a href="/c">C</a>`,
+		"/b":          ``,
+		"/c":          `<a href="/d">D</a>`,
+		"/d":          ``,
+		"/private/p":  ``,
+		"/robots.txt": "User-agent: *\nDisallow: /private\n",
+	}, map[string]map[string]string{
+		"/":  {XFrameHeader: "DENY", CSPHeader: "default-src 'self'"},
+		"/a": {XFrameHeader: "DENY"},
+	})
+	defer server.Close()
+	scan := func(crawlDepth int) *models.Crawl {
+		responseBody, err := CalculateOverallScore(server.URL, &models.ScanOptions{
+			Skip: []string{SkipVulnerabilities, SkipDNS}, CrawlDepth: crawlDepth,
+		})
+		assert.NoError(t, err)
+		var response models.ScoresResponse
+		assert.NoError(t, json.Unmarshal(responseBody, &response))
+		return response.Crawl
+	}
+	pageURLs := func(crawl *models.Crawl) (urls []string) {
+		for _, page := range crawl.Pages {
+			urls = append(urls, page.URL)
+		}
+		return
+	}
+
+	// the landing page alone is scored unless requested
+	assert.Nil(t, scan(0))
+
+	crawl := scan(1)
+	assert.Equal(t, crawl.Depth, 1)
+	assert.Equal(t, pageURLs(crawl), []string{server.URL, server.URL + "/a", server.URL + "/b"})
+	assert.False(t, crawl.Truncated)
+	assert.Zero(t, requested()["/private/p"])
+	assert.Zero(t, requested()["/c"])
+
+	// the pages are scored on their own headers
+	landing, a, b := crawl.Pages[0], crawl.Pages[1], crawl.Pages[2]
+	assert.True(t, landing.Score > a.Score && a.Score > b.Score)
+	assert.Equal(t, a.MaxScore, landing.MaxScore)
+	score := float64(landing.Score+a.Score+b.Score) / float64(landing.MaxScore+a.MaxScore+b.MaxScore)
+	assert.InDelta(t, crawl.Score, score, 0.01)
+
+	crawl = scan(2)
+	assert.Equal(t, pageURLs(crawl), []string{server.URL, server.URL + "/a", server.URL + "/b", server.URL + "/c"})
+	assert.Zero(t, requested()["/d"])
+	assert.Zero(t, requested()["/private/p"])
+}
+
+func TestCalculateOverallScoreCrawlLimit(t *testing.T) {
+	pages := map[string]string{"/": ""}
+	for i := 0; i < CrawlMaxPages+3; i++ {
+		path := fmt.Sprintf("/page-%d", i)
+		pages["/"] += fmt.Sprintf(`<a href="%s">%d</a>`, path, i)
+		pages[path] = ""
+	}
+	pages["/page-0"] = `<a href="/missing">missing</a>`
+	server, _ := newMockSite(pages, nil)
+	defer server.Close()
+
+	landing, _, _, err := getResponseHeaderScore(context.Background(), server.URL, nil)
+	assert.NoError(t, err)
+	crawl := crawlSite(context.Background(), &landing, MaxCrawlDepth, nil, nil)
+	assert.Len(t, crawl.Pages, CrawlMaxPages+1)
+	assert.True(t, crawl.Truncated)
+}
+
+func TestValidateScanOptionsCrawlDepth(t *testing.T) {
+	assert.NoError(t, ValidateScanOptions(&models.ScanOptions{CrawlDepth: MaxCrawlDepth}))
+	assert.True(t, errors.Is(ValidateScanOptions(&models.ScanOptions{CrawlDepth: MaxCrawlDepth + 1}), ErrInvalidCrawlDepth))
+	assert.True(t, errors.Is(ValidateScanOptions(&models.ScanOptions{CrawlDepth: -1}), ErrInvalidCrawlDepth))
+}
+
+func TestExtractLinks(t *testing.T) {
+	page, _ := url.Parse("https://example.com/docs/index.html")
+	body := []byte(`<a href="guide.html#intro">guide</a><area href="/map"/><a href="javascript:void(0)">js</a>
+		<a name="anchor">no link</a><link href="/style.css"><a href=" https://example.org/ ">other</a>`)
+	var links []string
+	for _, link := range extractLinks(body, page) {
+		links = append(links, link.String())
+	}
+	assert.Equal(t, links, []string{"https://example.com/docs/guide.html", "https://example.com/map", "https://example.org/"})
+}
+
+func TestIsDisallowed(t *testing.T) {
+	disallowed := []string{"/private", "/*.pdf$", "/search*?q="}
+	assert.True(t, isDisallowed("/private/page", disallowed))
+	assert.False(t, isDisallowed("/public", disallowed))
+	assert.True(t, isDisallowed("/docs/report.pdf", disallowed))
+	assert.True(t, isDisallowed("/a.pdf/b.pdf", disallowed))
+	assert.False(t, isDisallowed("/report.pdf.html", disallowed))
+	assert.True(t, isDisallowed("/search/all?q=go", disallowed))
+	assert.False(t, isDisallowed("/search", disallowed))
+	assert.True(t, isDisallowed("/public", []string{"/"}))
+	assert.True(t, isDisallowed("", []string{"/"}))
+}
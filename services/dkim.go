@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"snift-api/utils"
+	"strings"
+)
+
+// getDKIMKey returns the public key of a DKIM record, ok is false when the record is not a DKIM key record
+func getDKIMKey(record string) (key string, ok bool) {
+	for _, tag := range strings.Split(record, ";") {
+		name, value, found := strings.Cut(strings.TrimSpace(tag), "=")
+		if !found {
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		case "v":
+			if strings.TrimSpace(value) != "DKIM1" {
+				return "", false
+			}
+		case "p":
+			key, ok = strings.TrimSpace(value), true
+		}
+	}
+	return
+}
+
+// GetDKIMScore returns the DomainKeys Identified Mail Score of the Domain, probing the public key of every selector.
+// Selectors are specific to each organization, so when none are supplied the configured or common selectors are
+// probed instead, and finding no key for them only leaves the check unscored rather than failing it
+func GetDKIMScore(domain string, selectors []string) (dkimScore int, maxDKIMScore int, findings []string) {
+	return GetDKIMScoreContext(context.Background(), domain, selectors)
+}
+
+// GetDKIMScoreContext is GetDKIMScore with the lookups made through the DNS cache of ctx
+func GetDKIMScoreContext(ctx context.Context, domain string, selectors []string) (dkimScore int, maxDKIMScore int, findings []string) {
+	maxDKIMScore = DKIMScore
+	if len(selectors) == 0 {
+		selectors = utils.GetDKIMSelectors()
+	}
+	if len(selectors) == 0 {
+		selectors = DKIMSelectors[:]
+		maxDKIMScore = 0
+	}
+	if len(selectors) > MaxDKIMSelectors {
+		selectors = selectors[:MaxDKIMSelectors]
+	}
+	for _, selector := range selectors {
+		// a selector without a key is expected while probing, so lookup errors are not reported
+		records, _ := cachedLookupTXT(ctx, selector+DKIMDomainKeyPrefix+domain)
+		for _, record := range records {
+			key, ok := getDKIMKey(record)
+			if !ok {
+				continue
+			}
+			// an empty key is published to revoke a selector
+			if key == "" {
+				findings = append(findings, fmt.Sprintf(utils.DKIMKeyRevokedMessage, selector))
+				continue
+			}
+			findings = append(findings, fmt.Sprintf(utils.DKIMKeyFoundMessage, selector))
+			dkimScore = DKIMScore
+			break
+		}
+	}
+	if dkimScore == 0 {
+		findings = append(findings, fmt.Sprintf(utils.DKIMKeyNotFoundMessage, strings.Join(selectors, ", ")))
+	} else {
+		maxDKIMScore = DKIMScore
+	}
+	return
+}
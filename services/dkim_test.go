@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"snift-api/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDKIMScoreCustomSelectors(t *testing.T) {
+	defer mockLookupTXT(map[string][]string{
+		"selector1._domainkey.example.com": {"v=DKIM1; k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQC"},
+		"mta._domainkey.example.com":       {"v=DKIM1; k=rsa; p=MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA"},
+		"old._domainkey.example.com":       {"v=DKIM1; p="},
+	})()
+
+	// the custom selectors override the common ones, so selector1 is not probed
+	dkimScore, maxDKIMScore, findings := GetDKIMScore("example.com", []string{"old", "mta"})
+	assert.Equal(t, dkimScore, 5)
+	assert.Equal(t, maxDKIMScore, 5)
+	assert.Equal(t, findings, []string{
+		fmt.Sprintf(utils.DKIMKeyRevokedMessage, "old"),
+		fmt.Sprintf(utils.DKIMKeyFoundMessage, "mta"),
+	})
+
+	// a supplied selector without a key fails the check
+	dkimScore, maxDKIMScore, findings = GetDKIMScore("example.com", []string{"s1"})
+	assert.Equal(t, dkimScore, 0)
+	assert.Equal(t, maxDKIMScore, 5)
+	assert.Equal(t, findings, []string{fmt.Sprintf(utils.DKIMKeyNotFoundMessage, "s1")})
+
+	// the configured selectors are used when the request supplies none
+	os.Setenv("DKIM_SELECTORS", "mta, s1")
+	defer os.Unsetenv("DKIM_SELECTORS")
+	dkimScore, maxDKIMScore, _ = GetDKIMScore("example.com", nil)
+	assert.Equal(t, dkimScore, 5)
+	assert.Equal(t, maxDKIMScore, 5)
+}
+
+func TestGetDKIMScoreCommonSelectors(t *testing.T) {
+	defer mockLookupTXT(map[string][]string{
+		"selector2._domainkey.example.com": {"k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQC"},
+		"google._domainkey.example.org":    {"v=spf1 -all"},
+	})()
+
+	dkimScore, maxDKIMScore, findings := GetDKIMScore("example.com", nil)
+	assert.Equal(t, dkimScore, 5)
+	assert.Equal(t, maxDKIMScore, 5)
+	assert.Equal(t, findings, []string{fmt.Sprintf(utils.DKIMKeyFoundMessage, "selector2")})
+
+	// the selectors of a domain may simply not be among the common ones, so the check is left unscored
+	dkimScore, maxDKIMScore, findings = GetDKIMScore("example.org", nil)
+	assert.Equal(t, dkimScore, 0)
+	assert.Equal(t, maxDKIMScore, 0)
+	assert.Equal(t, len(findings), 1)
+}
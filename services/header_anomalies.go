@@ -0,0 +1,50 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"snift-api/utils"
+	"strings"
+)
+
+// newReflectionProbe returns a random value sent through ReflectionProbeHeader, a response header containing it
+// echoes the request into the response
+func newReflectionProbe() string {
+	probe := make([]byte, 8)
+	if _, err := rand.Read(probe); err != nil {
+		return ""
+	}
+	return "snift-" + hex.EncodeToString(probe)
+}
+
+// getHeaderAnomalies returns the warnings about the security headers of a response by the name of their check:
+// a header sent more than once with conflicting values, which browsers resolve inconsistently, and a header
+// reflecting the probe of the request, which may allow injecting the header
+func getHeaderAnomalies(header http.Header, probe string) map[string][]string {
+	anomalies := make(map[string][]string)
+	for name, check := range SecurityHeaderChecks {
+		values := header.Values(name)
+		if len(values) > 1 && !ListValuedHeaders[name] && hasConflictingValues(values) {
+			anomalies[check] = append(anomalies[check], fmt.Sprintf(utils.ConflictingHeaderMessage, name, len(values), strings.Join(values, " | ")))
+		}
+		for _, value := range values {
+			if probe != "" && strings.Contains(value, probe) {
+				anomalies[check] = append(anomalies[check], fmt.Sprintf(utils.ReflectedHeaderMessage, name))
+				break
+			}
+		}
+	}
+	return anomalies
+}
+
+// hasConflictingValues returns true when the values of a header differ, regardless of case and surrounding spaces
+func hasConflictingValues(values []string) bool {
+	for _, value := range values[1:] {
+		if !strings.EqualFold(strings.TrimSpace(value), strings.TrimSpace(values[0])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"snift-api/models"
+	"snift-api/utils"
+	"strings"
+)
+
+var lookupIP = net.DefaultResolver.LookupIP
+
+var lookupAddr = net.DefaultResolver.LookupAddr
+
+var getASNDatabase = utils.GetASNDatabase
+
+// GetHostInfo returns all the A and AAAA records of a host with their PTR records and ASN,
+// a host that does not resolve or an address without PTR records is reported without them
+func GetHostInfo(host string) *models.HostInfo {
+	return GetHostInfoContext(context.Background(), host)
+}
+
+// GetHostInfoContext is GetHostInfo with the addresses resolved through the DNS cache of ctx
+func GetHostInfoContext(ctx context.Context, host string) *models.HostInfo {
+	hostInfo := &models.HostInfo{Host: host, Addresses: []*models.IPAddress{}}
+	ctx, cancel := context.WithTimeout(ctx, HostInfoTimeout)
+	defer cancel()
+	ips, err := utils.GetDNSCache(ctx).LookupIP(ctx, "ip", host, lookupIP)
+	if err != nil {
+		fmt.Println("Error Occured while resolving the addresses of "+host, err)
+		return hostInfo
+	}
+	asnDatabase := getASNDatabase()
+	for _, ip := range ips {
+		address := &models.IPAddress{IP: ip.String(), ReverseDNS: []string{}, ASN: asnDatabase.Lookup(ip)}
+		names, err := lookupAddr(ctx, ip.String())
+		if err == nil {
+			for _, name := range names {
+				address.ReverseDNS = append(address.ReverseDNS, strings.TrimSuffix(name, "."))
+			}
+		}
+		hostInfo.Addresses = append(hostInfo.Addresses, address)
+	}
+	return hostInfo
+}
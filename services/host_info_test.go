@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"net"
+	"snift-api/models"
+	"snift-api/utils"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockHostLookups(ips map[string][]net.IP, names map[string][]string) func() {
+	originalLookupIP, originalLookupAddr := lookupIP, lookupAddr
+	lookupIP = func(ctx context.Context, network string, host string) ([]net.IP, error) {
+		if addresses, ok := ips[host]; ok {
+			return addresses, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		if ptr, ok := names[addr]; ok {
+			return ptr, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+	}
+	return func() { lookupIP, lookupAddr = originalLookupIP, originalLookupAddr }
+}
+
+func TestGetHostInfo(t *testing.T) {
+	defer mockHostLookups(
+		map[string][]net.IP{"www.example.com": {net.ParseIP("93.184.216.34"), net.ParseIP("93.184.216.35"), net.ParseIP("2606:2800:220:1::248")}},
+		map[string][]string{
+			"93.184.216.34": {"edge-1.cdn.example.net."},
+			"93.184.216.35": {"edge-2.cdn.example.net.", "shared.hosting.example.org."},
+		},
+	)()
+
+	hostInfo := GetHostInfo("www.example.com")
+	assert.Equal(t, hostInfo.Host, "www.example.com")
+	assert.Len(t, hostInfo.Addresses, 3)
+	assert.Equal(t, hostInfo.Addresses[0].IP, "93.184.216.34")
+	assert.Equal(t, hostInfo.Addresses[0].ReverseDNS, []string{"edge-1.cdn.example.net"})
+	assert.Equal(t, hostInfo.Addresses[1].ReverseDNS, []string{"edge-2.cdn.example.net", "shared.hosting.example.org"})
+	// an address without PTR records is still reported
+	assert.Equal(t, hostInfo.Addresses[2].IP, "2606:2800:220:1::248")
+	assert.Empty(t, hostInfo.Addresses[2].ReverseDNS)
+	// without an ASN database the addresses have no ASN
+	assert.Nil(t, hostInfo.Addresses[0].ASN)
+}
+
+func TestGetHostInfoASN(t *testing.T) {
+	defer mockHostLookups(map[string][]net.IP{"www.example.com": {net.ParseIP("93.184.216.34"), net.ParseIP("10.0.0.1")}}, nil)()
+	database, _ := utils.ParseASNDatabase(strings.NewReader("93.184.216.0\t93.184.216.255\t15133\tUS\tEDGECAST\n"))
+	original := getASNDatabase
+	getASNDatabase = func() *utils.ASNDatabase { return database }
+	defer func() { getASNDatabase = original }()
+
+	hostInfo := GetHostInfo("www.example.com")
+	assert.Equal(t, hostInfo.Addresses[0].ASN, &models.ASNInfo{Number: 15133, Organization: "EDGECAST", Country: "US"})
+	assert.Nil(t, hostInfo.Addresses[1].ASN)
+}
+
+func TestGetHostInfoUnresolvable(t *testing.T) {
+	defer mockHostLookups(nil, nil)()
+
+	hostInfo := GetHostInfo("missing.example.com")
+	assert.Equal(t, hostInfo.Host, "missing.example.com")
+	assert.Empty(t, hostInfo.Addresses)
+}
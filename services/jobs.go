@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"snift-api/models"
+	"snift-api/utils"
+	"time"
+)
+
+// Jobs keeps the scans run asynchronously so that their status and result can be polled
+var Jobs = utils.NewJobStore()
+
+// ErrInvalidCallbackURL is returned for a callback URL that is not an absolute http or https URL
+var ErrInvalidCallbackURL = errors.New("invalid callback URL")
+
+// callbackClient posts the completed jobs, a callback URL redirecting elsewhere is not followed
+var callbackClient = &http.Client{
+	Transport: utils.HTTPTransport,
+	Timeout:   time.Duration(utils.RequestTimeoutSeconds) * time.Second,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// ValidateCallbackURL returns ErrInvalidCallbackURL for a callback URL that is not an absolute http or https URL
+func ValidateCallbackURL(callbackURL string) error {
+	if len(callbackURL) > utils.MaxURLLength {
+		return ErrInvalidCallbackURL
+	}
+	parsed, err := url.Parse(callbackURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return ErrInvalidCallbackURL
+	}
+	return nil
+}
+
+// CompleteJob records the outcome of a job, its scores response or the message of the error that failed it, then
+// posts the job to its callback URL, retrying the transient failures, and returns the completed job
+func CompleteJob(id string, result []byte, message string) models.Job {
+	completedAt := time.Now().UTC()
+	job := Jobs.Update(id, func(job *models.Job) {
+		job.CompletedAt = &completedAt
+		if message != "" {
+			job.Status = models.JobFailed
+			job.Error = message
+			return
+		}
+		job.Status = models.JobCompleted
+		job.Result = result
+	})
+	attempts, err := deliverJob(job)
+	if err != nil {
+		fmt.Println("Error Occured while posting the job "+id+" to its callback URL", err)
+	}
+	return Jobs.Update(id, func(job *models.Job) {
+		job.CallbackAttempts = attempts
+		job.CallbackDelivered = err == nil
+	})
+}
+
+// deliverJob posts the job to its callback URL and returns the number of requests sent, an internal callback URL
+// is rejected as an internal scan target would be
+func deliverJob(job models.Job) (attempts int, err error) {
+	callbackURL, err := url.Parse(job.CallbackURL)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), TargetValidationTimeout)
+	err = utils.ValidateTarget(ctx, callbackURL.Hostname())
+	cancel()
+	if err != nil {
+		return 0, err
+	}
+	body, err := json.Marshal(job)
+	if err != nil {
+		return 0, err
+	}
+	response, attempts, err := utils.PostWithRetry(context.Background(), callbackClient, job.CallbackURL, "application/json", body)
+	if err != nil {
+		return attempts, err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return attempts, fmt.Errorf("callback URL responded with %s", response.Status)
+	}
+	return attempts, nil
+}
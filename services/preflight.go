@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"snift-api/models"
+	"snift-api/utils"
+)
+
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+var dialContext = utils.DialContext
+
+// Preflight resolves the host of a URL and opens a TCP connection to its port, without running any of the checks
+func Preflight(preflightURL string) (*models.Preflight, error) {
+	asciiURL, _, err := utils.NormalizeURL(preflightURL)
+	if err != nil {
+		return nil, err
+	}
+	domain, err := url.Parse(asciiURL)
+	if err != nil {
+		return nil, err
+	}
+	host, port := getHostAndPort(domain)
+	preflight := &models.Preflight{URL: preflightURL, Host: host, Port: port, IPs: []string{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), PreflightTimeout)
+	defer cancel()
+	addresses, err := lookupIPAddr(ctx, host)
+	if err != nil {
+		preflight.Error = err.Error()
+		return preflight, nil
+	}
+	preflight.Resolved = len(addresses) > 0
+	for _, address := range addresses {
+		if !utils.IsAllowedTarget(address.IP) {
+			return nil, utils.ErrPrivateTarget
+		}
+		preflight.IPs = append(preflight.IPs, address.IP.String())
+	}
+	for _, ip := range preflight.IPs {
+		conn, dialErr := dialContext(ctx, "tcp", net.JoinHostPort(ip, port))
+		if dialErr != nil {
+			preflight.Error = dialErr.Error()
+			continue
+		}
+		conn.Close()
+		preflight.Reachable = true
+		preflight.Error = ""
+		break
+	}
+	return preflight, nil
+}
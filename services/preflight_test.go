@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"snift-api/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflightReachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	original := lookupIPAddr
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+	defer func() { lookupIPAddr = original }()
+
+	preflight, err := Preflight("http://www.example.com:" + port + "/path")
+	assert.NoError(t, err)
+	assert.Equal(t, preflight.Host, "www.example.com")
+	assert.Equal(t, preflight.Port, port)
+	assert.True(t, preflight.Resolved)
+	assert.True(t, preflight.Reachable)
+	assert.Equal(t, preflight.IPs, []string{"127.0.0.1"})
+	assert.Empty(t, preflight.Error)
+}
+
+func TestPreflightUnresolvable(t *testing.T) {
+	original := lookupIPAddr
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	defer func() { lookupIPAddr = original }()
+
+	preflight, err := Preflight("https://missing.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, preflight.Port, "443")
+	assert.False(t, preflight.Resolved)
+	assert.False(t, preflight.Reachable)
+	assert.Empty(t, preflight.IPs)
+	assert.Contains(t, preflight.Error, "no such host")
+}
+
+func TestPreflightClosedPort(t *testing.T) {
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	listener.Close()
+
+	preflight, err := Preflight("http://127.0.0.1:" + port)
+	assert.NoError(t, err)
+	assert.True(t, preflight.Resolved)
+	assert.False(t, preflight.Reachable)
+	assert.NotEmpty(t, preflight.Error)
+}
+
+func TestPreflightInternalTarget(t *testing.T) {
+	original := lookupIPAddr
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("169.254.169.254")}}, nil
+	}
+	defer func() { lookupIPAddr = original }()
+
+	_, err := Preflight("http://metadata.example.com")
+	assert.True(t, errors.Is(err, utils.ErrPrivateTarget), err)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"snift-api/utils"
+	"strings"
+)
+
+// reportToGroup is a group of endpoints declared by the legacy Report-To Header
+type reportToGroup struct {
+	Group     string `json:"group"`
+	Endpoints []struct {
+		URL string `json:"url"`
+	} `json:"endpoints"`
+}
+
+// ParseReportingEndpoints returns the URL of every endpoint of a Reporting-Endpoints Header by name, the header
+// being a structured dictionary such as csp-endpoint="https://example.com/reports"
+func ParseReportingEndpoints(ReportingEndpoints string) map[string]string {
+	endpoints := make(map[string]string)
+	for _, member := range splitUnquoted(ReportingEndpoints, ',') {
+		parts := strings.SplitN(member, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(parts[0])
+		endpointURL := strings.TrimSpace(parts[1])
+		// parameters of the member follow the quoted URL
+		if index := strings.Index(endpointURL, `";`); index >= 0 {
+			endpointURL = endpointURL[:index+1]
+		}
+		endpointURL = strings.Trim(endpointURL, `"`)
+		if name != "" && endpointURL != "" {
+			endpoints[name] = endpointURL
+		}
+	}
+	return endpoints
+}
+
+// ParseReportTo returns the URLs of every group of endpoints of a Report-To Header by name, the header being a list
+// of JSON objects. A group without name is the default group
+func ParseReportTo(ReportTo string) map[string][]string {
+	groups := make(map[string][]string)
+	if strings.TrimSpace(ReportTo) == "" {
+		return groups
+	}
+	var reportToGroups []reportToGroup
+	if err := json.Unmarshal([]byte("["+ReportTo+"]"), &reportToGroups); err != nil {
+		return groups
+	}
+	for _, group := range reportToGroups {
+		name := group.Group
+		if name == "" {
+			name = ReportToDefaultGroup
+		}
+		for _, endpoint := range group.Endpoints {
+			if endpoint.URL != "" {
+				groups[name] = append(groups[name], endpoint.URL)
+			}
+		}
+	}
+	return groups
+}
+
+// splitUnquoted splits a header value on the separator, except within quoted strings
+func splitUnquoted(value string, separator rune) (members []string) {
+	quoted := false
+	start := 0
+	for index, char := range value {
+		switch {
+		case char == '"':
+			quoted = !quoted
+		case char == separator && !quoted:
+			members = append(members, value[start:index])
+			start = index + 1
+		}
+	}
+	return append(members, value[start:])
+}
+
+// GetReportingScore returns the score for the reporting endpoints of the Reporting-Endpoints and Report-To Headers,
+// cross-validated with the report-to directive of the Content-Security-Policy. Declaring endpoints earns a point, and
+// a report-to directive delivering the violations to a declared endpoint completes the setup
+func GetReportingScore(headers map[string]string) ResponseHeader {
+	return func(reportingScore *HeaderScore) error {
+		reportingScore.name = ReportingCheck
+		reportingScore.checkMaximumValue = ReportingScore
+		endpoints := make(map[string]bool)
+		for name := range ParseReportingEndpoints(headers[ReportingHeader]) {
+			endpoints[name] = true
+		}
+		for name := range ParseReportTo(headers[ReportToHeader]) {
+			endpoints[name] = true
+		}
+		if len(endpoints) > 0 {
+			reportingScore.value++
+		}
+
+		groups, ok := getCSPDirective(headers[CSPHeader], CSPReportToDirective)
+		if !ok || len(groups) == 0 {
+			if len(endpoints) > 0 {
+				reportingScore.message = utils.ReportingUnusedMessage
+			}
+			return nil
+		}
+		complete := len(endpoints) > 0
+		for _, group := range groups {
+			if !endpoints[group] {
+				reportingScore.findings = append(reportingScore.findings, fmt.Sprintf(utils.ReportingDanglingMessage, group))
+				complete = false
+			}
+		}
+		if complete {
+			reportingScore.value++
+		}
+		return nil
+	}
+}
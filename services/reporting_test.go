@@ -0,0 +1,75 @@
+package services
+
+import (
+	"fmt"
+	"snift-api/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReportingEndpoints(t *testing.T) {
+	assert.Equal(t, ParseReportingEndpoints(`csp-endpoint="https://example.com/csp", default="https://example.com/reports, all";priority=1`), map[string]string{
+		"csp-endpoint": "https://example.com/csp",
+		"default":      "https://example.com/reports, all",
+	})
+	assert.Empty(t, ParseReportingEndpoints(""))
+	assert.Empty(t, ParseReportingEndpoints("csp-endpoint"))
+}
+
+func TestParseReportTo(t *testing.T) {
+	reportTo := `{"group":"csp-endpoint","max_age":10886400,"endpoints":[{"url":"https://example.com/csp"}]}, ` +
+		`{"max_age":10886400,"endpoints":[{"url":"https://example.com/reports"},{"url":"https://backup.example.com/reports"}]}`
+	assert.Equal(t, ParseReportTo(reportTo), map[string][]string{
+		"csp-endpoint":       {"https://example.com/csp"},
+		ReportToDefaultGroup: {"https://example.com/reports", "https://backup.example.com/reports"},
+	})
+	assert.Empty(t, ParseReportTo("not json"))
+}
+
+func TestGetReportingScore(t *testing.T) {
+	for _, test := range []struct {
+		headers  map[string]string
+		score    int
+		message  string
+		findings []string
+	}{
+		// nothing reported
+		{map[string]string{CSPHeader: "default-src 'self'"}, 0, "", nil},
+		// consistent setups through either header
+		{map[string]string{
+			CSPHeader:       "default-src 'self'; report-to csp-endpoint",
+			ReportingHeader: `csp-endpoint="https://example.com/csp"`,
+		}, 2, "", nil},
+		{map[string]string{
+			CSPHeader:      "default-src 'self'; report-to csp-endpoint",
+			ReportToHeader: `{"group":"csp-endpoint","max_age":86400,"endpoints":[{"url":"https://example.com/csp"}]}`,
+		}, 2, "", nil},
+		{map[string]string{
+			CSPHeader:      "default-src 'self'; report-to default",
+			ReportToHeader: `{"max_age":86400,"endpoints":[{"url":"https://example.com/reports"}]}`,
+		}, 2, "", nil},
+		// endpoints that the policy does not report to
+		{map[string]string{
+			CSPHeader:       "default-src 'self'",
+			ReportingHeader: `csp-endpoint="https://example.com/csp"`,
+		}, 1, utils.ReportingUnusedMessage, nil},
+		// dangling report-to directives
+		{map[string]string{
+			CSPHeader:       "default-src 'self'; report-to csp-violations",
+			ReportingHeader: `csp-endpoint="https://example.com/csp"`,
+		}, 1, "", []string{fmt.Sprintf(utils.ReportingDanglingMessage, "csp-violations")}},
+		{map[string]string{
+			CSPHeader: "default-src 'self'; report-to csp-endpoint",
+		}, 0, "", []string{fmt.Sprintf(utils.ReportingDanglingMessage, "csp-endpoint")}},
+	} {
+		headerScore, err := BuildResponseHeaderScore(GetReportingScore(test.headers))
+		assert.NoError(t, err)
+		check := headerScore.checks[0]
+		assert.Equal(t, check.Name, ReportingCheck)
+		assert.Equal(t, check.Score, test.score, test.headers)
+		assert.Equal(t, check.MaxScore, ReportingScore)
+		assert.Equal(t, check.Message, test.message, test.headers)
+		assert.Equal(t, check.Findings, test.findings, test.headers)
+	}
+}
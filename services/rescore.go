@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"snift-api/models"
+	"snift-api/utils"
+)
+
+// ErrNoObservations is returned when rescoring a scan result stored without its observations
+var ErrNoObservations = errors.New("no observations stored for the scan result")
+
+// ScoreObservations returns the scores response of a scan from its observations, under the current scoring profiles,
+// check order and remediations
+func ScoreObservations(observations *models.Observations) (*models.ScoresResponse, error) {
+	profile, err := GetScoringProfile(observations.Profile)
+	if err != nil {
+		return nil, err
+	}
+	var only map[string]bool
+	if len(observations.Only) > 0 {
+		only = make(map[string]bool)
+		for _, name := range observations.Only {
+			only[name] = true
+		}
+	}
+	responseHeaderScore, err := scoreResponseObservations(observations)
+	if err != nil {
+		return nil, err
+	}
+	builder := models.NewScoreBuilder()
+	builder.SetProfile(profile)
+	builder.SetOrder(getCheckOrder())
+	builder.SetOnly(only)
+
+	protocolScore := CalculateProtocolScore(observations.Protocol)
+	if protocolScore == HTTPSScore {
+		builder.AddBadge(utils.GetHTTPSBadge())
+	} else {
+		// a site without HTTPS cannot get a good grade however its headers score, as none of them protects it
+		// from a network attacker
+		builder.SetGradeCap(utils.GetHTTPSGradeCap())
+	}
+	protocolCheck := models.GetCheckResult(ProtocolCheck, protocolScore, HTTPSScore)
+	protocolCheck.Critical = true
+	builder.AddCheck(protocolCheck)
+	for _, check := range responseHeaderScore.checks {
+		builder.AddCheck(check)
+	}
+	for _, badge := range responseHeaderScore.badges {
+		builder.AddBadge(badge)
+	}
+	// the remediation of an observed check is the current one
+	for _, check := range observations.Checks {
+		observed := *check
+		observed.Remediation = ""
+		builder.AddCheck(&observed)
+	}
+	for _, badge := range observations.Badges {
+		builder.AddBadge(badge)
+	}
+
+	addRemediations(builder.Checks())
+	scores := builder.Finalize(observations.URL)
+	scores.Badges = scopeBadges(scores.Badges, only)
+	scores.Only = observations.Only
+	scores.HSTSPreloadEligible = responseHeaderScore.hstsPreloadEligible
+	scores.ClearSiteDataPresent = len(responseHeaderScore.clearSiteData) > 0
+	scores.NegotiatedTLSVersion = TLSVersionNames[responseHeaderScore.negotiatedTLSVersion]
+	scores.MaxTLSVersion = TLSVersionNames[responseHeaderScore.maxTLSVersion]
+	scores.SessionResumption = observations.SessionResumption
+	scores.NegotiatedHTTPVersion = responseHeaderScore.negotiatedHTTPVersion
+	scores.MaxHTTPVersion = responseHeaderScore.maxHTTPVersion
+	if len(responseHeaderScore.redirectChain) > 1 {
+		scores.RedirectChain = responseHeaderScore.redirectChain
+		scores.FinalURL = responseHeaderScore.redirectChain[len(responseHeaderScore.redirectChain)-1]
+	}
+	scores.CrossHostRedirect = responseHeaderScore.crossHostRedirect
+	scores.RedirectLimitExceeded = responseHeaderScore.redirectLimitExceeded
+	scores.RequestMethod = responseHeaderScore.requestMethod
+	scores.PunycodeURL = observations.PunycodeURL
+	scores.ComplianceViolations = getComplianceViolations(observations.Headers)
+	response := models.BuildScoresResponse(scores, observations.Cert, observations.Incidents, getServerInformation(observations.Headers[Server]))
+	response.HostInfo = observations.HostInfo
+	return response, nil
+}
+
+// Rescore recomputes the scores response of a stored scan result from its observations, without any network I/O,
+// so that a change of the weights or of a scorer applies to past scans
+func Rescore(ctx context.Context, id uint) (*models.ScoresResponse, error) {
+	result, err := ResultStore.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if result.Observations == "" {
+		return nil, ErrNoObservations
+	}
+	observations := &models.Observations{}
+	err = json.Unmarshal([]byte(result.Observations), observations)
+	if err != nil {
+		return nil, err
+	}
+	return ScoreObservations(observations)
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"snift-api/models"
+	"snift-api/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRescore(t *testing.T) {
+	original := ResultStore
+	ResultStore = utils.NewMemoryResultStore()
+	defer func() { ResultStore = original }()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(XFrameHeader, "DENY")
+		w.Header().Set(XContentTypeHeader, "nosniff")
+	}))
+
+	responseBody, err := CalculateOverallScore(server.URL, &models.ScanOptions{Skip: []string{SkipVulnerabilities, SkipDNS}})
+	assert.NoError(t, err)
+	// the rescore works from the stored observations alone, the site is not requested again
+	server.Close()
+	var scanned models.ScoresResponse
+	assert.NoError(t, json.Unmarshal(responseBody, &scanned))
+	serverURL, _ := url.Parse(server.URL)
+	history, err := ResultStore.GetHistory(context.Background(), serverURL.Hostname())
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	rescored, err := Rescore(context.Background(), history[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, rescored.Scores, scanned.Scores)
+
+	// weighting up the passing X-Frame-Options check raises the score
+	profile := ScoringProfiles[BalancedProfile]
+	ScoringProfiles[BalancedProfile] = &models.ScoringProfile{
+		Name:            BalancedProfile,
+		Weights:         map[string]float64{XFrameHeader: 10},
+		GradeThresholds: models.GradeThresholds,
+	}
+	defer func() { ScoringProfiles[BalancedProfile] = profile }()
+	rescored, err = Rescore(context.Background(), history[0].ID)
+	assert.NoError(t, err)
+	assert.True(t, rescored.Scores.Score > scanned.Scores.Score, rescored.Scores.Score)
+	assert.Equal(t, len(rescored.Scores.Checks), len(scanned.Scores.Checks))
+
+	_, err = Rescore(context.Background(), history[0].ID+1)
+	assert.ErrorIs(t, err, utils.ErrResultNotFound)
+
+	legacy := models.GetScanResult("www.example.com", "https://www.example.com", 0.5)
+	assert.NoError(t, ResultStore.Save(context.Background(), legacy))
+	_, err = Rescore(context.Background(), legacy.ID)
+	assert.ErrorIs(t, err, ErrNoObservations)
+}
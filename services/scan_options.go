@@ -0,0 +1,201 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"snift-api/models"
+	"snift-api/utils"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// ErrUnknownScoringProfile is returned when the options select a scoring profile that does not exist
+var ErrUnknownScoringProfile = errors.New("unknown scoring profile")
+
+// ErrInvalidDKIMSelector is returned when the options supply too many DKIM selectors or one that is not a DNS name
+var ErrInvalidDKIMSelector = errors.New("invalid DKIM selector")
+
+// ErrInvalidTargetOverride is returned when the IP or SNI overrides of the options are malformed, or do not agree with
+// the scanned URL
+var ErrInvalidTargetOverride = errors.New("invalid IP or SNI override")
+
+// ErrForbiddenHeader is returned when the options supply too many custom request headers, one that is malformed, or
+// one that cannot be overridden
+var ErrForbiddenHeader = errors.New("forbidden request header")
+
+// ErrInvalidCrawlDepth is returned when the options crawl the site deeper than MaxCrawlDepth
+var ErrInvalidCrawlDepth = errors.New("invalid crawl depth")
+
+// ErrUnknownCheck is returned when the options restrict the scan to a check that does not exist
+var ErrUnknownCheck = errors.New("unknown check")
+
+var dkimSelectorPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,63}(\.[A-Za-z0-9_-]{1,63})*$`)
+
+var sniPattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)*[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+
+// ValidateScanOptions returns an error when the options skip a group of checks or run a check that does not exist,
+// supply DKIM selectors that cannot be queried, an IP or SNI override that is malformed, forbidden request headers,
+// or a crawl depth out of bounds
+func ValidateScanOptions(options *models.ScanOptions) error {
+	if options == nil {
+		return nil
+	}
+	for _, skipped := range options.Skip {
+		if !isSkippable(skipped) {
+			return fmt.Errorf("unknown group of checks to skip: %q", skipped)
+		}
+	}
+	for _, name := range options.Only {
+		if _, ok := resolveCheckName(name); !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownCheck, name)
+		}
+	}
+	if _, err := GetScoringProfile(options.Profile); err != nil {
+		return err
+	}
+	if len(options.DKIMSelectors) > MaxDKIMSelectors {
+		return fmt.Errorf("%w: more than %d selectors", ErrInvalidDKIMSelector, MaxDKIMSelectors)
+	}
+	for _, selector := range options.DKIMSelectors {
+		if !dkimSelectorPattern.MatchString(selector) {
+			return fmt.Errorf("%w: %q", ErrInvalidDKIMSelector, selector)
+		}
+	}
+	if options.IP != "" && net.ParseIP(options.IP) == nil {
+		return fmt.Errorf("%w: %q is not an IP", ErrInvalidTargetOverride, options.IP)
+	}
+	if options.SNI != "" && (net.ParseIP(options.SNI) != nil || len(options.SNI) > utils.MaxHostLength || !sniPattern.MatchString(options.SNI)) {
+		return fmt.Errorf("%w: %q is not a domain", ErrInvalidTargetOverride, options.SNI)
+	}
+	if len(options.Headers) > MaxCustomHeaders {
+		return fmt.Errorf("%w: more than %d headers", ErrForbiddenHeader, MaxCustomHeaders)
+	}
+	for name, value := range options.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) ||
+			ForbiddenCustomHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("%w: %q", ErrForbiddenHeader, name)
+		}
+	}
+	if options.CrawlDepth < 0 || options.CrawlDepth > MaxCrawlDepth {
+		return fmt.Errorf("%w: %d is not between 0 and %d", ErrInvalidCrawlDepth, options.CrawlDepth, MaxCrawlDepth)
+	}
+	return nil
+}
+
+// applyTargetOverride checks that the IP and SNI overrides of the options agree with the URL, and returns the IP its
+// connections are pinned to, nil when they are not pinned. A URL targeting an IP is rewritten to the SNI domain so
+// that the domain is presented through SNI and the Host header, while its connections go to the IP of the URL
+func applyTargetOverride(domain *url.URL, options *models.ScanOptions) (net.IP, error) {
+	ip, sni := options.GetTargetOverride()
+	if ip == "" && sni == "" {
+		return nil, nil
+	}
+	host := domain.Hostname()
+	if hostIP := net.ParseIP(host); hostIP != nil {
+		if ip != "" && !hostIP.Equal(net.ParseIP(ip)) {
+			return nil, fmt.Errorf("%w: the IP %s does not match the URL", ErrInvalidTargetOverride, ip)
+		}
+		if sni == "" {
+			return nil, nil
+		}
+		if port := domain.Port(); port != "" {
+			domain.Host = net.JoinHostPort(sni, port)
+		} else {
+			domain.Host = sni
+		}
+		return hostIP, nil
+	}
+	if ip == "" {
+		return nil, fmt.Errorf("%w: the SNI of a URL targeting a domain requires an IP", ErrInvalidTargetOverride)
+	}
+	if sni != "" && !strings.EqualFold(sni, host) {
+		return nil, fmt.Errorf("%w: the SNI %s does not match the URL", ErrInvalidTargetOverride, sni)
+	}
+	return net.ParseIP(ip), nil
+}
+
+// GetScoringProfile returns the scoring profile of the given name, or the DefaultScoringProfile when the name is empty
+func GetScoringProfile(name string) (*models.ScoringProfile, error) {
+	if name == "" {
+		name = DefaultScoringProfile
+	}
+	profile, ok := ScoringProfiles[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownScoringProfile, name)
+	}
+	return profile, nil
+}
+
+// resolveCheckName returns the name of the check given by its name or alias, ok is false when there is no such check
+func resolveCheckName(name string) (check string, ok bool) {
+	name = strings.TrimSpace(name)
+	if check, ok = CheckAliases[strings.ToLower(name)]; ok {
+		return check, true
+	}
+	for check = range CheckDescriptions {
+		if strings.EqualFold(check, name) {
+			return check, true
+		}
+	}
+	return "", false
+}
+
+// getOnlyChecks returns the names of the checks the options restrict the scan to, in the order they were requested,
+// and the same names as a set. Both are nil when the scan runs every check
+func getOnlyChecks(options *models.ScanOptions) (names []string, only map[string]bool) {
+	if options == nil || len(options.Only) == 0 {
+		return nil, nil
+	}
+	only = make(map[string]bool)
+	for _, name := range options.Only {
+		if check, ok := resolveCheckName(name); ok && !only[check] {
+			only[check] = true
+			names = append(names, check)
+		}
+	}
+	return names, only
+}
+
+// runsAnyCheck returns true when the scan runs one of the checks, a nil set running every check
+func runsAnyCheck(only map[string]bool, checks ...string) bool {
+	if only == nil {
+		return true
+	}
+	for _, check := range checks {
+		if only[check] {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeBadges returns the badges earned through the checks run by the scan
+func scopeBadges(badges []*models.Badge, only map[string]bool) []*models.Badge {
+	if only == nil {
+		return badges
+	}
+	scoped := []*models.Badge{}
+	for _, badge := range badges {
+		for check, name := range CheckBadges {
+			if name == badge.Name && only[check] {
+				scoped = append(scoped, badge)
+				break
+			}
+		}
+	}
+	return scoped
+}
+
+func isSkippable(group string) bool {
+	for _, skippable := range SkippableChecks {
+		if group == skippable {
+			return true
+		}
+	}
+	return false
+}
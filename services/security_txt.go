@@ -0,0 +1,70 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"snift-api/utils"
+	"strings"
+)
+
+// hasSecurityTxtContact returns true when a security.txt file has at least one Contact field, which RFC 9116 requires
+func hasSecurityTxtContact(body io.Reader) bool {
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		field := strings.SplitN(line, ":", 2)
+		if len(field) == 2 && strings.EqualFold(strings.TrimSpace(field[0]), "contact") && strings.TrimSpace(field[1]) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSecurityTxtScore returns the score for a security.txt file published at the well-known path of baseURL
+func GetSecurityTxtScore(baseURL string) int {
+	return getSecurityTxtScore(context.Background(), baseURL)
+}
+
+// getSecurityTxtScore is GetSecurityTxtScore with the request bound to ctx
+func getSecurityTxtScore(ctx context.Context, baseURL string) int {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		fmt.Println("Error Occured while parsing the URL for security.txt", err)
+		return 0
+	}
+	securityTxtURL := base.ResolveReference(&url.URL{Path: SecurityTxtPath})
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, securityTxtURL.String(), nil)
+	if err != nil {
+		fmt.Println("Error Occured while fetching security.txt", err)
+		return 0
+	}
+	response, err := utils.HTTPClient.Do(request)
+	if err != nil {
+		fmt.Println("Error Occured while fetching security.txt", err)
+		return 0
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return 0
+	}
+	body, truncated, err := utils.ReadBody(response.Body, SecurityTxtMaxSize)
+	if err != nil {
+		fmt.Println("Error Occured while reading security.txt", err)
+		return 0
+	}
+	if truncated {
+		fmt.Printf(utils.BodyTruncatedMessage+"\n", len(body), securityTxtURL)
+	}
+	if !hasSecurityTxtContact(bytes.NewReader(body)) {
+		return 0
+	}
+	return SecurityTxtScore
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newSecurityTxtServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != SecurityTxtPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestGetSecurityTxtScore(t *testing.T) {
+	server := newSecurityTxtServer(http.StatusOK, "# Our security policy\nContact: mailto:security@example.com\nExpires: 2030-01-01T00:00:00.000Z\n")
+	defer server.Close()
+	assert.Equal(t, GetSecurityTxtScore(server.URL), SecurityTxtScore)
+	// the file is looked up at the root, whatever the path of the scanned URL
+	assert.Equal(t, GetSecurityTxtScore(server.URL+"/blog/post?id=1"), SecurityTxtScore)
+}
+
+func TestGetSecurityTxtScoreMalformed(t *testing.T) {
+	server := newSecurityTxtServer(http.StatusOK, "# Contact: mailto:security@example.com\nExpires: 2030-01-01T00:00:00.000Z\nContact:\n")
+	defer server.Close()
+	assert.Equal(t, GetSecurityTxtScore(server.URL), 0)
+}
+
+func TestGetSecurityTxtScoreNotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	assert.Equal(t, GetSecurityTxtScore(server.URL), 0)
+}
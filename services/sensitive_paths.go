@@ -0,0 +1,124 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"snift-api/utils"
+	"strings"
+	"time"
+)
+
+// sensitivePathsClient reports the status of the requested path itself, a redirect to a login page is not followed
+var sensitivePathsClient = &http.Client{
+	Transport: utils.HTTPTransport,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// getPathStatus returns the status code of a GET request for path on the base URL
+func getPathStatus(ctx context.Context, base *url.URL, path string) (int, io.ReadCloser, error) {
+	pathURL := base.ResolveReference(&url.URL{Path: path})
+	req, err := http.NewRequestWithContext(ctx, "GET", pathURL.String(), nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	response, err := sensitivePathsClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	return response.StatusCode, response.Body, nil
+}
+
+// parseRobotsRules returns every path disallowed by a robots.txt file, whichever user agent it applies to
+func parseRobotsRules(body io.Reader) (paths []string) {
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		field := strings.SplitN(scanner.Text(), ":", 2)
+		if len(field) != 2 || !strings.EqualFold(strings.TrimSpace(field[0]), "disallow") {
+			continue
+		}
+		path := strings.TrimSpace(strings.SplitN(field[1], "#", 2)[0])
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return
+}
+
+// parseRobotsDisallow returns the plain paths disallowed by a robots.txt file, patterns with wildcards are skipped
+func parseRobotsDisallow(body io.Reader) (paths []string) {
+	for _, path := range parseRobotsRules(body) {
+		if path != "/" && !strings.ContainsAny(path, "*$") {
+			paths = append(paths, path)
+		}
+	}
+	return
+}
+
+// GetSensitivePathsScore checks the curated sensitive paths, along with the paths disallowed in robots.txt, and reports the
+// ones that do not return 404. The check loses its score only when a path is served with 200 OK
+func GetSensitivePathsScore(baseURL string) (score int, findings []string) {
+	return getSensitivePathsScore(context.Background(), baseURL)
+}
+
+// getSensitivePathsScore is GetSensitivePathsScore with the requests bound to ctx
+func getSensitivePathsScore(ctx context.Context, baseURL string) (score int, findings []string) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		fmt.Println("Error Occured while parsing the URL for the Sensitive Paths check", err)
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(utils.RequestTimeoutSeconds)*time.Second)
+	defer cancel()
+
+	paths := append([]string{}, SensitivePaths[:]...)
+	status, body, err := getPathStatus(ctx, base, RobotsTxtPath)
+	if err == nil {
+		if status == http.StatusOK {
+			robotsTxt, truncated, readErr := utils.ReadBody(body, RobotsTxtMaxSize)
+			if readErr == nil {
+				paths = append(paths, parseRobotsDisallow(bytes.NewReader(robotsTxt))...)
+			}
+			if truncated {
+				findings = append(findings, fmt.Sprintf(utils.BodyTruncatedMessage, len(robotsTxt), RobotsTxtPath))
+			}
+		}
+		body.Close()
+	}
+
+	score = SensitivePathsScore
+	requested := map[string]bool{}
+	for _, path := range paths {
+		// robots.txt counts towards the bounded number of requests
+		if len(requested)+1 >= SensitivePathsMaxRequests {
+			break
+		}
+		if requested[path] {
+			continue
+		}
+		requested[path] = true
+		status, body, err := getPathStatus(ctx, base, path)
+		if err != nil {
+			if ctx.Err() != nil {
+				findings = append(findings, fmt.Sprintf(utils.SensitivePathsTimeoutMessage, path))
+				break
+			}
+			continue
+		}
+		body.Close()
+		if status == http.StatusNotFound {
+			continue
+		}
+		findings = append(findings, fmt.Sprintf(utils.SensitivePathExposedMessage, path, status))
+		if status == http.StatusOK {
+			score = 0
+		}
+	}
+	return score, findings
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"snift-api/utils"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetSensitivePathsScore(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case RobotsTxtPath:
+			fmt.Fprint(w, "User-agent: *\nDisallow: /internal/ # staff only\nDisallow: /*.pdf$\nDisallow: /\n")
+		case "/.git/config":
+			fmt.Fprint(w, "[core]\n\trepositoryformatversion = 0\n")
+		case "/admin":
+			http.Redirect(w, r, "/login", http.StatusFound)
+		case "/internal/":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	score, findings := GetSensitivePathsScore(server.URL)
+	assert.Equal(t, score, 0)
+	assert.Equal(t, findings, []string{
+		"/.git/config responded with status 200",
+		"/admin responded with status 302",
+		"/internal/ responded with status 403",
+	})
+	assert.Equal(t, requests, len(SensitivePaths)+2)
+}
+
+func TestGetSensitivePathsScoreNotFound(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	score, findings := GetSensitivePathsScore(server.URL)
+	assert.Equal(t, score, SensitivePathsScore)
+	assert.Empty(t, findings)
+	assert.Equal(t, requests, len(SensitivePaths)+1)
+}
+
+func TestGetSensitivePathsScoreMaxRequests(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == RobotsTxtPath {
+			for i := 0; i < 50; i++ {
+				fmt.Fprintf(w, "Disallow: /private-%d\n", i)
+			}
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	GetSensitivePathsScore(server.URL)
+	assert.Equal(t, requests, SensitivePathsMaxRequests)
+}
+
+func TestGetSensitivePathsScoreLargeRobotsTxt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == RobotsTxtPath {
+			fmt.Fprint(w, "Disallow: /private/\n")
+			fmt.Fprint(w, strings.Repeat("# padding\n", RobotsTxtMaxSize))
+			fmt.Fprint(w, "Disallow: /hidden/\n")
+			return
+		}
+		if r.URL.Path == "/private/" || r.URL.Path == "/hidden/" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	// only the paths disallowed within the first RobotsTxtMaxSize bytes are checked
+	score, findings := GetSensitivePathsScore(server.URL)
+	assert.Equal(t, score, SensitivePathsScore)
+	assert.Equal(t, findings, []string{
+		fmt.Sprintf(utils.BodyTruncatedMessage, RobotsTxtMaxSize, RobotsTxtPath),
+		"/private/ responded with status 403",
+	})
+}
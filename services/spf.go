@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"snift-api/utils"
+	"strings"
+)
+
+// isSPFRecord returns true when a TXT record is an SPF version 1 record
+func isSPFRecord(record string) bool {
+	record = strings.ToLower(strings.TrimSpace(record))
+	return record == "v=spf1" || strings.HasPrefix(record, "v=spf1 ")
+}
+
+// lookupSPFRecords returns the SPF records published by a domain
+func lookupSPFRecords(ctx context.Context, domain string) (spfRecords []string, err error) {
+	records, err := cachedLookupTXT(ctx, domain)
+	for _, record := range records {
+		if isSPFRecord(record) {
+			spfRecords = append(spfRecords, strings.TrimSpace(record))
+		}
+	}
+	return
+}
+
+// SPFEvaluation holds the outcome of evaluating an SPF record along with every record it references
+type SPFEvaluation struct {
+	// AllQualifier is the qualifier of the effective all mechanism, empty when the record has none
+	AllQualifier string
+	Lookups      int
+	PermError    bool
+	Findings     []string
+}
+
+func (evaluation *SPFEvaluation) permError(format string, args ...interface{}) {
+	evaluation.PermError = true
+	evaluation.Findings = append(evaluation.Findings, fmt.Sprintf(format, args...))
+}
+
+// EvaluateSPF evaluates an SPF record of a domain, following include and redirect chains
+// while counting the DNS lookups they require
+func EvaluateSPF(domain string, record string) *SPFEvaluation {
+	return EvaluateSPFContext(context.Background(), domain, record)
+}
+
+// EvaluateSPFContext is EvaluateSPF with the referenced records looked up through the DNS cache of ctx
+func EvaluateSPFContext(ctx context.Context, domain string, record string) *SPFEvaluation {
+	evaluation := &SPFEvaluation{}
+	evaluation.AllQualifier = evaluateSPFRecord(ctx, domain, record, evaluation, map[string]bool{strings.ToLower(domain): true})
+	if evaluation.Lookups > SPFMaxDNSLookups {
+		evaluation.permError(utils.SPFLookupLimitMessage, domain, SPFMaxDNSLookups)
+	}
+	return evaluation
+}
+
+func evaluateSPFRecord(ctx context.Context, domain string, record string, evaluation *SPFEvaluation, visited map[string]bool) (allQualifier string) {
+	var redirect string
+	for _, term := range strings.Fields(record)[1:] {
+		if evaluation.Lookups > SPFMaxDNSLookups {
+			return
+		}
+		lowerTerm := strings.ToLower(term)
+		// modifiers are of the form name=value, only redirect affects the evaluation
+		if strings.HasPrefix(lowerTerm, "redirect=") {
+			redirect = term[len("redirect="):]
+			continue
+		}
+		if modifier := strings.SplitN(lowerTerm, "=", 2); len(modifier) == 2 && !strings.ContainsAny(modifier[0], ":/") {
+			continue
+		}
+		qualifier := "+"
+		if strings.ContainsAny(lowerTerm[:1], "+-~?") {
+			qualifier = lowerTerm[:1]
+			lowerTerm = lowerTerm[1:]
+			term = term[1:]
+		}
+		mechanism := lowerTerm
+		target := ""
+		if index := strings.IndexAny(lowerTerm, ":/"); index != -1 {
+			mechanism = lowerTerm[:index]
+			if lowerTerm[index] == ':' {
+				target = term[index+1:]
+			}
+		}
+		switch mechanism {
+		case "all":
+			if allQualifier == "" {
+				allQualifier = qualifier
+			}
+		case "include":
+			evaluation.Lookups++
+			if target == "" {
+				evaluation.permError(utils.SPFSyntaxErrorMessage, domain, term)
+				continue
+			}
+			evaluateReferencedSPF(ctx, domain, target, evaluation, visited)
+		case "a", "mx", "ptr", "exists":
+			evaluation.Lookups++
+		case "ip4", "ip6":
+		default:
+			evaluation.permError(utils.SPFSyntaxErrorMessage, domain, term)
+		}
+	}
+	// redirect is only followed when the record has no all mechanism
+	if allQualifier == "" && redirect != "" {
+		evaluation.Lookups++
+		allQualifier = evaluateReferencedSPF(ctx, domain, redirect, evaluation, visited)
+	}
+	return
+}
+
+func evaluateReferencedSPF(ctx context.Context, domain string, target string, evaluation *SPFEvaluation, visited map[string]bool) string {
+	// only the records along the current chain are tracked, so a record may be referenced from several branches
+	if visited[strings.ToLower(target)] {
+		evaluation.permError(utils.SPFIncludeLoopMessage, domain, target)
+		return ""
+	}
+	visited[strings.ToLower(target)] = true
+	defer delete(visited, strings.ToLower(target))
+	records, err := lookupSPFRecords(ctx, target)
+	if err != nil || len(records) == 0 {
+		evaluation.permError(utils.SPFMissingIncludeMessage, domain, target)
+		return ""
+	}
+	return evaluateSPFRecord(ctx, target, records[0], evaluation, visited)
+}
+
+// GetSPFScore returns the Sender Policy Framework Score of the Domain
+func GetSPFScore(domain string) (spfScore int, maxSPFScore int, txtRecords string, findings []string) {
+	return GetSPFScoreContext(context.Background(), domain)
+}
+
+// GetSPFScoreContext is GetSPFScore with the lookups made through the DNS cache of ctx
+func GetSPFScoreContext(ctx context.Context, domain string) (spfScore int, maxSPFScore int, txtRecords string, findings []string) {
+	records, err := cachedLookupTXT(ctx, domain)
+	if err != nil {
+		fmt.Println("Unexpected Error Occured while extracting TXT Records", err)
+	}
+	txtRecords = strings.Join(records, "\n")
+
+	var spfRecords []string
+	for _, record := range records {
+		if isSPFRecord(record) {
+			spfRecords = append(spfRecords, record)
+		}
+	}
+	if len(spfRecords) == 0 {
+		return
+	}
+	maxSPFScore = 5
+	// RFC 7208 forbids publishing more than one SPF record, receivers treat it as a permanent error
+	if len(spfRecords) > 1 {
+		findings = append(findings, fmt.Sprintf(utils.SPFMultipleRecordsMessage, domain, len(spfRecords)))
+		return
+	}
+
+	evaluation := EvaluateSPFContext(ctx, domain, spfRecords[0])
+	findings = append(findings, evaluation.Findings...)
+	if evaluation.PermError {
+		return
+	}
+	switch evaluation.AllQualifier {
+	case "":
+		// a record without an all mechanism defaults to neutral
+		findings = append(findings, fmt.Sprintf(utils.SPFMissingAllMessage, domain))
+		spfScore = SPFAllValues["?"]
+	case "+":
+		// a record allowing every sender defeats SPF, so no points are awarded
+		findings = append(findings, fmt.Sprintf(utils.SPFPermissiveAllMessage, domain))
+	default:
+		spfScore = SPFAllValues[evaluation.AllQualifier]
+	}
+	return
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"snift-api/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockLookupTXT(records map[string][]string) func() {
+	original := lookupTXT
+	lookupTXT = func(ctx context.Context, domain string) ([]string, error) {
+		if txtRecords, ok := records[domain]; ok {
+			return txtRecords, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	return func() { lookupTXT = original }
+}
+
+func TestGetSPFScoreNestedIncludes(t *testing.T) {
+	defer mockLookupTXT(map[string][]string{
+		"example.com":             {"google-site-verification=abc", "v=spf1 include:_spf.example.com -all"},
+		"_spf.example.com":        {"v=spf1 include:_netblocks.example.com include:_netblocks2.example.com ~all"},
+		"_netblocks.example.com":  {"v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 ?all"},
+		"_netblocks2.example.com": {"v=spf1 a mx redirect=_netblocks.example.com"},
+	})()
+
+	evaluation := EvaluateSPF("example.com", "v=spf1 include:_spf.example.com -all")
+	assert.Equal(t, evaluation.AllQualifier, "-")
+	assert.Equal(t, evaluation.Lookups, 6)
+	assert.False(t, evaluation.PermError)
+
+	spfScore, maxSPFScore, txtRecords, findings := GetSPFScore("example.com")
+	assert.Equal(t, spfScore, 5)
+	assert.Equal(t, maxSPFScore, 5)
+	assert.Equal(t, txtRecords, "google-site-verification=abc\nv=spf1 include:_spf.example.com -all")
+	assert.Empty(t, findings)
+}
+
+func TestGetSPFScorePermissiveAll(t *testing.T) {
+	defer mockLookupTXT(map[string][]string{
+		"example.com": {"v=spf1 ip4:192.0.2.1 +all"},
+	})()
+
+	spfScore, maxSPFScore, _, findings := GetSPFScore("example.com")
+	assert.Equal(t, spfScore, 0)
+	assert.Equal(t, maxSPFScore, 5)
+	assert.Equal(t, findings, []string{fmt.Sprintf(utils.SPFPermissiveAllMessage, "example.com")})
+
+	defer mockLookupTXT(map[string][]string{
+		"example.com":      {"v=spf1 redirect=_spf.example.com"},
+		"_spf.example.com": {"v=spf1 all"},
+	})()
+	spfScore, _, _, findings = GetSPFScore("example.com")
+	assert.Equal(t, spfScore, 0)
+	assert.Equal(t, len(findings), 1)
+}
+
+func TestGetSPFScoreRecordCount(t *testing.T) {
+	defer mockLookupTXT(map[string][]string{
+		"none.example.com":     {"google-site-verification=abc"},
+		"single.example.com":   {"v=spf1 ip4:192.0.2.1 ~all"},
+		"multiple.example.com": {"v=spf1 ip4:192.0.2.1 -all", "v=spf1 include:single.example.com -all"},
+	})()
+
+	spfScore, maxSPFScore, _, findings := GetSPFScore("none.example.com")
+	assert.Equal(t, spfScore, 0)
+	assert.Equal(t, maxSPFScore, 0)
+	assert.Empty(t, findings)
+
+	spfScore, maxSPFScore, _, findings = GetSPFScore("single.example.com")
+	assert.Equal(t, spfScore, 3)
+	assert.Equal(t, maxSPFScore, 5)
+	assert.Empty(t, findings)
+
+	spfScore, maxSPFScore, _, findings = GetSPFScore("multiple.example.com")
+	assert.Equal(t, spfScore, 0)
+	assert.Equal(t, maxSPFScore, 5)
+	assert.Equal(t, findings, []string{fmt.Sprintf(utils.SPFMultipleRecordsMessage, "multiple.example.com", 2)})
+}
+
+func TestGetSPFScoreLookupLimit(t *testing.T) {
+	records := map[string][]string{
+		"example.com":   {"v=spf1 include:a.example.com include:b.example.com -all"},
+		"a.example.com": {"v=spf1 a mx ptr exists:%{i}.example.com include:c.example.com"},
+		"b.example.com": {"v=spf1 a mx a:mail.example.com mx:mail.example.com -all"},
+		"c.example.com": {"v=spf1 mx -all"},
+	}
+	defer mockLookupTXT(records)()
+
+	evaluation := EvaluateSPF("example.com", records["example.com"][0])
+	assert.True(t, evaluation.PermError)
+	assert.True(t, evaluation.Lookups > SPFMaxDNSLookups)
+
+	spfScore, maxSPFScore, _, findings := GetSPFScore("example.com")
+	assert.Equal(t, spfScore, 0)
+	assert.Equal(t, maxSPFScore, 5)
+	assert.Contains(t, findings, fmt.Sprintf(utils.SPFLookupLimitMessage, "example.com", SPFMaxDNSLookups))
+}
+
+func TestGetSPFScoreInvalidRecords(t *testing.T) {
+	defer mockLookupTXT(map[string][]string{
+		"example.com":      {"v=spf1 include:missing.example.com ip5:192.0.2.1 -all"},
+		"loop.example.com": {"v=spf1 include:loop.example.com -all"},
+		"none.example.com": {"v=spf1 ip4:192.0.2.1"},
+	})()
+
+	evaluation := EvaluateSPF("example.com", "v=spf1 include:missing.example.com ip5:192.0.2.1 -all")
+	assert.True(t, evaluation.PermError)
+	assert.Equal(t, evaluation.Findings, []string{
+		fmt.Sprintf(utils.SPFMissingIncludeMessage, "example.com", "missing.example.com"),
+		fmt.Sprintf(utils.SPFSyntaxErrorMessage, "example.com", "ip5:192.0.2.1"),
+	})
+
+	evaluation = EvaluateSPF("loop.example.com", "v=spf1 include:loop.example.com -all")
+	assert.True(t, evaluation.PermError)
+
+	spfScore, maxSPFScore, _, findings := GetSPFScore("none.example.com")
+	assert.Equal(t, spfScore, 2)
+	assert.Equal(t, maxSPFScore, 5)
+	assert.Equal(t, findings, []string{fmt.Sprintf(utils.SPFMissingAllMessage, "none.example.com")})
+}
+
+func TestGetSPFScoreDNSCache(t *testing.T) {
+	records := map[string][]string{
+		"example.com":             {"v=spf1 include:_spf.example.com -all"},
+		"_spf.example.com":        {"v=spf1 include:_netblocks.example.com include:_netblocks2.example.com ~all"},
+		"_netblocks.example.com":  {"v=spf1 ip4:192.0.2.0/24 ?all"},
+		"_netblocks2.example.com": {"v=spf1 redirect=_netblocks.example.com"},
+	}
+	queries := 0
+	original := lookupTXT
+	defer func() { lookupTXT = original }()
+	lookupTXT = func(ctx context.Context, domain string) ([]string, error) {
+		queries++
+		return records[domain], nil
+	}
+
+	// _netblocks.example.com is referenced from two branches, and queried twice without the cache
+	spfScore, _, _, _ := GetSPFScore("example.com")
+	assert.Equal(t, queries, 5)
+
+	queries = 0
+	ctx := utils.WithDNSCache(context.Background(), utils.NewDNSCache())
+	cachedSPFScore, _, _, _ := GetSPFScoreContext(ctx, "example.com")
+	assert.Equal(t, queries, 4)
+	assert.Equal(t, cachedSPFScore, spfScore)
+
+	// the records are reused for the rest of the scan
+	GetSPFScoreContext(ctx, "example.com")
+	assert.Equal(t, queries, 4)
+	// a new scan starts with an empty cache
+	GetSPFScoreContext(utils.WithDNSCache(context.Background(), utils.NewDNSCache()), "example.com")
+	assert.Equal(t, queries, 8)
+}
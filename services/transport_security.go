@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"snift-api/utils"
+	"strings"
+)
+
+// httpsRedirect checks whether the plain HTTP site of a host redirects to HTTPS
+var httpsRedirect = checkHTTPSRedirect
+
+// checkHTTPSRedirect sends a request to the plain HTTP site of the host and returns true when its redirects lead to
+// HTTPS within the registrable domain of the host. The redirects are followed over HTTP only, the HTTPS site being
+// the one scanned
+func checkHTTPSRedirect(ctx context.Context, host string) (bool, error) {
+	var target *url.URL
+	client := &http.Client{
+		Transport: utils.HTTPTransport,
+		Timeout:   utils.RequestTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme == "https" {
+				target = req.URL
+				return http.ErrUseLastResponse
+			}
+			if len(via) > utils.GetMaxRedirects() {
+				return http.ErrUseLastResponse
+			}
+			// a public URL must not be able to redirect the scanner to an internal one
+			return utils.ValidateTarget(req.Context(), req.URL.Hostname())
+		},
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, (&url.URL{Scheme: "http", Host: host, Path: "/"}).String(), nil)
+	if err != nil {
+		return false, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return false, err
+	}
+	response.Body.Close()
+	hostname := (&url.URL{Host: host}).Hostname()
+	return target != nil && utils.RegistrableDomain(target.Hostname()) == utils.RegistrableDomain(hostname), nil
+}
+
+// getHTTPSRedirect returns whether the plain HTTP site redirects to HTTPS, nil when it is unknown. A request that did
+// not end on HTTPS tells it from its own redirects, while the HTTP site of an HTTPS URL on the default port is
+// requested. The HTTP site of an HTTPS URL on another port cannot be told
+func getHTTPSRedirect(response *http.Response, redirectChain []string) *bool {
+	redirected := response.Request.URL.Scheme == "https"
+	if !redirected || !strings.HasPrefix(redirectChain[0], "https:") {
+		return &redirected
+	}
+	if response.Request.URL.Port() != "" {
+		return nil
+	}
+	host := response.Request.URL.Hostname()
+	redirected, err := httpsRedirect(response.Request.Context(), host)
+	if err != nil {
+		fmt.Println("Error Occured while checking the HTTP to HTTPS redirect of "+host, err)
+		return nil
+	}
+	return &redirected
+}
+
+// GetTransportSecurityScore returns the score for the way plain HTTP visitors are moved to HTTPS: the redirect gets
+// them there on their first visit, and Strict-Transport-Security keeps them there without going through HTTP again.
+// The header is scored on its own by the HSTS check, so the composite only rewards the redirect, in full when it is
+// paired with a valid HSTS policy, and never counts the header alone. It is inconclusive when the redirect is unknown
+func GetTransportSecurityScore(protocol string, HSTS string, redirect *bool) ResponseHeader {
+	return func(transportSecurityScore *HeaderScore) error {
+		transportSecurityScore.name = TransportSecurityCheck
+		if redirect == nil {
+			transportSecurityScore.inconclusive = true
+			transportSecurityScore.checkMaximumValue = 0
+			transportSecurityScore.message = utils.TransportInconclusiveMessage
+			return nil
+		}
+		hsts := protocol == "https" && ParseHSTS(HSTS).ValidMaxAge
+		switch {
+		case *redirect && hsts:
+			transportSecurityScore.value += 5
+		case *redirect:
+			transportSecurityScore.value += 3
+			transportSecurityScore.findings = append(transportSecurityScore.findings, utils.TransportNoHSTSMessage)
+		case hsts:
+			transportSecurityScore.findings = append(transportSecurityScore.findings, utils.TransportNoRedirectMessage)
+		default:
+			transportSecurityScore.findings = append(transportSecurityScore.findings, utils.TransportPlainHTTPMessage)
+		}
+		return nil
+	}
+}
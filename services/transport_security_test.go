@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"snift-api/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTransportSecurityScore(t *testing.T) {
+	redirect, noRedirect := true, false
+	hsts := "max-age=31536000; includeSubDomains"
+	for _, test := range []struct {
+		redirect *bool
+		HSTS     string
+		score    int
+		findings []string
+	}{
+		{&redirect, hsts, 5, nil},
+		{&redirect, "", 3, []string{utils.TransportNoHSTSMessage}},
+		{&noRedirect, hsts, 0, []string{utils.TransportNoRedirectMessage}},
+		{&noRedirect, "", 0, []string{utils.TransportPlainHTTPMessage}},
+		// a policy without a valid max-age is not enforced
+		{&redirect, "includeSubDomains", 3, []string{utils.TransportNoHSTSMessage}},
+	} {
+		transportSecurityScore, err := BuildResponseHeaderScore(GetTransportSecurityScore("https", test.HSTS, test.redirect))
+		assert.NoError(t, err)
+		check := transportSecurityScore.checks[0]
+		assert.Equal(t, check.Name, TransportSecurityCheck)
+		assert.Equal(t, check.Score, test.score, test)
+		assert.Equal(t, check.MaxScore, 5)
+		assert.Equal(t, check.Findings, test.findings, test)
+	}
+
+	// the composite never counts the HSTS Header alone, so the combination scores the highest and HTTP alone the lowest
+	total := func(redirect *bool, HSTS string) int {
+		responseHeaderScore, _ := BuildResponseHeaderScore(GetHSTSScore(HSTS, "https"), GetTransportSecurityScore("https", HSTS, redirect))
+		return responseHeaderScore.value
+	}
+	hstsScore, _ := BuildResponseHeaderScore(GetHSTSScore(hsts, "https"))
+	assert.Equal(t, total(&noRedirect, hsts), hstsScore.value)
+	assert.Equal(t, total(&redirect, ""), 3)
+	assert.Equal(t, total(&noRedirect, ""), 0)
+	assert.True(t, total(&redirect, hsts) > total(&redirect, "") && total(&redirect, hsts) > total(&noRedirect, hsts))
+
+	// HSTS is ignored over HTTP
+	transportSecurityScore, _ := BuildResponseHeaderScore(GetTransportSecurityScore("http", hsts, &noRedirect))
+	assert.Equal(t, transportSecurityScore.checks[0].Findings, []string{utils.TransportPlainHTTPMessage})
+
+	transportSecurityScore, _ = BuildResponseHeaderScore(GetTransportSecurityScore("https", hsts, nil))
+	assert.True(t, transportSecurityScore.checks[0].Inconclusive)
+	assert.Equal(t, transportSecurityScore.maximumValue, 0)
+	assert.Equal(t, transportSecurityScore.checks[0].Message, utils.TransportInconclusiveMessage)
+}
+
+func TestCheckHTTPSRedirect(t *testing.T) {
+	for _, test := range []struct {
+		location   string
+		redirected bool
+	}{
+		{"https://127.0.0.1/", true},
+		{"/hop", true},
+		{"https://example.com/", false},
+		{"", false},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/hop" {
+				http.Redirect(w, r, "https://127.0.0.1/", http.StatusMovedPermanently)
+				return
+			}
+			if test.location != "" {
+				http.Redirect(w, r, test.location, http.StatusMovedPermanently)
+			}
+		}))
+		redirected, err := checkHTTPSRedirect(context.Background(), server.Listener.Addr().String())
+		server.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, redirected, test.redirected, test.location)
+	}
+}
+
+func TestGetHTTPSRedirect(t *testing.T) {
+	probed := 0
+	defer func(original func(ctx context.Context, host string) (bool, error)) { httpsRedirect = original }(httpsRedirect)
+	httpsRedirect = func(ctx context.Context, host string) (bool, error) {
+		probed++
+		if host == "unreachable.example.com" {
+			return false, errors.New("connection refused")
+		}
+		return true, nil
+	}
+	response := func(finalURL string) *http.Response {
+		final, _ := url.Parse(finalURL)
+		return &http.Response{Request: (&http.Request{URL: final}).WithContext(context.Background())}
+	}
+
+	// a request over HTTP tells the redirect from its own redirect chain
+	assert.True(t, *getHTTPSRedirect(response("https://example.com/"), []string{"http://example.com/", "https://example.com/"}))
+	assert.False(t, *getHTTPSRedirect(response("http://example.com/"), []string{"http://example.com/"}))
+	assert.False(t, *getHTTPSRedirect(response("http://example.com/"), []string{"https://example.com/", "http://example.com/"}))
+	assert.Equal(t, probed, 0)
+
+	// the HTTP site of an HTTPS URL is requested, unless the URL is on another port
+	assert.True(t, *getHTTPSRedirect(response("https://example.com/"), []string{"https://example.com/"}))
+	assert.Nil(t, getHTTPSRedirect(response("https://unreachable.example.com/"), []string{"https://unreachable.example.com/"}))
+	assert.Nil(t, getHTTPSRedirect(response("https://example.com:8443/"), []string{"https://example.com:8443/"}))
+	assert.Equal(t, probed, 2)
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"snift-api/utils"
+)
+
+// VulnerabilityClient fetches the security incidents reported for a host, from openbugbounty.org or from an
+// internal mirror of its API in air-gapped deployments
+type VulnerabilityClient struct {
+	// BaseURL is completed with the host to query, e.g. https://www.openbugbounty.org/api/1/search/?domain=
+	BaseURL string
+	// Client sends the requests, its Timeout bounds every attempt
+	Client *http.Client
+}
+
+// NewVulnerabilityClient returns a VulnerabilityClient querying OPENBUGBOUNTY_URL, or openbugbounty.org when it is not set
+func NewVulnerabilityClient(client *http.Client) *VulnerabilityClient {
+	baseURL := utils.GetOpenBugBountyURL()
+	if baseURL == "" {
+		baseURL = OpenBugBountyURL
+	}
+	return &VulnerabilityClient{BaseURL: baseURL, Client: client}
+}
+
+// FetchIncidents returns the XML list of the incidents reported for the host
+func (vulnerabilityClient *VulnerabilityClient) FetchIncidents(host string) ([]byte, error) {
+	return vulnerabilityClient.FetchIncidentsContext(context.Background(), host)
+}
+
+// FetchIncidentsContext is FetchIncidents with the requests bound to ctx
+func (vulnerabilityClient *VulnerabilityClient) FetchIncidentsContext(ctx context.Context, host string) ([]byte, error) {
+	client := vulnerabilityClient.Client
+	if client == nil {
+		client = utils.HTTPClient
+	}
+	incidentsURL := vulnerabilityClient.BaseURL + url.QueryEscape(host)
+	resp, err := utils.GetWithRetry(ctx, client, incidentsURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("openbugbounty returned status %d", resp.StatusCode)
+	}
+	body, truncated, err := utils.ReadBody(resp.Body, 0)
+	if truncated {
+		fmt.Printf(utils.BodyTruncatedMessage+"\n", len(body), incidentsURL)
+	}
+	return body, err
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"snift-api/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVulnerabilityClientFetchIncidents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Path, "/api/1/search/")
+		if r.URL.Query().Get("domain") == "missing.example.com" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `<incidents><item><host>%s</host><fixed>1</fixed></item></incidents>`, r.URL.Query().Get("domain"))
+	}))
+	defer server.Close()
+
+	client := &VulnerabilityClient{BaseURL: server.URL + "/api/1/search/?domain=", Client: server.Client()}
+	body, err := client.FetchIncidents("example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, string(body), `<incidents><item><host>example.com</host><fixed>1</fixed></item></incidents>`)
+
+	_, err = client.FetchIncidents("missing.example.com")
+	assert.EqualError(t, err, "openbugbounty returned status 404")
+}
+
+func TestVulnerabilityClientTimeout(t *testing.T) {
+	original := utils.RetryBackoff
+	utils.RetryBackoff = time.Millisecond
+	defer func() { utils.RetryBackoff = original }()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := &VulnerabilityClient{BaseURL: server.URL + "/?domain=", Client: &http.Client{Timeout: 10 * time.Millisecond}}
+	_, err := client.FetchIncidents("example.com")
+	assert.Error(t, err)
+}
+
+func TestNewVulnerabilityClient(t *testing.T) {
+	assert.Equal(t, NewVulnerabilityClient(nil).BaseURL, OpenBugBountyURL)
+
+	// an air-gapped deployment points the client at an internal mirror
+	os.Setenv("OPENBUGBOUNTY_URL", "https://openbugbounty.mirror.internal/api/1/search/?domain=")
+	defer os.Unsetenv("OPENBUGBOUNTY_URL")
+	assert.Equal(t, NewVulnerabilityClient(nil).BaseURL, "https://openbugbounty.mirror.internal/api/1/search/?domain=")
+}
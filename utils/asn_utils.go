@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"snift-api/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// asnRange holds a range of IP addresses announced by a single Autonomous System
+type asnRange struct {
+	start net.IP
+	end   net.IP
+	info  *models.ASNInfo
+}
+
+// ASNDatabase is an offline IP to ASN database, read from the tab separated ip2asn format of iptoasn.com:
+// range_start, range_end, AS_number, country_code and AS_description
+type ASNDatabase struct {
+	ranges []asnRange
+}
+
+// ParseASNDatabase reads an ASNDatabase, ranges that are not routed (AS number 0) are skipped
+func ParseASNDatabase(reader io.Reader) (*ASNDatabase, error) {
+	database := &ASNDatabase{}
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("invalid ASN database entry at line %d", line)
+		}
+		start, end := net.ParseIP(fields[0]).To16(), net.ParseIP(fields[1]).To16()
+		number, err := strconv.Atoi(fields[2])
+		if start == nil || end == nil || err != nil {
+			return nil, fmt.Errorf("invalid ASN database entry at line %d", line)
+		}
+		if number == 0 {
+			continue
+		}
+		database.ranges = append(database.ranges, asnRange{
+			start: start,
+			end:   end,
+			info:  &models.ASNInfo{Number: number, Country: fields[3], Organization: fields[4]},
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(database.ranges, func(i, j int) bool {
+		return bytes.Compare(database.ranges[i].start, database.ranges[j].start) < 0
+	})
+	return database, nil
+}
+
+// LoadASNDatabase reads the ASNDatabase stored at path
+func LoadASNDatabase(path string) (*ASNDatabase, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseASNDatabase(file)
+}
+
+// Lookup returns the ASN an IP address belongs to, nil when the address is not in the database
+func (database *ASNDatabase) Lookup(ip net.IP) *models.ASNInfo {
+	ip = ip.To16()
+	if database == nil || ip == nil {
+		return nil
+	}
+	// index of the first range starting after the address, the address can only be in the range before it
+	index := sort.Search(len(database.ranges), func(i int) bool {
+		return bytes.Compare(database.ranges[i].start, ip) > 0
+	})
+	if index == 0 || bytes.Compare(ip, database.ranges[index-1].end) > 0 {
+		return nil
+	}
+	return database.ranges[index-1].info
+}
+
+var asnDatabase *ASNDatabase
+
+var asnDatabaseOnce sync.Once
+
+// GetASNDatabase returns the ASNDatabase at ASN_DATABASE_PATH, loaded once. It is nil when the path is not set
+// or the database cannot be read, in which case the addresses are reported without their ASN
+func GetASNDatabase() *ASNDatabase {
+	asnDatabaseOnce.Do(func() {
+		path := os.Getenv("ASN_DATABASE_PATH")
+		if path == "" {
+			return
+		}
+		database, err := LoadASNDatabase(path)
+		if err != nil {
+			log.Println("Unable to load the ASN database at "+path, err)
+			return
+		}
+		asnDatabase = database
+	})
+	return asnDatabase
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestASNDatabaseLookup(t *testing.T) {
+	database, err := LoadASNDatabase("testdata/ip2asn.tsv")
+	assert.NoError(t, err)
+
+	asn := database.Lookup(net.ParseIP("93.184.216.34"))
+	assert.Equal(t, asn.Number, 15133)
+	assert.Equal(t, asn.Organization, "EDGECAST")
+	assert.Equal(t, asn.Country, "US")
+
+	assert.Equal(t, database.Lookup(net.ParseIP("151.101.255.255")).Organization, "FASTLY")
+	assert.Equal(t, database.Lookup(net.ParseIP("2606:2800:220:1::248")).Number, 15133)
+	assert.Equal(t, database.Lookup(net.ParseIP("1.0.0.1")).Organization, "CLOUDFLARENET")
+
+	// not routed ranges and addresses outside of every range have no ASN
+	assert.Nil(t, database.Lookup(net.ParseIP("1.0.2.1")))
+	assert.Nil(t, database.Lookup(net.ParseIP("10.0.0.1")))
+	assert.Nil(t, database.Lookup(net.ParseIP("2001:db8::1")))
+}
+
+func TestASNDatabaseUnavailable(t *testing.T) {
+	_, err := LoadASNDatabase("testdata/missing.tsv")
+	assert.Error(t, err)
+
+	_, err = ParseASNDatabase(strings.NewReader("1.0.0.0\tinvalid\t13335\tUS\tCLOUDFLARENET\n"))
+	assert.Error(t, err)
+
+	// a missing database degrades to addresses without an ASN
+	var database *ASNDatabase
+	assert.Nil(t, database.Lookup(net.ParseIP("93.184.216.34")))
+}
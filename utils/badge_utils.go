@@ -58,6 +58,16 @@ func GetSPFBadge() *models.Badge {
 	return createBadge(SPFBadge, SPFBadgeMessage, "EAVESDROPPING_SPOOFING_PROTECTION")
 }
 
+// GetCrossDomainPolicyBadge returns the Cross Domain Policy Badge
+func GetCrossDomainPolicyBadge() *models.Badge {
+	return createBadge(CrossDomainPolicyBadge, CrossDomainPolicyBadgeMessage, "CONTENT_SECURITY")
+}
+
+// GetCrossOriginIsolationBadge returns the Cross-Origin Isolation Badge
+func GetCrossOriginIsolationBadge() *models.Badge {
+	return createBadge(CrossOriginIsolationBadge, CrossOriginIsolationBadgeMessage, "CONTENT_SECURITY")
+}
+
 // GetRPBadge returns the Referrer Policy Badge
 func GetRPBadge() *models.Badge {
 	return createBadge(RPBadge, RPBadgeMessage, "USER_PRIVACY")
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// BodyReadTimeout is the time allowed to read a response body, a slower body is cut at what was received
+var BodyReadTimeout = 5 * time.Second
+
+// GetMaxBodyBytes returns the value of MAX_BODY_BYTES, falling back to DefaultMaxBodyBytes
+func GetMaxBodyBytes() int64 {
+	maxBodyBytes, err := strconv.ParseInt(os.Getenv("MAX_BODY_BYTES"), 10, 64)
+	if err != nil || maxBodyBytes < 1 {
+		return DefaultMaxBodyBytes
+	}
+	return maxBodyBytes
+}
+
+// ReadBody reads a response body up to maxBytes, bounded by MAX_BODY_BYTES as well, and within the BodyReadTimeout.
+// truncated is true when the body was cut at either limit, the prefix read until then is returned to be checked
+func ReadBody(body io.ReadCloser, maxBytes int64) (data []byte, truncated bool, err error) {
+	if maxBodyBytes := GetMaxBodyBytes(); maxBytes < 1 || maxBytes > maxBodyBytes {
+		maxBytes = maxBodyBytes
+	}
+	var buffer bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		// one more byte than allowed is read to tell a body of exactly maxBytes from a longer one
+		_, readErr := buffer.ReadFrom(io.LimitReader(body, maxBytes+1))
+		done <- readErr
+	}()
+	timer := time.NewTimer(BodyReadTimeout)
+	defer timer.Stop()
+	select {
+	case err = <-done:
+		if err != nil {
+			return nil, false, err
+		}
+	case <-timer.C:
+		// closing the body unblocks the pending read
+		body.Close()
+		<-done
+		truncated = true
+	}
+	data = buffer.Bytes()
+	if int64(len(data)) > maxBytes {
+		return data[:maxBytes], true, nil
+	}
+	return data, truncated, nil
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMaxBodyBytes(t *testing.T) {
+	defer os.Unsetenv("MAX_BODY_BYTES")
+	assert.Equal(t, GetMaxBodyBytes(), int64(DefaultMaxBodyBytes))
+	os.Setenv("MAX_BODY_BYTES", "1024")
+	assert.Equal(t, GetMaxBodyBytes(), int64(1024))
+	os.Setenv("MAX_BODY_BYTES", "invalid")
+	assert.Equal(t, GetMaxBodyBytes(), int64(DefaultMaxBodyBytes))
+}
+
+func TestReadBodyOversized(t *testing.T) {
+	defer os.Unsetenv("MAX_BODY_BYTES")
+	os.Setenv("MAX_BODY_BYTES", "1024")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("a", 4*DefaultMaxBodyBytes))
+	}))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	data, truncated, err := ReadBody(response.Body, 0)
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, len(data), 1024)
+
+	// the limit of the caller applies when it is below MAX_BODY_BYTES
+	data, truncated, err = ReadBody(io.NopCloser(strings.NewReader("0123456789")), 4)
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Equal(t, string(data), "0123")
+
+	data, truncated, err = ReadBody(io.NopCloser(strings.NewReader("0123")), 4)
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Equal(t, string(data), "0123")
+}
+
+func TestReadBodySlow(t *testing.T) {
+	original := BodyReadTimeout
+	BodyReadTimeout = 100 * time.Millisecond
+	defer func() { BodyReadTimeout = original }()
+	stop := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// trickles a byte every 20ms, well past the read deadline
+		for i := 0; i < 100; i++ {
+			select {
+			case <-stop:
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+			w.Write([]byte("a"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+	defer close(stop)
+
+	response, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	start := time.Now()
+	data, truncated, err := ReadBody(response.Body, 0)
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.True(t, time.Since(start) < time.Second)
+	assert.NotEmpty(t, data)
+	assert.True(t, bytes.Count(data, []byte("a")) == len(data))
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+	"os"
+)
+
+// clientCertTransport carries the requests to the hosts of clientCertHosts, presenting clientCert. It is routed like
+// baseTransport, but like pinnedTransport its connections are not kept alive, so that a connection is never reused
+// once the certificate changes
+var clientCertTransport = newClientCertTransport()
+
+// pinnedClientCertTransport carries the requests to the hosts of clientCertHosts sent with a context from
+// WithDialOverride, presenting clientCert
+var pinnedClientCertTransport = newPinnedTransport()
+
+func newClientCertTransport() *http.Transport {
+	transport := newHTTPTransport()
+	transport.DisableKeepAlives = true
+	return transport
+}
+
+var (
+	clientCert      *tls.Certificate
+	clientCertHosts []string
+)
+
+// LoadClientCertificate returns the client certificate of the PEM certificate and key files
+func LoadClientCertificate(certPath string, keyPath string) (*tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// GetClientCertificate returns the client certificate of CLIENT_CERT_PATH and CLIENT_KEY_PATH. It is nil when either
+// is not set or the certificate cannot be loaded, in which case no client certificate is presented
+func GetClientCertificate() *tls.Certificate {
+	certPath, keyPath := os.Getenv("CLIENT_CERT_PATH"), os.Getenv("CLIENT_KEY_PATH")
+	if certPath == "" || keyPath == "" {
+		return nil
+	}
+	cert, err := LoadClientCertificate(certPath, keyPath)
+	if err != nil {
+		log.Println("Unable to load the client certificate at "+certPath+", no client certificate is presented", err)
+		return nil
+	}
+	return cert
+}
+
+// GetClientCertHosts returns the hosts of CLIENT_CERT_HOSTS, the only ones the client certificate is presented to
+func GetClientCertHosts() []string {
+	return getDomainPatterns("CLIENT_CERT_HOSTS")
+}
+
+// SetClientCertificate makes the outbound requests and TLS Handshakes to the hosts present cert, a pattern like
+// *.example.com designating every subdomain of example.com. No certificate is presented when cert is nil
+func SetClientCertificate(cert *tls.Certificate, hosts []string) {
+	clientCert, clientCertHosts = cert, hosts
+	for _, transport := range []*http.Transport{clientCertTransport, pinnedClientCertTransport} {
+		config := &tls.Config{}
+		if transport.TLSClientConfig != nil {
+			config = transport.TLSClientConfig.Clone()
+		}
+		config.Certificates = nil
+		if cert != nil {
+			config.Certificates = []tls.Certificate{*cert}
+		}
+		transport.TLSClientConfig = config
+	}
+}
+
+// ClientCertificates returns the client certificate presented to host, none unless host is designated
+func ClientCertificates(host string) []tls.Certificate {
+	if clientCert == nil || !MatchesDomain(host, clientCertHosts) {
+		return nil
+	}
+	return []tls.Certificate{*clientCert}
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeClientCertificate writes a self-signed client certificate and its key to dir, and returns their paths
+func writeClientCertificate(t *testing.T, dir string) (string, string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "snift-scanner"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	certPath, keyPath := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certPath, keyPath
+}
+
+func TestSetClientCertificate(t *testing.T) {
+	defer allowLoopback()()
+	var presented []string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented = append(presented, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	SetTrustedRoots(roots)
+	defer SetTrustedRoots(nil)
+	certPath, keyPath := writeClientCertificate(t, t.TempDir())
+	cert, err := LoadClientCertificate(certPath, keyPath)
+	assert.NoError(t, err)
+
+	// the handshake fails without a client certificate, or when the host is not designated
+	for _, hosts := range [][]string{nil, {"*.example.com"}} {
+		SetClientCertificate(cert, hosts)
+		_, err = HTTPClient.Get(server.URL)
+		assert.Error(t, err)
+	}
+
+	// the test server is reached at 127.0.0.1
+	SetClientCertificate(cert, []string{"127.0.0.1"})
+	defer SetClientCertificate(nil, nil)
+	response, err := HTTPClient.Get(server.URL)
+	assert.NoError(t, err)
+	if err == nil {
+		response.Body.Close()
+	}
+	assert.Equal(t, presented, []string{"snift-scanner"})
+
+	// a designated host pinned to the test server
+	SetClientCertificate(cert, []string{"example.com"})
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	ctx := WithDialOverride(context.Background(), "example.com", net.ParseIP("127.0.0.1"))
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com:"+port, nil)
+	response, err = HTTPClient.Do(request)
+	assert.NoError(t, err)
+	if err == nil {
+		response.Body.Close()
+	}
+	assert.Equal(t, presented, []string{"snift-scanner", "snift-scanner"})
+
+	SetClientCertificate(cert, []string{"127.0.0.1"})
+	assert.Len(t, ClientCertificates("127.0.0.1"), 1)
+	assert.Nil(t, ClientCertificates("www.example.com"))
+
+	SetClientCertificate(nil, []string{"127.0.0.1"})
+	assert.Nil(t, ClientCertificates("127.0.0.1"))
+}
+
+func TestClientCertTransport(t *testing.T) {
+	certPath, keyPath := writeClientCertificate(t, t.TempDir())
+	cert, err := LoadClientCertificate(certPath, keyPath)
+	assert.NoError(t, err)
+	SetClientCertificate(cert, []string{"www.example.com"})
+	defer SetClientCertificate(nil, nil)
+
+	// the requests to a designated host are routed through the configured proxy
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer proxyServer.Close()
+	defer setProxyEnv(map[string]string{"HTTP_PROXY": proxyServer.URL})()
+	resp, err := HTTPClient.Get("http://www.example.com/path")
+	assert.NoError(t, err)
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, resp.StatusCode, http.StatusTeapot)
+	}
+
+	// and follow the configured timeouts
+	defer mockTimeouts(Timeouts{DNS: time.Minute, Connect: time.Minute, TLSHandshake: 50 * time.Millisecond, Request: time.Minute})()
+	assert.Equal(t, clientCertTransport.TLSHandshakeTimeout, 50*time.Millisecond)
+	assert.Equal(t, pinnedClientCertTransport.TLSHandshakeTimeout, 50*time.Millisecond)
+}
+
+func TestGetClientCertificate(t *testing.T) {
+	assert.Nil(t, GetClientCertificate())
+	dir := t.TempDir()
+	certPath, keyPath := writeClientCertificate(t, dir)
+	os.Setenv("CLIENT_CERT_PATH", certPath)
+	defer os.Unsetenv("CLIENT_CERT_PATH")
+	assert.Nil(t, GetClientCertificate())
+	os.Setenv("CLIENT_KEY_PATH", keyPath)
+	defer os.Unsetenv("CLIENT_KEY_PATH")
+	assert.NotNil(t, GetClientCertificate())
+	os.Setenv("CLIENT_KEY_PATH", filepath.Join(dir, "missing.pem"))
+	assert.Nil(t, GetClientCertificate())
+}
+
+func TestGetClientCertHosts(t *testing.T) {
+	assert.Empty(t, GetClientCertHosts())
+	os.Setenv("CLIENT_CERT_HOSTS", "Internal.Example.com, *.mtls.example.org")
+	defer os.Unsetenv("CLIENT_CERT_HOSTS")
+	assert.Equal(t, GetClientCertHosts(), []string{"internal.example.com", "*.mtls.example.org"})
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// getAcceptedEncoding returns the compression accepted by the client, gzip being preferred over deflate,
+// or an empty string when the client accepts neither
+func getAcceptedEncoding(acceptEncoding string) string {
+	accepted := make(map[string]bool)
+	for _, value := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+		quality := 1.0
+		if name, q, found := strings.Cut(strings.TrimSpace(params), "="); found && strings.TrimSpace(name) == "q" {
+			quality, _ = strconv.ParseFloat(strings.TrimSpace(q), 64)
+		}
+		accepted[strings.ToLower(coding)] = quality > 0
+	}
+	for _, encoding := range []string{"gzip", "deflate"} {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// compressWriter buffers the start of a response until it is known to be a JSON body of at least minSize bytes,
+// which is then compressed, anything else is written as it is
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	status   int
+	buffer   []byte
+	decided  bool
+	writer   io.WriteCloser
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buffer = append(w.buffer, p...)
+		if len(w.buffer) < w.minSize {
+			return len(p), nil
+		}
+		return len(p), w.decide()
+	}
+	if w.writer != nil {
+		return w.writer.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide writes the headers and the buffered body, compressing them when the body is large enough
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.Header()
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		header.Add("Vary", "Accept-Encoding")
+		if len(w.buffer) >= w.minSize && header.Get("Content-Encoding") == "" {
+			header.Del("Content-Length")
+			header.Set("Content-Encoding", w.encoding)
+			if w.encoding == "gzip" {
+				w.writer = gzip.NewWriter(w.ResponseWriter)
+			} else {
+				w.writer = zlib.NewWriter(w.ResponseWriter)
+			}
+		}
+	}
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buffer := w.buffer
+	w.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	_, err := w.Write(buffer)
+	return err
+}
+
+// Flush sends what was written so far, so that streamed responses are not held back by the buffer
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide()
+	}
+	if flusher, ok := w.writer.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) close() error {
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			return err
+		}
+	}
+	if w.writer != nil {
+		return w.writer.Close()
+	}
+	return nil
+}
+
+// Compress compresses the JSON responses of at least CompressionMinSize bytes with the gzip or deflate
+// encoding accepted by the client
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := getAcceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		writer := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: CompressionMinSize}
+		next.ServeHTTP(writer, r)
+		if err := writer.close(); err != nil {
+			log.Print("Error Occured while compressing the response: ", err)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func jsonHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	})
+}
+
+func TestGetAcceptedEncoding(t *testing.T) {
+	assert.Equal(t, getAcceptedEncoding(""), "")
+	assert.Equal(t, getAcceptedEncoding("gzip, deflate, br"), "gzip")
+	assert.Equal(t, getAcceptedEncoding("deflate"), "deflate")
+	assert.Equal(t, getAcceptedEncoding("GZIP;q=0.5"), "gzip")
+	assert.Equal(t, getAcceptedEncoding("gzip;q=0, deflate"), "deflate")
+	assert.Equal(t, getAcceptedEncoding("br, identity"), "")
+}
+
+func TestCompressGzip(t *testing.T) {
+	body := `{"checks":[` + strings.Repeat(`{"name":"Content-Security-Policy","score":5,"max_score":5},`, 50) + `{}]}`
+	req, _ := http.NewRequest("GET", "/scores", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rr := httptest.NewRecorder()
+	Compress(jsonHandler(body)).ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Code, http.StatusCreated)
+	assert.Equal(t, rr.Header().Get("Content-Encoding"), "gzip")
+	assert.Equal(t, rr.Header().Get("Vary"), "Accept-Encoding")
+	assert.Empty(t, rr.Header().Get("Content-Length"))
+	assert.True(t, rr.Body.Len() < len(body))
+	reader, err := gzip.NewReader(rr.Body)
+	assert.NoError(t, err)
+	decompressed, _ := io.ReadAll(reader)
+	assert.Equal(t, string(decompressed), body)
+}
+
+func TestCompressDeflate(t *testing.T) {
+	body := `{"incidents":"` + strings.Repeat("a", 2*CompressionMinSize) + `"}`
+	req, _ := http.NewRequest("GET", "/scores", nil)
+	req.Header.Set("Accept-Encoding", "deflate")
+	rr := httptest.NewRecorder()
+	Compress(jsonHandler(body)).ServeHTTP(rr, req)
+
+	assert.Equal(t, rr.Header().Get("Content-Encoding"), "deflate")
+	reader, err := zlib.NewReader(rr.Body)
+	assert.NoError(t, err)
+	decompressed, _ := io.ReadAll(reader)
+	assert.Equal(t, string(decompressed), body)
+}
+
+func TestCompressSkipped(t *testing.T) {
+	large := `{"checks":"` + strings.Repeat("a", 2*CompressionMinSize) + `"}`
+
+	// small bodies are sent as they are
+	req, _ := http.NewRequest("GET", "/version", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	Compress(jsonHandler(`{"version":"dev"}`)).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusCreated)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, rr.Body.String(), `{"version":"dev"}`)
+
+	// clients not accepting a compression get the body as it is
+	req.Header.Del("Accept-Encoding")
+	rr = httptest.NewRecorder()
+	Compress(jsonHandler(large)).ServeHTTP(rr, req)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, rr.Body.String(), large)
+
+	// only JSON is compressed
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr = httptest.NewRecorder()
+	Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte(large))
+	})).ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, rr.Body.String(), large)
+}
+
+func TestCompressStream(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/scores/stream", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		assert.True(t, ok)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: check\n\n"))
+		flusher.Flush()
+		// the event is sent before the handler returns
+		assert.Equal(t, rr.Body.String(), "event: check\n\n")
+		assert.True(t, rr.Flushed)
+	})).ServeHTTP(rr, req)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+}
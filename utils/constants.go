@@ -1,38 +1,268 @@
 package utils
 
+import "time"
+
 // Holds the list of Badges and Messages
 const (
-	HTTPSBadge                   = "HTTP_SECURE"
-	HTTPSBadgeMessage            = "Encrypted HTTPS Connection"
-	HTTPSBadgeDescription        = "This site is encrypted and is less prone to Man-in-the-Middle attacks(MITM) and Eavesdropping Attacks"
-	XSSBadge                     = "XSS_PROTECT"
-	XSSBadgeMessage              = "Prevention from reflected Cross-Site Scripting (XSS) Attacks"
-	XSSBadgeDescription          = "This site is less prone to from reflected cross-site scripting (XSS) attacks"
-	XFrameBadge                  = "CLICKJACKING_PROTECT"
-	XFrameBadgeMessage           = "Protection from Cross-Site Click Jacking Attacks"
-	XFrameBadgeDescription       = "The content from this site cannot be embedded into other sites and is protected from cross-site Clickjacking"
-	HSTSBadge                    = "HTTPS_ONLY"
-	HSTSBadgeMessage             = "Enforces HTTPS-Only Site Access"
-	HSTSBadgeDescription         = "This site can only be accessed via HTTPS"
-	CSPBadge                     = "CSP_ENABLED"
-	CSPBadgeMessage              = "Protection against Cross Site Scripting (XSS), Data Injection and Packet Sniffing attacks"
-	CSPBadgeDescription          = "This site has is relatively secure against Cross Site Scripting (XSS), Data Injection and Packet Sniffing attacks"
-	HPKPBadge                    = "PUBLIC_KEY_PINNING_ENABLED"
-	HPKPBadgeMessage             = "Prevention against Man-in-the-Middle attacks(MITM) using forged certificates"
-	HPKPBadgeDescription         = "This site has a decreased risk of Man-in-the-Middle attacks(MITM) with forged certificates"
-	RPBadge                      = "ENSURE_PRIVACY"
-	RPBadgeMessage               = "Enforces a Referrer Policy to avoid leaking sensitive user information from being shared."
-	RPBadgeDescription           = "This site has a Referrer Policy that may help protect user privacy"
-	XContentTypeBadge            = "NO_SNIFF"
-	XContentTypeBadgeMessage     = "Prevention from media-type (MIME) sniffing"
-	XContentTypeBadgeDescription = "This site prevents the browser from media type (MIME) sniffing"
-	HTTPVersionBadge             = "LATEST_HTTP"
-	HTTPVersionBadgeMessage      = "Uses the latest version of the HTTP Protocol"
-	HTTPVersionBadgeDescription  = "This site uses the latest HyperText Transfer Protocol(HTTP) supporting better performance and security standards"
-	TLSVersionBadge              = "LATEST_TLS"
-	TLSVersionBadgeMessage       = "Uses the latest version of the TLS Protocol"
-	TLSVersionBadgeDescription   = "This site uses the latest Transport Layer Security(TLS) supporting better performance and security standards"
-	SPFBadge                     = "EMAIL_SPOOFING_PROTECT"
-	SPFBadgeMessage              = "Prevention from Email Spoofing by having a valid Sender Policy Framework Record"
-	SPFBadgeDescription          = "This site has a valid Sender Policy Framework(SPF) record that reduces the risk of forged emails being sent on behalf of this domain"
+	HTTPSBadge                           = "HTTP_SECURE"
+	HTTPSBadgeMessage                    = "Encrypted HTTPS Connection"
+	HTTPSBadgeDescription                = "This site is encrypted and is less prone to Man-in-the-Middle attacks(MITM) and Eavesdropping Attacks"
+	XSSBadge                             = "XSS_PROTECT"
+	XSSBadgeMessage                      = "Prevention from reflected Cross-Site Scripting (XSS) Attacks"
+	XSSBadgeDescription                  = "This site is less prone to from reflected cross-site scripting (XSS) attacks"
+	XFrameBadge                          = "CLICKJACKING_PROTECT"
+	XFrameBadgeMessage                   = "Protection from Cross-Site Click Jacking Attacks"
+	XFrameBadgeDescription               = "The content from this site cannot be embedded into other sites and is protected from cross-site Clickjacking"
+	HSTSBadge                            = "HTTPS_ONLY"
+	HSTSBadgeMessage                     = "Enforces HTTPS-Only Site Access"
+	HSTSBadgeDescription                 = "This site can only be accessed via HTTPS"
+	CSPBadge                             = "CSP_ENABLED"
+	CSPBadgeMessage                      = "Protection against Cross Site Scripting (XSS), Data Injection and Packet Sniffing attacks"
+	CSPBadgeDescription                  = "This site has is relatively secure against Cross Site Scripting (XSS), Data Injection and Packet Sniffing attacks"
+	HPKPBadge                            = "PUBLIC_KEY_PINNING_ENABLED"
+	HPKPBadgeMessage                     = "Prevention against Man-in-the-Middle attacks(MITM) using forged certificates"
+	HPKPBadgeDescription                 = "This site has a decreased risk of Man-in-the-Middle attacks(MITM) with forged certificates"
+	RPBadge                              = "ENSURE_PRIVACY"
+	RPBadgeMessage                       = "Enforces a Referrer Policy to avoid leaking sensitive user information from being shared."
+	RPBadgeDescription                   = "This site has a Referrer Policy that may help protect user privacy"
+	XContentTypeBadge                    = "NO_SNIFF"
+	XContentTypeBadgeMessage             = "Prevention from media-type (MIME) sniffing"
+	XContentTypeBadgeDescription         = "This site prevents the browser from media type (MIME) sniffing"
+	HTTPVersionBadge                     = "LATEST_HTTP"
+	HTTPVersionBadgeMessage              = "Uses the latest version of the HTTP Protocol"
+	HTTPVersionBadgeDescription          = "This site uses the latest HyperText Transfer Protocol(HTTP) supporting better performance and security standards"
+	TLSVersionBadge                      = "LATEST_TLS"
+	TLSVersionBadgeMessage               = "Uses the latest version of the TLS Protocol"
+	TLSVersionBadgeDescription           = "This site uses the latest Transport Layer Security(TLS) supporting better performance and security standards"
+	SPFBadge                             = "EMAIL_SPOOFING_PROTECT"
+	SPFBadgeMessage                      = "Prevention from Email Spoofing by having a valid Sender Policy Framework Record"
+	SPFBadgeDescription                  = "This site has a valid Sender Policy Framework(SPF) record that reduces the risk of forged emails being sent on behalf of this domain"
+	CrossDomainPolicyBadge               = "CROSS_DOMAIN_POLICY_RESTRICTED"
+	CrossDomainPolicyBadgeMessage        = "Prevention from loading data through Adobe cross-domain policy files"
+	CrossDomainPolicyBadgeDescription    = "This site does not allow Adobe Flash and PDF clients to load its data from other domains"
+	CrossOriginIsolationBadge            = "CROSS_ORIGIN_ISOLATED"
+	CrossOriginIsolationBadgeMessage     = "Protection from cross-origin data leaks such as Spectre"
+	CrossOriginIsolationBadgeDescription = "This site isolates its documents from cross-origin windows and resources, reducing the risk of side-channel attacks"
+)
+
+// Holds the messages reported for individual checks
+const (
+	HSTSOverHTTPMessage          = "Strict-Transport-Security is ignored by browsers when it is not delivered over HTTPS"
+	HSTSShortMaxAgeMessage       = "Strict-Transport-Security max-age of %d seconds is shorter than %d seconds, set a longer max-age such as 31536000 (1 year)"
+	XSSModernGuidanceMessage     = "Modern browsers no longer ship an XSS filter, X-XSS-Protection: 0 is recommended when a strong Content-Security-Policy is in place"
+	SPFPermissiveAllMessage      = "SPF record ends with +all and permits any server to send mail for %s"
+	SPFMissingAllMessage         = "SPF record for %s has no all mechanism and defaults to neutral"
+	SPFLookupLimitMessage        = "SPF record for %s requires more than %d DNS lookups"
+	SPFSyntaxErrorMessage        = "SPF record for %s contains an invalid term %q"
+	SPFMissingIncludeMessage     = "SPF record for %s references %s which has no SPF record"
+	SPFMultipleRecordsMessage    = "%s publishes %d SPF records, only a single SPF record is allowed"
+	SPFIncludeLoopMessage        = "SPF record for %s references %s in a loop"
+	SensitivePathExposedMessage  = "%s responded with status %d"
+	SensitivePathsTimeoutMessage = "Sensitive Paths check timed out before %s was checked"
+	BodyTruncatedMessage         = "Only the first %d bytes of %s were checked"
+	XFrameAllowFromMessage       = "X-Frame-Options: ALLOW-FROM is deprecated and ignored by modern browsers, use Content-Security-Policy: frame-ancestors instead"
+	CacheControlPublicMessage    = "Cache-Control declares the response as public, shared caches such as proxies may store it"
+	ServerGenericMessage         = "Server Header identifies the web server as %q, without its version"
+	ServerVersionMessage         = "Server Header reveals the version of the web server: %q"
+	DKIMKeyFoundMessage          = "DKIM key published for selector %s"
+	DKIMKeyRevokedMessage        = "DKIM key for selector %s is revoked"
+	DKIMKeyNotFoundMessage       = "No DKIM key found for the selectors %s"
+	ConflictingHeaderMessage     = "%s is sent %d times with conflicting values: %s"
+	ReflectedHeaderMessage       = "%s reflects a value sent in the request, which may allow injecting the header"
+	PKPDeprecatedMessage         = "Public-Key-Pins is deprecated and ignored by browsers, while a wrong pin can lock visitors out, remove it"
+	TLSCompressionMessage        = "TLS compression is enabled, which exposes the connections to CRIME"
+	InsecureRenegotiationMessage = "Secure renegotiation (RFC 5746) is not supported, the server may allow insecure renegotiation"
+	TLSHelloInconclusiveMessage  = "The server did not answer a TLS 1.2 ClientHello, TLS compression and renegotiation could not be checked"
+	ReportingUnusedMessage       = "Reporting endpoints are declared, but no Content-Security-Policy report-to directive reports to them"
+	CORSReflectedMessage         = "Access-Control-Allow-Origin reflects any Origin sent in the request, every site can read the responses"
+	CORSNullOriginMessage        = "Access-Control-Allow-Origin allows the null origin, which sandboxed iframes of any site can send"
+	CORSCredentialsMessage       = "Access-Control-Allow-Credentials: true lets any site read the responses on behalf of the logged-in visitors"
+	CORSWildcardMessage          = "Access-Control-Allow-Origin: * is sent along with Access-Control-Allow-Credentials: true, browsers reject it but credentialed responses were meant to be shared with any origin"
+	TransportNoHSTSMessage       = "HTTP redirects to HTTPS without Strict-Transport-Security, the first request of every visit can still be intercepted"
+	TransportNoRedirectMessage   = "Strict-Transport-Security is sent, but HTTP does not redirect to HTTPS, visitors reaching the site over HTTP stay on it"
+	TransportPlainHTTPMessage    = "HTTP neither redirects to HTTPS nor is it followed by Strict-Transport-Security"
+	TransportInconclusiveMessage = "The HTTP site could not be checked for a redirect to HTTPS"
+	ReportingDanglingMessage     = "Content-Security-Policy report-to references the endpoint %q, which no Reporting-Endpoints or Report-To Header declares"
+	CertValidityMessage          = "The certificate is valid for %d days, longer than the %d days allowed by the CA/Browser Forum"
+	ClearSiteDataMessage         = "Clears %s"
+)
+
+// Holds the remediation reported for failing checks
+const (
+	ProtocolRemediation                = "Serve the site over HTTPS with a certificate from a trusted Certificate Authority, e.g. Let's Encrypt"
+	XSSRemediation                     = "Add the header X-XSS-Protection: 1; mode=block, or X-XSS-Protection: 0 along with a strong Content-Security-Policy"
+	XFrameRemediation                  = "Add the header Content-Security-Policy: frame-ancestors 'none', or frame-ancestors 'self' if the site frames its own pages, along with X-Frame-Options: DENY or SAMEORIGIN for older browsers"
+	HSTSRemediation                    = "Add the header Strict-Transport-Security: max-age=31536000; includeSubDomains; preload over HTTPS"
+	CSPRemediation                     = "Add a Content-Security-Policy header, starting from Content-Security-Policy: default-src 'self'"
+	PKPRemediation                     = "Public-Key-Pins is deprecated, remove it and monitor issued certificates through Certificate Transparency instead"
+	RPRemediation                      = "Add the header Referrer-Policy: strict-origin-when-cross-origin, or Referrer-Policy: no-referrer"
+	XContentTypeRemediation            = "Add the header X-Content-Type-Options: nosniff"
+	CrossDomainPolicyRemediation       = "Add the header X-Permitted-Cross-Domain-Policies: none"
+	CrossOriginIsolationRemediation    = "Add the headers Cross-Origin-Opener-Policy: same-origin, Cross-Origin-Embedder-Policy: require-corp and Cross-Origin-Resource-Policy: same-origin"
+	ClearSiteDataRemediation           = "Send the header Clear-Site-Data: \"cache\", \"cookies\", \"storage\" from the logout endpoint"
+	HTTPVersionRemediation             = "Enable HTTP/2 on the web server"
+	TLSVersionRemediation              = "Enable TLS 1.2 or later on the web server and disable older protocol versions"
+	OCSPStaplingRemediation            = "Enable OCSP stapling on the web server, e.g. ssl_stapling on; in nginx or SSLUseStapling On in Apache"
+	CertificateTransparencyRemediation = "Use a certificate from a Certificate Authority that logs it to Certificate Transparency and embeds the SCTs, as every public CA does"
+	CertificateValidityRemediation     = "Replace the certificate with one valid for at most 398 days, and automate its renewal, e.g. with ACME"
+	SPFRemediation                     = "Publish a single TXT record such as v=spf1 include:<mail provider> -all within 10 DNS lookups"
+	DMARCRemediation                   = "Publish a TXT record at _dmarc.<domain> such as v=DMARC1; p=reject; rua=mailto:<report address>"
+	DKIMRemediation                    = "Sign outgoing mail with DKIM and publish the public key at <selector>._domainkey.<domain>"
+	BIMIRemediation                    = "Enforce DMARC with p=quarantine or p=reject, then publish a TXT record at default._bimi.<domain> such as v=BIMI1; l=https://<logo>.svg"
+	PreviousVulnerabilitiesRemediation = "Fix the security incidents reported on openbugbounty.org within 30 days of disclosure"
+	SecurityTxtRemediation             = "Publish /.well-known/security.txt with at least a Contact: field, as described in RFC 9116"
+	SensitivePathsRemediation          = "Block public access to version control metadata, environment files, backups and admin pages on the web server"
+	CacheControlRemediation            = "Send Cache-Control: no-store on responses containing sensitive data, so that they are not kept by shared caches"
+	ServerRemediation                  = "Remove the Server Header, or at least its version, e.g. server_tokens off in nginx or ServerTokens Prod in Apache"
+	TLSRenegotiationRemediation        = "Disable TLS compression and upgrade the TLS library of the web server to one supporting secure renegotiation (RFC 5746)"
+	SessionResumptionRemediation       = "Enable TLS session tickets on the web server, e.g. ssl_session_tickets on; in nginx or SSLSessionTickets on in Apache"
+	TransportSecurityRemediation       = "Redirect every HTTP request to HTTPS with a 301, and add Strict-Transport-Security to the HTTPS responses"
+	CORSRemediation                    = "Allow only the trusted origins through Access-Control-Allow-Origin, checked against an allowlist rather than reflected, and never the null origin"
+	ReportingRemediation               = "Declare an endpoint with Reporting-Endpoints: csp-endpoint=\"https://<report collector>\" and add report-to csp-endpoint to the Content-Security-Policy"
 )
+
+// Holds the descriptions of the checks listed in the catalog of checks
+const (
+	ProtocolDescription                = "Whether the site is served over HTTPS"
+	XSSDescription                     = "X-XSS-Protection Header configuring the legacy XSS filter of browsers"
+	XFrameDescription                  = "X-Frame-Options Header or Content-Security-Policy frame-ancestors protecting against Clickjacking"
+	HSTSDescription                    = "Strict-Transport-Security Header enforcing HTTPS, with the full score when eligible for the preload list"
+	CSPDescription                     = "Content-Security-Policy Header restricting the sources of the content of the site"
+	PKPDescription                     = "Deprecated Public-Key-Pins Header, informational as browsers no longer enforce it"
+	RPDescription                      = "Referrer-Policy Header limiting the information sent in the Referer Header"
+	XContentTypeDescription            = "X-Content-Type-Options Header preventing MIME sniffing"
+	CrossDomainPolicyDescription       = "X-Permitted-Cross-Domain-Policies Header restricting Adobe cross-domain policy files"
+	CrossOriginIsolationDescription    = "Cross-Origin-Opener-Policy, Cross-Origin-Embedder-Policy and Cross-Origin-Resource-Policy Headers isolating the site"
+	ClearSiteDataDescription           = "Clear-Site-Data Header clearing cookies, storage and cache, informational"
+	CacheControlDescription            = "Cache-Control Header keeping responses out of shared caches, informational"
+	ServerDescription                  = "Server Header not revealing the software and version of the web server to fingerprinting, informational"
+	HTTPVersionDescription             = "Version of the HTTP Protocol used by the site"
+	TLSVersionDescription              = "Highest version of the TLS Protocol supported by the site"
+	OCSPStaplingDescription            = "OCSP response stapled to the TLS Handshake for faster and more private revocation checks"
+	CertificateTransparencyDescription = "Signed Certificate Timestamps proving the certificate was logged to Certificate Transparency"
+	CertificateValidityDescription     = "Total validity of the certificate, within the maximum allowed by the CA/Browser Forum"
+	TLSRenegotiationDescription        = "TLS compression (CRIME) and insecure renegotiation offered by the server, inconclusive when it does not answer a TLS 1.2 ClientHello"
+	SessionResumptionDescription       = "TLS session resumption sparing returning clients a full Handshake, informational"
+	TransportSecurityDescription       = "HTTP to HTTPS redirect paired with Strict-Transport-Security, the HSTS Header alone being scored by its own check"
+	CORSDescription                    = "Access-Control-Allow-Origin Header not sharing the responses with any origin, along with credentials"
+	ReportingDescription               = "Reporting-Endpoints or Report-To Header declaring the endpoint the Content-Security-Policy report-to directive reports to, informational"
+	SecurityTxtDescription             = "security.txt file listing a security contact (RFC 9116)"
+	SensitivePathsDescription          = "Exposure of version control metadata, environment files, backups and admin pages"
+	SPFDescription                     = "Sender Policy Framework record of the domain, unscored when the domain has none"
+	DMARCDescription                   = "DMARC record of the domain"
+	DKIMDescription                    = "DKIM public key of the domain, unscored when none is found for the common selectors"
+	BIMIDescription                    = "BIMI record of the domain along with an enforced DMARC policy, informational"
+	PreviousVulnerabilitiesDescription = "Response time to the incidents reported on openbugbounty.org, weighted by their severity"
+)
+
+// VerificationRecordPrefix starts the TXT record proving the control of a domain, followed by its verification token
+const VerificationRecordPrefix = "snift-verify="
+
+// MaxURLLength is the longest URL accepted for a scan, and MaxHostLength the longest host, as limited by DNS
+const (
+	MaxURLLength  = 2048
+	MaxHostLength = 253
+)
+
+// Limits of a file of URLs uploaded for a batch scan, the lines of a CSV file may hold more columns than the URL.
+// The upload request may exceed the size of the file by UploadFormOverhead for the multipart encoding
+const (
+	MaxUploadSize       = 1 << 20
+	UploadFormOverhead  = 64 << 10
+	MaxUploadLineLength = 4096
+	MaxUploadURLs       = 500
+)
+
+// UploadScanConcurrency is the number of URLs of an uploaded file scanned at the same time
+const UploadScanConcurrency = 4
+
+// JobRetention is the time a completed scan job can still be polled
+const JobRetention = 24 * time.Hour
+
+// MaxStoredResultsPerDomain and MaxStoredResults bound the scan results kept by a MemoryResultStore, the oldest
+// results are dropped first
+const (
+	MaxStoredResultsPerDomain = 100
+	MaxStoredResults          = 10000
+)
+
+// CompressionMinSize is the size from which JSON responses are compressed, smaller ones gain too little to be worth it
+const CompressionMinSize = 1024
+
+// ScoringProfileHeader selects the scoring profile of a scan when the request does not name one
+const ScoringProfileHeader = "X-Scoring-Profile"
+
+// NDJSONContentType streams the checks of a scan as newline delimited JSON
+const NDJSONContentType = "application/x-ndjson"
+
+// MinScoreHeader sets the minimum percentage of a scan when the request does not set one
+const MinScoreHeader = "X-Min-Score"
+
+// IdempotencyKeyHeader identifies a scan request, a request repeating its key is answered with the outcome of the
+// first one, as flagged by IdempotentReplayedHeader
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	MaxIdempotencyKeyLength  = 255
+)
+
+// DefaultIdempotencyTTL is the time a request can be repeated with the same key when IDEMPOTENCY_TTL is not set
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// AcceptVersionHeader requests an older schema version of the scores response
+const AcceptVersionHeader = "Accept-Version"
+
+// DefaultRedactedHeaders are the headers whose values are redacted from the raw headers of a response when
+// REDACTED_HEADERS is not set, as they may carry session cookies or authentication details
+var DefaultRedactedHeaders = []string{"Set-Cookie", "Www-Authenticate", "Proxy-Authenticate"}
+
+// RedactedValue replaces the value of a redacted header
+const RedactedValue = "[REDACTED]"
+
+// DefaultLanguage is the language of the messages, remediations and descriptions of the constants
+const DefaultLanguage = "en"
+
+// DefaultI18nDirectory holds the message catalogs, named after their language, when I18N_DIR is not set
+const DefaultI18nDirectory = "resources/i18n"
+
+// DefaultMaxCertValidityDays is the longest total validity of a certificate when MAX_CERT_VALIDITY_DAYS is not set, the
+// 398 days allowed by the CA/Browser Forum Baseline Requirements
+const DefaultMaxCertValidityDays = 398
+
+// DefaultHSTSMinMaxAge is the max-age (1 year) below which the HSTS check loses points when HSTS_MIN_MAX_AGE is not set
+const DefaultHSTSMinMaxAge = 31536000
+
+// DefaultHTTPSGradeCap is the best grade of a site not served over HTTPS when HTTPS_GRADE_CAP is not set
+const DefaultHTTPSGradeCap = "D"
+
+// DefaultScheme is assumed for a URL submitted without scheme
+const DefaultScheme = "https"
+
+// DefaultUserAgent identifies the scanner in outbound requests, followed by the version
+const DefaultUserAgent = "SniftScanner"
+
+// ProjectURL is referenced in the default User-Agent so scanned sites can find out about the scanner
+const ProjectURL = "https://github.com/maruthi-adithya/snift-backend"
+
+// DefaultMaxConcurrentScans is the number of concurrent scans allowed when MAX_CONCURRENT_SCANS is not set
+const DefaultMaxConcurrentScans = 10
+
+// ScanQueueTimeout is the time a scan waits for a free slot before it is rejected
+const ScanQueueTimeout = 5 * time.Second
+
+// DefaultMaxRedirects is the number of redirects followed from a scanned URL when MAX_REDIRECTS is not set
+const DefaultMaxRedirects = 10
+
+// DefaultMaxBodyBytes is the number of bytes read from a response body when MAX_BODY_BYTES is not set
+const DefaultMaxBodyBytes = 2 << 20
+
+// Default timeouts of the stages of the connections to a scanned site, see Timeouts
+const (
+	DefaultDNSTimeout          = 3 * time.Second
+	DefaultConnectTimeout      = 3 * time.Second
+	DefaultTLSHandshakeTimeout = 5 * time.Second
+	DefaultRequestTimeout      = 15 * time.Second
+)
+
+// RequestTimeoutSeconds is the total time allowed for an outbound third-party API request
+const RequestTimeoutSeconds = 5
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"strings"
+)
+
+type dialOverrideKey struct{}
+
+// dialOverride pins the connections to a host to a pre-resolved IP
+type dialOverride struct {
+	host string
+	ip   net.IP
+}
+
+// WithDialOverride returns a context in which the connections to host are opened to ip instead of its resolved
+// addresses, the host is still sent through SNI and the Host header. The connections to other hosts are left untouched
+func WithDialOverride(ctx context.Context, host string, ip net.IP) context.Context {
+	return context.WithValue(ctx, dialOverrideKey{}, &dialOverride{host: host, ip: ip})
+}
+
+// GetDialOverride returns the IP the connections to host are pinned to in ctx, nil when they are not pinned
+func GetDialOverride(ctx context.Context, host string) net.IP {
+	override, ok := ctx.Value(dialOverrideKey{}).(*dialOverride)
+	if !ok || !strings.EqualFold(override.host, strings.Trim(host, "[]")) {
+		return nil
+	}
+	return override.ip
+}
+
+// hasDialOverride returns true when ctx pins the connections to a host
+func hasDialOverride(ctx context.Context) bool {
+	_, ok := ctx.Value(dialOverrideKey{}).(*dialOverride)
+	return ok
+}
+
+// overrideAddress returns address with its host replaced by the IP it is pinned to, ok is false when it is not pinned
+func overrideAddress(ctx context.Context, address string) (string, bool) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, false
+	}
+	ip := GetDialOverride(ctx, host)
+	if ip == nil {
+		return address, false
+	}
+	return net.JoinHostPort(ip.String(), port), true
+}
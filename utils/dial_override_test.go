@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialOverride(t *testing.T) {
+	var serverName, host string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverName, host = r.TLS.ServerName, r.Host
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// the test server certificate is valid for example.com, which does not resolve to the server
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	original := pinnedTransport
+	pinnedTransport = newPinnedTransport()
+	pinnedTransport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	defer func() { pinnedTransport = original }()
+
+	defer allowLoopback()()
+	ctx := WithDialOverride(context.Background(), "example.com", net.ParseIP("127.0.0.1"))
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://example.com:"+port, nil)
+	response, err := (&http.Client{Transport: HTTPTransport}).Do(request)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, serverName, "example.com")
+	assert.Equal(t, host, "example.com:"+port)
+
+	// only the pinned host is dialed at the IP
+	assert.Equal(t, GetDialOverride(ctx, "EXAMPLE.com").String(), "127.0.0.1")
+	assert.Nil(t, GetDialOverride(ctx, "www.example.com"))
+	assert.Nil(t, GetDialOverride(context.Background(), "example.com"))
+}
+
+func TestValidateTargetDialOverride(t *testing.T) {
+	original := lookupTargetIPAddr
+	defer func() { lookupTargetIPAddr = original }()
+	lookupTargetIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+	}
+
+	// a pinned host is validated against the IP it is pinned to rather than its resolved addresses
+	ctx := WithDialOverride(context.Background(), "www.example.com", net.ParseIP("10.0.0.5"))
+	assert.True(t, errors.Is(ValidateTarget(ctx, "www.example.com"), ErrPrivateTarget))
+	assert.NoError(t, ValidateTarget(ctx, "shop.example.com"))
+	ctx = WithDialOverride(context.Background(), "www.example.com", net.ParseIP("93.184.216.35"))
+	assert.NoError(t, ValidateTarget(ctx, "www.example.com"))
+}
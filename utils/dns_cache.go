@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+)
+
+// DNSCache deduplicates the DNS lookups of a single scan, keyed by the record type and name.
+// Failed lookups are cached as well, so that a failing name is not queried again during the scan
+type DNSCache struct {
+	mutex   sync.Mutex
+	entries map[string]*dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	once    sync.Once
+	records interface{}
+	err     error
+}
+
+// NewDNSCache returns an empty DNSCache, it is meant to be discarded at the end of the scan
+func NewDNSCache() *DNSCache {
+	return &DNSCache{entries: make(map[string]*dnsCacheEntry)}
+}
+
+type dnsCacheKey struct{}
+
+// WithDNSCache returns a context carrying the DNSCache of a scan, so that concurrent scans never share their records
+func WithDNSCache(ctx context.Context, cache *DNSCache) context.Context {
+	return context.WithValue(ctx, dnsCacheKey{}, cache)
+}
+
+// GetDNSCache returns the DNSCache carried by ctx, nil when it carries none
+func GetDNSCache(ctx context.Context) *DNSCache {
+	cache, _ := ctx.Value(dnsCacheKey{}).(*DNSCache)
+	return cache
+}
+
+// lookup runs the lookup once per key, concurrent callers of the same key wait for its result
+func (cache *DNSCache) lookup(recordType string, name string, lookup func() (interface{}, error)) (interface{}, error) {
+	key := recordType + " " + strings.TrimSuffix(strings.ToLower(name), ".")
+	cache.mutex.Lock()
+	entry, ok := cache.entries[key]
+	if !ok {
+		entry = &dnsCacheEntry{}
+		cache.entries[key] = entry
+	}
+	cache.mutex.Unlock()
+	entry.once.Do(func() {
+		entry.records, entry.err = lookup()
+	})
+	return entry.records, entry.err
+}
+
+// LookupTXT returns the TXT records of a domain through the cache, a nil DNSCache always queries
+func (cache *DNSCache) LookupTXT(ctx context.Context, domain string,
+	lookupTXT func(context.Context, string) ([]string, error)) ([]string, error) {
+	if cache == nil {
+		return lookupTXT(ctx, domain)
+	}
+	records, err := cache.lookup("TXT", domain, func() (interface{}, error) {
+		return lookupTXT(ctx, domain)
+	})
+	txtRecords, _ := records.([]string)
+	return txtRecords, err
+}
+
+// LookupIP returns the addresses of a host through the cache, a nil DNSCache always queries
+func (cache *DNSCache) LookupIP(ctx context.Context, network string, host string,
+	lookupIP func(context.Context, string, string) ([]net.IP, error)) ([]net.IP, error) {
+	if cache == nil {
+		return lookupIP(ctx, network, host)
+	}
+	records, err := cache.lookup("IP/"+network, host, func() (interface{}, error) {
+		return lookupIP(ctx, network, host)
+	})
+	ips, _ := records.([]net.IP)
+	return ips, err
+}
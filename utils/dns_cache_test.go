@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDNSCacheLookupTXT(t *testing.T) {
+	queries := map[string]int{}
+	lookupTXT := func(ctx context.Context, domain string) ([]string, error) {
+		queries[domain]++
+		if domain == "missing.example.com" {
+			return nil, errors.New("no such host")
+		}
+		return []string{"v=spf1 -all"}, nil
+	}
+
+	cache := NewDNSCache()
+	for _, domain := range []string{"example.com", "EXAMPLE.com.", "example.com"} {
+		records, err := cache.LookupTXT(context.Background(), domain, lookupTXT)
+		assert.NoError(t, err)
+		assert.Equal(t, records, []string{"v=spf1 -all"})
+	}
+	assert.Equal(t, queries["example.com"], 1)
+
+	// failures are cached as well
+	for i := 0; i < 2; i++ {
+		_, err := cache.LookupTXT(context.Background(), "missing.example.com", lookupTXT)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, queries["missing.example.com"], 1)
+
+	// without a cache every lookup is sent
+	var noCache *DNSCache
+	noCache.LookupTXT(context.Background(), "example.com", lookupTXT)
+	noCache.LookupTXT(context.Background(), "example.com", lookupTXT)
+	assert.Equal(t, queries["example.com"], 3)
+}
+
+func TestDNSCacheLookupIPConcurrent(t *testing.T) {
+	var mutex sync.Mutex
+	queries := 0
+	lookupIP := func(ctx context.Context, network string, host string) ([]net.IP, error) {
+		mutex.Lock()
+		queries++
+		mutex.Unlock()
+		return []net.IP{net.ParseIP("93.184.216.34")}, nil
+	}
+
+	cache := NewDNSCache()
+	var wait sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			ips, err := cache.LookupIP(context.Background(), "ip", "www.example.com", lookupIP)
+			assert.NoError(t, err)
+			assert.Equal(t, ips, []net.IP{net.ParseIP("93.184.216.34")})
+		}()
+	}
+	wait.Wait()
+	assert.Equal(t, queries, 1)
+
+	// the record type is part of the key
+	cache.LookupIP(context.Background(), "ip4", "www.example.com", lookupIP)
+	assert.Equal(t, queries, 2)
+}
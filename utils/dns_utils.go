@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// DefaultDNSServers are queried in order when DNS_SERVERS is not set
+var DefaultDNSServers = []string{"8.8.8.8", "1.1.1.1"}
+
+// DNSTimeout is the time allowed for a single DNS server to answer a query
+var DNSTimeout = DefaultDNSTimeout
+
+// GetDNSServers returns the DNS servers from the comma separated DNS_SERVERS, with the port defaulting to 53
+func GetDNSServers() (servers []string) {
+	configured := DefaultDNSServers
+	if value := os.Getenv("DNS_SERVERS"); value != "" {
+		configured = strings.Split(value, ",")
+	}
+	for _, server := range configured {
+		server = strings.TrimSpace(server)
+		if server == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+		servers = append(servers, server)
+	}
+	return
+}
+
+// GetDKIMSelectors returns the DKIM selectors probed by default from the comma separated DKIM_SELECTORS
+func GetDKIMSelectors() (selectors []string) {
+	for _, selector := range strings.Split(os.Getenv("DKIM_SELECTORS"), ",") {
+		if selector = strings.TrimSpace(selector); selector != "" {
+			selectors = append(selectors, selector)
+		}
+	}
+	return
+}
+
+// newResolver returns a Resolver sending every query to the given DNS server
+func newResolver(server string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialer := net.Dialer{Timeout: DNSTimeout}
+			return dialer.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// lookupTXTFromServer queries the TXT records of a domain from a single DNS server, within the DNSTimeout and ctx
+var lookupTXTFromServer = func(ctx context.Context, server string, domain string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, DNSTimeout)
+	defer cancel()
+	return newResolver(server).LookupTXT(ctx, domain)
+}
+
+// isNotFound returns true when the DNS server answered that the name or record does not exist
+func isNotFound(err error) bool {
+	var dnsError *net.DNSError
+	return errors.As(err, &dnsError) && dnsError.IsNotFound
+}
+
+// LookupTXT returns the TXT records of a domain, falling back to the next DNS server
+// when a server fails to answer
+func LookupTXT(domain string) ([]string, error) {
+	return LookupTXTContext(context.Background(), domain)
+}
+
+// LookupTXTContext is LookupTXT with the queries bound to ctx, no server is queried once it is done
+func LookupTXTContext(ctx context.Context, domain string) (records []string, err error) {
+	servers := GetDNSServers()
+	for index, server := range servers {
+		records, err = lookupTXTFromServer(ctx, server, domain)
+		if err == nil || isNotFound(err) {
+			return
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if index < len(servers)-1 {
+			fmt.Println("DNS server "+server+" failed to resolve "+domain+", falling back to "+servers[index+1], err)
+		}
+	}
+	if err == nil {
+		err = errors.New("no DNS servers configured")
+	}
+	return
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockLookupTXTFromServer(answers map[string]error) (queried *[]string, restore func()) {
+	original := lookupTXTFromServer
+	queried = &[]string{}
+	lookupTXTFromServer = func(ctx context.Context, server string, domain string) ([]string, error) {
+		*queried = append(*queried, server)
+		if err := answers[server]; err != nil {
+			return nil, err
+		}
+		return []string{"v=spf1 -all"}, nil
+	}
+	return queried, func() { lookupTXTFromServer = original }
+}
+
+func TestGetDNSServers(t *testing.T) {
+	defer os.Unsetenv("DNS_SERVERS")
+	assert.Equal(t, GetDNSServers(), []string{"8.8.8.8:53", "1.1.1.1:53"})
+
+	os.Setenv("DNS_SERVERS", "9.9.9.9, 127.0.0.1:5353,2606:4700:4700::1111")
+	assert.Equal(t, GetDNSServers(), []string{"9.9.9.9:53", "127.0.0.1:5353", "[2606:4700:4700::1111]:53"})
+}
+
+func TestLookupTXTFallback(t *testing.T) {
+	queried, restore := mockLookupTXTFromServer(map[string]error{
+		"8.8.8.8:53": &net.DNSError{Err: "i/o timeout", IsTimeout: true},
+	})
+	defer restore()
+
+	records, err := LookupTXT("example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, records, []string{"v=spf1 -all"})
+	assert.Equal(t, *queried, []string{"8.8.8.8:53", "1.1.1.1:53"})
+}
+
+func TestLookupTXTNotFound(t *testing.T) {
+	queried, restore := mockLookupTXTFromServer(map[string]error{
+		"8.8.8.8:53": &net.DNSError{Err: "no such host", IsNotFound: true},
+	})
+	defer restore()
+
+	// a name that does not exist is an answer, so the fallback is not queried
+	_, err := LookupTXT("missing.example.com")
+	assert.Error(t, err)
+	assert.Equal(t, *queried, []string{"8.8.8.8:53"})
+
+	queried, restore = mockLookupTXTFromServer(map[string]error{
+		"8.8.8.8:53": &net.DNSError{Err: "i/o timeout", IsTimeout: true},
+		"1.1.1.1:53": &net.DNSError{Err: "i/o timeout", IsTimeout: true},
+	})
+	defer restore()
+	_, err = LookupTXT("example.com")
+	assert.Error(t, err)
+	assert.Equal(t, *queried, []string{"8.8.8.8:53", "1.1.1.1:53"})
+}
+
+func TestLookupTXTContext(t *testing.T) {
+	// the DNS servers receive the queries but never answer them
+	var servers []string
+	for i := 0; i < 2; i++ {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer conn.Close()
+		servers = append(servers, conn.LocalAddr().String())
+	}
+	os.Setenv("DNS_SERVERS", strings.Join(servers, ","))
+	defer os.Unsetenv("DNS_SERVERS")
+	original := DNSTimeout
+	DNSTimeout = time.Minute
+	defer func() { DNSTimeout = original }()
+
+	// the lookup ends with the context rather than the DNSTimeout, and no fallback is queried
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := LookupTXTContext(ctx, "example.com")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, time.Since(start) < time.Second)
+}
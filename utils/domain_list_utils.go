@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// getDomainPatterns returns the normalized patterns of a comma separated list of domains
+func getDomainPatterns(name string) (patterns []string) {
+	for _, pattern := range strings.Split(os.Getenv(name), ",") {
+		pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+		if pattern == "" {
+			continue
+		}
+		wildcard := strings.HasPrefix(pattern, "*.")
+		asciiPattern, err := NormalizeHost(strings.TrimPrefix(pattern, "*."))
+		if err != nil {
+			continue
+		}
+		if wildcard {
+			asciiPattern = "*." + asciiPattern
+		}
+		patterns = append(patterns, asciiPattern)
+	}
+	return
+}
+
+// GetDomainAllowlist returns the domains of DOMAIN_ALLOWLIST, when set only these domains can be scanned
+func GetDomainAllowlist() []string {
+	return getDomainPatterns("DOMAIN_ALLOWLIST")
+}
+
+// GetDomainDenylist returns the domains of DOMAIN_DENYLIST, which can never be scanned
+func GetDomainDenylist() []string {
+	return getDomainPatterns("DOMAIN_DENYLIST")
+}
+
+// MatchesDomain returns true when the host is one of the patterns, a pattern like *.example.com matches
+// every subdomain of example.com but not example.com itself
+func MatchesDomain(host string, patterns []string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "*.") {
+			if strings.HasSuffix(host, pattern[1:]) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// IsScannableURL returns false when the host of the URL is denied, or is missing from a configured allowlist.
+// The denylist takes precedence over the allowlist
+func IsScannableURL(rawURL string) bool {
+	host, err := GetURLHost(rawURL)
+	if err != nil {
+		return false
+	}
+	if MatchesDomain(host, GetDomainDenylist()) {
+		return false
+	}
+	allowlist := GetDomainAllowlist()
+	return len(allowlist) == 0 || MatchesDomain(host, allowlist)
+}
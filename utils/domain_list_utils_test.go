@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesDomain(t *testing.T) {
+	patterns := []string{"example.com", "*.example.org"}
+	assert.True(t, MatchesDomain("example.com", patterns))
+	assert.True(t, MatchesDomain("EXAMPLE.com.", patterns))
+	assert.False(t, MatchesDomain("www.example.com", patterns))
+	assert.True(t, MatchesDomain("www.example.org", patterns))
+	assert.True(t, MatchesDomain("a.b.example.org", patterns))
+	assert.False(t, MatchesDomain("example.org", patterns))
+	assert.False(t, MatchesDomain("badexample.org", patterns))
+}
+
+func TestIsScannableURL(t *testing.T) {
+	defer os.Unsetenv("DOMAIN_ALLOWLIST")
+	defer os.Unsetenv("DOMAIN_DENYLIST")
+	// every domain can be scanned by default
+	assert.True(t, IsScannableURL("https://www.example.com"))
+
+	os.Setenv("DOMAIN_ALLOWLIST", "example.com, *.example.com,*.bücher.example")
+	assert.True(t, IsScannableURL("https://example.com"))
+	assert.True(t, IsScannableURL("https://www.example.com:8443/path"))
+	assert.True(t, IsScannableURL("https://shop.bücher.example"))
+	assert.True(t, IsScannableURL("https://shop.xn--bcher-kva.example"))
+	assert.False(t, IsScannableURL("https://www.example.org"))
+
+	os.Setenv("DOMAIN_DENYLIST", "*.internal.example.com")
+	assert.False(t, IsScannableURL("https://admin.internal.example.com"))
+	assert.True(t, IsScannableURL("https://www.example.com"))
+
+	os.Unsetenv("DOMAIN_ALLOWLIST")
+	assert.True(t, IsScannableURL("https://www.example.org"))
+	assert.False(t, IsScannableURL("https://admin.internal.example.com"))
+}
@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// MaxRetries is the number of times a transient failure is retried
+var MaxRetries = 3
+
+// RetryBackoff is the delay before the first retry, doubled on every subsequent attempt
+var RetryBackoff = 500 * time.Millisecond
+
+// RetryBudget bounds the time spent retrying a request, no retry is sent once it would start after the budget elapsed
+// since the first attempt, so that a failing third-party API delays a scan by at most the budget and one attempt
+var RetryBudget = 5 * time.Second
+
+// ErrRedirectLoop is returned when a URL redirects back to a URL that was already visited
+var ErrRedirectLoop = errors.New("redirect loop detected")
+
+// GetMaxRedirects returns the number of redirects followed from a scanned URL from MAX_REDIRECTS, falling back
+// to DefaultMaxRedirects
+func GetMaxRedirects() int {
+	maxRedirects, err := strconv.Atoi(os.Getenv("MAX_REDIRECTS"))
+	if err != nil || maxRedirects < 0 {
+		return DefaultMaxRedirects
+	}
+	return maxRedirects
+}
+
+// HTTPTransport is the shared transport for all outbound requests, routed through the configured proxy
+// and identifying the scanner through the User-Agent
+var HTTPTransport http.RoundTripper = &userAgentTransport{base: baseTransport}
+
+// baseTransport carries the requests of HTTPTransport that are not pinned to an IP
+var baseTransport = newHTTPTransport()
+
+func newHTTPTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = ProxyFromEnvironment
+	transport.DialContext = dialDirect
+	transport.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	return transport
+}
+
+// pinnedTransport carries the requests sent with a context from WithDialOverride, its connections are not kept alive
+// so that a connection to a pinned IP is never reused by a request that is not pinned, and the other way around
+var pinnedTransport = newPinnedTransport()
+
+func newPinnedTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = DialContext
+	transport.TLSHandshakeTimeout = DefaultTLSHandshakeTimeout
+	transport.DisableKeepAlives = true
+	return transport
+}
+
+// userAgentTransport sets the configured User-Agent on requests that do not set their own
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (transport *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", GetUserAgent())
+	}
+	pinned := hasDialOverride(req.Context())
+	if ClientCertificates(req.URL.Hostname()) != nil {
+		if pinned {
+			return pinnedClientCertTransport.RoundTrip(req)
+		}
+		return clientCertTransport.RoundTrip(req)
+	}
+	if pinned {
+		return pinnedTransport.RoundTrip(req)
+	}
+	return transport.base.RoundTrip(req)
+}
+
+// GetUserAgent returns the value of USER_AGENT, falling back to a User-Agent identifying the scanner
+func GetUserAgent() string {
+	userAgent := os.Getenv("USER_AGENT")
+	if userAgent == "" {
+		return DefaultUserAgent + "/" + Version + " (+" + ProjectURL + ")"
+	}
+	return userAgent
+}
+
+// GetOpenBugBountyURL returns the value of OPENBUGBOUNTY_URL, the base URL of a mirror of the openbugbounty.org API
+func GetOpenBugBountyURL() string {
+	return os.Getenv("OPENBUGBOUNTY_URL")
+}
+
+// HTTPClient is the shared client used for outbound third-party API requests
+var HTTPClient = &http.Client{
+	Transport: HTTPTransport,
+	Timeout:   time.Duration(RequestTimeoutSeconds) * time.Second,
+}
+
+// GetWithRetry sends a GET request bound to ctx, retrying network errors and 5xx responses with exponential backoff
+// within the RetryBudget
+func GetWithRetry(ctx context.Context, client *http.Client, url string) (resp *http.Response, err error) {
+	resp, _, err = doWithRetry(ctx, client, func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	})
+	return
+}
+
+// PostWithRetry sends a POST request with body bound to ctx, retrying network errors and 5xx responses with
+// exponential backoff within the RetryBudget, and returns the number of requests sent
+func PostWithRetry(ctx context.Context, client *http.Client, url string, contentType string, body []byte) (resp *http.Response, attempts int, err error) {
+	return doWithRetry(ctx, client, func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err == nil {
+			request.Header.Set("Content-Type", contentType)
+		}
+		return request, err
+	})
+}
+
+// doWithRetry sends the requests built by newRequest until one neither fails nor gets a 5xx response, MaxRetries
+// retries were sent or the RetryBudget elapsed, and returns the response or error of the last request along with the
+// number of requests sent. The wait before a retry ends as soon as ctx is done
+func doWithRetry(ctx context.Context, client *http.Client, newRequest func() (*http.Request, error)) (resp *http.Response, attempts int, err error) {
+	deadline := time.Now().Add(RetryBudget)
+	backoff := RetryBackoff
+	for {
+		request, err := newRequest()
+		if err != nil {
+			return nil, attempts, err
+		}
+		attempts++
+		resp, err = client.Do(request)
+		last := attempts > MaxRetries || time.Now().Add(backoff).After(deadline)
+		if err != nil && last {
+			return nil, attempts, err
+		}
+		if err == nil {
+			if resp.StatusCode < http.StatusInternalServerError || last {
+				return resp, attempts, nil
+			}
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, attempts, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
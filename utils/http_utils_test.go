@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetWithRetry(t *testing.T) {
+	defer allowLoopback()()
+	RetryBackoff = time.Millisecond
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, err := GetWithRetry(context.Background(), HTTPClient, server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, requests, 2)
+}
+
+func TestGetWithRetryExhausted(t *testing.T) {
+	defer allowLoopback()()
+	RetryBackoff = time.Millisecond
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	resp, err := GetWithRetry(context.Background(), HTTPClient, server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusBadGateway)
+	assert.Equal(t, requests, MaxRetries+1)
+
+	server.Close()
+	_, err = GetWithRetry(context.Background(), HTTPClient, server.URL)
+	assert.Error(t, err)
+}
+
+func TestGetWithRetryBudget(t *testing.T) {
+	defer allowLoopback()()
+	defer func(backoff time.Duration, budget time.Duration) { RetryBackoff, RetryBudget = backoff, budget }(RetryBackoff, RetryBudget)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// no retry starts after the budget, the response of the last request is returned
+	RetryBackoff, RetryBudget = 20*time.Millisecond, 50*time.Millisecond
+	resp, err := GetWithRetry(context.Background(), HTTPClient, server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusServiceUnavailable)
+	assert.Equal(t, requests, 2)
+
+	// the wait before a retry ends with the context
+	RetryBackoff, RetryBudget = time.Hour, 2*time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, attempts, err := PostWithRetry(ctx, HTTPClient, server.URL, "application/json", []byte("{}"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, attempts, 1)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestUserAgent(t *testing.T) {
+	defer allowLoopback()()
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	resp, err := HTTPClient.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, userAgent, "SniftScanner/"+Version+" (+https://github.com/maruthi-adithya/snift-backend)")
+
+	os.Setenv("USER_AGENT", "ExampleScanner/1.0")
+	defer os.Unsetenv("USER_AGENT")
+	resp, err = HTTPClient.Get(server.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, userAgent, "ExampleScanner/1.0")
+
+	// a User-Agent set on the request itself is kept
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("User-Agent", "Custom/2.0")
+	resp, err = HTTPClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, userAgent, "Custom/2.0")
+}
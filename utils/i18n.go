@@ -0,0 +1,207 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"snift-api/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// formatVerb matches the verbs of the formatted messages, along with their explicit argument index
+var formatVerb = regexp.MustCompile(`%(\[(\d+)\])?[sdqv]`)
+
+// languageTag matches a primary language subtag, the name of a message catalog
+var languageTag = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+// formattedMessage is a message of a catalog holding verbs, matched against the messages once formatted
+type formattedMessage struct {
+	pattern     *regexp.Regexp
+	translation string
+}
+
+// MessageCatalog translates the English messages, remediations and descriptions of the constants into a language
+type MessageCatalog struct {
+	Language  string
+	messages  map[string]string
+	formatted []formattedMessage
+}
+
+var messageCatalogs sync.Map
+
+// GetI18nDirectory returns the value of I18N_DIR, the directory of the message catalogs, falling back to
+// DefaultI18nDirectory
+func GetI18nDirectory() string {
+	directory := os.Getenv("I18N_DIR")
+	if directory == "" {
+		return DefaultI18nDirectory
+	}
+	return directory
+}
+
+// GetMessageCatalog returns the message catalog of a language, loaded once from <I18N_DIR>/<language>.json. The
+// English catalog translates nothing, as the constants are in English. ok is false when the language has no catalog
+func GetMessageCatalog(language string) (catalog *MessageCatalog, ok bool) {
+	if language == DefaultLanguage {
+		return &MessageCatalog{Language: DefaultLanguage}, true
+	}
+	if !languageTag.MatchString(language) {
+		return nil, false
+	}
+	path := filepath.Join(GetI18nDirectory(), language+".json")
+	if cached, ok := messageCatalogs.Load(path); ok {
+		return cached.(*MessageCatalog), true
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var messages map[string]string
+	if err = json.Unmarshal(data, &messages); err != nil {
+		return nil, false
+	}
+	catalog = &MessageCatalog{Language: language, messages: messages}
+	for message, translation := range messages {
+		if !formatVerb.MatchString(message) {
+			continue
+		}
+		// the verbs match anything, while the text around them is matched literally
+		parts := formatVerb.Split(message, -1)
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		catalog.formatted = append(catalog.formatted, formattedMessage{
+			pattern:     regexp.MustCompile("^" + strings.Join(parts, "(.*)") + "$"),
+			translation: translation,
+		})
+	}
+	// the longest messages are the most specific, they are matched first
+	sort.Slice(catalog.formatted, func(i, j int) bool {
+		return len(catalog.formatted[i].pattern.String()) > len(catalog.formatted[j].pattern.String())
+	})
+	messageCatalogs.Store(path, catalog)
+	return catalog, true
+}
+
+// NegotiateLanguage returns the language of the first catalog found, trying the requested language and then the
+// languages of the Accept-Language header by preference, and falling back to DefaultLanguage
+func NegotiateLanguage(language string, acceptLanguage string) string {
+	for _, candidate := range append([]string{language}, parseAcceptLanguage(acceptLanguage)...) {
+		candidate = strings.ToLower(strings.TrimSpace(strings.SplitN(candidate, "-", 2)[0]))
+		if _, ok := GetMessageCatalog(candidate); ok {
+			return candidate
+		}
+	}
+	return DefaultLanguage
+}
+
+// parseAcceptLanguage returns the language ranges of an Accept-Language header by decreasing quality, the ranges of
+// quality 0 left out
+func parseAcceptLanguage(acceptLanguage string) []string {
+	type languageRange struct {
+		tag     string
+		quality float64
+	}
+	var ranges []languageRange
+	for _, member := range strings.Split(acceptLanguage, ",") {
+		parts := strings.Split(member, ";")
+		tag := strings.TrimSpace(parts[0])
+		quality := 1.0
+		for _, parameter := range parts[1:] {
+			parameter = strings.TrimSpace(parameter)
+			if strings.HasPrefix(parameter, "q=") {
+				value, err := strconv.ParseFloat(strings.TrimPrefix(parameter, "q="), 64)
+				if err == nil {
+					quality = value
+				}
+			}
+		}
+		if tag != "" && tag != "*" && quality > 0 {
+			ranges = append(ranges, languageRange{tag, quality})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+	tags := make([]string, len(ranges))
+	for i, languageRange := range ranges {
+		tags[i] = languageRange.tag
+	}
+	return tags
+}
+
+// Translate returns the translation of a message, a formatted message being translated with its arguments as they
+// were formatted. A message without translation is returned as it is
+func (catalog *MessageCatalog) Translate(message string) string {
+	if catalog == nil || message == "" {
+		return message
+	}
+	if translation, ok := catalog.messages[message]; ok {
+		return translation
+	}
+	for _, formatted := range catalog.formatted {
+		arguments := formatted.pattern.FindStringSubmatch(message)
+		if arguments == nil {
+			continue
+		}
+		next := 0
+		return formatVerb.ReplaceAllStringFunc(formatted.translation, func(verb string) string {
+			index := next
+			if match := formatVerb.FindStringSubmatch(verb); match[2] != "" {
+				index, _ = strconv.Atoi(match[2])
+				index--
+			}
+			next = index + 1
+			if index < 0 || index+1 >= len(arguments) {
+				return verb
+			}
+			return arguments[index+1]
+		})
+	}
+	return message
+}
+
+// TranslateAll returns the translations of the messages
+func (catalog *MessageCatalog) TranslateAll(messages []string) []string {
+	if len(messages) == 0 {
+		return messages
+	}
+	translations := make([]string, len(messages))
+	for i, message := range messages {
+		translations[i] = catalog.Translate(message)
+	}
+	return translations
+}
+
+// LocalizeChecks translates the messages, findings, warnings and remediations of the checks
+func (catalog *MessageCatalog) LocalizeChecks(checks []*models.CheckResult) {
+	for _, check := range checks {
+		check.Message = catalog.Translate(check.Message)
+		check.Findings = catalog.TranslateAll(check.Findings)
+		check.Warnings = catalog.TranslateAll(check.Warnings)
+		check.Remediation = catalog.Translate(check.Remediation)
+	}
+}
+
+// LocalizeScoresResponse translates the text of a scores response, the badge messages along with the text of its
+// checks and of the checks of its crawled pages
+func (catalog *MessageCatalog) LocalizeScoresResponse(response *models.ScoresResponse) {
+	if catalog == nil || catalog.Language == DefaultLanguage {
+		return
+	}
+	if response.Scores != nil {
+		for _, badge := range response.Scores.Badges {
+			badge.Message = catalog.Translate(badge.Message)
+		}
+		catalog.LocalizeChecks(response.Scores.Checks)
+	}
+	if response.Crawl != nil {
+		for _, page := range response.Crawl.Pages {
+			catalog.LocalizeChecks(page.Checks)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"snift-api/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateLanguage(t *testing.T) {
+	os.Setenv("I18N_DIR", "../resources/i18n")
+	defer os.Unsetenv("I18N_DIR")
+	for _, test := range []struct {
+		language       string
+		acceptLanguage string
+		expected       string
+	}{
+		{"", "", "en"},
+		{"fr", "", "fr"},
+		{"FR-ca", "", "fr"},
+		{"", "fr-FR,fr;q=0.9,en;q=0.8", "fr"},
+		{"", "en;q=0.9, fr", "fr"},
+		{"", "de, fr;q=0.5", "fr"},
+		{"", "fr;q=0, en", "en"},
+		// the requested language comes before Accept-Language
+		{"en", "fr", "en"},
+		// languages without a catalog fall back to the next one, then to English
+		{"de", "fr", "fr"},
+		{"de", "es, *", "en"},
+		{"../fr", "", "en"},
+	} {
+		assert.Equal(t, NegotiateLanguage(test.language, test.acceptLanguage), test.expected, test)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	os.Setenv("I18N_DIR", "../resources/i18n")
+	defer os.Unsetenv("I18N_DIR")
+	catalog, ok := GetMessageCatalog("fr")
+	assert.True(t, ok)
+	assert.Equal(t, catalog.Language, "fr")
+	assert.Equal(t, catalog.Translate(HTTPSBadgeMessage), "Connexion HTTPS chiffrée")
+	assert.Equal(t, catalog.Translate(XContentTypeRemediation), "Ajoutez l'en-tête X-Content-Type-Options: nosniff")
+
+	// formatted messages are translated along with their arguments
+	assert.Equal(t, catalog.Translate(fmt.Sprintf(SPFLookupLimitMessage, "example.com", 10)), "L'enregistrement SPF de example.com nécessite plus de 10 requêtes DNS")
+	assert.Equal(t, catalog.Translate(fmt.Sprintf(ServerVersionMessage, "nginx/1.25.3")), `L'en-tête Server révèle la version du serveur web : "nginx/1.25.3"`)
+	assert.Equal(t, catalog.Translate(fmt.Sprintf(ClearSiteDataMessage, "cookies, storage")), "Efface cookies, storage")
+
+	// messages without translation are left in English
+	assert.Equal(t, catalog.Translate("Unknown message"), "Unknown message")
+	assert.Equal(t, catalog.Translate(""), "")
+
+	_, ok = GetMessageCatalog("de")
+	assert.False(t, ok)
+	english, ok := GetMessageCatalog(DefaultLanguage)
+	assert.True(t, ok)
+	assert.Equal(t, english.Translate(HTTPSBadgeMessage), HTTPSBadgeMessage)
+}
+
+func TestLocalizeScoresResponse(t *testing.T) {
+	os.Setenv("I18N_DIR", "../resources/i18n")
+	defer os.Unsetenv("I18N_DIR")
+	catalog, _ := GetMessageCatalog("fr")
+	response := &models.ScoresResponse{
+		Scores: &models.Scores{
+			Badges: []*models.Badge{{Name: "HTTP_SECURE", Message: HTTPSBadgeMessage}},
+			Checks: []*models.CheckResult{{
+				Name:        "X-Content-Type-Options",
+				Findings:    []string{"Unknown finding"},
+				Remediation: XContentTypeRemediation,
+			}},
+		},
+		Crawl: &models.Crawl{Pages: []*models.PageScore{{Checks: []*models.CheckResult{{Message: TLSCompressionMessage}}}}},
+	}
+	catalog.LocalizeScoresResponse(response)
+	assert.Equal(t, response.Scores.Badges[0].Message, "Connexion HTTPS chiffrée")
+	assert.Equal(t, response.Scores.Checks[0].Name, "X-Content-Type-Options")
+	assert.Equal(t, response.Scores.Checks[0].Findings, []string{"Unknown finding"})
+	assert.Equal(t, response.Scores.Checks[0].Remediation, "Ajoutez l'en-tête X-Content-Type-Options: nosniff")
+	assert.Equal(t, response.Crawl.Pages[0].Checks[0].Message, "La compression TLS est activée, ce qui expose les connexions à CRIME")
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"snift-api/models"
+	"time"
+)
+
+// GetIdempotencyTTL returns the time a request can be repeated with the same idempotency key from IDEMPOTENCY_TTL,
+// falling back to DefaultIdempotencyTTL
+func GetIdempotencyTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL"))
+	if err != nil || ttl <= 0 {
+		return DefaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// GetIdempotencyKey returns the key of the Idempotency-Key header scoped to the auth token of the request, so that
+// the keys of different clients cannot collide, empty when the header is not set. ok is false for a key that is
+// longer than MaxIdempotencyKeyLength or has characters other than printable ASCII
+func GetIdempotencyKey(r *http.Request) (key string, ok bool) {
+	header := r.Header.Get(IdempotencyKeyHeader)
+	if header == "" {
+		return "", true
+	}
+	if len(header) > MaxIdempotencyKeyLength {
+		return "", false
+	}
+	for _, c := range header {
+		if c < ' ' || c > '~' {
+			return "", false
+		}
+	}
+	sum := sha256.Sum256([]byte(r.Header.Get("X-Auth-Token") + "\n" + header))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// GetRequestFingerprint returns the fingerprint of a scan request, of its normalized URL, options and callback URL,
+// which is stored along with its idempotency key so that the key cannot be reused for another request
+func GetRequestFingerprint(rawURL string, options *models.ScanOptions, callbackURL string) string {
+	normalizedURL, _, err := NormalizeURL(rawURL)
+	if err != nil {
+		normalizedURL = rawURL
+	}
+	// the keys of the headers map are sorted, so that equal options always have the same fingerprint
+	optionsJSON, _ := json.Marshal(options)
+	sum := sha256.Sum256([]byte(normalizedURL + "\n" + string(optionsJSON) + "\n" + callbackURL))
+	return hex.EncodeToString(sum[:])
+}
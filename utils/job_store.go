@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"snift-api/models"
+	"sync"
+	"time"
+)
+
+// JobStore keeps the scan jobs in memory, a job is dropped JobRetention after it completed
+type JobStore struct {
+	mutex sync.RWMutex
+	jobs  map[string]*models.Job
+}
+
+// NewJobStore returns an empty JobStore
+func NewJobStore() *JobStore {
+	return &JobStore{jobs: make(map[string]*models.Job)}
+}
+
+// Create stores a new running job scanning url, whose result is posted to callbackURL, and returns a copy of it.
+// The idempotency key of the request starting the job, and its fingerprint, may be empty
+func (store *JobStore) Create(url string, callbackURL string, idempotencyKey string, idempotencyFingerprint string) (models.Job, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return models.Job{}, err
+	}
+	job := &models.Job{
+		ID:                     hex.EncodeToString(id),
+		Status:                 models.JobRunning,
+		URL:                    url,
+		CallbackURL:            callbackURL,
+		CreatedAt:              time.Now().UTC(),
+		IdempotencyKey:         idempotencyKey,
+		IdempotencyFingerprint: idempotencyFingerprint,
+	}
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for id, expired := range store.jobs {
+		if expired.CompletedAt != nil && time.Since(*expired.CompletedAt) > JobRetention {
+			delete(store.jobs, id)
+		}
+	}
+	store.jobs[job.ID] = job
+	return *job, nil
+}
+
+// Get returns a copy of the job with the ID, ok is false when there is none
+func (store *JobStore) Get(id string) (job models.Job, ok bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	stored, ok := store.jobs[id]
+	if !ok {
+		return job, false
+	}
+	return *stored, true
+}
+
+// FindByIdempotencyKey returns a copy of the latest job started for the idempotency key since the given time, ok is
+// false when there is none
+func (store *JobStore) FindByIdempotencyKey(key string, since time.Time) (job models.Job, ok bool) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	for _, stored := range store.jobs {
+		if stored.IdempotencyKey == key && !stored.CreatedAt.Before(since) && (!ok || stored.CreatedAt.After(job.CreatedAt)) {
+			job, ok = *stored, true
+		}
+	}
+	return job, ok
+}
+
+// Update applies update to the job with the ID, and returns a copy of the updated job
+func (store *JobStore) Update(id string, update func(job *models.Job)) models.Job {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	job, ok := store.jobs[id]
+	if !ok {
+		return models.Job{}
+	}
+	update(job)
+	return *job
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Types of the errors counted by ScanErrors
+const (
+	InvalidRequestError   = "invalid_request"
+	InvalidURLError       = "invalid_url"
+	InvalidDomainError    = "invalid_domain"
+	RedirectError         = "redirect"
+	PrivateTargetError    = "private_target"
+	UnreachableError      = "unreachable"
+	TimeoutError          = "timeout"
+	ForbiddenDomainError  = "forbidden_domain"
+	UnverifiedDomainError = "unverified_domain"
+	UnauthorizedError     = "unauthorized"
+	TooManyScansError     = "too_many_scans"
+	InternalError         = "internal"
+)
+
+// ScansTotal counts the scans requested through the API
+var ScansTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "snift_scans_total",
+	Help: "Total number of scans requested.",
+})
+
+// ScanErrors counts the failed scan requests by the type of error
+var ScanErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "snift_scan_errors_total",
+	Help: "Total number of failed scan requests by error type.",
+}, []string{"type"})
+
+// ScanDuration observes the time taken to respond to a scan request
+var ScanDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "snift_scan_duration_seconds",
+	Help:    "Time taken to respond to a scan request.",
+	Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30},
+})
+
+// ScoreCalculations counts the score calculations by whether they were served from the database, scanned, or failed
+var ScoreCalculations = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "snift_score_calculations_total",
+	Help: "Total number of score calculations by result.",
+}, []string{"result"})
+
+// ScoreCalculationDuration observes the time taken to scan a URL and calculate its score
+var ScoreCalculationDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "snift_score_calculation_duration_seconds",
+	Help:    "Time taken to scan a URL and calculate its score.",
+	Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30},
+})
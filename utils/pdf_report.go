@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"io"
+	"snift-api/models"
+	"strconv"
+	"time"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Dimensions of the PDF report, in millimetres
+const (
+	reportLineHeight  = 7
+	reportLabelWidth  = 50
+	reportCheckWidth  = 110
+	reportScoreWidth  = 35
+	reportHeadingSize = 18
+	reportSectionSize = 13
+	reportTextSize    = 10
+)
+
+// WriteScoresPDF renders a scores response scanned at scannedAt as a PDF report with the grade, the per-check table
+// and the certificate summary
+func WriteScoresPDF(w io.Writer, response *models.ScoresResponse, scannedAt time.Time) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	// the core fonts are encoded in cp1252, the UTF-8 text is translated to it
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle("Snift Security Report", true)
+	pdf.SetCreator(DefaultUserAgent+"/"+Version, true)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", reportHeadingSize)
+	pdf.CellFormat(0, reportHeadingSize/2, "Snift Security Report", "", 1, "L", false, 0, "")
+	pdf.Ln(reportLineHeight)
+
+	pdf.SetFont("Helvetica", "", reportTextSize)
+	scores := response.Scores
+	if scores == nil {
+		scores = &models.Scores{}
+	}
+	writeReportRow(pdf, "URL", tr(scores.URL))
+	writeReportRow(pdf, "Scanned at", scannedAt.UTC().Format(time.RFC1123))
+	writeReportRow(pdf, "Grade", scores.Grade)
+	writeReportRow(pdf, "Score", strconv.FormatFloat(scores.Score*100, 'f', 0, 64)+" / 100")
+	if scores.Profile != "" {
+		writeReportRow(pdf, "Scoring profile", scores.Profile)
+	}
+
+	writeReportSection(pdf, "Checks")
+	pdf.SetFont("Helvetica", "B", reportTextSize)
+	pdf.SetFillColor(230, 230, 230)
+	pdf.CellFormat(reportCheckWidth, reportLineHeight, "Check", "1", 0, "L", true, 0, "")
+	pdf.CellFormat(reportScoreWidth, reportLineHeight, "Score", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(reportScoreWidth, reportLineHeight, "Maximum", "1", 1, "C", true, 0, "")
+	pdf.SetFont("Helvetica", "", reportTextSize)
+	for _, check := range scores.Checks {
+		pdf.CellFormat(reportCheckWidth, reportLineHeight, tr(check.Name), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(reportScoreWidth, reportLineHeight, strconv.Itoa(check.Score), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(reportScoreWidth, reportLineHeight, strconv.Itoa(check.MaxScore), "1", 1, "C", false, 0, "")
+	}
+
+	writeReportSection(pdf, "Certificate")
+	cert := response.Cert
+	if cert == nil {
+		pdf.CellFormat(0, reportLineHeight, "The site is not served over HTTPS", "", 1, "L", false, 0, "")
+	} else {
+		writeReportRow(pdf, "Common name", tr(cert.CommonName))
+		writeReportRow(pdf, "Issuer", tr(cert.Issuer))
+		writeReportRow(pdf, "Valid from", cert.NotBefore)
+		writeReportRow(pdf, "Valid until", cert.NotAfter)
+		writeReportRow(pdf, "Verified", strconv.FormatBool(cert.Verified))
+		if cert.ErrorType != "" {
+			writeReportRow(pdf, "Error", cert.ErrorType)
+		}
+	}
+	return pdf.Output(w)
+}
+
+func writeReportSection(pdf *gofpdf.Fpdf, title string) {
+	pdf.Ln(reportLineHeight)
+	pdf.SetFont("Helvetica", "B", reportSectionSize)
+	pdf.CellFormat(0, reportLineHeight, title, "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", reportTextSize)
+}
+
+func writeReportRow(pdf *gofpdf.Fpdf, label string, value string) {
+	pdf.SetFont("Helvetica", "B", reportTextSize)
+	pdf.CellFormat(reportLabelWidth, reportLineHeight, label, "", 0, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", reportTextSize)
+	pdf.CellFormat(0, reportLineHeight, value, "", 1, "L", false, 0, "")
+}
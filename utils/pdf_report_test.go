@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"bytes"
+	"snift-api/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteScoresPDF(t *testing.T) {
+	scores := models.GetScores("https://müller.de", 0.8, nil, []*models.CheckResult{models.GetCheckResult("Protocol", 5, 5)})
+	for _, cert := range []*models.Cert{nil, {CommonName: "müller.de", Issuer: "Test CA", ErrorType: models.CertErrorExpired}} {
+		var report bytes.Buffer
+		err := WriteScoresPDF(&report, models.BuildScoresResponse(scores, cert, nil, nil), time.Now())
+		assert.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(report.Bytes(), []byte("%PDF-")))
+		assert.True(t, bytes.HasSuffix(bytes.TrimSpace(report.Bytes()), []byte("%%EOF")))
+	}
+}
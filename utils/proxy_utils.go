@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+func getEnvAny(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// isNoProxy returns true when NO_PROXY excludes the host from being proxied
+func isNoProxy(host string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range strings.Split(getEnvAny("NO_PROXY", "no_proxy"), ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" || host == strings.TrimPrefix(entry, ".") || strings.HasSuffix(host, "."+strings.TrimPrefix(entry, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetProxyURL returns the proxy configured for the scheme through HTTP_PROXY/HTTPS_PROXY,
+// falling back to ALL_PROXY, or nil when the host should be reached directly
+func GetProxyURL(scheme string, host string) (*url.URL, error) {
+	value := getEnvAny("HTTP_PROXY", "http_proxy")
+	if scheme == "https" {
+		value = getEnvAny("HTTPS_PROXY", "https_proxy")
+	}
+	if value == "" {
+		value = getEnvAny("ALL_PROXY", "all_proxy")
+	}
+	if value == "" || isNoProxy(host) {
+		return nil, nil
+	}
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+	return url.Parse(value)
+}
+
+// isProxyHost returns true when host is the host of a proxy configured through HTTP_PROXY, HTTPS_PROXY or ALL_PROXY,
+// which the transports dial along with the scanned sites
+func isProxyHost(host string) bool {
+	for _, value := range []string{getEnvAny("HTTP_PROXY", "http_proxy"), getEnvAny("HTTPS_PROXY", "https_proxy"), getEnvAny("ALL_PROXY", "all_proxy")} {
+		if value == "" {
+			continue
+		}
+		if !strings.Contains(value, "://") {
+			value = "http://" + value
+		}
+		proxyURL, err := url.Parse(value)
+		if err == nil && strings.EqualFold(proxyURL.Hostname(), strings.Trim(host, "[]")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ProxyFromEnvironment is used as the Proxy of outbound HTTP transports
+func ProxyFromEnvironment(req *http.Request) (*url.URL, error) {
+	return GetProxyURL(req.URL.Scheme, req.URL.Hostname())
+}
+
+// directDialer opens the connections that are not proxied, within the Connect timeout
+var directDialer = &net.Dialer{Timeout: DefaultConnectTimeout}
+
+// DialContext opens a TCP connection to address for a TLS handshake, tunnelling it through
+// a SOCKS5 proxy or an HTTP CONNECT proxy when one is configured. A host pinned to an IP through WithDialOverride
+// is dialed directly at that IP
+func DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	if pinned, ok := overrideAddress(ctx, address); ok {
+		host, _, _ := net.SplitHostPort(address)
+		if err := checkTarget(host, GetDialOverride(ctx, host)); err != nil {
+			return nil, err
+		}
+		return directDialer.DialContext(ctx, network, pinned)
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	proxyURL, err := GetProxyURL("https", host)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL == nil {
+		return dialDirect(ctx, network, address)
+	}
+	switch proxyURL.Scheme {
+	case "socks5", "socks5h":
+		dialer, err := proxy.FromURL(proxyURL, directDialer)
+		if err != nil {
+			return nil, err
+		}
+		return dialer.(proxy.ContextDialer).DialContext(ctx, network, address)
+	case "http":
+		return dialConnect(ctx, proxyURL, address)
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+}
+
+// dialConnect opens a tunnel to address through an HTTP proxy with the CONNECT method
+func dialConnect(ctx context.Context, proxyURL *url.URL, address string) (net.Conn, error) {
+	conn, err := directDialer.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	connectRequest := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		connectRequest.SetBasicAuth(proxyURL.User.Username(), password)
+		connectRequest.Header.Set("Proxy-Authorization", connectRequest.Header.Get("Authorization"))
+		connectRequest.Header.Del("Authorization")
+	}
+	err = connectRequest.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, connectRequest)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, errors.New("proxy refused to connect to " + address + ": " + response.Status)
+	}
+	return conn, nil
+}
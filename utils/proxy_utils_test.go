@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setProxyEnv(env map[string]string) func() {
+	for _, name := range []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "all_proxy", "no_proxy"} {
+		os.Unsetenv(name)
+	}
+	for name, value := range env {
+		os.Setenv(name, value)
+	}
+	return func() {
+		for name := range env {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestGetProxyURL(t *testing.T) {
+	defer setProxyEnv(map[string]string{
+		"HTTP_PROXY": "proxy.example.com:3128",
+		"ALL_PROXY":  "socks5://socks.example.com:1080",
+		"NO_PROXY":   ".internal.example.com,localhost",
+	})()
+
+	proxyURL, err := GetProxyURL("http", "www.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, proxyURL.String(), "http://proxy.example.com:3128")
+
+	proxyURL, err = GetProxyURL("https", "www.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, proxyURL.String(), "socks5://socks.example.com:1080")
+
+	proxyURL, err = GetProxyURL("https", "app.internal.example.com")
+	assert.NoError(t, err)
+	assert.Nil(t, proxyURL)
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	var proxiedURL string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer proxyServer.Close()
+	defer setProxyEnv(map[string]string{"HTTP_PROXY": proxyServer.URL})()
+
+	resp, err := HTTPClient.Get("http://www.example.com/path")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, resp.StatusCode, http.StatusTeapot)
+	assert.Equal(t, proxiedURL, "http://www.example.com/path")
+}
+
+func pipe(left net.Conn, right net.Conn) {
+	go func() {
+		io.Copy(left, right)
+		left.Close()
+	}()
+	io.Copy(right, left)
+	right.Close()
+}
+
+func TestDialContextHTTPConnectProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "tunnelled")
+	}))
+	defer target.Close()
+	var connectedTo string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		connectedTo = r.Host
+		targetConn, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		pipe(conn, targetConn)
+	}))
+	defer proxyServer.Close()
+	defer setProxyEnv(map[string]string{"HTTPS_PROXY": proxyServer.URL})()
+
+	conn, err := DialContext(context.Background(), "tcp", target.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, connectedTo, target.Listener.Addr().String())
+
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: target\r\nConnection: close\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, string(body), "tunnelled")
+}
+
+// serveSOCKS5 accepts a single SOCKS5 connection without authentication and tunnels it to the requested IPv4 address
+func serveSOCKS5(listener net.Listener, requested chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	reader := bufio.NewReader(conn)
+	greeting := make([]byte, 2)
+	io.ReadFull(reader, greeting)
+	io.ReadFull(reader, make([]byte, greeting[1]))
+	conn.Write([]byte{5, 0})
+	request := make([]byte, 10)
+	io.ReadFull(reader, request)
+	address := net.JoinHostPort(net.IP(request[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(request[8:10]))))
+	requested <- address
+	targetConn, err := net.Dial("tcp", address)
+	if err != nil {
+		conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+		conn.Close()
+		return
+	}
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	pipe(conn, targetConn)
+}
+
+func TestDialContextSOCKS5Proxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "tunnelled")
+	}))
+	defer target.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	requested := make(chan string, 1)
+	go serveSOCKS5(listener, requested)
+	defer setProxyEnv(map[string]string{"ALL_PROXY": "socks5://" + listener.Addr().String()})()
+
+	conn, err := DialContext(context.Background(), "tcp", target.Listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, <-requested, target.Listener.Addr().String())
+
+	fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: target\r\nConnection: close\r\n\r\n")
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Equal(t, string(body), "tunnelled")
+}
@@ -1,11 +1,17 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"snift-api/models"
+	"strconv"
+	"strings"
+	"unicode"
 )
 
 // Writer checks and validates the response
@@ -48,13 +54,237 @@ func Unauthorized(w http.ResponseWriter, isJSON bool, err string) {
 	fmt.Fprintf(w, `{"error":%q}`, err)
 }
 
-// IsValidURL tests a string to determine if it is a url or not.
+// Forbidden returns error JSON for Forbidden Error
+func Forbidden(w http.ResponseWriter, isJSON bool, err string) {
+	if !isJSON {
+		http.Error(w, err, http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	fmt.Fprintf(w, `{"error":%q}`, err)
+}
+
+// NotFound returns error JSON for Not Found Error
+func NotFound(w http.ResponseWriter, isJSON bool, err string) {
+	if !isJSON {
+		http.Error(w, err, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	fmt.Fprintf(w, `{"error":%q}`, err)
+}
+
+// ServiceUnavailable returns error JSON for Service Unavailable Error
+func ServiceUnavailable(w http.ResponseWriter, isJSON bool, err string) {
+	if !isJSON {
+		http.Error(w, err, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintf(w, `{"error":%q}`, err)
+}
+
+// BadGateway returns error JSON for Bad Gateway Error
+func BadGateway(w http.ResponseWriter, isJSON bool, err string) {
+	if !isJSON {
+		http.Error(w, err, http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadGateway)
+	fmt.Fprintf(w, `{"error":%q}`, err)
+}
+
+// GatewayTimeout returns error JSON for Gateway Timeout Error
+func GatewayTimeout(w http.ResponseWriter, isJSON bool, err string) {
+	if !isJSON {
+		http.Error(w, err, http.StatusGatewayTimeout)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusGatewayTimeout)
+	fmt.Fprintf(w, `{"error":%q}`, err)
+}
+
+// UnprocessableEntity returns error JSON for Unprocessable Entity Error
+func UnprocessableEntity(w http.ResponseWriter, isJSON bool, err string) {
+	if !isJSON {
+		http.Error(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	fmt.Fprintf(w, `{"error":%q}`, err)
+}
+
+// RequestEntityTooLarge returns error JSON for Request Entity Too Large Error
+func RequestEntityTooLarge(w http.ResponseWriter, isJSON bool, err string) {
+	if !isJSON {
+		http.Error(w, err, http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	fmt.Fprintf(w, `{"error":%q}`, err)
+}
+
+// ErrURLTooLong is returned for a URL longer than MaxURLLength, or with a host longer than MaxHostLength
+var ErrURLTooLong = errors.New("URL is too long")
+
+// ErrURLControlCharacter is returned for a URL containing a control character, such as CR or LF
+var ErrURLControlCharacter = errors.New("URL contains a control character")
+
+// IsValidURL tests a string to determine if it is a url or not. A URL without scheme is accepted
+// when its host has a dot or is an IP address, as a single word is more likely a typo than a hostname.
+// Control characters are rejected so that the URL cannot inject headers into the requests of the scan
 func IsValidURL(rawURL string) error {
-	_, err := url.ParseRequestURI(rawURL)
-	return err
+	if len(rawURL) > MaxURLLength {
+		return ErrURLTooLong
+	}
+	if strings.IndexFunc(rawURL, unicode.IsControl) >= 0 {
+		return ErrURLControlCharacter
+	}
+	schemeURL, explicit := WithDefaultScheme(rawURL)
+	parsedURL, err := url.ParseRequestURI(schemeURL)
+	if err != nil {
+		return err
+	}
+	host := parsedURL.Hostname()
+	if len(host) > MaxHostLength {
+		return ErrURLTooLong
+	}
+	if !explicit && !strings.Contains(host, ".") && net.ParseIP(host) == nil {
+		return errors.New("invalid hostname " + rawURL)
+	}
+	return nil
+}
+
+// IsCSVRequested returns true when the Accept Header prefers text/csv over application/json
+func IsCSVRequested(r *http.Request) bool {
+	return isPreferredOverJSON(r, "text/csv")
+}
+
+// IsNDJSONRequested returns true when the Accept Header prefers application/x-ndjson over application/json
+func IsNDJSONRequested(r *http.Request) bool {
+	return isPreferredOverJSON(r, NDJSONContentType)
+}
+
+// isPreferredOverJSON returns true when the Accept Header prefers the media type over application/json
+func isPreferredOverJSON(r *http.Request, preferred string) bool {
+	bestPreferred, bestJSON := -1.0, -1.0
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(mediaRange, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if mediaType == preferred && quality > bestPreferred {
+			bestPreferred = quality
+		} else if mediaType == "application/json" && quality > bestJSON {
+			bestJSON = quality
+		}
+	}
+	return bestPreferred > 0 && bestPreferred > bestJSON
+}
+
+// GetCheckOrder returns the names of the checks reported first from the comma separated CHECK_ORDER
+func GetCheckOrder() (order []string) {
+	for _, name := range strings.Split(os.Getenv("CHECK_ORDER"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			order = append(order, name)
+		}
+	}
+	return
+}
+
+// IsSensitivePathsCheckEnabled returns true when the optional Sensitive Paths check is enabled through SENSITIVE_PATHS_CHECK
+func IsSensitivePathsCheckEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("SENSITIVE_PATHS_CHECK"))
+	return enabled
+}
+
+// GetRequiredHeaders returns the canonical names of the headers every scanned site must send from the comma separated
+// REQUIRED_HEADERS, those missing being reported as compliance violations
+func GetRequiredHeaders() (headers []string) {
+	for _, name := range strings.Split(os.Getenv("REQUIRED_HEADERS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			headers = append(headers, http.CanonicalHeaderKey(name))
+		}
+	}
+	return
+}
+
+// GetRedactedHeaders returns the canonical names of the headers whose values are redacted from the raw headers of a
+// response from the comma separated REDACTED_HEADERS, DefaultRedactedHeaders when it is not set
+func GetRedactedHeaders() (headers []string) {
+	value, ok := os.LookupEnv("REDACTED_HEADERS")
+	if !ok {
+		return DefaultRedactedHeaders
+	}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			headers = append(headers, http.CanonicalHeaderKey(name))
+		}
+	}
+	return
+}
+
+// IsRequiredHeadersEnforced returns true when a scan with compliance violations fails like a score below the minimum
+// score, through REQUIRED_HEADERS_ENFORCED
+func IsRequiredHeadersEnforced() bool {
+	enforced, _ := strconv.ParseBool(os.Getenv("REQUIRED_HEADERS_ENFORCED"))
+	return enforced
+}
+
+// GetHTTPSGradeCap returns the best grade of a site not served over HTTPS from HTTPS_GRADE_CAP, DefaultHTTPSGradeCap
+// when it is not set or not a grade, and empty when the cap is turned off with a false value such as off
+func GetHTTPSGradeCap() string {
+	value := strings.TrimSpace(os.Getenv("HTTPS_GRADE_CAP"))
+	grade := strings.ToUpper(value)
+	if grade == models.LowestGrade {
+		return grade
+	}
+	for _, threshold := range models.GradeThresholds {
+		if threshold.Grade == grade {
+			return grade
+		}
+	}
+	if enabled, err := strconv.ParseBool(value); (err == nil && !enabled) || strings.EqualFold(value, "off") {
+		return ""
+	}
+	return DefaultHTTPSGradeCap
 }
 
 // GetAccessControlAllowOrigin returns the value of Access-Control-Allow-Origin Header
 func GetAccessControlAllowOrigin() string {
 	return os.Getenv("ACCESS_CONTROL_ALLOW_ORIGIN")
 }
+
+// GetMaxCertValidityDays returns the longest total validity of a certificate from MAX_CERT_VALIDITY_DAYS, falling back
+// to DefaultMaxCertValidityDays when unset or invalid
+func GetMaxCertValidityDays() int {
+	maxValidityDays, err := strconv.Atoi(os.Getenv("MAX_CERT_VALIDITY_DAYS"))
+	if err != nil || maxValidityDays <= 0 {
+		return DefaultMaxCertValidityDays
+	}
+	return maxValidityDays
+}
+
+// GetHSTSMinMaxAge returns the max-age in seconds below which the HSTS check loses points from HSTS_MIN_MAX_AGE,
+// falling back to DefaultHSTSMinMaxAge when unset or invalid
+func GetHSTSMinMaxAge() int64 {
+	minMaxAge, err := strconv.ParseInt(os.Getenv("HSTS_MIN_MAX_AGE"), 10, 64)
+	if err != nil || minMaxAge < 0 {
+		return DefaultHSTSMinMaxAge
+	}
+	return minMaxAge
+}
@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"net/http"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,7 +14,87 @@ func TestIsValidURL(t *testing.T) {
 	assert.NoError(t, IsValidURL("http://www.example.com"))
 	assert.NoError(t, IsValidURL("http://example.com"))
 	assert.NoError(t, IsValidURL("https://example.com"))
-	assert.Error(t, IsValidURL("example.com"))
+	// a hostname without scheme is accepted, but not a single word
+	assert.NoError(t, IsValidURL("example.com"))
+	assert.NoError(t, IsValidURL("//www.example.com/path"))
+	assert.NoError(t, IsValidURL("93.184.216.34"))
 	assert.Error(t, IsValidURL("example-domain"))
 	assert.Error(t, IsValidURL("example"))
+	assert.Error(t, IsValidURL(""))
+}
+
+func TestIsValidURLLimits(t *testing.T) {
+	assert.NoError(t, IsValidURL("https://www.example.com/"+strings.Repeat("a", MaxURLLength-24)))
+	assert.ErrorIs(t, IsValidURL("https://www.example.com/"+strings.Repeat("a", MaxURLLength)), ErrURLTooLong)
+	assert.ErrorIs(t, IsValidURL("https://"+strings.Repeat("a.", 127)+"com"), ErrURLTooLong)
+
+	// CR and LF could inject headers into the requests of the scan
+	assert.ErrorIs(t, IsValidURL("https://www.example.com\r\nX-Injected: 1"), ErrURLControlCharacter)
+	assert.ErrorIs(t, IsValidURL("www.exam\nple.com"), ErrURLControlCharacter)
+	assert.ErrorIs(t, IsValidURL("https://www.example.com/\x00"), ErrURLControlCharacter)
+	assert.ErrorIs(t, IsValidURL("https://www.example.com/\u0085"), ErrURLControlCharacter)
+}
+
+func TestIsNDJSONRequested(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/scores", nil)
+	assert.False(t, IsNDJSONRequested(req))
+	req.Header.Set("Accept", "application/x-ndjson")
+	assert.True(t, IsNDJSONRequested(req))
+	assert.False(t, IsCSVRequested(req))
+	req.Header.Set("Accept", "application/x-ndjson;q=0.5, application/json")
+	assert.False(t, IsNDJSONRequested(req))
+}
+
+func TestIsCSVRequested(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/scores", nil)
+	assert.False(t, IsCSVRequested(req))
+
+	req.Header.Set("Accept", "text/csv")
+	assert.True(t, IsCSVRequested(req))
+
+	req.Header.Set("Accept", "application/json, text/csv")
+	assert.False(t, IsCSVRequested(req))
+
+	req.Header.Set("Accept", "application/json;q=0.5, text/csv")
+	assert.True(t, IsCSVRequested(req))
+
+	req.Header.Set("Accept", "*/*")
+	assert.False(t, IsCSVRequested(req))
+}
+
+func TestGetHTTPSGradeCap(t *testing.T) {
+	defer os.Unsetenv("HTTPS_GRADE_CAP")
+	assert.Equal(t, GetHTTPSGradeCap(), DefaultHTTPSGradeCap)
+	os.Setenv("HTTPS_GRADE_CAP", "c")
+	assert.Equal(t, GetHTTPSGradeCap(), "C")
+	os.Setenv("HTTPS_GRADE_CAP", "F")
+	assert.Equal(t, GetHTTPSGradeCap(), "F")
+	os.Setenv("HTTPS_GRADE_CAP", "invalid")
+	assert.Equal(t, GetHTTPSGradeCap(), DefaultHTTPSGradeCap)
+	os.Setenv("HTTPS_GRADE_CAP", "off")
+	assert.Equal(t, GetHTTPSGradeCap(), "")
+	os.Setenv("HTTPS_GRADE_CAP", "false")
+	assert.Equal(t, GetHTTPSGradeCap(), "")
+}
+
+func TestGetMaxCertValidityDays(t *testing.T) {
+	defer os.Unsetenv("MAX_CERT_VALIDITY_DAYS")
+	assert.Equal(t, GetMaxCertValidityDays(), DefaultMaxCertValidityDays)
+	os.Setenv("MAX_CERT_VALIDITY_DAYS", "200")
+	assert.Equal(t, GetMaxCertValidityDays(), 200)
+	os.Setenv("MAX_CERT_VALIDITY_DAYS", "0")
+	assert.Equal(t, GetMaxCertValidityDays(), DefaultMaxCertValidityDays)
+	os.Setenv("MAX_CERT_VALIDITY_DAYS", "long")
+	assert.Equal(t, GetMaxCertValidityDays(), DefaultMaxCertValidityDays)
+}
+
+func TestGetHSTSMinMaxAge(t *testing.T) {
+	defer os.Unsetenv("HSTS_MIN_MAX_AGE")
+	assert.Equal(t, GetHSTSMinMaxAge(), int64(DefaultHSTSMinMaxAge))
+	os.Setenv("HSTS_MIN_MAX_AGE", "15552000")
+	assert.Equal(t, GetHSTSMinMaxAge(), int64(15552000))
+	os.Setenv("HSTS_MIN_MAX_AGE", "-1")
+	assert.Equal(t, GetHSTSMinMaxAge(), int64(DefaultHSTSMinMaxAge))
+	os.Setenv("HSTS_MIN_MAX_AGE", "1y")
+	assert.Equal(t, GetHSTSMinMaxAge(), int64(DefaultHSTSMinMaxAge))
 }
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"snift-api/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	// Import for SQLite
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// ErrResultNotFound is returned when no scan result is stored under an ID
+var ErrResultNotFound = errors.New("scan result not found")
+
+// ResultStore persists scan results so the score history of a domain can be retrieved
+type ResultStore interface {
+	Save(ctx context.Context, result *models.ScanResult) error
+	Get(ctx context.Context, id uint) (*models.ScanResult, error)
+	FindByIdempotencyKey(ctx context.Context, key string, since time.Time) (*models.ScanResult, error)
+	GetHistory(ctx context.Context, domain string) ([]*models.ScanResult, error)
+}
+
+// MemoryResultStore keeps scan results in memory, they are lost when the server restarts. It keeps the latest
+// maxPerDomain results of every domain and the latest maxResults results overall, so that its memory stays bounded
+type MemoryResultStore struct {
+	mutex   sync.RWMutex
+	results map[string][]*models.ScanResult
+	byID    map[uint]*models.ScanResult
+	lastID  uint
+	// oldestID is the lowest ID that may still be stored
+	oldestID     uint
+	maxPerDomain int
+	maxResults   int
+}
+
+// NewMemoryResultStore returns an empty MemoryResultStore keeping at most MaxStoredResultsPerDomain results per domain
+// and MaxStoredResults results overall
+func NewMemoryResultStore() *MemoryResultStore {
+	return &MemoryResultStore{
+		results:      make(map[string][]*models.ScanResult),
+		byID:         make(map[uint]*models.ScanResult),
+		oldestID:     1,
+		maxPerDomain: MaxStoredResultsPerDomain,
+		maxResults:   MaxStoredResults,
+	}
+}
+
+// Save stores the scan result under its domain, and assigns it the next ID. The oldest results of the domain, then
+// the oldest results overall, are dropped once there are more than the store keeps
+func (store *MemoryResultStore) Save(ctx context.Context, result *models.ScanResult) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.lastID++
+	result.ID = store.lastID
+	store.results[result.Domain] = append(store.results[result.Domain], result)
+	store.byID[result.ID] = result
+	if len(store.results[result.Domain]) > store.maxPerDomain {
+		store.drop(store.results[result.Domain][0])
+	}
+	for len(store.byID) > store.maxResults {
+		if oldest, ok := store.byID[store.oldestID]; ok {
+			store.drop(oldest)
+		}
+		store.oldestID++
+	}
+	return nil
+}
+
+// drop removes a stored result, which is the oldest of its domain as the results of a domain are kept in ID order
+func (store *MemoryResultStore) drop(result *models.ScanResult) {
+	delete(store.byID, result.ID)
+	// the dropped result is cleared from the backing array, so that it can be garbage collected
+	store.results[result.Domain][0] = nil
+	results := store.results[result.Domain][1:]
+	if len(results) == 0 {
+		delete(store.results, result.Domain)
+		return
+	}
+	store.results[result.Domain] = results
+}
+
+// Get returns the scan result stored under the ID
+func (store *MemoryResultStore) Get(ctx context.Context, id uint) (*models.ScanResult, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	result, ok := store.byID[id]
+	if !ok {
+		return nil, ErrResultNotFound
+	}
+	return result, nil
+}
+
+// FindByIdempotencyKey returns the latest scan result run for the idempotency key since the given time
+func (store *MemoryResultStore) FindByIdempotencyKey(ctx context.Context, key string, since time.Time) (*models.ScanResult, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	for id := store.lastID; id > 0; id-- {
+		result, ok := store.byID[id]
+		if ok && result.IdempotencyKey == key && !result.ScannedAt.Before(since) {
+			return result, nil
+		}
+	}
+	return nil, ErrResultNotFound
+}
+
+// GetHistory returns the scan results of the domain, oldest first
+func (store *MemoryResultStore) GetHistory(ctx context.Context, domain string) ([]*models.ScanResult, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	history := append([]*models.ScanResult{}, store.results[domain]...)
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].ScannedAt.Before(history[j].ScannedAt)
+	})
+	return history, nil
+}
+
+// SQLiteResultStore keeps scan results in a SQLite database file
+type SQLiteResultStore struct {
+	db *gorm.DB
+}
+
+// NewSQLiteResultStore opens, and creates if needed, the SQLite database at path
+func NewSQLiteResultStore(path string) (*SQLiteResultStore, error) {
+	db, err := gorm.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	err = db.AutoMigrate(&models.ScanResult{}).Error
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteResultStore{db: db}, nil
+}
+
+// Save inserts the scan result
+func (store *SQLiteResultStore) Save(ctx context.Context, result *models.ScanResult) error {
+	return store.db.Create(result).Error
+}
+
+// Get returns the scan result stored under the ID
+func (store *SQLiteResultStore) Get(ctx context.Context, id uint) (*models.ScanResult, error) {
+	result := &models.ScanResult{}
+	err := store.db.First(result, id).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrResultNotFound
+	}
+	return result, err
+}
+
+// FindByIdempotencyKey returns the latest scan result run for the idempotency key since the given time
+func (store *SQLiteResultStore) FindByIdempotencyKey(ctx context.Context, key string, since time.Time) (*models.ScanResult, error) {
+	result := &models.ScanResult{}
+	err := store.db.Where("idempotency_key = ? AND scanned_at >= ?", key, since).Order("id desc").First(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, ErrResultNotFound
+	}
+	return result, err
+}
+
+// GetHistory returns the scan results of the domain, oldest first
+func (store *SQLiteResultStore) GetHistory(ctx context.Context, domain string) ([]*models.ScanResult, error) {
+	history := []*models.ScanResult{}
+	err := store.db.Where("domain = ?", domain).Order("scanned_at asc, id asc").Find(&history).Error
+	return history, err
+}
+
+// Close closes the underlying database
+func (store *SQLiteResultStore) Close() error {
+	return store.db.Close()
+}
+
+// GetResultStore returns a SQLiteResultStore when RESULT_STORE_PATH is set, and a MemoryResultStore otherwise
+func GetResultStore() ResultStore {
+	path := os.Getenv("RESULT_STORE_PATH")
+	if path == "" {
+		return NewMemoryResultStore()
+	}
+	store, err := NewSQLiteResultStore(path)
+	if err != nil {
+		log.Println("Unable to open the result store at "+path+", falling back to memory", err)
+		return NewMemoryResultStore()
+	}
+	return store
+}
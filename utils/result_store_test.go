@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"context"
+	"path/filepath"
+	"snift-api/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTestScanResults() []*models.ScanResult {
+	scannedAt := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []*models.ScanResult{
+		{Domain: "example.com", URL: "https://example.com", Score: 0.8, ScannedAt: scannedAt.Add(2 * time.Hour)},
+		{Domain: "example.com", URL: "https://example.com", Score: 0.5, ScannedAt: scannedAt},
+		{Domain: "example.org", URL: "https://example.org", Score: 0.9, ScannedAt: scannedAt.Add(time.Hour)},
+		{Domain: "example.com", URL: "http://example.com", Score: 0.6, ScannedAt: scannedAt.Add(time.Hour)},
+	}
+}
+
+func assertResultStore(t *testing.T, store ResultStore) {
+	for _, result := range getTestScanResults() {
+		assert.NoError(t, store.Save(context.Background(), result))
+	}
+
+	history, err := store.GetHistory(context.Background(), "example.com")
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+	assert.Equal(t, []float64{history[0].Score, history[1].Score, history[2].Score}, []float64{0.5, 0.6, 0.8})
+	assert.Equal(t, history[1].URL, "http://example.com")
+
+	history, err = store.GetHistory(context.Background(), "example.net")
+	assert.NoError(t, err)
+	assert.Empty(t, history)
+
+	history, err = store.GetHistory(context.Background(), "example.org")
+	assert.NoError(t, err)
+	assert.NotZero(t, history[0].ID)
+	result, err := store.Get(context.Background(), history[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, result.URL, "https://example.org")
+
+	_, err = store.Get(context.Background(), 1000)
+	assert.Equal(t, err, ErrResultNotFound)
+
+	keyed := &models.ScanResult{Domain: "example.com", URL: "https://example.com", Score: 0.7, ScannedAt: time.Now().UTC(), IdempotencyKey: "key"}
+	assert.NoError(t, store.Save(context.Background(), keyed))
+	result, err = store.FindByIdempotencyKey(context.Background(), "key", time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, result.Score, 0.7)
+	_, err = store.FindByIdempotencyKey(context.Background(), "key", time.Now().Add(time.Hour))
+	assert.Equal(t, err, ErrResultNotFound)
+	_, err = store.FindByIdempotencyKey(context.Background(), "other", time.Now().Add(-time.Hour))
+	assert.Equal(t, err, ErrResultNotFound)
+}
+
+func TestMemoryResultStore(t *testing.T) {
+	assertResultStore(t, NewMemoryResultStore())
+}
+
+func TestMemoryResultStoreLimits(t *testing.T) {
+	store := NewMemoryResultStore()
+	store.maxPerDomain, store.maxResults = 3, 5
+	save := func(domain string, score float64) {
+		assert.NoError(t, store.Save(context.Background(), &models.ScanResult{Domain: domain, Score: score, ScannedAt: time.Now().UTC()}))
+	}
+	scores := func(domain string) (scores []float64) {
+		history, err := store.GetHistory(context.Background(), domain)
+		assert.NoError(t, err)
+		for _, result := range history {
+			scores = append(scores, result.Score)
+		}
+		return
+	}
+
+	// only the latest results of a domain are kept
+	for score := 0.1; score < 0.55; score += 0.1 {
+		save("example.com", score)
+	}
+	assert.InDeltaSlice(t, scores("example.com"), []float64{0.3, 0.4, 0.5}, 0.001)
+	_, err := store.Get(context.Background(), 1)
+	assert.Equal(t, err, ErrResultNotFound)
+
+	// the oldest results overall are dropped, whatever their domain
+	save("example.org", 0.6)
+	save("example.org", 0.7)
+	save("example.net", 0.8)
+	assert.InDeltaSlice(t, scores("example.com"), []float64{0.4, 0.5}, 0.001)
+	save("example.net", 0.9)
+	save("example.net", 1.0)
+	assert.Empty(t, scores("example.com"))
+	assert.InDeltaSlice(t, scores("example.org"), []float64{0.6, 0.7}, 0.001)
+	assert.InDeltaSlice(t, scores("example.net"), []float64{0.8, 0.9, 1.0}, 0.001)
+	assert.Len(t, store.byID, 5)
+	assert.NotContains(t, store.results, "example.com")
+}
+
+func TestSQLiteResultStore(t *testing.T) {
+	store, err := NewSQLiteResultStore(filepath.Join(t.TempDir(), "results.db"))
+	assert.NoError(t, err)
+	defer store.Close()
+	assertResultStore(t, store)
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// ScanLimiter bounds the number of scans running at the same time
+type ScanLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// NewScanLimiter returns a ScanLimiter allowing limit concurrent scans,
+// excess scans wait in a queue for at most queueTimeout
+func NewScanLimiter(limit int, queueTimeout time.Duration) *ScanLimiter {
+	return &ScanLimiter{
+		slots:        make(chan struct{}, limit),
+		queueTimeout: queueTimeout,
+	}
+}
+
+// Acquire reserves a slot for a scan, returning false if none freed up within the queue timeout
+func (limiter *ScanLimiter) Acquire() bool {
+	timer := time.NewTimer(limiter.queueTimeout)
+	defer timer.Stop()
+	select {
+	case limiter.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Release frees the slot reserved by Acquire
+func (limiter *ScanLimiter) Release() {
+	<-limiter.slots
+}
+
+// InFlight returns the number of scans currently running
+func (limiter *ScanLimiter) InFlight() int {
+	return len(limiter.slots)
+}
+
+// Limit returns the maximum number of concurrent scans
+func (limiter *ScanLimiter) Limit() int {
+	return cap(limiter.slots)
+}
+
+// GetMaxConcurrentScans returns the value of MAX_CONCURRENT_SCANS, falling back to the default when unset or invalid
+func GetMaxConcurrentScans() int {
+	maxConcurrentScans, err := strconv.Atoi(os.Getenv("MAX_CONCURRENT_SCANS"))
+	if err != nil || maxConcurrentScans <= 0 {
+		return DefaultMaxConcurrentScans
+	}
+	return maxConcurrentScans
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanLimiter(t *testing.T) {
+	limiter := NewScanLimiter(2, 10*time.Millisecond)
+	assert.True(t, limiter.Acquire())
+	assert.True(t, limiter.Acquire())
+	assert.Equal(t, limiter.InFlight(), 2)
+	assert.False(t, limiter.Acquire())
+	limiter.Release()
+	assert.Equal(t, limiter.InFlight(), 1)
+
+	// a queued scan starts once a slot is released
+	queued := NewScanLimiter(1, time.Second)
+	assert.True(t, queued.Acquire())
+	go queued.Release()
+	assert.True(t, queued.Acquire())
+	assert.Equal(t, queued.InFlight(), 1)
+}
+
+func TestGetMaxConcurrentScans(t *testing.T) {
+	defer os.Unsetenv("MAX_CONCURRENT_SCANS")
+	os.Setenv("MAX_CONCURRENT_SCANS", "3")
+	assert.Equal(t, GetMaxConcurrentScans(), 3)
+	os.Setenv("MAX_CONCURRENT_SCANS", "invalid")
+	assert.Equal(t, GetMaxConcurrentScans(), DefaultMaxConcurrentScans)
+	os.Unsetenv("MAX_CONCURRENT_SCANS")
+	assert.Equal(t, GetMaxConcurrentScans(), DefaultMaxConcurrentScans)
+}
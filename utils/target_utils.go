@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// ErrPrivateTarget is returned when a target resolves to a private, loopback, link-local or otherwise internal address
+var ErrPrivateTarget = errors.New("target resolves to an internal address")
+
+// internalNetworks are the ranges not covered by the net.IP classification methods, that must not be scanned either
+var internalNetworks = parseCIDRs([]string{
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+})
+
+func parseCIDRs(cidrs []string) (networks []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			fmt.Println("Ignoring invalid network "+cidr, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return
+}
+
+// GetTargetAllowlist returns the networks from the comma separated TARGET_ALLOWLIST, which may be scanned even though
+// they are internal, as when testing against a local server
+func GetTargetAllowlist() []*net.IPNet {
+	value := os.Getenv("TARGET_ALLOWLIST")
+	if value == "" {
+		return nil
+	}
+	return parseCIDRs(strings.Split(value, ","))
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAllowedTarget returns false for the private, loopback, link-local (including the 169.254.169.254 metadata service),
+// multicast and unspecified addresses that are not part of the allowlist
+func IsAllowedTarget(ip net.IP) bool {
+	if containsIP(GetTargetAllowlist(), ip) {
+		return true
+	}
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	return !containsIP(internalNetworks, ip)
+}
+
+// checkTarget returns ErrPrivateTarget when ip, an address of host, is not an allowed target
+func checkTarget(host string, ip net.IP) error {
+	if !IsAllowedTarget(ip) {
+		return fmt.Errorf("%s (%s): %w", host, ip, ErrPrivateTarget)
+	}
+	return nil
+}
+
+var lookupTargetIPAddr = net.DefaultResolver.LookupIPAddr
+
+// ValidateTarget resolves the host and returns ErrPrivateTarget when any of its addresses is not an allowed target,
+// a host pinned to an IP through WithDialOverride is validated against that IP only. It rejects an internal target
+// before any request is sent, the addresses are checked again when they are dialed, so that a host resolving to
+// another address in the meantime cannot be used to reach an internal one
+func ValidateTarget(ctx context.Context, host string) error {
+	host = strings.Trim(host, "[]")
+	addresses := []net.IPAddr{{IP: GetDialOverride(ctx, host)}}
+	if addresses[0].IP == nil {
+		addresses[0].IP = net.ParseIP(host)
+	}
+	if addresses[0].IP == nil {
+		var err error
+		addresses, err = lookupTargetIPAddr(ctx, host)
+		if err != nil {
+			return err
+		}
+	}
+	for _, address := range addresses {
+		if err := checkTarget(host, address.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// allowLoopback allows the test servers listening on the loopback addresses to be dialed, and returns a function
+// restoring the default
+func allowLoopback() func() {
+	os.Setenv("TARGET_ALLOWLIST", "127.0.0.0/8,::1/128")
+	return func() { os.Unsetenv("TARGET_ALLOWLIST") }
+}
+
+func TestIsAllowedTarget(t *testing.T) {
+	for _, test := range []struct {
+		ip      string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::248", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+	} {
+		assert.Equal(t, IsAllowedTarget(net.ParseIP(test.ip)), test.allowed, test.ip)
+	}
+}
+
+func TestIsAllowedTargetAllowlist(t *testing.T) {
+	defer os.Unsetenv("TARGET_ALLOWLIST")
+	os.Setenv("TARGET_ALLOWLIST", "127.0.0.0/8, invalid ,10.1.0.0/16")
+	assert.True(t, IsAllowedTarget(net.ParseIP("127.0.0.1")))
+	assert.True(t, IsAllowedTarget(net.ParseIP("10.1.2.3")))
+	assert.False(t, IsAllowedTarget(net.ParseIP("10.2.0.1")))
+	assert.False(t, IsAllowedTarget(net.ParseIP("169.254.169.254")))
+}
+
+func TestValidateTarget(t *testing.T) {
+	original := lookupTargetIPAddr
+	defer func() { lookupTargetIPAddr = original }()
+	lookupTargetIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		switch host {
+		case "www.example.com":
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+		case "internal.example.com":
+			// a single internal address is enough to reject the host
+			return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}, {IP: net.ParseIP("10.0.0.5")}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	assert.NoError(t, ValidateTarget(context.Background(), "www.example.com"))
+	assert.True(t, errors.Is(ValidateTarget(context.Background(), "internal.example.com"), ErrPrivateTarget))
+	assert.True(t, errors.Is(ValidateTarget(context.Background(), "169.254.169.254"), ErrPrivateTarget))
+	assert.True(t, errors.Is(ValidateTarget(context.Background(), "[::1]"), ErrPrivateTarget))
+	err := ValidateTarget(context.Background(), "unknown.example.com")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrPrivateTarget))
+}
+
+func TestDialRebindingTarget(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	originalTarget, originalDial := lookupTargetIPAddr, lookupIPAddr
+	defer func() { lookupTargetIPAddr, lookupIPAddr = originalTarget, originalDial }()
+	lookupTargetIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("93.184.216.34")}}, nil
+	}
+
+	// the host is validated on its public address, then resolves to internal ones once it is dialed
+	assert.NoError(t, ValidateTarget(context.Background(), "rebind.example.com"))
+	for _, ip := range []string{"169.254.169.254", "127.0.0.1"} {
+		lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+			return []net.IPAddr{{IP: net.ParseIP(ip)}}, nil
+		}
+		_, err = dialDirect(context.Background(), "tcp", net.JoinHostPort("rebind.example.com", port))
+		assert.ErrorIs(t, err, ErrPrivateTarget, ip)
+		_, err = DialContext(context.Background(), "tcp", net.JoinHostPort("rebind.example.com", port))
+		assert.ErrorIs(t, err, ErrPrivateTarget, ip)
+	}
+	_, err = dialDirect(context.Background(), "tcp", listener.Addr().String())
+	assert.ErrorIs(t, err, ErrPrivateTarget)
+	ctx := WithDialOverride(context.Background(), "rebind.example.com", net.ParseIP("127.0.0.1"))
+	_, err = DialContext(ctx, "tcp", net.JoinHostPort("rebind.example.com", port))
+	assert.ErrorIs(t, err, ErrPrivateTarget)
+
+	// the configured proxy is dialed even though it is internal
+	os.Setenv("HTTP_PROXY", listener.Addr().String())
+	conn, err := dialDirect(context.Background(), "tcp", listener.Addr().String())
+	os.Unsetenv("HTTP_PROXY")
+	assert.NoError(t, err)
+	conn.Close()
+
+	// as are the allowed internal targets
+	defer allowLoopback()()
+	conn, err = dialDirect(context.Background(), "tcp", net.JoinHostPort("rebind.example.com", port))
+	assert.NoError(t, err)
+	conn.Close()
+}
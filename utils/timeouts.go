@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+)
+
+// Timeouts bounds every stage of the outbound connections of a scan independently, so that a slow target exhausts
+// the budget of the stage it is slow at rather than the whole scan
+type Timeouts struct {
+	// DNS bounds the resolution of a host, Connect the TCP connection to one of its addresses
+	DNS     time.Duration
+	Connect time.Duration
+	// TLSHandshake bounds the Handshake once connected, Request the whole request including reading the headers
+	TLSHandshake time.Duration
+	Request      time.Duration
+}
+
+// RequestTimeout is the total time allowed for a request to the scanned site
+var RequestTimeout = DefaultRequestTimeout
+
+// lookupIPAddr resolves the hosts dialed directly
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// GetTimeouts returns the timeouts from DNS_TIMEOUT, CONNECT_TIMEOUT, TLS_HANDSHAKE_TIMEOUT and REQUEST_TIMEOUT,
+// durations such as 5s or 1500ms, each falling back to its default when it is not set or invalid
+func GetTimeouts() Timeouts {
+	return Timeouts{
+		DNS:          getTimeout("DNS_TIMEOUT", DefaultDNSTimeout),
+		Connect:      getTimeout("CONNECT_TIMEOUT", DefaultConnectTimeout),
+		TLSHandshake: getTimeout("TLS_HANDSHAKE_TIMEOUT", DefaultTLSHandshakeTimeout),
+		Request:      getTimeout("REQUEST_TIMEOUT", DefaultRequestTimeout),
+	}
+}
+
+// getTimeout returns the positive duration of the environment variable, or the fallback
+func getTimeout(name string, fallback time.Duration) time.Duration {
+	timeout, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || timeout <= 0 {
+		return fallback
+	}
+	return timeout
+}
+
+// SetTimeouts applies the timeouts to the shared dialer, the transports of HTTPTransport and the DNS lookups
+func SetTimeouts(timeouts Timeouts) {
+	DNSTimeout = timeouts.DNS
+	directDialer.Timeout = timeouts.Connect
+	baseTransport.TLSHandshakeTimeout = timeouts.TLSHandshake
+	pinnedTransport.TLSHandshakeTimeout = timeouts.TLSHandshake
+	clientCertTransport.TLSHandshakeTimeout = timeouts.TLSHandshake
+	pinnedClientCertTransport.TLSHandshakeTimeout = timeouts.TLSHandshake
+	RequestTimeout = timeouts.Request
+}
+
+// dialDirect opens a TCP connection to address without a proxy, the host is resolved within the DNSTimeout and
+// every address is then dialed in turn within the Connect timeout of the dialer. The addresses are checked with
+// IsAllowedTarget as they are dialed, so that a host resolving to an internal address after it was validated is not
+// reached, except for the configured proxy which is often internal
+func dialDirect(ctx context.Context, network string, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	proxied := isProxyHost(host)
+	if ip := net.ParseIP(host); ip != nil {
+		if err := checkTarget(host, ip); err != nil && !proxied {
+			return nil, err
+		}
+		return directDialer.DialContext(ctx, network, address)
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, DNSTimeout)
+	addrs, err := lookupIPAddr(lookupCtx, host)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		if err := checkTarget(host, addr.IP); err != nil && !proxied {
+			return nil, err
+		}
+	}
+	for _, addr := range addrs {
+		var conn net.Conn
+		conn, err = directDialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockTimeouts applies the timeouts and returns a function restoring the current ones
+func mockTimeouts(timeouts Timeouts) func() {
+	original := Timeouts{
+		DNS:          DNSTimeout,
+		Connect:      directDialer.Timeout,
+		TLSHandshake: baseTransport.TLSHandshakeTimeout,
+		Request:      RequestTimeout,
+	}
+	SetTimeouts(timeouts)
+	return func() { SetTimeouts(original) }
+}
+
+func TestGetTimeouts(t *testing.T) {
+	os.Setenv("DNS_TIMEOUT", "500ms")
+	os.Setenv("CONNECT_TIMEOUT", "2s")
+	os.Setenv("TLS_HANDSHAKE_TIMEOUT", "ten seconds")
+	os.Setenv("REQUEST_TIMEOUT", "-1s")
+	defer func() {
+		for _, name := range []string{"DNS_TIMEOUT", "CONNECT_TIMEOUT", "TLS_HANDSHAKE_TIMEOUT", "REQUEST_TIMEOUT"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	// the invalid timeouts fall back to their defaults
+	assert.Equal(t, GetTimeouts(), Timeouts{
+		DNS:          500 * time.Millisecond,
+		Connect:      2 * time.Second,
+		TLSHandshake: DefaultTLSHandshakeTimeout,
+		Request:      DefaultRequestTimeout,
+	})
+}
+
+func TestDNSTimeout(t *testing.T) {
+	defer mockTimeouts(Timeouts{DNS: 50 * time.Millisecond, Connect: time.Minute, TLSHandshake: time.Minute, Request: time.Minute})()
+	original := lookupIPAddr
+	defer func() { lookupIPAddr = original }()
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	_, err := dialDirect(context.Background(), "tcp", "www.example.com:443")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), err)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestConnectTimeout(t *testing.T) {
+	defer allowLoopback()()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	original := lookupIPAddr
+	defer func() { lookupIPAddr = original }()
+	lookupIPAddr = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		return []net.IPAddr{{IP: net.ParseIP("127.0.0.1")}}, nil
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	// the resolved address is dialed within the connect timeout, which the lookup does not use up
+	conn, err := dialDirect(context.Background(), "tcp", net.JoinHostPort("www.example.com", port))
+	assert.NoError(t, err)
+	conn.Close()
+
+	defer mockTimeouts(Timeouts{DNS: time.Minute, Connect: time.Nanosecond, TLSHandshake: time.Minute, Request: time.Minute})()
+	_, err = dialDirect(context.Background(), "tcp", net.JoinHostPort("www.example.com", port))
+	var netError net.Error
+	assert.True(t, errors.As(err, &netError) && netError.Timeout(), err)
+}
+
+func TestTLSHandshakeTimeout(t *testing.T) {
+	defer allowLoopback()()
+	// the listener accepts the connection but never answers the Handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	defer mockTimeouts(Timeouts{DNS: time.Minute, Connect: time.Minute, TLSHandshake: 50 * time.Millisecond, Request: time.Minute})()
+
+	start := time.Now()
+	client := &http.Client{Transport: HTTPTransport}
+	_, err = client.Get("https://" + listener.Addr().String())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "TLS handshake timeout")
+	assert.True(t, time.Since(start) < time.Second)
+}
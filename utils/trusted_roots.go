@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ErrNoTrustedRoots is returned when the trusted roots path holds no PEM certificate
+var ErrNoTrustedRoots = errors.New("no PEM certificates found")
+
+// LoadTrustedRoots returns the system pool extended with the PEM certificates of the file at path, or of the files of
+// the directory at path
+func LoadTrustedRoots(path string) (*x509.CertPool, error) {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	files := []string{path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = files[:0]
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(files)
+	}
+	loaded := false
+	for _, file := range files {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		// files that are not PEM certificates, such as a README next to them, are skipped
+		if roots.AppendCertsFromPEM(pem) {
+			loaded = true
+		}
+	}
+	if !loaded {
+		return nil, ErrNoTrustedRoots
+	}
+	return roots, nil
+}
+
+// GetTrustedRoots returns the system pool extended with the roots at TRUSTED_ROOTS_PATH. It is nil when the path is not
+// set or cannot be loaded, in which case the system pool is used as is
+func GetTrustedRoots() *x509.CertPool {
+	path := os.Getenv("TRUSTED_ROOTS_PATH")
+	if path == "" {
+		return nil
+	}
+	roots, err := LoadTrustedRoots(path)
+	if err != nil {
+		log.Println("Unable to load the trusted roots at "+path+", using the system roots", err)
+		return nil
+	}
+	return roots
+}
+
+// SetTrustedRoots makes the outbound requests verify the certificates against roots, the system pool when it is nil
+func SetTrustedRoots(roots *x509.CertPool) {
+	for _, transport := range []*http.Transport{baseTransport, pinnedTransport, clientCertTransport, pinnedClientCertTransport} {
+		config := &tls.Config{}
+		if transport.TLSClientConfig != nil {
+			config = transport.TLSClientConfig.Clone()
+		}
+		config.RootCAs = roots
+		transport.TLSClientConfig = config
+	}
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadTrustedRoots(t *testing.T) {
+	defer allowLoopback()()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	dir := t.TempDir()
+	rootPath := filepath.Join(dir, "internal-ca.pem")
+	os.WriteFile(rootPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	os.WriteFile(filepath.Join(dir, "README"), []byte("internal roots"), 0600)
+
+	// the test server certificate is only trusted once loaded
+	_, err := HTTPClient.Get(server.URL)
+	assert.Error(t, err)
+	for _, path := range []string{rootPath, dir} {
+		roots, err := LoadTrustedRoots(path)
+		assert.NoError(t, err, path)
+		SetTrustedRoots(roots)
+		response, err := HTTPClient.Get(server.URL)
+		assert.NoError(t, err, path)
+		if err == nil {
+			response.Body.Close()
+		}
+		SetTrustedRoots(nil)
+	}
+
+	_, err = LoadTrustedRoots(filepath.Join(dir, "README"))
+	assert.ErrorIs(t, err, ErrNoTrustedRoots)
+	_, err = LoadTrustedRoots(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestGetTrustedRoots(t *testing.T) {
+	assert.Nil(t, GetTrustedRoots())
+	os.Setenv("TRUSTED_ROOTS_PATH", filepath.Join(t.TempDir(), "missing.pem"))
+	defer os.Unsetenv("TRUSTED_ROOTS_PATH")
+	assert.Nil(t, GetTrustedRoots())
+}
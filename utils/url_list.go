@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrLineTooLong is returned for a file of URLs with a line longer than MaxUploadLineLength
+var ErrLineTooLong = errors.New("line is too long")
+
+// ErrTooManyURLs is returned for a file of URLs listing more than MaxUploadURLs
+var ErrTooManyURLs = errors.New("too many URLs")
+
+// URLListEntry is a URL read from a file of URLs, along with its line number
+type URLListEntry struct {
+	Line int
+	URL  string
+}
+
+// ParseURLList reads the URLs of a text file, one per line, or of a CSV file, from the first column of every row.
+// Blank lines, comments starting with # and a CSV header naming the url column are skipped
+func ParseURLList(reader io.Reader) ([]URLListEntry, error) {
+	scanner := bufio.NewScanner(reader)
+	// the buffer holds one byte more than the longest line so that a longer one is told apart
+	scanner.Buffer(make([]byte, 0, 4096), MaxUploadLineLength+1)
+	var entries []URLListEntry
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		// a byte order mark may start the file
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if comma := strings.IndexByte(text, ','); comma >= 0 {
+			text = text[:comma]
+		}
+		text = strings.Trim(strings.TrimSpace(text), `"`)
+		if line == 1 && strings.EqualFold(text, "url") {
+			continue
+		}
+		if len(entries) == MaxUploadURLs {
+			return nil, ErrTooManyURLs
+		}
+		entries = append(entries, URLListEntry{Line: line, URL: text})
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return nil, ErrLineTooLong
+	}
+	return entries, scanner.Err()
+}
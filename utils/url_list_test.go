@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURLList(t *testing.T) {
+	entries, err := ParseURLList(strings.NewReader("\ufeffURL\r\n\"https://www.example.com\", \"Example\"\r\n\r\n# skipped\r\n  www.example.org  \r\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, entries, []URLListEntry{{Line: 2, URL: "https://www.example.com"}, {Line: 5, URL: "www.example.org"}})
+
+	_, err = ParseURLList(strings.NewReader("www.example.com\n" + strings.Repeat("a", MaxUploadLineLength+1)))
+	assert.Equal(t, err, ErrLineTooLong)
+
+	_, err = ParseURLList(strings.NewReader(strings.Repeat("www.example.com\n", MaxUploadURLs+1)))
+	assert.Equal(t, err, ErrTooManyURLs)
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+)
+
+// hostProfile applies the IDNA lookup mapping, but still allows the underscores some hostnames use
+var hostProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.StrictDomainName(false))
+
+// WithDefaultScheme prefixes a bare hostname, or a scheme-relative //host URL, with the DefaultScheme.
+// explicit is false when the scheme had to be added
+func WithDefaultScheme(rawURL string) (schemeURL string, explicit bool) {
+	if rawURL == "" || strings.Contains(rawURL, "://") {
+		return rawURL, true
+	}
+	if strings.HasPrefix(rawURL, "//") {
+		return DefaultScheme + ":" + rawURL, false
+	}
+	return DefaultScheme + "://" + rawURL, false
+}
+
+// NormalizeURL converts an internationalized host to its ASCII-compatible punycode form so DNS lookups and TLS handshakes
+// resolve the domain, and returns the host as originally given for display. A URL without scheme gets the DefaultScheme
+func NormalizeURL(rawURL string) (normalizedURL string, displayHost string, err error) {
+	rawURL, _ = WithDefaultScheme(rawURL)
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", err
+	}
+	displayHost = parsedURL.Hostname()
+	if displayHost == "" {
+		return parsedURL.String(), displayHost, nil
+	}
+	asciiHost, err := NormalizeHost(displayHost)
+	if err != nil {
+		return "", "", err
+	}
+	if port := parsedURL.Port(); port != "" {
+		parsedURL.Host = net.JoinHostPort(asciiHost, port)
+	} else {
+		parsedURL.Host = asciiHost
+	}
+	return parsedURL.String(), displayHost, nil
+}
+
+// GetURLHost returns the punycode form of the host of a URL, without the port
+func GetURLHost(rawURL string) (string, error) {
+	asciiURL, _, err := NormalizeURL(rawURL)
+	if err != nil {
+		return "", err
+	}
+	parsedURL, err := url.Parse(asciiURL)
+	if err != nil {
+		return "", err
+	}
+	return parsedURL.Hostname(), nil
+}
+
+// RegistrableDomain returns the domain of a host registered under its public suffix, the organizational domain that
+// holds its mail records, such as example.co.uk for mail.corp.example.co.uk. The domain is in its punycode form, and
+// an IP address, or a host that is a public suffix itself, is returned as it is
+func RegistrableDomain(host string) string {
+	host = strings.TrimSuffix(host, ".")
+	asciiHost, err := NormalizeHost(host)
+	if err != nil {
+		return host
+	}
+	if net.ParseIP(asciiHost) != nil {
+		return asciiHost
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(asciiHost)
+	if err != nil {
+		return asciiHost
+	}
+	return domain
+}
+
+// NormalizeHost returns the ASCII-compatible punycode form of a host, IP addresses are returned unchanged
+func NormalizeHost(host string) (string, error) {
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	return hostProfile.ToASCII(host)
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	normalizedURL, displayHost, err := NormalizeURL("https://müller.de:8443/path?q=1")
+	assert.NoError(t, err)
+	assert.Equal(t, normalizedURL, "https://xn--mller-kva.de:8443/path?q=1")
+	assert.Equal(t, displayHost, "müller.de")
+
+	normalizedURL, displayHost, err = NormalizeURL("https://www.example.com/")
+	assert.NoError(t, err)
+	assert.Equal(t, normalizedURL, "https://www.example.com/")
+	assert.Equal(t, displayHost, "www.example.com")
+
+	normalizedURL, _, err = NormalizeURL("https://[::1]:443")
+	assert.NoError(t, err)
+	assert.Equal(t, normalizedURL, "https://[::1]:443")
+}
+
+func TestWithDefaultScheme(t *testing.T) {
+	for _, test := range []struct {
+		rawURL    string
+		schemeURL string
+		explicit  bool
+	}{
+		{"example.com", "https://example.com", false},
+		{"example.com:8080/path", "https://example.com:8080/path", false},
+		{"//example.com/path", "https://example.com/path", false},
+		{"http://example.com", "http://example.com", true},
+		{"https://example.com", "https://example.com", true},
+	} {
+		schemeURL, explicit := WithDefaultScheme(test.rawURL)
+		assert.Equal(t, schemeURL, test.schemeURL, test.rawURL)
+		assert.Equal(t, explicit, test.explicit, test.rawURL)
+	}
+
+	normalizedURL, displayHost, err := NormalizeURL("müller.de")
+	assert.NoError(t, err)
+	assert.Equal(t, normalizedURL, "https://xn--mller-kva.de")
+	assert.Equal(t, displayHost, "müller.de")
+}
+
+func TestNormalizeURLHomograph(t *testing.T) {
+	// The first letter is the Cyrillic а, which must resolve to its own punycode domain and never to apple.com
+	normalizedURL, displayHost, err := NormalizeURL("https://аpple.com")
+	assert.NoError(t, err)
+	assert.Equal(t, normalizedURL, "https://xn--pple-43d.com")
+	assert.Equal(t, displayHost, "аpple.com")
+
+	_, _, err = NormalizeURL("https://xn--zz.com")
+	assert.Error(t, err)
+}
+
+func TestRegistrableDomain(t *testing.T) {
+	domains := map[string]string{
+		"example.com":             "example.com",
+		"www.example.com":         "example.com",
+		"WWW.Example.COM.":        "example.com",
+		"a.b.c.deep.example.com":  "example.com",
+		"example.co.uk":           "example.co.uk",
+		"www.example.co.uk":       "example.co.uk",
+		"mail.corp.example.co.uk": "example.co.uk",
+		"shop.example.com.au":     "example.com.au",
+		"user.github.io":          "user.github.io",
+		"www.müller.de":           "xn--mller-kva.de",
+		"co.uk":                   "co.uk",
+		"localhost":               "localhost",
+		"127.0.0.1":               "127.0.0.1",
+		"2001:db8::1":             "2001:db8::1",
+	}
+	for host, domain := range domains {
+		assert.Equal(t, RegistrableDomain(host), domain, host)
+	}
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IsDomainVerificationEnabled returns true when DOMAIN_VERIFICATION requires the control of a domain to be proven before it is scanned
+func IsDomainVerificationEnabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("DOMAIN_VERIFICATION"))
+	return enabled
+}
+
+// GetVerificationToken returns the verification token of a domain, derived from the SECRET so that it does not need to be stored
+func GetVerificationToken(domain string) string {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("SECRET")))
+	mac.Write([]byte(strings.TrimSuffix(strings.ToLower(domain), ".")))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// GetVerificationRecord returns the TXT record proving the control of a domain
+func GetVerificationRecord(domain string) string {
+	return VerificationRecordPrefix + GetVerificationToken(domain)
+}
+
+var lookupVerificationTXT = LookupTXT
+
+// hasVerificationRecord returns true when the domain publishes its verification record
+func hasVerificationRecord(domain string) bool {
+	records, err := lookupVerificationTXT(domain)
+	if err != nil {
+		return false
+	}
+	expected := GetVerificationRecord(domain)
+	for _, record := range records {
+		if strings.TrimSpace(record) == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDomainVerified returns true when the host, or one of its parent domains, publishes its verification record.
+// IP addresses cannot be verified
+func IsDomainVerified(host string) bool {
+	if net.ParseIP(host) != nil {
+		return false
+	}
+	labels := strings.Split(strings.TrimSuffix(strings.ToLower(host), "."), ".")
+	for i := 0; i < len(labels)-1; i++ {
+		if hasVerificationRecord(strings.Join(labels[i:], ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsVerifiedURL returns true when domain verification is disabled, or when the host of the URL is verified
+func IsVerifiedURL(rawURL string) bool {
+	if !IsDomainVerificationEnabled() {
+		return true
+	}
+	host, err := GetURLHost(rawURL)
+	if err != nil {
+		return false
+	}
+	return IsDomainVerified(host)
+}
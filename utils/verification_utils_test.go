@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockLookupVerificationTXT(records map[string][]string) (queried *[]string, restore func()) {
+	original := lookupVerificationTXT
+	queried = &[]string{}
+	lookupVerificationTXT = func(domain string) ([]string, error) {
+		*queried = append(*queried, domain)
+		if txtRecords, ok := records[domain]; ok {
+			return txtRecords, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	return queried, func() { lookupVerificationTXT = original }
+}
+
+func TestGetVerificationToken(t *testing.T) {
+	token := GetVerificationToken("example.com")
+	assert.Len(t, token, 32)
+	assert.Equal(t, GetVerificationToken("Example.com."), token)
+	assert.NotEqual(t, GetVerificationToken("example.org"), token)
+	assert.Equal(t, GetVerificationRecord("example.com"), "snift-verify="+token)
+}
+
+func TestIsDomainVerified(t *testing.T) {
+	queried, restore := mockLookupVerificationTXT(map[string][]string{
+		"example.com": {"v=spf1 -all", GetVerificationRecord("example.com")},
+		"example.org": {GetVerificationRecord("example.com")},
+	})
+	defer restore()
+
+	assert.True(t, IsDomainVerified("example.com"))
+	// the record of a parent domain verifies its subdomains
+	*queried = nil
+	assert.True(t, IsDomainVerified("www.shop.example.com"))
+	assert.Equal(t, *queried, []string{"www.shop.example.com", "shop.example.com", "example.com"})
+	// the record of another domain does not verify it
+	assert.False(t, IsDomainVerified("example.org"))
+	assert.False(t, IsDomainVerified("unknown.example.net"))
+	assert.False(t, IsDomainVerified("93.184.216.34"))
+}
+
+func TestIsVerifiedURL(t *testing.T) {
+	_, restore := mockLookupVerificationTXT(map[string][]string{
+		"example.com": {GetVerificationRecord("example.com")},
+	})
+	defer restore()
+	defer os.Unsetenv("DOMAIN_VERIFICATION")
+
+	assert.True(t, IsVerifiedURL("https://www.example.org"))
+	os.Setenv("DOMAIN_VERIFICATION", "true")
+	assert.True(t, IsVerifiedURL("https://www.example.com/path"))
+	assert.False(t, IsVerifiedURL("https://www.example.org"))
+}
@@ -0,0 +1,9 @@
+package utils
+
+// Build metadata, injected at build time with
+// go build -ldflags "-X snift-api/utils.Version=<version> -X snift-api/utils.GitCommit=<commit> -X snift-api/utils.BuildTime=<time>"
+var (
+	Version   = "dev"
+	GitCommit = "dev"
+	BuildTime = "dev"
+)
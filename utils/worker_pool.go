@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// WorkerPool runs the functions submitted to it on at most size goroutines at the same time
+type WorkerPool struct {
+	ctx   context.Context
+	slots chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewWorkerPool returns a WorkerPool running at most size functions at the same time, a size below 1 running one
+// at a time. Once ctx is done, the functions submitted are dropped
+func NewWorkerPool(ctx context.Context, size int) *WorkerPool {
+	if size < 1 {
+		size = 1
+	}
+	return &WorkerPool{ctx: ctx, slots: make(chan struct{}, size)}
+}
+
+// Submit waits for a free goroutine and runs task on it, it returns false without running task when the context of
+// the pool is done first. Submit is safe for concurrent use, but not concurrently with Wait
+func (pool *WorkerPool) Submit(task func()) bool {
+	select {
+	case pool.slots <- struct{}{}:
+	case <-pool.ctx.Done():
+		return false
+	}
+	// a free slot and a done context may be ready at the same time
+	if pool.ctx.Err() != nil {
+		<-pool.slots
+		return false
+	}
+	pool.wg.Add(1)
+	go func() {
+		defer pool.wg.Done()
+		defer func() { <-pool.slots }()
+		task()
+	}()
+	return true
+}
+
+// Wait blocks until every function submitted has returned
+func (pool *WorkerPool) Wait() {
+	pool.wg.Wait()
+}
+
+// Size returns the maximum number of functions running at the same time
+func (pool *WorkerPool) Size() int {
+	return cap(pool.slots)
+}
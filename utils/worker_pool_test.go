@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// concurrencyTracker records the highest number of its tasks running at the same time
+type concurrencyTracker struct {
+	running, maxRunning, completed int32
+}
+
+func (tracker *concurrencyTracker) task(duration time.Duration) func() {
+	return func() {
+		current := atomic.AddInt32(&tracker.running, 1)
+		for {
+			observed := atomic.LoadInt32(&tracker.maxRunning)
+			if current <= observed || atomic.CompareAndSwapInt32(&tracker.maxRunning, observed, current) {
+				break
+			}
+		}
+		time.Sleep(duration)
+		atomic.AddInt32(&tracker.running, -1)
+		atomic.AddInt32(&tracker.completed, 1)
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	pool := NewWorkerPool(context.Background(), 3)
+	assert.Equal(t, pool.Size(), 3)
+	tracker := &concurrencyTracker{}
+	for i := 0; i < 100; i++ {
+		assert.True(t, pool.Submit(tracker.task(time.Millisecond)))
+	}
+	pool.Wait()
+	assert.Equal(t, atomic.LoadInt32(&tracker.completed), int32(100))
+	assert.Equal(t, atomic.LoadInt32(&tracker.maxRunning), int32(3))
+
+	// a pool without a valid size runs one function at a time
+	assert.Equal(t, NewWorkerPool(context.Background(), 0).Size(), 1)
+}
+
+func TestWorkerPoolConcurrentSubmit(t *testing.T) {
+	pool := NewWorkerPool(context.Background(), 4)
+	tracker := &concurrencyTracker{}
+	done := make(chan bool)
+	for submitter := 0; submitter < 8; submitter++ {
+		go func() {
+			for i := 0; i < 50; i++ {
+				pool.Submit(tracker.task(100 * time.Microsecond))
+			}
+			done <- true
+		}()
+	}
+	for submitter := 0; submitter < 8; submitter++ {
+		<-done
+	}
+	pool.Wait()
+	assert.Equal(t, atomic.LoadInt32(&tracker.completed), int32(400))
+	assert.True(t, atomic.LoadInt32(&tracker.maxRunning) <= 4, atomic.LoadInt32(&tracker.maxRunning))
+}
+
+func TestWorkerPoolCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := NewWorkerPool(ctx, 1)
+	release := make(chan bool)
+	var completed int32
+	assert.True(t, pool.Submit(func() {
+		<-release
+		atomic.AddInt32(&completed, 1)
+	}))
+
+	// the pool is full, so the submission waits until the context is done and the function is dropped
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	assert.False(t, pool.Submit(func() { atomic.AddInt32(&completed, 1) }))
+	close(release)
+	pool.Wait()
+	assert.Equal(t, atomic.LoadInt32(&completed), int32(1))
+	assert.False(t, pool.Submit(func() { atomic.AddInt32(&completed, 1) }))
+}